@@ -10,11 +10,13 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
 	dsvc "github.com/RanguraGIT/sso/domain/service"
 	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/infrastructure/config"
+	handler "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
 	route "github.com/RanguraGIT/sso/infrastructure/delivery/http/route"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
 	"github.com/RanguraGIT/sso/infrastructure/persistence"
 	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
 	iservice "github.com/RanguraGIT/sso/infrastructure/service"
@@ -30,6 +32,11 @@ func main() {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
@@ -57,35 +64,104 @@ func main() {
 	sessionRepo = mysqlrepo.NewSessionRepo(db)
 	log.Printf("repo-types: user=%T client=%T token=%T authCode=%T session=%T", userRepo, clientRepo, tokenRepo, authCodeRepo, sessionRepo)
 
-	keyRotation := iservice.NewInMemoryKeyRotation(12 * time.Hour)
-	tokenService := iservice.NewJWTTokenService(keyRotation)
-	bcryptAuth := iservice.NewBcryptAuthService(userRepo, 12)
+	masterKey, err := iservice.MasterKeyFromEnv()
+	if err != nil {
+		log.Fatalf("signing keys: %v", err)
+	}
+	signingAlgs := cfg.SigningAlgs
+	if len(signingAlgs) == 0 {
+		signingAlgs = iservice.SupportedSigningAlgs
+	}
+	keyRotation, err := iservice.NewPersistentKeyRotation(ctx, mysqlrepo.NewSigningKeyRepo(db), masterKey, cfg.KeyRotationInterval, cfg.ActiveKeyOverlap, signingAlgs)
+	if err != nil {
+		log.Fatalf("signing keys: %v", err)
+	}
+	go rotateSigningKeys(ctx, keyRotation, 30*time.Second)
+	issuer := cfg.Issuer
+	if issuer == "" {
+		log.Println("[warn] issuer not configured; deriving it from the host forwarded by trusted_proxies when it is in allowed_hosts")
+	}
+	issuers, err := handler.NewIssuerResolver(issuer, cfg.TrustedProxies, cfg.AllowedHosts)
+	if err != nil {
+		log.Fatalf("issuer: %v", err)
+	}
+	revokedAccessRepo := mysqlrepo.NewRevokedAccessTokenRepo(db)
+	go purgeRevokedAccessTokens(ctx, revokedAccessRepo, time.Hour)
+	// An empty issuer is resolved per request, so handlers and usecases check iss themselves.
+	tokenService := iservice.NewJWTTokenService(keyRotation, iservice.TokenValidation{Issuer: issuer, ClockSkew: 30 * time.Second, Denylist: revokedAccessRepo})
+	bcryptAuth := iservice.NewBcryptAuthService(userRepo, clientRepo, 12)
+	clientAuth := iservice.NewClientAuthenticator(clientRepo, bcryptAuth)
+	go clientAuth.Run(ctx, time.Minute)
 	loginUC := iusecase.NewUserLogin(userRepo, bcryptAuth)
 	createSessionUC := iusecase.NewCreateSession(sessionRepo)
-	registerUC := iusecase.NewRegisterUser(userRepo, bcryptAuth.(interface{ HashPassword(string) (string, error) }))
+	hasher := bcryptAuth.(config.PasswordHasher)
+	registerUC := iusecase.NewRegisterUser(userRepo, hasher)
 
-	// Seed demo client & user (IDs deterministic for demo) - in real system use proper creation flows.
-	seedDemo(userRepo, clientRepo)
+	// Make the clients and users declared in config exist; safe to repeat on every start.
+	if err := config.Reconcile(ctx, cfg, clientRepo, userRepo, bcryptAuth, hasher); err != nil {
+		log.Fatalf("config reconcile: %v", err)
+	}
 
-	issueTokenUC := iusecase.NewIssueToken(clientRepo, tokenRepo, tokenService)
-	startAuthUC := iusecase.NewStartAuthorization(clientRepo, authCodeRepo)
-	refreshTokenUC := iusecase.NewRefreshToken(tokenRepo, clientRepo, tokenService)
+	uow := mysqlrepo.NewUnitOfWork(db)
+	consentRepo := mysqlrepo.NewConsentRepo(db)
+	var extraScopes []dsvc.ScopeDefinition
+	for _, s := range cfg.Scopes {
+		extraScopes = append(extraScopes, dsvc.ScopeDefinition{Name: s.Name, Description: s.Description, Claims: s.Claims})
+	}
+	scopeRegistry := iservice.NewScopeRegistry(extraScopes...)
+	issueTokenUC := iusecase.NewIssueToken(clientRepo, tokenRepo, tokenService, userRepo, scopeRegistry)
+	startAuthUC := iusecase.NewStartAuthorization(clientRepo, authCodeRepo, consentRepo, scopeRegistry, sessionRepo)
+	refreshTokenUC := iusecase.NewRefreshToken(tokenRepo, clientRepo, tokenService, uow)
+	clientCredsUC := iusecase.NewClientCredentials(clientRepo, tokenRepo, tokenService, scopeRegistry)
+	introspectUC := iusecase.NewIntrospect(tokenRepo, tokenService)
+	revokeUC := iusecase.NewRevokeToken(tokenRepo, revokedAccessRepo, tokenService)
+	consentsUC := iusecase.NewConsents(consentRepo, clientRepo, tokenRepo, uow)
+	backchannelLogout := iservice.NewBackchannelLogoutService(clientRepo, mysqlrepo.NewLogoutDeliveryRepo(db), tokenService)
+	go backchannelLogout.Run(ctx, 30*time.Second)
+	endSessionUC := iusecase.NewEndSession(clientRepo, sessionRepo, tokenRepo, backchannelLogout)
+	userSessionsUC := iusecase.NewUserSessions(sessionRepo, clientRepo, tokenRepo, backchannelLogout)
 	// userLoginUC := usecase.NewUserLogin(userRepo, authService) // Would be used by /authorize when password login form is added.
 
+	templates, err := ui.Load(cfg.UITemplateDir)
+	if err != nil {
+		log.Fatalf("ui templates: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
-	issuer := "http://localhost:8080" // TODO: derive from config / request / X-Forwarded headers
 	// Register routes using central wiring helper
 	uc := du.UsecaseWrapper{
-		StartAuth:    startAuthUC,
-		IssueToken:   issueTokenUC,
-		Refresh:      refreshTokenUC,
-		CreateSess:   createSessionUC,
-		UserLogin:    loginUC,
-		RegisterUser: registerUC,
+		StartAuth:         startAuthUC,
+		IssueToken:        issueTokenUC,
+		Refresh:           refreshTokenUC,
+		ClientCredentials: clientCredsUC,
+		Introspect:        introspectUC,
+		Revoke:            revokeUC,
+		Consents:          consentsUC,
+		CreateSess:        createSessionUC,
+		UserLogin:         loginUC,
+		RegisterUser:      registerUC,
+		EndSession:        endSessionUC,
+		UserSessions:      userSessionsUC,
 	}
-	svcs := dsvc.ServiceWrapper{AuthService: bcryptAuth, TokenService: tokenService, KeyRotationService: keyRotation}
-	route.RegisterRoutes(mux, uc, authCodeRepo, sessionRepo, userRepo, tokenRepo, svcs, issuer)
+	// Sliding expiry is buffered and flushed every 30s and on shutdown. After a crash, stored
+	// last-seen times trail real use by up to 90s (30s plus the 1m write granularity), which is
+	// small against the 5m minimum idle timeout.
+	sessionActivity := iservice.NewSessionActivityService(sessionRepo, cfg.SessionPolicy())
+	go sessionActivity.Run(ctx, 30*time.Second)
+	svcs := dsvc.ServiceWrapper{AuthService: bcryptAuth, TokenService: tokenService, KeyRotationService: keyRotation, ClientAuthenticator: clientAuth, ScopeRegistry: scopeRegistry, BackchannelLogout: backchannelLogout, SessionActivity: sessionActivity}
+	route.RegisterRoutes(mux, uc, authCodeRepo, sessionRepo, userRepo, clientRepo, tokenRepo, svcs, route.Options{
+		Issuer:              issuers,
+		AccessTokenTTL:      cfg.AccessTokenTTL,
+		RefreshTokenTTL:     cfg.RefreshTokenTTL,
+		AuthCodeTTL:         cfg.AuthCodeTTL,
+		AuthorizeRPM:        cfg.RateLimitAuthorizeRPM,
+		TokenRPM:            cfg.RateLimitTokenRPM,
+		SessionPolicy:       cfg.SessionPolicy(),
+		Templates:           templates,
+		DiscoveryMaxAge:     cfg.DiscoveryMaxAge,
+		LogoutRevokesTokens: cfg.LogoutRevokesTokens,
+	})
 
 	// Debug endpoint to confirm which repository implementations are active.
 	mux.HandleFunc("/debug/repos", func(w http.ResponseWriter, r *http.Request) {
@@ -109,10 +185,7 @@ func main() {
 	loggedHandler := withRequestLogging(mux)
 	// /authorize handler omitted (future step) – will issue authorization codes & handle PKCE.
 
-	addr := ":8080"
-	if v := os.Getenv("PORT"); v != "" {
-		addr = ":" + v
-	}
+	addr := cfg.HTTPAddr
 
 	srv := &http.Server{Addr: addr, Handler: loggedHandler, ReadHeaderTimeout: 10 * time.Second}
 
@@ -130,6 +203,8 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		fmt.Fprintf(os.Stderr, "graceful shutdown failed: %v\n", err)
 	}
+	// Requests have drained, so no more session activity can be queued.
+	sessionActivity.Flush(shutdownCtx)
 }
 
 // withRequestLogging adds simple structured request logs including status and latency.
@@ -152,17 +227,38 @@ func (w *statusWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
-// seedDemo inserts a single demo user & client for quick manual curl testing.
-func seedDemo(userRepo interface {
-	Create(context.Context, *entity.User) error
-	GetByEmail(context.Context, string) (*entity.User, error)
-}, clientRepo interface {
-	Create(context.Context, *entity.Client) error
-}) {
-	ctx := context.Background()
-	// Using minimal duplication to avoid pulling in external hash libs now.
-	u, _ := entity.NewUser("user@example.com", "dummy-hash")
-	_ = userRepo.Create(ctx, u)
-	c, _ := entity.NewClient("app123", "Demo App", "", []string{"http://localhost:3000/cb"}, []string{"openid", "profile"}, false, true)
-	_ = clientRepo.Create(ctx, c)
+// rotateSigningKeys reloads the shared signing key set and drives its rotation, so every replica
+// signs and verifies with the keys its peers created, promoted or retired, whether or not it
+// serves /jwks.json.
+func rotateSigningKeys(ctx context.Context, keys dsvc.KeyRotationService, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := keys.RotateIfNeeded(ctx); err != nil {
+				log.Printf("rotate signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// purgeRevokedAccessTokens drops denylist entries for access tokens that have expired anyway.
+func purgeRevokedAccessTokens(ctx context.Context, repo repository.RevokedAccessTokenRepository, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if n, err := repo.PurgeExpired(ctx, now.UTC()); err != nil {
+				log.Printf("purge revoked access tokens: %v", err)
+			} else if n > 0 {
+				log.Printf("purged %d expired revoked access tokens", n)
+			}
+		}
+	}
 }
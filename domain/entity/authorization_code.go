@@ -3,6 +3,8 @@ package entity
 import (
 	"errors"
 	"time"
+
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
 // AuthorizationCode represents a short-lived OAuth2 authorization code.
@@ -14,7 +16,11 @@ type AuthorizationCode struct {
 	RedirectURI         string
 	Scope               []string
 	CodeChallenge       string
-	CodeChallengeMethod string // "S256" or "plain" (plain discouraged)
+	CodeChallengeMethod string           // "S256" or "plain" (plain discouraged)
+	Nonce               string           // OIDC nonce from the authorization request, echoed in the ID token
+	AuthTime            time.Time        // when the user authenticated the session that approved the code
+	Claims              vo.ClaimsRequest // claims request parameter, narrowed to what the user approved
+	SessionID           string           // browser session that approved the code; tokens inherit it
 	ExpiresAt           time.Time
 	Used                bool
 	CreatedAt           time.Time
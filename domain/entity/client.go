@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
 // Client represents an OAuth2 client (confidential or public) capable of requesting tokens.
@@ -20,6 +23,29 @@ type Client struct {
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	PKCERequired bool // Force PKCE even if confidential for defense in depth
+	// Registered token_endpoint_auth_method; empty falls back to DefaultAuthMethod.
+	TokenEndpointAuthMethod  enum.ClientAuthMethod
+	JWKS                     string // Raw JWK Set JSON (public keys) for private_key_jwt assertions and ID token encryption
+	IDTokenSignedResponseAlg string // JWS alg for ID tokens; empty uses the server default
+	// JWE alg/enc for ID tokens; empty alg leaves ID tokens signed only. Keys come from JWKS.
+	IDTokenEncryptedResponseAlg string
+	IDTokenEncryptedResponseEnc string
+	UserinfoSignedResponseAlg   string   // JWS alg for userinfo responses; empty answers with plain JSON
+	PostLogoutRedirectURIs      []string // where /logout may send the user agent afterwards
+	BackchannelLogoutURI        string   // receives logout_token POSTs when a session ends; empty opts out
+	FrontchannelLogoutURI       string   // loaded in an iframe by the logout page; empty opts out
+	// FrontchannelLogoutSessionRequired adds iss and sid to the FrontchannelLogoutURI query.
+	FrontchannelLogoutSessionRequired bool
+	FirstParty                        bool // operated by us: the consent screen is skipped
+	// SessionIdleTimeout and SessionMaxLifetime make the client ask for a new login when the
+	// browser session has been idle or has lasted longer than that; zero defers to the deployment.
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+}
+
+// SessionPolicy is the client's own bound on the sessions it accepts, on top of the deployment's.
+func (c *Client) SessionPolicy() vo.SessionPolicy {
+	return vo.SessionPolicy{IdleTimeout: c.SessionIdleTimeout, MaxLifetime: c.SessionMaxLifetime}
 }
 
 func NewClient(clientID, name, hashedSecret string, redirectURIs, scopes []string, confidential bool, pkceRequired bool) (*Client, error) {
@@ -50,3 +76,15 @@ func NewClient(clientID, name, hashedSecret string, redirectURIs, scopes []strin
 }
 
 func (c *Client) Touch() { c.UpdatedAt = time.Now().UTC() }
+
+// AuthMethod returns the registered token endpoint auth method, defaulting to
+// client_secret_basic for confidential clients and none for public clients.
+func (c *Client) AuthMethod() enum.ClientAuthMethod {
+	if c.TokenEndpointAuthMethod != "" {
+		return c.TokenEndpointAuthMethod
+	}
+	if c.Confidential {
+		return enum.ClientAuthSecretBasic
+	}
+	return enum.ClientAuthNone
+}
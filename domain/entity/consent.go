@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Consent records the scopes a user has approved for a client, keyed by user and public client_id.
+type Consent struct {
+	UserID    uuid.UUID
+	ClientID  string
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func NewConsent(userID uuid.UUID, clientID string, scopes []string) (*Consent, error) {
+	if userID == uuid.Nil {
+		return nil, errors.New("userID required")
+	}
+	if clientID == "" {
+		return nil, errors.New("clientID required")
+	}
+	now := time.Now().UTC()
+	c := &Consent{UserID: userID, ClientID: clientID, CreatedAt: now, UpdatedAt: now}
+	c.Grant(scopes)
+	return c, nil
+}
+
+// Grant adds scopes to the approved set, keeping earlier grants.
+func (c *Consent) Grant(scopes []string) {
+	for _, s := range c.Missing(scopes) {
+		c.Scopes = append(c.Scopes, s)
+	}
+	c.UpdatedAt = time.Now().UTC()
+}
+
+// Missing returns the requested scopes not yet approved, in request order without duplicates.
+func (c *Consent) Missing(requested []string) []string {
+	have := make(map[string]bool, len(c.Scopes))
+	for _, s := range c.Scopes {
+		have[s] = true
+	}
+	var out []string
+	for _, s := range requested {
+		if !have[s] {
+			have[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
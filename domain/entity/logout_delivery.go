@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogoutDelivery is a queued Back-Channel Logout notification for one client. It keeps the claims
+// of the logout_token rather than the token itself, so every attempt is signed afresh with a
+// short expiry.
+type LogoutDelivery struct {
+	ID            uuid.UUID
+	ClientID      string // public client_id, the logout_token audience
+	URI           string // the client's backchannel_logout_uri
+	Issuer        string
+	Subject       string
+	SessionID     uuid.UUID // sid of the ended session
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+func NewLogoutDelivery(clientID, uri, issuer, subject string, sessionID uuid.UUID) (*LogoutDelivery, error) {
+	if clientID == "" || uri == "" {
+		return nil, errors.New("client and backchannel logout uri required")
+	}
+	if subject == "" && sessionID == uuid.Nil {
+		return nil, errors.New("subject or session required")
+	}
+	now := time.Now().UTC()
+	return &LogoutDelivery{
+		ID:            uuid.New(),
+		ClientID:      clientID,
+		URI:           uri,
+		Issuer:        issuer,
+		Subject:       subject,
+		SessionID:     sessionID,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
@@ -5,19 +5,22 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
 // Session represents a browser-based login session (for authorization endpoint UI or consent screens).
 // Can bind to refresh token chains for additional security.
 type Session struct {
-	ID        uuid.UUID
-	UserID    uuid.UUID
-	ClientIDs []uuid.UUID // Clients authorized within this session
-	CreatedAt time.Time
-	ExpiresAt time.Time
-	Revoked   bool
-	IP        string
-	UserAgent string
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	ClientIDs  []uuid.UUID // Clients authorized within this session
+	CreatedAt  time.Time
+	LastSeenAt time.Time // last time the session was used to authorize a client
+	ExpiresAt  time.Time
+	Revoked    bool
+	IP         string
+	UserAgent  string
 }
 
 func NewSession(userID uuid.UUID, ttl time.Duration, ip, ua string) (*Session, error) {
@@ -29,13 +32,25 @@ func NewSession(userID uuid.UUID, ttl time.Duration, ip, ua string) (*Session, e
 	}
 	now := time.Now().UTC()
 	return &Session{
-		ID:        uuid.New(),
-		UserID:    userID,
-		CreatedAt: now,
-		ExpiresAt: now.Add(ttl),
-		IP:        ip,
-		UserAgent: ua,
+		ID:         uuid.New(),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+		IP:         ip,
+		UserAgent:  ua,
 	}, nil
 }
 
 func (s *Session) IsExpired(now time.Time) bool { return now.After(s.ExpiresAt) }
+
+// Touch records use of the session at now and slides ExpiresAt within policy.
+func (s *Session) Touch(now time.Time, policy vo.SessionPolicy) {
+	s.LastSeenAt = now
+	if at := policy.ExpiresAt(s.CreatedAt, now); !at.IsZero() {
+		s.ExpiresAt = at
+	}
+}
+
+// AuthTime is when the user authenticated; a session is only created by a successful login.
+func (s *Session) AuthTime() time.Time { return s.CreatedAt }
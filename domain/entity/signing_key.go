@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/enum"
+)
+
+// SigningKey is a persisted JWS signing key shared by every replica.
+// The private key is only ever held encrypted (EncryptedKey); decryption is an infrastructure concern.
+type SigningKey struct {
+	KID          string
+	Algorithm    string // JWS alg, e.g. "RS256"
+	State        enum.KeyState
+	EncryptedKey []byte
+	CreatedAt    time.Time
+	ActivatedAt  time.Time // Zero until the key becomes active
+	RetiredAt    time.Time // Zero until the key is retired
+}
+
+func NewSigningKey(kid, alg string, encryptedKey []byte) (*SigningKey, error) {
+	if kid == "" {
+		return nil, errors.New("kid required")
+	}
+	if alg == "" {
+		return nil, errors.New("algorithm required")
+	}
+	if len(encryptedKey) == 0 {
+		return nil, errors.New("encrypted key required")
+	}
+	return &SigningKey{
+		KID:          kid,
+		Algorithm:    alg,
+		State:        enum.KeyStatePending,
+		EncryptedKey: encryptedKey,
+		CreatedAt:    time.Now().UTC(),
+	}, nil
+}
+
+// IsPublishable reports whether the key belongs in the public JWKS: pending and active keys always,
+// retired keys until overlap has elapsed since retirement.
+func (k *SigningKey) IsPublishable(now time.Time, overlap time.Duration) bool {
+	switch k.State {
+	case enum.KeyStatePending, enum.KeyStateActive:
+		return true
+	case enum.KeyStateRetired:
+		return now.Before(k.RetiredAt.Add(overlap))
+	default:
+		return false
+	}
+}
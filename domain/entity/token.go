@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
 // Token represents an issued OAuth2 / OIDC token pair metadata (access + refresh).
@@ -15,14 +17,18 @@ type Token struct {
 	ClientID        uuid.UUID
 	ClientPublicID  string // Public client identifier (e.g., "app123")
 	Scopes          []string
-	AccessJWT       string // Signed JWT string (short-lived)
-	RefreshTokenID  string // Opaque identifier (hash of refresh token) for rotation tracking
-	ParentRefreshID string // Points to the refresh token this was rotated from (for chain tracking)
-	Rotated         bool   // True if this refresh token has been rotated (used for reuse detection)
+	AccessJWT       string    // Signed JWT string (short-lived)
+	AccessJTI       string    // jti of AccessJWT, used to find the record for introspection
+	RefreshTokenID  string    // Opaque identifier (hash of refresh token) for rotation tracking
+	ParentRefreshID string    // Points to the refresh token this was rotated from (for chain tracking)
+	FamilyID        uuid.UUID // ID of the first token of the rotation chain; shared by every rotation
+	Rotated         bool      // True if this refresh token has been rotated (used for reuse detection)
 	ExpiresAt       time.Time
 	RefreshExpires  time.Time
 	Revoked         bool
 	CreatedAt       time.Time
+	Claims          vo.ClaimsRequest // claims request of the grant; its userinfo member applies to every rotation
+	SessionID       uuid.UUID        // browser session the grant was approved in; nil for grants without one
 }
 
 func NewToken(userID, clientID uuid.UUID, scopes []string, accessJWT, refreshTokenID string, expiresAt, refreshExpires time.Time) (*Token, error) {
@@ -35,18 +41,52 @@ func NewToken(userID, clientID uuid.UUID, scopes []string, accessJWT, refreshTok
 	if refreshTokenID == "" {
 		return nil, errors.New("refresh token id required")
 	}
+	id := uuid.New()
 	return &Token{
-		ID:             uuid.New(),
+		ID:             id,
 		UserID:         userID,
 		ClientID:       clientID,
 		Scopes:         scopes,
 		AccessJWT:      accessJWT,
 		RefreshTokenID: refreshTokenID,
+		FamilyID:       id, // a new grant starts its own family
 		ExpiresAt:      expiresAt,
 		RefreshExpires: refreshExpires,
 		CreatedAt:      time.Now().UTC(),
 	}, nil
 }
 
+// NewClientToken records an access-only token issued to a client acting on its own behalf
+// (client_credentials grant). UserID stays nil and no refresh token is tracked.
+func NewClientToken(clientID uuid.UUID, scopes []string, accessJWT string, expiresAt time.Time) (*Token, error) {
+	if clientID == uuid.Nil {
+		return nil, errors.New("clientID required")
+	}
+	if accessJWT == "" {
+		return nil, errors.New("access JWT required")
+	}
+	id := uuid.New()
+	return &Token{
+		ID:        id,
+		ClientID:  clientID,
+		Scopes:    scopes,
+		AccessJWT: accessJWT,
+		FamilyID:  id,
+		ExpiresAt: expiresAt,
+		// No refresh window: mirror the access expiry so persistence never sees a zero timestamp.
+		RefreshExpires: expiresAt,
+		CreatedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// ContinueFamily marks t as the rotation successor of parent.
+func (t *Token) ContinueFamily(parent *Token) {
+	t.ParentRefreshID = parent.RefreshTokenID
+	t.FamilyID = parent.FamilyID
+	if t.FamilyID == uuid.Nil {
+		t.FamilyID = parent.ID
+	}
+}
+
 func (t *Token) IsExpired(now time.Time) bool        { return now.After(t.ExpiresAt) }
 func (t *Token) IsRefreshExpired(now time.Time) bool { return now.After(t.RefreshExpires) }
@@ -18,6 +18,7 @@ type User struct {
 	// Security flags
 	EmailVerified bool
 	Locked        bool
+	Profile       UserProfile
 }
 
 func NewUser(email, passwordHash string) (*User, error) {
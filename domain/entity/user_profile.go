@@ -0,0 +1,59 @@
+package entity
+
+// UserProfile holds the standard OpenID Connect profile attributes of a user (OIDC Core section
+// 5.1). Empty fields are simply not released.
+type UserProfile struct {
+	Name                string
+	GivenName           string
+	FamilyName          string
+	Picture             string // URL
+	Locale              string // BCP 47 tag, e.g. "en-US"
+	Zoneinfo            string // IANA time zone, e.g. "Europe/Paris"
+	PhoneNumber         string // E.164 recommended
+	PhoneNumberVerified bool
+	Address             Address
+}
+
+// Address is the OIDC address claim (OIDC Core section 5.1.1).
+type Address struct {
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+// IsZero reports whether no address component is set.
+func (a Address) IsZero() bool { return a == Address{} }
+
+// StandardClaims returns every OIDC claim the user has a value for, keyed by claim name. Which of
+// them a client receives is decided by its granted scopes.
+func (u *User) StandardClaims() map[string]any {
+	p := u.Profile
+	out := map[string]any{
+		"email":          u.Email,
+		"email_verified": u.EmailVerified,
+		"updated_at":     u.UpdatedAt.Unix(),
+	}
+	for name, v := range map[string]string{
+		"name":         p.Name,
+		"given_name":   p.GivenName,
+		"family_name":  p.FamilyName,
+		"picture":      p.Picture,
+		"locale":       p.Locale,
+		"zoneinfo":     p.Zoneinfo,
+		"phone_number": p.PhoneNumber,
+	} {
+		if v != "" {
+			out[name] = v
+		}
+	}
+	if p.PhoneNumber != "" {
+		out["phone_number_verified"] = p.PhoneNumberVerified
+	}
+	if !p.Address.IsZero() {
+		out["address"] = p.Address
+	}
+	return out
+}
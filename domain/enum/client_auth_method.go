@@ -0,0 +1,31 @@
+package enum
+
+import "fmt"
+
+// ClientAuthMethod is a token endpoint client authentication method (OIDC Core section 9 / RFC 7591).
+type ClientAuthMethod string
+
+const (
+	ClientAuthSecretBasic   ClientAuthMethod = "client_secret_basic"
+	ClientAuthSecretPost    ClientAuthMethod = "client_secret_post"
+	ClientAuthPrivateKeyJWT ClientAuthMethod = "private_key_jwt"
+	// ClientAuthNone is used by public clients which only identify themselves via client_id.
+	ClientAuthNone ClientAuthMethod = "none"
+)
+
+func (m ClientAuthMethod) String() string { return string(m) }
+
+func ParseClientAuthMethod(v string) (ClientAuthMethod, error) {
+	switch v {
+	case string(ClientAuthSecretBasic):
+		return ClientAuthSecretBasic, nil
+	case string(ClientAuthSecretPost):
+		return ClientAuthSecretPost, nil
+	case string(ClientAuthPrivateKeyJWT):
+		return ClientAuthPrivateKeyJWT, nil
+	case string(ClientAuthNone):
+		return ClientAuthNone, nil
+	default:
+		return "", fmt.Errorf("unsupported token endpoint auth method: %s", v)
+	}
+}
@@ -0,0 +1,16 @@
+package enum
+
+import "testing"
+
+func TestClientAuthMethodParse(t *testing.T) {
+	m, err := ParseClientAuthMethod("private_key_jwt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m != ClientAuthPrivateKeyJWT {
+		t.Fatalf("expected private_key_jwt got %s", m)
+	}
+	if _, err := ParseClientAuthMethod("client_secret_jwt"); err == nil {
+		t.Fatal("expected error for unsupported method")
+	}
+}
@@ -0,0 +1,35 @@
+package enum
+
+import "fmt"
+
+// KeyState tracks a signing key through its lifecycle.
+//
+//	pending: generated and published in JWKS ahead of use
+//	active:  the key new tokens are signed with
+//	retired: no longer signs, still published until tokens signed with it expire
+//	revoked: withdrawn immediately (compromise); never published or trusted
+type KeyState string
+
+const (
+	KeyStatePending KeyState = "pending"
+	KeyStateActive  KeyState = "active"
+	KeyStateRetired KeyState = "retired"
+	KeyStateRevoked KeyState = "revoked"
+)
+
+func (s KeyState) String() string { return string(s) }
+
+func ParseKeyState(v string) (KeyState, error) {
+	switch v {
+	case string(KeyStatePending):
+		return KeyStatePending, nil
+	case string(KeyStateActive):
+		return KeyStateActive, nil
+	case string(KeyStateRetired):
+		return KeyStateRetired, nil
+	case string(KeyStateRevoked):
+		return KeyStateRevoked, nil
+	default:
+		return "", fmt.Errorf("unknown key state: %s", v)
+	}
+}
@@ -0,0 +1,18 @@
+package enum
+
+import "testing"
+
+func TestKeyStateParse(t *testing.T) {
+	for _, v := range []string{"pending", "active", "retired", "revoked"} {
+		s, err := ParseKeyState(v)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", v, err)
+		}
+		if s.String() != v {
+			t.Fatalf("expected %s got %s", v, s)
+		}
+	}
+	if _, err := ParseKeyState("expired"); err == nil {
+		t.Fatal("expected error for unknown state")
+	}
+}
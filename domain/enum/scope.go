@@ -5,6 +5,12 @@ import (
 	"strings"
 )
 
+// Scopes with protocol meaning beyond authorization.
+const (
+	ScopeOpenID        = "openid"         // requests an ID token (OIDC Core section 3.1.2.1)
+	ScopeOfflineAccess = "offline_access" // requests a refresh token usable without the user present
+)
+
 // ScopeSet provides normalized handling of OAuth2 scope strings (space-delimited per RFC 6749).
 type ScopeSet struct{ items map[string]struct{} }
 
@@ -19,6 +25,17 @@ func ParseScopeString(s string) ScopeSet {
 	return ss
 }
 
+// NewScopeSet builds a set from individual scope values, ignoring blanks.
+func NewScopeSet(scopes ...string) ScopeSet {
+	ss := ScopeSet{items: map[string]struct{}{}}
+	for _, s := range scopes {
+		if s != "" {
+			ss.items[s] = struct{}{}
+		}
+	}
+	return ss
+}
+
 func (s ScopeSet) Has(scope string) bool {
 	_, ok := s.items[scope]
 	return ok
@@ -36,15 +53,48 @@ func (s ScopeSet) Merge(other ScopeSet) ScopeSet {
 	return out
 }
 
-// String returns a deterministic, alphabetical representation for stable comparisons & caching keys.
-func (s ScopeSet) String() string {
-	if len(s.items) == 0 {
-		return ""
+// Intersect returns the scopes present in both sets.
+func (s ScopeSet) Intersect(other ScopeSet) ScopeSet {
+	out := ScopeSet{items: map[string]struct{}{}}
+	for k := range s.items {
+		if other.Has(k) {
+			out.items[k] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Without returns the scopes of s that are not in other.
+func (s ScopeSet) Without(other ScopeSet) ScopeSet {
+	out := ScopeSet{items: map[string]struct{}{}}
+	for k := range s.items {
+		if !other.Has(k) {
+			out.items[k] = struct{}{}
+		}
 	}
+	return out
+}
+
+// Len returns the number of scopes in the set.
+func (s ScopeSet) Len() int { return len(s.items) }
+
+// Slice returns the scopes in alphabetical order.
+func (s ScopeSet) Slice() []string {
 	arr := make([]string, 0, len(s.items))
 	for k := range s.items {
 		arr = append(arr, k)
 	}
 	sort.Strings(arr)
-	return strings.Join(arr, " ")
+	return arr
+}
+
+// IsEmpty reports whether the set holds no scopes.
+func (s ScopeSet) IsEmpty() bool { return len(s.items) == 0 }
+
+// String returns a deterministic, alphabetical representation for stable comparisons & caching keys.
+func (s ScopeSet) String() string {
+	if len(s.items) == 0 {
+		return ""
+	}
+	return strings.Join(s.Slice(), " ")
 }
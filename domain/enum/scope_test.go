@@ -14,3 +14,31 @@ func TestScopeSet(t *testing.T) {
 		t.Fatalf("normalized ordering unexpected: %s", set.String())
 	}
 }
+
+func TestScopeSetSlice(t *testing.T) {
+	set := ParseScopeString("write read")
+	got := set.Slice()
+	if len(got) != 2 || got[0] != "read" || got[1] != "write" {
+		t.Fatalf("unexpected slice: %v", got)
+	}
+	if set.IsEmpty() {
+		t.Fatal("set should not be empty")
+	}
+	if !ParseScopeString("  ").IsEmpty() {
+		t.Fatal("blank scope string should produce empty set")
+	}
+}
+
+func TestScopeSetIntersectWithout(t *testing.T) {
+	requested := ParseScopeString("openid profile admin")
+	registered := NewScopeSet("openid", "profile", "email", "")
+	if got := requested.Intersect(registered).String(); got != "openid profile" {
+		t.Fatalf("intersect: %q", got)
+	}
+	if got := requested.Without(registered).String(); got != "admin" {
+		t.Fatalf("without: %q", got)
+	}
+	if registered.Len() != 3 || !requested.Intersect(NewScopeSet()).IsEmpty() {
+		t.Fatal("unexpected set sizes")
+	}
+}
@@ -11,5 +11,7 @@ import (
 type AuthorizationCodeRepository interface {
 	Create(ctx context.Context, c *entity.AuthorizationCode) error
 	Get(ctx context.Context, code string) (*entity.AuthorizationCode, error)
-	MarkUsed(ctx context.Context, code string) error
+	// MarkUsed is a compare-and-set marking an unused code as used; it reports whether this call
+	// won, so concurrent redemptions of one code yield a single winner.
+	MarkUsed(ctx context.Context, code string) (bool, error)
 }
@@ -3,12 +3,16 @@ package repository
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"github.com/RanguraGIT/sso/domain/entity"
 )
 
 // ClientRepository handles OAuth client persistence.
 type ClientRepository interface {
 	GetByClientID(ctx context.Context, clientID string) (*entity.Client, error)
+	// GetByID finds a client by its internal id (entity.Client.ID), or returns nil.
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Client, error)
 	Create(ctx context.Context, c *entity.Client) error
 	Update(ctx context.Context, c *entity.Client) error
 }
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// ConsentRepository stores the scopes each user approved per client.
+type ConsentRepository interface {
+	// Get returns nil when the user has not consented to the client.
+	Get(ctx context.Context, userID uuid.UUID, clientID string) (*entity.Consent, error)
+	// Save inserts or replaces the consent for (UserID, ClientID).
+	Save(ctx context.Context, c *entity.Consent) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Consent, error)
+	// Delete reports whether a consent existed.
+	Delete(ctx context.Context, userID uuid.UUID, clientID string) (bool, error)
+}
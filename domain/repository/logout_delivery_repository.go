@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// LogoutDeliveryRepository is the persisted Back-Channel Logout queue, shared by every replica.
+type LogoutDeliveryRepository interface {
+	Enqueue(ctx context.Context, d *entity.LogoutDelivery) error
+	// ClaimDue returns up to limit deliveries due at now, oldest first, and pushes their next attempt
+	// back by lease so no other replica picks them up while they are being sent.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*entity.LogoutDelivery, error)
+	// Reschedule records a failed attempt and when to try again.
+	Reschedule(ctx context.Context, id uuid.UUID, attempts int, next time.Time, lastError string) error
+	// Complete removes a delivery that succeeded or was given up.
+	Complete(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// RevokedAccessTokenRepository is the access token denylist: jti values revoked before their
+// expiry. Entries are only needed until the token would have expired anyway.
+type RevokedAccessTokenRepository interface {
+	Add(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsRevoked reports whether jti is denylisted and not yet expired.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// PurgeExpired deletes entries whose tokens expired before now.
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
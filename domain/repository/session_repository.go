@@ -14,4 +14,14 @@ type SessionRepository interface {
 	Get(ctx context.Context, id uuid.UUID) (*entity.Session, error)
 	AddClient(ctx context.Context, id uuid.UUID, clientID uuid.UUID) error
 	Revoke(ctx context.Context, id uuid.UUID) error
+	// ListByUser returns the user's live sessions (neither revoked nor expired), most recently
+	// used first.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error)
+	// RevokeAllForUser revokes every live session of the user and returns the sessions it ended.
+	RevokeAllForUser(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error)
+	// SaveActivity writes the LastSeenAt and ExpiresAt of each session in one batch. A session is
+	// left alone when revoked or when it already records later use.
+	SaveActivity(ctx context.Context, sessions []*entity.Session) error
+	// RevokeAllExcept is RevokeAllForUser sparing the session keep.
+	RevokeAllExcept(ctx context.Context, userID uuid.UUID, keep uuid.UUID) ([]*entity.Session, error)
 }
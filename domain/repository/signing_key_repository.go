@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// SigningKeyRepository persists signing keys so all replicas share one key set.
+type SigningKeyRepository interface {
+	Create(ctx context.Context, k *entity.SigningKey) error
+	// List returns pending and active keys plus keys retired after retiredAfter. Revoked keys are never returned.
+	List(ctx context.Context, retiredAfter time.Time) ([]*entity.SigningKey, error)
+	// Promote atomically retires the active key of kid's algorithm and activates the pending key kid.
+	// It does nothing and returns false if an active key of that algorithm was activated after staleBefore
+	// (another replica already rotated) or kid is no longer pending.
+	Promote(ctx context.Context, kid string, staleBefore time.Time) (bool, error)
+	Revoke(ctx context.Context, kid string) error
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"github.com/RanguraGIT/sso/domain/entity"
 )
 
@@ -10,9 +12,20 @@ import (
 type TokenRepository interface {
 	Store(ctx context.Context, t *entity.Token) error
 	GetByRefreshID(ctx context.Context, refreshTokenID string) (*entity.Token, error)
+	GetByAccessJTI(ctx context.Context, jti string) (*entity.Token, error)
 	RevokeByRefreshID(ctx context.Context, refreshTokenID string) error
-	// Revoke this and descendant rotated tokens in a chain.
+	// ListFamily returns the rotation lineage of a family, root first, following parent_refresh_id.
+	ListFamily(ctx context.Context, familyID uuid.UUID) ([]*entity.Token, error)
+	// RevokeChain revokes the whole family of refreshTokenID in one transaction, denylisting the
+	// family's unexpired access tokens as well. Unknown tokens are a no-op.
 	RevokeChain(ctx context.Context, refreshTokenID string) error
-	// MarkRotated marks a refresh token as having been rotated (i.e., a child issued). Enables reuse detection.
-	MarkRotated(ctx context.Context, refreshTokenID string) error
+	// MarkRotated is a compare-and-set marking a live refresh token as rotated (a child issued); it
+	// returns false when the token was already rotated or revoked. Enables reuse detection.
+	MarkRotated(ctx context.Context, refreshTokenID string) (bool, error)
+	// RevokeByUserClient revokes every token the user holds for the client (public client_id) and
+	// denylists their unexpired access tokens.
+	RevokeByUserClient(ctx context.Context, userID uuid.UUID, clientID string) error
+	// RevokeBySession revokes every token granted within a browser session and denylists their
+	// unexpired access tokens.
+	RevokeBySession(ctx context.Context, sessionID uuid.UUID) error
 }
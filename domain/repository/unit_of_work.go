@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+// UnitOfWork runs fn inside one transaction. Repositories backed by the same store join the
+// transaction carried by the ctx handed to fn; if fn returns an error (or panics) nothing is
+// committed. Nested Do calls join the outer transaction.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// BackchannelLogoutEvent is the events member of a logout_token (Back-Channel Logout 1.0 section 2.4).
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// BackchannelLogout notifies relying parties that a session they took part in has ended.
+type BackchannelLogout interface {
+	// SessionEnded queues a logout_token for every client of sess that registered a
+	// backchannel_logout_uri. Delivery happens asynchronously with retries. issuer is the iss the
+	// session's ID tokens carried.
+	SessionEnded(ctx context.Context, sess *entity.Session, issuer string) error
+}
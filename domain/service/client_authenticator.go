@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+)
+
+// ErrInvalidClient is returned when client authentication fails for any reason (unknown client,
+// wrong secret, bad assertion or a method other than the registered one).
+var ErrInvalidClient = errors.New("invalid_client")
+
+// ClientAuthRequest carries the credentials a client presented at an authenticated endpoint.
+// Transport parsing (Basic header, form fields) happens in the delivery layer.
+type ClientAuthRequest struct {
+	Method        enum.ClientAuthMethod
+	ClientID      string
+	ClientSecret  string
+	AssertionType string
+	Assertion     string
+	// Audience lists the values accepted in a private_key_jwt assertion's aud claim
+	// (typically the endpoint URL and the issuer identifier).
+	Audience []string
+}
+
+// ClientAuthenticator verifies client credentials against the client's registration,
+// enforcing its registered token_endpoint_auth_method.
+type ClientAuthenticator interface {
+	Authenticate(ctx context.Context, req ClientAuthRequest) (*entity.Client, error)
+	// Methods lists the authentication methods Authenticate can verify.
+	Methods() []enum.ClientAuthMethod
+	// AssertionSigningAlgs lists the JWS algorithms accepted for private_key_jwt assertions.
+	AssertionSigningAlgs() []string
+}
@@ -8,8 +8,11 @@ import (
 )
 
 // KeyRotationService manages active + previous signing keys and JWKS exposure.
+// One key is managed per supported algorithm; CurrentKeyID refers to the default (access token) key.
 type KeyRotationService interface {
 	CurrentKeyID() string
+	// SupportedAlgorithms lists the JWS algorithms an active key is kept for, default first.
+	SupportedAlgorithms() []string
 	RotateIfNeeded(ctx context.Context) error
 	GetPublicJWKS(ctx context.Context) (any, error) // Returns a JWKS representation (structure defined in infra layer)
 	SignJWT(claims vo.JWTClaims, ttl time.Duration) (string, error)
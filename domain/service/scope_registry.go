@@ -0,0 +1,38 @@
+package service
+
+// ScopeDefinition describes a scope the server understands. Description is shown to users on the
+// consent screen; Claims are the user claims the scope releases.
+type ScopeDefinition struct {
+	Name        string
+	Description string
+	Claims      []string
+}
+
+// ScopeRegistry is the server-wide set of known scopes. Requests for scopes it does not know are
+// rejected with invalid_scope.
+type ScopeRegistry interface {
+	Lookup(name string) (ScopeDefinition, bool)
+	// All returns every definition, sorted by name.
+	All() []ScopeDefinition
+}
+
+// ReleaseClaims picks from available the claims released by the given scopes. Unknown scopes
+// release nothing.
+func ReleaseClaims(registry ScopeRegistry, scopes []string, available map[string]any) map[string]any {
+	out := map[string]any{}
+	if registry == nil {
+		return out
+	}
+	for _, s := range scopes {
+		def, ok := registry.Lookup(s)
+		if !ok {
+			continue
+		}
+		for _, c := range def.Claims {
+			if v, ok := available[c]; ok {
+				out[c] = v
+			}
+		}
+	}
+	return out
+}
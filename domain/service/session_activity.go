@@ -0,0 +1,15 @@
+package service
+
+import (
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// SessionActivity slides a browser session's expiry each time it is used, within the deployment's
+// session policy. Implementations may batch the writes, so a session read back from the repository
+// can trail recent use by a short delay.
+type SessionActivity interface {
+	// Seen records use of sess at now, updating its LastSeenAt and ExpiresAt in place.
+	Seen(sess *entity.Session, now time.Time)
+}
@@ -2,11 +2,24 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/RanguraGIT/sso/domain/vo"
 )
 
+// Validation errors returned by TokenService.ValidateAccessToken so callers can distinguish
+// an expired token from a forged or foreign one.
+var (
+	ErrTokenMalformed        = errors.New("token malformed")
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenNotYetValid      = errors.New("token not yet valid")
+	ErrTokenSignatureInvalid = errors.New("token signature invalid")
+	ErrTokenUnknownKey       = errors.New("token signed with unknown key")
+	ErrTokenClaimsInvalid    = errors.New("token claims invalid") // iss / aud mismatch or missing required claims
+	ErrTokenRevoked          = errors.New("token revoked")
+)
+
 // TokenIssueResult returned by TokenService issue operations.
 type TokenIssueResult struct {
 	AccessToken      string
@@ -18,9 +31,44 @@ type TokenIssueResult struct {
 	IDToken          string // OIDC ID Token (optional; set for authorization_code flow)
 }
 
+// IDTokenOptions carry per-client ID token settings.
+type IDTokenOptions struct {
+	SigningAlg string // JWS alg from the client's id_token_signed_response_alg; empty uses the server default
+	// EncryptionAlg and EncryptionEnc come from id_token_encrypted_response_alg/enc. When EncryptionAlg
+	// is set the signed ID token is wrapped in a JWE for a key from ClientJWKS.
+	EncryptionAlg string
+	EncryptionEnc string // empty uses DefaultEncryptionEnc
+	ClientJWKS    string
+}
+
+// SupportedSigningAlgs lists the JWS algorithms the server can sign with.
+var SupportedSigningAlgs = []string{"RS256", "PS256", "ES256", "EdDSA"}
+
+// DefaultSigningAlg is always managed; it signs access tokens and the ID tokens of clients that
+// registered no id_token_signed_response_alg.
+const DefaultSigningAlg = "RS256"
+
+// JWE algorithms the server can encrypt ID tokens with (OIDC Core section 10.2).
+var (
+	SupportedEncryptionAlgs = []string{"RSA-OAEP-256", "ECDH-ES"}
+	SupportedEncryptionEncs = []string{"A128CBC-HS256", "A256CBC-HS512", "A128GCM", "A256GCM"}
+)
+
+// DefaultEncryptionEnc is the content encryption used when a client registers only an alg.
+const DefaultEncryptionEnc = "A128CBC-HS256"
+
 // TokenService creates & validates signed JWT access tokens and manages refresh rotation meta.
 type TokenService interface {
 	IssueAccessAndRefresh(ctx context.Context, claims vo.JWTClaims, refreshTTL time.Duration) (*TokenIssueResult, error)
+	// IssueAccessToken signs an access token only (no refresh token), e.g. for the client_credentials grant.
+	IssueAccessToken(ctx context.Context, claims vo.JWTClaims) (*TokenIssueResult, error)
 	ValidateAccessToken(ctx context.Context, tokenString string) (*vo.JWTClaims, error)
-	IssueIDToken(ctx context.Context, claims vo.JWTClaims, ttl time.Duration) (string, error)
+	IssueIDToken(ctx context.Context, claims vo.JWTClaims, ttl time.Duration, opts IDTokenOptions) (string, error)
+	// ValidateIDTokenHint verifies an ID token we issued (signature and iss) for use as id_token_hint.
+	// Expiry is not enforced, since hints are routinely expired ID tokens. Access tokens and other
+	// typed JWTs are refused; callers check that aud contains the requesting client.
+	ValidateIDTokenHint(ctx context.Context, tokenString string) (*vo.JWTClaims, error)
+	// SignClaims signs an arbitrary claim set, setting typ in the JOSE header when non-empty.
+	// An empty alg selects the server default.
+	SignClaims(ctx context.Context, typ string, claims map[string]any, alg string) (string, error)
 }
@@ -1,7 +1,11 @@
 package service
 
 type ServiceWrapper struct {
-	AuthService        AuthService
-	TokenService       TokenService
-	KeyRotationService KeyRotationService
+	AuthService         AuthService
+	TokenService        TokenService
+	KeyRotationService  KeyRotationService
+	ClientAuthenticator ClientAuthenticator
+	ScopeRegistry       ScopeRegistry
+	BackchannelLogout   BackchannelLogout
+	SessionActivity     SessionActivity
 }
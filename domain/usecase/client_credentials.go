@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"context"
+	"time"
+)
+
+// ClientCredentialsInput expects ClientID to be already authenticated by the delivery layer.
+type ClientCredentialsInput struct {
+	ClientID  string
+	Scope     string
+	Audience  []string
+	Issuer    string
+	AccessTTL time.Duration
+}
+
+type ClientCredentialsOutput struct {
+	AccessToken string
+	ExpiresIn   int64
+	Scope       string
+	TokenType   string
+}
+
+// ClientCredentials issues machine-to-machine access tokens (RFC 6749 section 4.4).
+// No refresh token and no ID token are produced; the token subject is the client itself.
+type ClientCredentials interface {
+	Execute(ctx context.Context, in ClientCredentialsInput) (*ClientCredentialsOutput, error)
+}
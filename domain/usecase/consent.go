@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConsentCheck describes what the consent screen must ask for.
+type ConsentCheck struct {
+	ClientName string
+	// Missing lists requested scopes the user has not approved yet; empty means no prompt is needed
+	// (everything granted before, or the client is first party).
+	Missing []string
+}
+
+// ConsentGrant is one client a user has approved, as listed to that user.
+type ConsentGrant struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Consents manages the scopes users approve per client. Unknown clients return ErrInvalidClient.
+type Consents interface {
+	Check(ctx context.Context, userID uuid.UUID, clientID string, scopes []string) (*ConsentCheck, error)
+	// Grant adds scopes to the user's consent for the client.
+	Grant(ctx context.Context, userID uuid.UUID, clientID string, scopes []string) error
+	List(ctx context.Context, userID uuid.UUID) ([]ConsentGrant, error)
+	// Withdraw deletes the consent and revokes the tokens the client holds for the user. It returns
+	// ErrConsentNotFound when there was nothing to withdraw.
+	Withdraw(ctx context.Context, userID uuid.UUID, clientID string) error
+}
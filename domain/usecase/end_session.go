@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// EndSessionInput describes an RP-Initiated Logout request. The delivery layer has already
+// verified any id_token_hint and resolved ClientID from it or from the client_id parameter.
+type EndSessionInput struct {
+	SessionID             uuid.UUID // browser session from the sid cookie; uuid.Nil when there is none
+	ClientID              string
+	PostLogoutRedirectURI string
+	RevokeTokens          bool   // also revoke the tokens granted within the session
+	Issuer                string // iss of the session's ID tokens, repeated in back-channel logout tokens
+}
+
+// EndSessionOutput lists what the user agent still has to do once the session is gone.
+type EndSessionOutput struct {
+	// FrontchannelLogoutURIs are the frontchannel_logout_uri values of the clients that took part
+	// in the session, with iss and sid added for clients that require them. The logout page loads
+	// each in a hidden iframe (Front-Channel Logout 1.0).
+	FrontchannelLogoutURIs []string
+}
+
+// EndSession implements OIDC RP-Initiated Logout 1.0.
+type EndSession interface {
+	// Validate checks post_logout_redirect_uri against the client's registered values, returning
+	// ErrInvalidClient or ErrInvalidRedirectURI. Until it succeeds the user agent must not be
+	// redirected anywhere.
+	Validate(ctx context.Context, in EndSessionInput) error
+	// Execute revokes the session (a missing or already ended session is not an error) and,
+	// when asked, its tokens. Clients that took part in the session are notified over the back
+	// channel; those registered for front-channel logout are returned in the output.
+	Execute(ctx context.Context, in EndSessionInput) (*EndSessionOutput, error)
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import "errors"
+
+// Sentinel errors returned by usecases so delivery layers can map them to RFC 6749 error codes
+// without inspecting error strings.
+var (
+	ErrInvalidClient      = errors.New("invalid_client")
+	ErrUnauthorizedClient = errors.New("unauthorized_client")
+	ErrInvalidScope       = errors.New("invalid_scope")
+	// ErrInvalidRedirectURI means the redirect_uri is not registered for the client; the
+	// authorization server must not redirect back to it.
+	ErrInvalidRedirectURI      = errors.New("invalid_redirect_uri")
+	ErrUnsupportedResponseType = errors.New("unsupported_response_type")
+	// ErrConsentRequired means the user has not approved every requested scope for the client.
+	ErrConsentRequired = errors.New("consent_required")
+	ErrConsentNotFound = errors.New("consent not found")
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrUnmetAuthenticationRequirements means an essential acr in the claims request cannot be met.
+	ErrUnmetAuthenticationRequirements = errors.New("unmet_authentication_requirements")
+
+	// Refresh token grant failures, all answered with invalid_grant.
+	ErrRefreshTokenInvalid   = errors.New("refresh token invalid") // unknown or revoked
+	ErrRefreshTokenExpired   = errors.New("refresh token expired")
+	ErrRefreshClientMismatch = errors.New("refresh token issued to another client")
+	// ErrRefreshTokenReused means an already rotated token was presented again; its family has
+	// been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrRefreshTokenRotated is returned to the loser of a concurrent rotation of the same token.
+	ErrRefreshTokenRotated = errors.New("refresh token already rotated")
+)
@@ -0,0 +1,29 @@
+package usecase
+
+import "context"
+
+// IntrospectInput carries the raw token presented to /introspect. TokenTypeHint ("access_token"
+// or "refresh_token") only changes the lookup order.
+type IntrospectInput struct {
+	Token         string
+	TokenTypeHint string
+	Issuer        string // expected iss of access tokens; empty skips the check
+}
+
+// IntrospectOutput mirrors the RFC 7662 response. Every field but Active is empty for inactive tokens.
+type IntrospectOutput struct {
+	Active    bool
+	Scope     string
+	ClientID  string
+	Subject   string
+	ExpiresAt int64
+	IssuedAt  int64
+	TokenType string // "Bearer" for access tokens, "refresh_token" for refresh tokens
+	Issuer    string
+	Audience  []string
+}
+
+// Introspect reports whether an access or refresh token is currently active (RFC 7662).
+type Introspect interface {
+	Execute(ctx context.Context, in IntrospectInput) (*IntrospectOutput, error)
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
 type IssueTokenInput struct {
@@ -15,6 +17,10 @@ type IssueTokenInput struct {
 	Issuer     string
 	AccessTTL  time.Duration
 	RefreshTTL time.Duration
+	Nonce      string    // echoed in the ID token
+	AuthTime   time.Time // auth_time for the ID token; zero omits it
+	Claims     vo.ClaimsRequest
+	SessionID  uuid.UUID // browser session of the grant, recorded so logout can revoke its tokens
 }
 
 type IssueTokenOutput struct {
@@ -7,6 +7,7 @@ import (
 
 type RefreshTokenInput struct {
 	RefreshTokenID string
+	ClientID       string // Authenticated client; must match the client the token was issued to
 	Issuer         string
 	Audience       []string
 	AccessTTL      time.Duration
@@ -0,0 +1,18 @@
+package usecase
+
+import "context"
+
+// RevokeTokenInput expects ClientID to be already authenticated by the delivery layer.
+// TokenTypeHint ("access_token" or "refresh_token") only changes the lookup order.
+type RevokeTokenInput struct {
+	Token         string
+	TokenTypeHint string
+	ClientID      string
+	Issuer        string // issuer of this request; access tokens from another issuer are unknown
+}
+
+// RevokeToken implements RFC 7009 revocation. Unknown, expired or already revoked tokens are not
+// an error; a token issued to another client returns ErrUnauthorizedClient.
+type RevokeToken interface {
+	Execute(ctx context.Context, in RevokeTokenInput) error
+}
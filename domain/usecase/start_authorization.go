@@ -2,10 +2,18 @@ package usecase
 
 import (
 	"context"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
+// PasswordACR is the authentication context class reference of a password login, the only kind of
+// authentication the server performs. It is the one acr value a claims request can be satisfied with.
+const PasswordACR = "urn:sso:acr:password"
+
 type StartAuthInput struct {
 	ResponseType        string
+	ResponseMode        string // query, fragment or form_post; chosen by the delivery layer
 	ClientID            string
 	RedirectURI         string
 	Scope               string
@@ -13,14 +21,30 @@ type StartAuthInput struct {
 	CodeChallenge       string
 	CodeChallengeMethod string
 	UserID              string
+	Nonce               string
+	AuthTime            time.Time // authentication time of the session approving the request
+	SessionID           string    // browser session approving the request
+	Claims              vo.ClaimsRequest
+	CodeTTL             time.Duration // zero selects the default lifetime
 }
 
 type StartAuthResult struct {
 	Code  string
 	State string
+	Scope string // granted scope, possibly narrower than requested
 }
 
 // StartAuthorization defines the interface for starting an OAuth authorization flow.
 type StartAuthorization interface {
+	// Validate checks client_id and redirect_uri only, returning ErrInvalidClient or
+	// ErrInvalidRedirectURI. Until it succeeds, errors must not be sent to the redirect_uri.
+	Validate(ctx context.Context, in StartAuthInput) error
+	// GrantedScopes applies the scope policy: unknown scopes are ErrInvalidScope and the rest are
+	// narrowed to the client's registration.
+	GrantedScopes(ctx context.Context, in StartAuthInput) ([]string, error)
+	// ClaimScopes lists scopes outside the grant that the claims request needs: claims released by
+	// a scope the client is registered for but did not request. The user approves them alongside
+	// the granted scopes.
+	ClaimScopes(ctx context.Context, in StartAuthInput) ([]string, error)
 	Execute(ctx context.Context, in StartAuthInput) (*StartAuthResult, error)
 }
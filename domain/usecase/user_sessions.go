@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionClient is a client that took part in a session.
+type SessionClient struct {
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+}
+
+// SessionInfo is one live browser session of a user, as listed to the user or an administrator.
+type SessionInfo struct {
+	ID         uuid.UUID       `json:"id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastSeenAt time.Time       `json:"last_seen_at"`
+	ExpiresAt  time.Time       `json:"expires_at"`
+	Clients    []SessionClient `json:"clients"`
+	Current    bool            `json:"current,omitempty"` // the session the request was made with
+}
+
+// UserSessions lists and terminates a user's browser sessions. Terminating a session revokes the
+// tokens granted within it and notifies its clients over the back channel, as logging out does.
+type UserSessions interface {
+	List(ctx context.Context, userID uuid.UUID) ([]SessionInfo, error)
+	// Terminate ends one session of the user, returning ErrSessionNotFound when the user has no
+	// such live session. issuer is repeated in back-channel logout tokens.
+	Terminate(ctx context.Context, userID, sessionID uuid.UUID, issuer string) error
+	// TerminateAll ends every live session of the user except keep (uuid.Nil ends them all) and
+	// reports how many were ended.
+	TerminateAll(ctx context.Context, userID, keep uuid.UUID, issuer string) (int, error)
+}
@@ -2,10 +2,16 @@ package usecase
 
 // Wrapper groups usecase interfaces for easy wiring.
 type UsecaseWrapper struct {
-	StartAuth    StartAuthorization
-	IssueToken   IssueToken
-	Refresh      RefreshToken
-	CreateSess   CreateSession
-	UserLogin    UserLogin
-	RegisterUser RegisterUser
+	StartAuth         StartAuthorization
+	IssueToken        IssueToken
+	Refresh           RefreshToken
+	ClientCredentials ClientCredentials
+	Introspect        Introspect
+	Revoke            RevokeToken
+	Consents          Consents
+	CreateSess        CreateSession
+	UserLogin         UserLogin
+	RegisterUser      RegisterUser
+	EndSession        EndSession
+	UserSessions      UserSessions
 }
@@ -0,0 +1,126 @@
+package vo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ClaimsRequest is the OIDC claims request parameter (OIDC Core section 5.5): individual claims a
+// client asks for in the ID token and at the userinfo endpoint.
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimSpec `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimSpec `json:"id_token,omitempty"`
+}
+
+// ClaimSpec qualifies a requested claim. A nil spec (JSON null) requests the claim voluntarily.
+type ClaimSpec struct {
+	Essential bool  `json:"essential,omitempty"`
+	Value     any   `json:"value,omitempty"`
+	Values    []any `json:"values,omitempty"`
+}
+
+// ParseClaimsRequest decodes the JSON claims parameter. An empty string is an empty request;
+// members other than userinfo and id_token are ignored as the specification requires.
+func ParseClaimsRequest(raw string) (ClaimsRequest, error) {
+	var cr ClaimsRequest
+	if raw == "" {
+		return cr, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cr); err != nil {
+		return ClaimsRequest{}, fmt.Errorf("claims request: %w", err)
+	}
+	for _, m := range []map[string]*ClaimSpec{cr.UserInfo, cr.IDToken} {
+		for name, spec := range m {
+			if name == "" {
+				return ClaimsRequest{}, errors.New("claims request: empty claim name")
+			}
+			if spec != nil && spec.Value != nil && len(spec.Values) > 0 {
+				return ClaimsRequest{}, fmt.Errorf("claims request: %s has both value and values", name)
+			}
+		}
+	}
+	return cr, nil
+}
+
+func (c ClaimsRequest) IsZero() bool { return len(c.UserInfo) == 0 && len(c.IDToken) == 0 }
+
+// String returns the JSON form, or "" for an empty request.
+func (c ClaimsRequest) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	b, _ := json.Marshal(c)
+	return string(b)
+}
+
+// Names lists every requested claim name across both members, sorted and without duplicates.
+func (c ClaimsRequest) Names() []string {
+	seen := map[string]bool{}
+	for _, m := range []map[string]*ClaimSpec{c.UserInfo, c.IDToken} {
+		for name := range m {
+			seen[name] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Restrict returns a copy holding only the claims keep accepts.
+func (c ClaimsRequest) Restrict(keep func(name string) bool) ClaimsRequest {
+	filter := func(m map[string]*ClaimSpec) map[string]*ClaimSpec {
+		var out map[string]*ClaimSpec
+		for name, spec := range m {
+			if keep(name) {
+				if out == nil {
+					out = map[string]*ClaimSpec{}
+				}
+				out[name] = spec
+			}
+		}
+		return out
+	}
+	return ClaimsRequest{UserInfo: filter(c.UserInfo), IDToken: filter(c.IDToken)}
+}
+
+// Select picks the requested claims from available, skipping values that miss a value / values
+// constraint. requested is one member of a ClaimsRequest.
+func Select(requested map[string]*ClaimSpec, available map[string]any) map[string]any {
+	out := map[string]any{}
+	for name, spec := range requested {
+		if v, ok := available[name]; ok && spec.Accepts(v) {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// Accepts reports whether v satisfies the value / values constraint; a nil or unconstrained spec
+// accepts anything.
+func (s *ClaimSpec) Accepts(v any) bool {
+	if s == nil || (s.Value == nil && len(s.Values) == 0) {
+		return true
+	}
+	if s.Value != nil {
+		return sameJSON(s.Value, v)
+	}
+	for _, want := range s.Values {
+		if sameJSON(want, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameJSON compares two values by their JSON encoding, so 1 and 1.0 or a string and its decoded
+// form compare equal.
+func sameJSON(a, b any) bool {
+	ja, err1 := json.Marshal(a)
+	jb, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(ja) == string(jb)
+}
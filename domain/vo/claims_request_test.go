@@ -0,0 +1,45 @@
+package vo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseClaimsRequest(t *testing.T) {
+	cr, err := ParseClaimsRequest(`{"userinfo":{"given_name":{"essential":true},"email":null},"id_token":{"acr":{"values":["urn:a","urn:b"]},"auth_time":{"essential":true}},"other":{}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(cr.Names(), " "); got != "acr auth_time email given_name" {
+		t.Fatalf("unexpected names: %s", got)
+	}
+	if !cr.UserInfo["given_name"].Essential || cr.UserInfo["email"] != nil {
+		t.Fatalf("unexpected userinfo specs: %+v", cr.UserInfo)
+	}
+	if !cr.IDToken["acr"].Accepts("urn:b") || cr.IDToken["acr"].Accepts("urn:c") {
+		t.Fatal("values constraint not applied")
+	}
+
+	narrowed := cr.Restrict(func(n string) bool { return n != "email" && n != "acr" })
+	if got := strings.Join(narrowed.Names(), " "); got != "auth_time given_name" {
+		t.Fatalf("unexpected restricted names: %s", got)
+	}
+	again, err := ParseClaimsRequest(narrowed.String())
+	if err != nil || strings.Join(again.Names(), " ") != "auth_time given_name" {
+		t.Fatalf("round trip failed: %v %v", again, err)
+	}
+
+	sel := Select(map[string]*ClaimSpec{"locale": {Value: "en"}, "name": nil, "zoneinfo": nil}, map[string]any{"locale": "fr", "name": "Alice"})
+	if len(sel) != 1 || sel["name"] != "Alice" {
+		t.Fatalf("unexpected selection: %v", sel)
+	}
+
+	for _, bad := range []string{`[]`, `{"id_token":{"acr":{"value":"a","values":["b"]}}}`, `{"userinfo":{"":null}}`} {
+		if _, err := ParseClaimsRequest(bad); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+	if empty, err := ParseClaimsRequest(""); err != nil || !empty.IsZero() || empty.String() != "" {
+		t.Fatalf("empty request: %v %v", empty, err)
+	}
+}
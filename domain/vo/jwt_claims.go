@@ -13,6 +13,12 @@ type JWTClaims struct {
 	Scope     string // space-delimited scopes per RFC 6749
 	ClientID  string
 	Nonce     string
+	AuthTime  int64  // auth_time: when the end-user authenticated (Unix seconds); 0 when unknown
+	ID        string // jti; assigned by the token service to access tokens so they can be looked up
+	SessionID string // sid: the browser session an ID token was issued in; empty when none
+	// Extra holds additional claims (e.g. profile claims released by scope) for ID tokens. They
+	// never override the registered claims above.
+	Extra map[string]any
 }
 
 func (c JWTClaims) IsExpired(now time.Time) bool {
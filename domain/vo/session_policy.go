@@ -0,0 +1,44 @@
+package vo
+
+import "time"
+
+// SessionPolicy bounds the life of a browser session. IdleTimeout ends a session left unused for
+// that long; MaxLifetime ends it that long after login however active it is. A zero bound is unset.
+type SessionPolicy struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+// ExpiresAt is when a session created at createdAt and last used at lastSeen expires: the earlier
+// of the two bounds. It is the zero time when neither bound is set.
+func (p SessionPolicy) ExpiresAt(createdAt, lastSeen time.Time) time.Time {
+	var at time.Time
+	if p.IdleTimeout > 0 {
+		at = lastSeen.Add(p.IdleTimeout)
+	}
+	if p.MaxLifetime > 0 {
+		if limit := createdAt.Add(p.MaxLifetime); at.IsZero() || limit.Before(at) {
+			at = limit
+		}
+	}
+	return at
+}
+
+// Allows reports whether a session created at createdAt and last used at lastSeen is still
+// within the policy at now.
+func (p SessionPolicy) Allows(createdAt, lastSeen, now time.Time) bool {
+	at := p.ExpiresAt(createdAt, lastSeen)
+	return at.IsZero() || !now.After(at)
+}
+
+// Restrict returns the stricter of each bound of p and other, so a client policy can shorten the
+// deployment's limits but never extend them.
+func (p SessionPolicy) Restrict(other SessionPolicy) SessionPolicy {
+	shorter := func(a, b time.Duration) time.Duration {
+		if a <= 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return SessionPolicy{IdleTimeout: shorter(p.IdleTimeout, other.IdleTimeout), MaxLifetime: shorter(p.MaxLifetime, other.MaxLifetime)}
+}
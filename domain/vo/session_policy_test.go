@@ -0,0 +1,72 @@
+package vo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionPolicy(t *testing.T) {
+	created := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	p := SessionPolicy{IdleTimeout: time.Hour, MaxLifetime: 10 * time.Hour}
+
+	if got := p.ExpiresAt(created, created.Add(2*time.Hour)); !got.Equal(created.Add(3 * time.Hour)) {
+		t.Fatalf("idle bound not applied: %v", got)
+	}
+	if got := p.ExpiresAt(created, created.Add(9*time.Hour+30*time.Minute)); !got.Equal(created.Add(10 * time.Hour)) {
+		t.Fatalf("absolute bound not applied: %v", got)
+	}
+	if !p.Allows(created, created.Add(2*time.Hour), created.Add(3*time.Hour)) || p.Allows(created, created.Add(2*time.Hour), created.Add(3*time.Hour+time.Second)) {
+		t.Fatal("Allows disagrees with ExpiresAt")
+	}
+	if got := (SessionPolicy{}).ExpiresAt(created, created); !got.IsZero() || !(SessionPolicy{}).Allows(created, created, created.Add(1000*time.Hour)) {
+		t.Fatal("an empty policy must not expire sessions")
+	}
+
+	r := p.Restrict(SessionPolicy{IdleTimeout: 15 * time.Minute, MaxLifetime: 24 * time.Hour})
+	if r.IdleTimeout != 15*time.Minute || r.MaxLifetime != 10*time.Hour {
+		t.Fatalf("unexpected restriction: %+v", r)
+	}
+	if r := (SessionPolicy{MaxLifetime: time.Hour}).Restrict(SessionPolicy{IdleTimeout: time.Minute}); r.IdleTimeout != time.Minute || r.MaxLifetime != time.Hour {
+		t.Fatalf("unset bounds must take the other side: %+v", r)
+	}
+}
+
+func TestSessionPolicyAllows(t *testing.T) {
+	created := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	p := SessionPolicy{IdleTimeout: 30 * time.Minute, MaxLifetime: 8 * time.Hour}
+	cases := []struct {
+		name          string
+		lastSeen, now time.Duration // after created
+		policy        SessionPolicy
+		want          bool
+	}{
+		{name: "fresh", lastSeen: 0, now: time.Minute, policy: p, want: true},
+		{name: "idle up to the timeout", lastSeen: time.Hour, now: time.Hour + 30*time.Minute, policy: p, want: true},
+		{name: "idle past the timeout", lastSeen: time.Hour, now: time.Hour + 30*time.Minute + time.Second, policy: p},
+		{name: "never used past the timeout", lastSeen: 0, now: 31 * time.Minute, policy: p},
+		{name: "active at the absolute lifetime", lastSeen: 8*time.Hour - time.Minute, now: 8 * time.Hour, policy: p, want: true},
+		{name: "active past the absolute lifetime", lastSeen: 8*time.Hour - time.Minute, now: 8*time.Hour + time.Second, policy: p},
+		{name: "no idle timeout", lastSeen: 0, now: 7 * time.Hour, policy: SessionPolicy{MaxLifetime: 8 * time.Hour}, want: true},
+		{name: "no absolute lifetime", lastSeen: 100 * time.Hour, now: 100*time.Hour + time.Minute, policy: SessionPolicy{IdleTimeout: 30 * time.Minute}, want: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.Allows(created, created.Add(tc.lastSeen), created.Add(tc.now)); got != tc.want {
+				t.Fatalf("Allows = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("activity extends up to the absolute lifetime", func(t *testing.T) {
+		lastSeen := created
+		for now := created.Add(20 * time.Minute); now.Before(created.Add(8 * time.Hour)); now = now.Add(20 * time.Minute) {
+			if !p.Allows(created, lastSeen, now) {
+				t.Fatalf("active session refused at %s", now.Sub(created))
+			}
+			lastSeen = now
+		}
+		if p.Allows(created, lastSeen, created.Add(8*time.Hour+time.Second)) {
+			t.Fatal("active session outlived the absolute lifetime")
+		}
+	})
+}
@@ -0,0 +1,422 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/RanguraGIT/sso/domain/enum"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// PathEnv names the environment variable holding the config file path (default config.yaml).
+const PathEnv = "SSO_CONFIG"
+
+// Config is the typed form of config.yaml. Durations use Go syntax ("10m", "720h").
+type Config struct {
+	// Issuer is the canonical issuer identifier. When empty it is derived from the forwarded host
+	// sent by one of TrustedProxies, which must be one of AllowedHosts; both are then required.
+	Issuer              string        `yaml:"issuer"`
+	HTTPAddr            string        `yaml:"http_addr"`
+	AccessTokenTTL      time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL     time.Duration `yaml:"refresh_token_ttl"`
+	AuthCodeTTL         time.Duration `yaml:"auth_code_ttl"`
+	SessionTTL          time.Duration `yaml:"session_ttl"`          // absolute session lifetime, however active
+	SessionIdleTimeout  time.Duration `yaml:"session_idle_timeout"` // ends unused sessions; 0 keeps every session for session_ttl
+	KeyRotationInterval time.Duration `yaml:"key_rotation_interval"`
+	ActiveKeyOverlap    time.Duration `yaml:"active_key_overlap"`
+	SigningAlgs         []string      `yaml:"signing_algs"`      // empty manages every supported algorithm
+	DiscoveryMaxAge     time.Duration `yaml:"discovery_max_age"` // how long clients may cache discovery metadata
+	// CIDRs (or single addresses) of reverse proxies whose X-Forwarded-* / Forwarded headers are trusted.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// Hosts (host[:port]) a derived issuer may name; the first is used for requests that do not
+	// come through a trusted proxy.
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// Directory whose *.html files override the embedded login and consent templates.
+	UITemplateDir string `yaml:"ui_template_dir"`
+	// LogoutRevokesTokens makes /logout also revoke the tokens granted within the ended session.
+	LogoutRevokesTokens bool `yaml:"logout_revokes_tokens"`
+	// Requests per minute per client IP; 0 disables the limit.
+	RateLimitAuthorizeRPM int            `yaml:"rate_limit_authorize_rpm"`
+	RateLimitTokenRPM     int            `yaml:"rate_limit_token_rpm"`
+	Clients               []ClientConfig `yaml:"clients"`
+	Users                 []UserConfig   `yaml:"users"`
+	// Scopes added to (or re-described in) the built-in OpenID Connect scope registry.
+	Scopes []ScopeConfig `yaml:"scopes"`
+}
+
+// ScopeConfig declares a scope the server accepts, with the text shown on the consent screen.
+type ScopeConfig struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Claims      []string `yaml:"claims"` // user claims the scope releases
+}
+
+// ClientConfig declares an OAuth client reconciled into the client repository on startup.
+type ClientConfig struct {
+	ClientID                 string   `yaml:"client_id"`
+	Name                     string   `yaml:"name"` // defaults to client_id
+	ClientSecret             string   `yaml:"client_secret"`
+	RedirectURIs             []string `yaml:"redirect_uris"`
+	Scopes                   []string `yaml:"scopes"`
+	Public                   bool     `yaml:"public"`
+	FirstParty               bool     `yaml:"first_party"` // skip the consent screen
+	TokenEndpointAuthMethod  string   `yaml:"token_endpoint_auth_method"`
+	IDTokenSignedResponseAlg string   `yaml:"id_token_signed_response_alg"`
+	// JWE for ID tokens, encrypted to a key in jwks; enc defaults to A128CBC-HS256.
+	IDTokenEncryptedResponseAlg string   `yaml:"id_token_encrypted_response_alg"`
+	IDTokenEncryptedResponseEnc string   `yaml:"id_token_encrypted_response_enc"`
+	UserinfoSignedResponseAlg   string   `yaml:"userinfo_signed_response_alg"` // answer userinfo as a signed JWT
+	PostLogoutRedirectURIs      []string `yaml:"post_logout_redirect_uris"`
+	BackchannelLogoutURI        string   `yaml:"backchannel_logout_uri"`
+	FrontchannelLogoutURI       string   `yaml:"frontchannel_logout_uri"`
+	// Stricter session limits for this client (step-up): a session idle or older than these must
+	// log in again before the client is answered.
+	SessionIdleTimeout time.Duration `yaml:"session_idle_timeout"`
+	SessionMaxLifetime time.Duration `yaml:"session_max_lifetime"`
+	// Append iss and sid to frontchannel_logout_uri.
+	FrontchannelLogoutSessionRequired bool   `yaml:"frontchannel_logout_session_required"`
+	JWKS                              string `yaml:"jwks"`
+}
+
+// UserConfig declares a user reconciled into the user repository on startup, matched by email.
+type UserConfig struct {
+	ID       string        `yaml:"id"` // UUID, or any stable string from which a UUID is derived
+	Email    string        `yaml:"email"`
+	Password string        `yaml:"password"`
+	Claims   []string      `yaml:"claims"` // unused; kept so older config files still load
+	Profile  ProfileConfig `yaml:"profile"`
+}
+
+// ProfileConfig holds the OIDC profile claims of a declared user.
+type ProfileConfig struct {
+	Name                string        `yaml:"name"`
+	GivenName           string        `yaml:"given_name"`
+	FamilyName          string        `yaml:"family_name"`
+	Picture             string        `yaml:"picture"`
+	Locale              string        `yaml:"locale"`
+	Zoneinfo            string        `yaml:"zoneinfo"`
+	PhoneNumber         string        `yaml:"phone_number"`
+	PhoneNumberVerified bool          `yaml:"phone_number_verified"`
+	Address             AddressConfig `yaml:"address"`
+}
+
+// AddressConfig is the OIDC address claim of a declared user.
+type AddressConfig struct {
+	Formatted     string `yaml:"formatted"`
+	StreetAddress string `yaml:"street_address"`
+	Locality      string `yaml:"locality"`
+	Region        string `yaml:"region"`
+	PostalCode    string `yaml:"postal_code"`
+	Country       string `yaml:"country"`
+}
+
+// Default returns the settings used for anything config.yaml leaves out.
+func Default() *Config {
+	return &Config{
+		Issuer:              "http://localhost:8080",
+		HTTPAddr:            ":8080",
+		AccessTokenTTL:      10 * time.Minute,
+		RefreshTokenTTL:     24 * time.Hour,
+		AuthCodeTTL:         5 * time.Minute,
+		SessionTTL:          8 * time.Hour,
+		KeyRotationInterval: 24 * time.Hour,
+		ActiveKeyOverlap:    time.Hour,
+		DiscoveryMaxAge:     time.Hour,
+	}
+}
+
+// Load reads the YAML file at path (or $SSO_CONFIG, or config.yaml when path is empty) over the
+// defaults, applies environment overrides and validates the result. A missing file is an error.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(PathEnv)
+	}
+	if path == "" {
+		path = "config.yaml"
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	cfg, err := Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes YAML over the defaults, applies environment overrides and validates.
+// Unknown keys are rejected so typos do not silently fall back to defaults.
+func Parse(raw []byte) (*Config, error) {
+	cfg := Default()
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides scalar settings from SSO_* variables (PORT is honoured for http_addr)
+// and client secrets from SSO_CLIENT_SECRET_<CLIENT_ID>, with the id upper-cased and
+// non-alphanumerics replaced by underscores.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	str := func(key string, dst *string) {
+		if v, ok := lookup(key); ok && v != "" {
+			*dst = v
+		}
+	}
+	var errs []error
+	dur := func(key string, dst *time.Duration) {
+		if v, ok := lookup(key); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = d
+		}
+	}
+	num := func(key string, dst *int) {
+		if v, ok := lookup(key); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*dst = n
+		}
+	}
+	if port, ok := lookup("PORT"); ok && port != "" {
+		c.HTTPAddr = ":" + port
+	}
+	str("SSO_ISSUER", &c.Issuer)
+	str("SSO_HTTP_ADDR", &c.HTTPAddr)
+	dur("SSO_ACCESS_TOKEN_TTL", &c.AccessTokenTTL)
+	dur("SSO_REFRESH_TOKEN_TTL", &c.RefreshTokenTTL)
+	dur("SSO_AUTH_CODE_TTL", &c.AuthCodeTTL)
+	dur("SSO_SESSION_TTL", &c.SessionTTL)
+	dur("SSO_SESSION_IDLE_TIMEOUT", &c.SessionIdleTimeout)
+	str("SSO_UI_TEMPLATE_DIR", &c.UITemplateDir)
+	dur("SSO_KEY_ROTATION_INTERVAL", &c.KeyRotationInterval)
+	dur("SSO_ACTIVE_KEY_OVERLAP", &c.ActiveKeyOverlap)
+	dur("SSO_DISCOVERY_MAX_AGE", &c.DiscoveryMaxAge)
+	num("SSO_RATE_LIMIT_AUTHORIZE_RPM", &c.RateLimitAuthorizeRPM)
+	num("SSO_RATE_LIMIT_TOKEN_RPM", &c.RateLimitTokenRPM)
+	if v, ok := lookup("SSO_SIGNING_ALGS"); ok && v != "" {
+		c.SigningAlgs = strings.Split(v, ",")
+	}
+	if v, ok := lookup("SSO_TRUSTED_PROXIES"); ok && v != "" {
+		c.TrustedProxies = strings.Split(v, ",")
+	}
+	if v, ok := lookup("SSO_ALLOWED_HOSTS"); ok && v != "" {
+		c.AllowedHosts = strings.Split(v, ",")
+	}
+	if v, ok := lookup("SSO_LOGOUT_REVOKES_TOKENS"); ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SSO_LOGOUT_REVOKES_TOKENS: %w", err))
+		} else {
+			c.LogoutRevokesTokens = b
+		}
+	}
+	for i := range c.Clients {
+		str("SSO_CLIENT_SECRET_"+envKey(c.Clients[i].ClientID), &c.Clients[i].ClientSecret)
+	}
+	return errors.Join(errs...)
+}
+
+func envKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+// SessionPolicy is the deployment-wide bound on browser sessions.
+func (c *Config) SessionPolicy() vo.SessionPolicy {
+	return vo.SessionPolicy{IdleTimeout: c.SessionIdleTimeout, MaxLifetime: c.SessionTTL}
+}
+
+// minSessionIdleTimeout keeps idle timeouts well above the delay of batched last-seen writes.
+const minSessionIdleTimeout = 5 * time.Minute
+
+func validIdleTimeout(d time.Duration) bool { return d == 0 || d >= minSessionIdleTimeout }
+
+// Validate reports every problem at once so a broken config can be fixed in one pass.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	if c.Issuer != "" {
+		if u, err := url.Parse(c.Issuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(c.Issuer, "/") {
+			fail("issuer must be an absolute http(s) URL without query, fragment or trailing slash")
+		}
+	} else if len(c.TrustedProxies) == 0 || len(c.AllowedHosts) == 0 {
+		fail("issuer required unless trusted_proxies and allowed_hosts are both set")
+	}
+	for _, h := range c.AllowedHosts {
+		if h == "" || strings.ContainsAny(h, "/?#@ ") {
+			fail("allowed_hosts: %q is not a host", h)
+		}
+	}
+	for _, p := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				fail("trusted_proxies: %q is not a CIDR or IP address", p)
+			}
+		}
+	}
+	if c.HTTPAddr == "" {
+		fail("http_addr required")
+	}
+	for name, d := range map[string]time.Duration{
+		"access_token_ttl":      c.AccessTokenTTL,
+		"refresh_token_ttl":     c.RefreshTokenTTL,
+		"auth_code_ttl":         c.AuthCodeTTL,
+		"session_ttl":           c.SessionTTL,
+		"key_rotation_interval": c.KeyRotationInterval,
+		"discovery_max_age":     c.DiscoveryMaxAge,
+	} {
+		if d <= 0 {
+			fail("%s must be positive", name)
+		}
+	}
+	if !validIdleTimeout(c.SessionIdleTimeout) {
+		fail("session_idle_timeout must be 0 or at least %s", minSessionIdleTimeout)
+	}
+	if c.ActiveKeyOverlap < 0 || c.ActiveKeyOverlap >= c.KeyRotationInterval {
+		fail("active_key_overlap must be non-negative and shorter than key_rotation_interval")
+	}
+	// Retired keys stay published for the overlap, so every token signed just before a rotation
+	// must expire within it. ID tokens are issued with the access token lifetime.
+	if c.ActiveKeyOverlap < c.AccessTokenTTL {
+		fail("active_key_overlap must be at least access_token_ttl (the access and ID token lifetime)")
+	}
+	if c.RateLimitAuthorizeRPM < 0 || c.RateLimitTokenRPM < 0 {
+		fail("rate limits must not be negative")
+	}
+
+	signingAlgs := c.SigningAlgs
+	if len(signingAlgs) == 0 {
+		signingAlgs = dservice.SupportedSigningAlgs
+	}
+	for _, alg := range c.SigningAlgs {
+		if !slices.Contains(dservice.SupportedSigningAlgs, alg) {
+			fail("signing_algs: unsupported alg %q", alg)
+		}
+	}
+
+	seenClients := map[string]bool{}
+	for i, cl := range c.Clients {
+		where := fmt.Sprintf("clients[%d]", i)
+		if cl.ClientID == "" {
+			fail("%s: client_id required", where)
+		} else if seenClients[cl.ClientID] {
+			fail("%s: duplicate client_id %q", where, cl.ClientID)
+		}
+		seenClients[cl.ClientID] = true
+		if len(cl.RedirectURIs) == 0 {
+			fail("%s: at least one redirect_uri required", where)
+		}
+		for _, ru := range cl.RedirectURIs {
+			if u, err := url.Parse(ru); err != nil || !u.IsAbs() || u.Fragment != "" {
+				fail("%s: redirect_uri %q must be absolute without fragment", where, ru)
+			}
+		}
+		for _, ru := range cl.PostLogoutRedirectURIs {
+			if u, err := url.Parse(ru); err != nil || !u.IsAbs() || u.Fragment != "" {
+				fail("%s: post_logout_redirect_uri %q must be absolute without fragment", where, ru)
+			}
+		}
+		for _, lu := range []struct{ name, uri string }{{"backchannel_logout_uri", cl.BackchannelLogoutURI}, {"frontchannel_logout_uri", cl.FrontchannelLogoutURI}} {
+			if u, err := url.Parse(lu.uri); lu.uri != "" && (err != nil || !u.IsAbs() || u.Fragment != "") {
+				fail("%s: %s %q must be absolute without fragment", where, lu.name, lu.uri)
+			}
+		}
+		if !validIdleTimeout(cl.SessionIdleTimeout) {
+			fail("%s: session_idle_timeout must be 0 or at least %s", where, minSessionIdleTimeout)
+		}
+		if cl.SessionMaxLifetime < 0 {
+			fail("%s: session_max_lifetime must not be negative", where)
+		}
+		if cl.FrontchannelLogoutSessionRequired && cl.FrontchannelLogoutURI == "" {
+			fail("%s: frontchannel_logout_session_required needs frontchannel_logout_uri", where)
+		}
+		for _, sa := range []struct{ name, alg string }{{"id_token_signed_response_alg", cl.IDTokenSignedResponseAlg}, {"userinfo_signed_response_alg", cl.UserinfoSignedResponseAlg}} {
+			if sa.alg != "" && sa.alg != dservice.DefaultSigningAlg && !slices.Contains(signingAlgs, sa.alg) {
+				fail("%s: %s %q is not a managed signing alg", where, sa.name, sa.alg)
+			}
+		}
+		if cl.TokenEndpointAuthMethod != "" {
+			if _, err := enum.ParseClientAuthMethod(cl.TokenEndpointAuthMethod); err != nil {
+				fail("%s: %v", where, err)
+			}
+		}
+		switch {
+		case cl.Public && cl.ClientSecret != "":
+			fail("%s: public clients must not have a client_secret", where)
+		case !cl.Public && cl.ClientSecret == "" && cl.TokenEndpointAuthMethod != "private_key_jwt":
+			fail("%s: confidential clients need a client_secret (or token_endpoint_auth_method private_key_jwt)", where)
+		case cl.TokenEndpointAuthMethod == "private_key_jwt" && cl.JWKS == "":
+			fail("%s: private_key_jwt requires jwks", where)
+		}
+		switch {
+		case cl.IDTokenEncryptedResponseAlg == "" && cl.IDTokenEncryptedResponseEnc != "":
+			fail("%s: id_token_encrypted_response_enc requires id_token_encrypted_response_alg", where)
+		case cl.IDTokenEncryptedResponseAlg == "":
+		case !slices.Contains(dservice.SupportedEncryptionAlgs, cl.IDTokenEncryptedResponseAlg):
+			fail("%s: unsupported id_token_encrypted_response_alg %q", where, cl.IDTokenEncryptedResponseAlg)
+		case cl.IDTokenEncryptedResponseEnc != "" && !slices.Contains(dservice.SupportedEncryptionEncs, cl.IDTokenEncryptedResponseEnc):
+			fail("%s: unsupported id_token_encrypted_response_enc %q", where, cl.IDTokenEncryptedResponseEnc)
+		case cl.JWKS == "":
+			fail("%s: id_token_encrypted_response_alg requires jwks", where)
+		}
+	}
+
+	for i, s := range c.Scopes {
+		if s.Name == "" || strings.ContainsAny(s.Name, " \t\n\"\\") {
+			fail("scopes[%d]: name must be a non-empty scope token", i)
+		}
+	}
+
+	seenUsers := map[string]bool{}
+	for i, u := range c.Users {
+		where := fmt.Sprintf("users[%d]", i)
+		email, err := vo.NewEmail(u.Email)
+		if err != nil {
+			fail("%s: valid email required", where)
+		} else if seenUsers[email.String()] {
+			fail("%s: duplicate email %q", where, u.Email)
+		}
+		seenUsers[email.String()] = true
+		if u.Password == "" {
+			fail("%s: password required", where)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sso.yaml")
+	if err := os.WriteFile(path, []byte("issuer: https://sso.example.com\naccess_token_ttl: 5m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PathEnv, path)
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Issuer != "https://sso.example.com" || cfg.AccessTokenTTL != 5*time.Minute || cfg.RefreshTokenTTL != Default().RefreshTokenTTL {
+		t.Fatalf("unexpected %+v", cfg)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Fatal("missing file must be an error")
+	}
+	if _, err := Parse([]byte("acces_token_ttl: 5m")); err == nil {
+		t.Fatal("unknown key must be rejected")
+	}
+	if cfg, err := Parse(nil); err != nil || cfg.HTTPAddr != ":8080" {
+		t.Fatalf("empty file: %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"PORT":                      "9000",
+		"SSO_ISSUER":                "https://env.example.com",
+		"SSO_SESSION_TTL":           "2h",
+		"SSO_RATE_LIMIT_TOKEN_RPM":  "30",
+		"SSO_SIGNING_ALGS":          "RS256,ES256",
+		"SSO_LOGOUT_REVOKES_TOKENS": "true",
+		"SSO_CLIENT_SECRET_MY_RP":   "from-env",
+		"SSO_CLIENT_SECRET_OTHER":   "",
+		"SSO_ACCESS_TOKEN_TTL":      "",
+	}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+	cfg := Default()
+	cfg.Clients = []ClientConfig{{ClientID: "my-rp", ClientSecret: "from-file"}, {ClientID: "other", ClientSecret: "kept"}}
+	if err := cfg.applyEnv(lookup); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTPAddr != ":9000" || cfg.Issuer != "https://env.example.com" || cfg.SessionTTL != 2*time.Hour || cfg.RateLimitTokenRPM != 30 || !cfg.LogoutRevokesTokens {
+		t.Fatalf("scalars not overridden: %+v", cfg)
+	}
+	if len(cfg.SigningAlgs) != 2 || cfg.SigningAlgs[1] != "ES256" || cfg.AccessTokenTTL != Default().AccessTokenTTL {
+		t.Fatalf("algs %v, access ttl %s", cfg.SigningAlgs, cfg.AccessTokenTTL)
+	}
+	if cfg.Clients[0].ClientSecret != "from-env" || cfg.Clients[1].ClientSecret != "kept" {
+		t.Fatalf("secrets %+v", cfg.Clients)
+	}
+
+	env = map[string]string{"SSO_SESSION_TTL": "forever", "SSO_RATE_LIMIT_TOKEN_RPM": "many"}
+	err := Default().applyEnv(lookup)
+	if err == nil || !strings.Contains(err.Error(), "SSO_SESSION_TTL") || !strings.Contains(err.Error(), "SSO_RATE_LIMIT_TOKEN_RPM") {
+		t.Fatalf("err = %v, want both bad variables reported", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want []string // substrings of the error
+	}{
+		{name: "issuer with trailing slash", yaml: `issuer: https://sso.example.com/`, want: []string{"issuer must be"}},
+		{name: "bad trusted proxy", yaml: `trusted_proxies: [10.0.0.0/8, proxy.local]`, want: []string{`"proxy.local" is not a CIDR`}},
+		{name: "no issuer", yaml: `issuer: ""`, want: []string{"issuer required unless trusted_proxies and allowed_hosts"}},
+		{name: "no issuer without allowed hosts", yaml: "issuer: \"\"\ntrusted_proxies: [10.0.0.0/8]", want: []string{"issuer required unless"}},
+		{name: "bad allowed host", yaml: "issuer: \"\"\ntrusted_proxies: [10.0.0.0/8]\nallowed_hosts: [sso.example.com, evil.example.com/x]", want: []string{`allowed_hosts: "evil.example.com/x" is not a host`}},
+		{name: "non-positive ttl", yaml: `access_token_ttl: 0s`, want: []string{"access_token_ttl must be positive"}},
+		{name: "short idle timeout", yaml: `session_idle_timeout: 1m`, want: []string{"session_idle_timeout must be 0 or at least"}},
+		{name: "overlap too long", yaml: "key_rotation_interval: 1h\nactive_key_overlap: 2h", want: []string{"active_key_overlap"}},
+		{name: "overlap shorter than token lifetime", yaml: "access_token_ttl: 2h\nactive_key_overlap: 1h", want: []string{"active_key_overlap must be at least access_token_ttl"}},
+		{name: "client problems reported together", yaml: `
+clients:
+  - client_id: rp
+    redirect_uris: ["/relative"]
+  - client_id: rp
+    public: true
+    client_secret: s
+`, want: []string{
+			`redirect_uri "/relative" must be absolute`,
+			"confidential clients need a client_secret",
+			`clients[1]: duplicate client_id "rp"`,
+			"clients[1]: at least one redirect_uri required",
+			"public clients must not have a client_secret",
+		}},
+		{name: "user problems", yaml: `
+users:
+  - email: not-an-email
+    password: pw
+  - email: a@example.com
+  - email: A@example.com
+    password: pw
+`, want: []string{"users[0]: valid email required", "users[1]: password required", `users[2]: duplicate email`}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.yaml))
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q lacks %q", err, w)
+				}
+			}
+		})
+	}
+	t.Run("issuer derived behind trusted proxies", func(t *testing.T) {
+		if _, err := Parse([]byte("issuer: \"\"\ntrusted_proxies: [10.0.0.0/8]\nallowed_hosts: [sso.example.com]")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestValidateSigningAlgs(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want string // substring of the error; empty expects a valid config
+	}{
+		{name: "every alg managed by default", yaml: `
+clients:
+  - client_id: rp
+    client_secret: s
+    redirect_uris: ["https://rp.example.com/cb"]
+    id_token_signed_response_alg: EdDSA
+`},
+		{name: "default alg always managed", yaml: `
+signing_algs: [ES256]
+clients:
+  - client_id: rp
+    client_secret: s
+    redirect_uris: ["https://rp.example.com/cb"]
+    id_token_signed_response_alg: RS256
+`},
+		{name: "unsupported signing alg", yaml: `signing_algs: [HS256]`, want: `signing_algs: unsupported alg "HS256"`},
+		{name: "unsupported client alg", yaml: `
+clients:
+  - client_id: rp
+    client_secret: s
+    redirect_uris: ["https://rp.example.com/cb"]
+    id_token_signed_response_alg: HS256
+`, want: `id_token_signed_response_alg "HS256" is not a managed signing alg`},
+		{name: "client alg not managed", yaml: `
+signing_algs: [ES256]
+clients:
+  - client_id: rp
+    client_secret: s
+    redirect_uris: ["https://rp.example.com/cb"]
+    userinfo_signed_response_alg: PS256
+`, want: `userinfo_signed_response_alg "PS256" is not a managed signing alg`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.yaml))
+			if tc.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
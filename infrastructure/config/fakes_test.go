@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+// fakeHasher "hashes" by prefixing and counts its calls.
+type fakeHasher struct{ calls int }
+
+func (h *fakeHasher) HashPassword(plain string) (string, error) {
+	h.calls++
+	return "hash:" + plain, nil
+}
+
+// fakeAuth verifies against the fakeHasher form of the stored hashes; err, when set, fails
+// every lookup as a broken database would.
+type fakeAuth struct {
+	clients *testfake.Clients
+	users   *testfake.Users
+	err     error
+}
+
+func (a *fakeAuth) VerifyUserPassword(ctx context.Context, userID uuid.UUID, password string) (bool, error) {
+	if a.err != nil {
+		return false, a.err
+	}
+	u, _ := a.users.GetByID(ctx, userID)
+	return u != nil && u.PasswordHash == "hash:"+password, nil
+}
+
+func (a *fakeAuth) VerifyClientSecret(ctx context.Context, clientID, secret string) (bool, error) {
+	if a.err != nil {
+		return false, a.err
+	}
+	c, _ := a.clients.GetByClientID(ctx, clientID)
+	return c != nil && c.HashedSecret == "hash:"+secret, nil
+}
+
+func (a *fakeAuth) ValidatePKCE(_, _, _ string) error { return errors.New("not used") }
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// PasswordHasher hashes user passwords and client secrets for storage.
+type PasswordHasher interface{ HashPassword(string) (string, error) }
+
+// userIDNamespace derives stable user UUIDs from non-UUID ids such as "u1".
+var userIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("sso:config:user"))
+
+// Reconcile makes the declared clients and users exist in the repositories. It is idempotent:
+// existing records are updated in place and keep their IDs, and secrets or passwords are only
+// re-hashed when the stored hash no longer matches. Records missing from the config are left alone.
+func Reconcile(ctx context.Context, cfg *Config, clients repository.ClientRepository, users repository.UserRepository, auth dservice.AuthService, hasher PasswordHasher) error {
+	for _, cc := range cfg.Clients {
+		if err := reconcileClient(ctx, cc, clients, auth, hasher); err != nil {
+			return fmt.Errorf("client %s: %w", cc.ClientID, err)
+		}
+	}
+	for _, uc := range cfg.Users {
+		if err := reconcileUser(ctx, uc, users, auth, hasher); err != nil {
+			return fmt.Errorf("user %s: %w", uc.Email, err)
+		}
+	}
+	return nil
+}
+
+func reconcileClient(ctx context.Context, cc ClientConfig, clients repository.ClientRepository, auth dservice.AuthService, hasher PasswordHasher) error {
+	existing, err := clients.GetByClientID(ctx, cc.ClientID)
+	if err != nil {
+		return err
+	}
+	name := cc.Name
+	if name == "" {
+		name = cc.ClientID
+	}
+	if existing == nil {
+		hashed := ""
+		if cc.ClientSecret != "" {
+			if hashed, err = hasher.HashPassword(cc.ClientSecret); err != nil {
+				return err
+			}
+		}
+		c, err := entity.NewClient(cc.ClientID, name, hashed, cc.RedirectURIs, cc.Scopes, !cc.Public, cc.Public)
+		if err != nil {
+			return err
+		}
+		applyClientMetadata(c, cc)
+		return clients.Create(ctx, c)
+	}
+	existing.Name = name
+	existing.RedirectURIs = cc.RedirectURIs
+	existing.Scopes = cc.Scopes
+	existing.Confidential = !cc.Public
+	existing.PKCERequired = cc.Public
+	applyClientMetadata(existing, cc)
+	switch {
+	case cc.ClientSecret == "":
+		existing.HashedSecret = ""
+	case existing.HashedSecret == "":
+		if existing.HashedSecret, err = hasher.HashPassword(cc.ClientSecret); err != nil {
+			return err
+		}
+	default:
+		// VerifyClientSecret reads the stored hash, so check before overwriting it. A lookup
+		// failure is not a changed secret and must not trigger a re-hash.
+		ok, err := auth.VerifyClientSecret(ctx, cc.ClientID, cc.ClientSecret)
+		if err != nil {
+			return fmt.Errorf("verify secret: %w", err)
+		}
+		if !ok {
+			if existing.HashedSecret, err = hasher.HashPassword(cc.ClientSecret); err != nil {
+				return err
+			}
+		}
+	}
+	existing.Touch()
+	return clients.Update(ctx, existing)
+}
+
+func applyClientMetadata(c *entity.Client, cc ClientConfig) {
+	c.TokenEndpointAuthMethod = enum.ClientAuthMethod(cc.TokenEndpointAuthMethod)
+	c.IDTokenSignedResponseAlg = cc.IDTokenSignedResponseAlg
+	c.IDTokenEncryptedResponseAlg = cc.IDTokenEncryptedResponseAlg
+	c.IDTokenEncryptedResponseEnc = cc.IDTokenEncryptedResponseEnc
+	c.UserinfoSignedResponseAlg = cc.UserinfoSignedResponseAlg
+	c.PostLogoutRedirectURIs = cc.PostLogoutRedirectURIs
+	c.BackchannelLogoutURI = cc.BackchannelLogoutURI
+	c.FrontchannelLogoutURI = cc.FrontchannelLogoutURI
+	c.FrontchannelLogoutSessionRequired = cc.FrontchannelLogoutSessionRequired
+	c.SessionIdleTimeout = cc.SessionIdleTimeout
+	c.SessionMaxLifetime = cc.SessionMaxLifetime
+	c.JWKS = cc.JWKS
+	c.FirstParty = cc.FirstParty
+}
+
+func reconcileUser(ctx context.Context, uc UserConfig, users repository.UserRepository, auth dservice.AuthService, hasher PasswordHasher) error {
+	email := strings.ToLower(strings.TrimSpace(uc.Email))
+	existing, err := users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		hashed, err := hasher.HashPassword(uc.Password)
+		if err != nil {
+			return err
+		}
+		u, err := entity.NewUser(email, hashed)
+		if err != nil {
+			return err
+		}
+		if uc.ID != "" {
+			u.ID = configUserID(uc.ID)
+		}
+		u.Profile = profileFromConfig(uc.Profile)
+		return users.Create(ctx, u)
+	}
+	profile := profileFromConfig(uc.Profile)
+	passwordOK, err := auth.VerifyUserPassword(ctx, existing.ID, uc.Password)
+	if err != nil {
+		return fmt.Errorf("verify password: %w", err)
+	}
+	if passwordOK && existing.Profile == profile {
+		return nil
+	}
+	if !passwordOK {
+		if existing.PasswordHash, err = hasher.HashPassword(uc.Password); err != nil {
+			return err
+		}
+	}
+	existing.Profile = profile
+	existing.Touch()
+	return users.Update(ctx, existing)
+}
+
+// profileFromConfig converts a declared profile into the domain form.
+func profileFromConfig(p ProfileConfig) entity.UserProfile {
+	a := p.Address
+	return entity.UserProfile{
+		Name: p.Name, GivenName: p.GivenName, FamilyName: p.FamilyName, Picture: p.Picture,
+		Locale: p.Locale, Zoneinfo: p.Zoneinfo, PhoneNumber: p.PhoneNumber, PhoneNumberVerified: p.PhoneNumberVerified,
+		Address: entity.Address{Formatted: a.Formatted, StreetAddress: a.StreetAddress, Locality: a.Locality, Region: a.Region, PostalCode: a.PostalCode, Country: a.Country},
+	}
+}
+
+// configUserID uses id as-is when it is a UUID and derives a stable UUID from it otherwise.
+func configUserID(id string) uuid.UUID {
+	if parsed, err := uuid.Parse(id); err == nil {
+		return parsed
+	}
+	return uuid.NewSHA1(userIDNamespace, []byte(id))
+}
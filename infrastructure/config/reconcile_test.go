@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+const reconcileYAML = `
+clients:
+  - client_id: rp
+    client_secret: first
+    redirect_uris: ["https://rp.example.com/cb"]
+  - client_id: spa
+    public: true
+    redirect_uris: ["https://spa.example.com/cb"]
+users:
+  - id: u1
+    email: Alice@Example.com
+    password: pw
+    profile: {name: Alice}
+`
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := Parse([]byte(reconcileYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients, users, hasher := testfake.NewClients(), testfake.NewUsers(), &fakeHasher{}
+	auth := &fakeAuth{clients: clients, users: users}
+	run := func() {
+		t.Helper()
+		if err := Reconcile(ctx, cfg, clients, users, auth, hasher); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run()
+	rp := clients.ByClientID["rp"]
+	alice := users.ByEmail["alice@example.com"]
+	if rp == nil || !rp.Confidential || rp.HashedSecret != "hash:first" || clients.ByClientID["spa"] == nil || clients.ByClientID["spa"].HashedSecret != "" {
+		t.Fatalf("clients not created: %+v", clients.ByClientID)
+	}
+	if alice == nil || alice.PasswordHash != "hash:pw" || alice.Profile.Name != "Alice" {
+		t.Fatalf("user not created: %+v", users.ByEmail)
+	}
+
+	t.Run("config user ids are stable", func(t *testing.T) {
+		if alice.ID != configUserID("u1") || configUserID("u1") != configUserID("u1") || configUserID("u1") == configUserID("u2") {
+			t.Fatalf("id %s", alice.ID)
+		}
+		id := uuid.New()
+		if configUserID(id.String()) != id {
+			t.Fatal("a UUID id must be used as-is")
+		}
+	})
+	t.Run("second run changes nothing", func(t *testing.T) {
+		userWrites, hashes := users.Writes, hasher.calls
+		run()
+		if hasher.calls != hashes || users.Writes != userWrites {
+			t.Fatalf("re-hashed %d, user writes %d", hasher.calls-hashes, users.Writes-userWrites)
+		}
+		if got := clients.ByClientID["rp"]; got.ID != rp.ID || got.HashedSecret != rp.HashedSecret {
+			t.Fatalf("client changed: %+v", got)
+		}
+		if got := users.ByEmail["alice@example.com"]; got.ID != alice.ID || got.PasswordHash != alice.PasswordHash {
+			t.Fatalf("user changed: %+v", got)
+		}
+	})
+	t.Run("changed secret is re-hashed", func(t *testing.T) {
+		cfg.Clients[0].ClientSecret = "second"
+		cfg.Users[0].Password = "pw2"
+		defer func() { cfg.Clients[0].ClientSecret, cfg.Users[0].Password = "first", "pw" }()
+		hashes := hasher.calls
+		run()
+		if hasher.calls != hashes+2 || clients.ByClientID["rp"].HashedSecret != "hash:second" || users.ByEmail["alice@example.com"].PasswordHash != "hash:pw2" {
+			t.Fatalf("re-hashed %d", hasher.calls-hashes)
+		}
+		if clients.ByClientID["rp"].ID != rp.ID || users.ByEmail["alice@example.com"].ID != alice.ID {
+			t.Fatal("ids changed")
+		}
+	})
+	t.Run("verification errors are not changed secrets", func(t *testing.T) {
+		auth.err = errors.New("db down")
+		defer func() { auth.err = nil }()
+		before, hashes := clients.ByClientID["rp"].HashedSecret, hasher.calls
+		if err := Reconcile(ctx, cfg, clients, users, auth, hasher); !errors.Is(err, auth.err) {
+			t.Fatalf("err = %v", err)
+		}
+		if hasher.calls != hashes || clients.ByClientID["rp"].HashedSecret != before {
+			t.Fatal("secret re-hashed on a verification error")
+		}
+	})
+}
@@ -1,53 +1,280 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
 	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
 	"github.com/google/uuid"
 )
 
 type AuthorizeHandler struct {
 	Start    usecase.StartAuthorization
 	Sessions repository.SessionRepository
+	CodeTTL  time.Duration // authorization code lifetime; the usecase default applies when zero
+	// LoginPath is the hosted login page users without a session are sent to. When empty the
+	// request fails with login_required instead.
+	LoginPath string
+	// Consents and Templates drive the consent screen. Without templates a missing consent fails
+	// with consent_required.
+	Consents  usecase.Consents
+	Templates *ui.Templates
+	Scopes    dservice.ScopeRegistry // scope descriptions for the consent screen
+	Tokens    dservice.TokenService  // verifies id_token_hint; the hint is ignored when nil
+	Issuer    *IssuerResolver        // the issuer an id_token_hint must come from; its path prefixes our URLs
+	// Clients supplies per-client session policies; without it only the deployment's applies.
+	Clients repository.ClientRepository
+	// Activity slides the expiry of sessions used here; nil keeps expiry fixed at login.
+	Activity dservice.SessionActivity
 }
 
-// For now returns JSON instead of redirect for easier testing. Later will 302 to redirect_uri with code & state.
+// authorizePath is where the consent form posts back to.
+const authorizePath = "/authorize"
+
+// ServeHTTP answers the authorization request with a redirect to the client's redirect_uri in the
+// requested response_mode. Problems with client_id or redirect_uri are shown to the user agent
+// instead, since the redirect target cannot be trusted.
 func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	userID := ""
-	if c, err := r.Cookie("sid"); err == nil {
-		if sid, err := uuid.Parse(c.Value); err == nil && h.Sessions != nil {
-			sess, _ := h.Sessions.Get(r.Context(), sid)
-			if sess != nil && !sess.Revoked && !sess.IsExpired(time.Now().UTC()) {
-				userID = sess.UserID.String()
-			}
-		}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "GET or POST required", "")
+		return
 	}
-	if userID == "" { // require authenticated session for now
-		writeOAuthError(w, http.StatusUnauthorized, "login_required", "End-user authentication required", q.Get("state"))
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed request", "")
 		return
 	}
+	q := r.Form
 	in := usecase.StartAuthInput{
 		ResponseType:        q.Get("response_type"),
+		ResponseMode:        q.Get("response_mode"),
 		ClientID:            q.Get("client_id"),
 		RedirectURI:         q.Get("redirect_uri"),
 		Scope:               q.Get("scope"),
 		State:               q.Get("state"),
 		CodeChallenge:       q.Get("code_challenge"),
 		CodeChallengeMethod: q.Get("code_challenge_method"),
-		UserID:              userID,
+		Nonce:               q.Get("nonce"),
+		CodeTTL:             h.CodeTTL,
+	}
+	if err := h.Start.Validate(r.Context(), in); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidClient):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Unknown client_id", in.State)
+		case errors.Is(err, usecase.ErrInvalidRedirectURI):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "redirect_uri not registered for client", in.State)
+		default:
+			log.Printf("authorize validate error: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Authorization failed", in.State)
+		}
+		return
+	}
+	mode := defaultResponseMode(in.ResponseMode)
+	if !isSupportedResponseMode(mode) {
+		writeAuthorizationError(w, r, in.RedirectURI, responseModeQuery, "invalid_request", "Unsupported response_mode", in.State)
+		return
+	}
+	in.ResponseMode = mode
+	claims, err := vo.ParseClaimsRequest(q.Get("claims"))
+	if err != nil {
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "invalid_request", "Malformed claims parameter", in.State)
+		return
+	}
+	in.Claims = claims
+	scopes, err := h.Start.GrantedScopes(r.Context(), in)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidScope) {
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "invalid_scope", "Requested scope is unknown or not allowed for this client", in.State)
+			return
+		}
+		log.Printf("authorize scope error: %v", err)
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "server_error", "Authorization failed", in.State)
+		return
+	}
+	// Claims requested beyond the granted scopes need the user's approval of their scopes too.
+	claimScopes, err := h.Start.ClaimScopes(r.Context(), in)
+	if err != nil {
+		log.Printf("authorize claims error: %v", err)
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "server_error", "Authorization failed", in.State)
+		return
+	}
+	scopes = append(scopes, claimScopes...)
+
+	prompt, err := parseAuthPrompt(q)
+	if err != nil {
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "invalid_request", err.Error(), in.State)
+		return
+	}
+	hintSubject, err := idTokenHintSubject(r.Context(), h.Tokens, q.Get("id_token_hint"), in.ClientID, resolveIssuer(h.Issuer, r))
+	if err != nil {
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "invalid_request", "Invalid id_token_hint", in.State)
+		return
+	}
+
+	sess := sessionFor(r, h.Sessions)
+	now := time.Now().UTC()
+	if sess != nil {
+		ok, err := h.clientAccepts(r.Context(), in.ClientID, sess, now)
+		if err != nil {
+			log.Printf("authorize client session policy error: %v", err)
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "server_error", "Authorization failed", in.State)
+			return
+		}
+		if !ok {
+			sess = nil
+		}
+	}
+	if !prompt.satisfiedBy(sess, hintSubject, now) {
+		if h.LoginPath != "" && !prompt.none() && !prompt.wrongUser(sess, hintSubject, now) {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, loginRedirect(h.Issuer.Path(), h.LoginPath, withoutReauth(q)), http.StatusFound)
+			return
+		}
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "login_required", "End-user authentication required", in.State)
+		return
+	}
+	if h.Activity != nil {
+		h.Activity.Seen(sess, now)
+	}
+	in.UserID = sess.UserID.String()
+	in.AuthTime = sess.AuthTime()
+	in.SessionID = sess.ID.String()
+	if done := h.handleConsent(w, r, in, mode, scopes, prompt); done {
+		return
 	}
 	res, err := h.Start.Execute(r.Context(), in)
 	if err != nil {
-		log.Printf("authorize error: %v", err)
-		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error(), q.Get("state"))
+		switch {
+		case errors.Is(err, usecase.ErrUnsupportedResponseType):
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "unsupported_response_type", "Only response_type=code is supported", in.State)
+		case errors.Is(err, usecase.ErrUnmetAuthenticationRequirements):
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "unmet_authentication_requirements", "Requested acr cannot be satisfied", in.State)
+		case errors.Is(err, usecase.ErrConsentRequired):
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "consent_required", "End-user consent required", in.State)
+		case errors.Is(err, usecase.ErrInvalidScope):
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "invalid_scope", "Requested scope not allowed", in.State)
+		default:
+			log.Printf("authorize error: %v", err)
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "server_error", "Authorization failed", in.State)
+		}
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"code":"` + res.Code + `","state":"` + res.State + `"}`))
+	params := url.Values{"code": {res.Code}}
+	if res.State != "" {
+		params.Set("state", res.State)
+	}
+	// Sessions from before session management was enabled have no browser state cookie yet.
+	if c, err := r.Cookie(browserStateCookie); err != nil || c.Value != browserState(sess.ID.String()) {
+		setBrowserStateCookie(w, r, browserState(sess.ID.String()), 0, sess.ExpiresAt)
+	}
+	params.Set("session_state", sessionState(in.ClientID, in.RedirectURI, sess.ID.String()))
+	writeAuthorizationResponse(w, r, in.RedirectURI, mode, params)
+}
+
+// handleConsent records a submitted consent decision and shows the consent screen while scopes are
+// still unapproved. It reports whether a response has been written.
+// Consent is asked for the granted scopes, which may be narrower than the request.
+// With prompt=none a missing consent is an error rather than a screen; prompt=consent asks again
+// for every scope even when all were approved before.
+func (h *AuthorizeHandler) handleConsent(w http.ResponseWriter, r *http.Request, in usecase.StartAuthInput, mode string, scopes []string, prompt authPrompt) bool {
+	if h.Consents == nil {
+		return false
+	}
+	userID, err := uuid.Parse(in.UserID)
+	if err != nil {
+		return false
+	}
+	if decision := r.PostForm.Get("consent"); r.Method == http.MethodPost && decision != "" {
+		if !validCSRF(r) {
+			http.Error(w, "invalid csrf token", http.StatusForbidden)
+			return true
+		}
+		if decision != "allow" {
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "access_denied", "End-user denied the request", in.State)
+			return true
+		}
+		if err := h.Consents.Grant(r.Context(), userID, in.ClientID, scopes); err != nil {
+			log.Printf("authorize consent grant error: %v", err)
+			writeAuthorizationError(w, r, in.RedirectURI, mode, "server_error", "Authorization failed", in.State)
+			return true
+		}
+		return false
+	}
+	check, err := h.Consents.Check(r.Context(), userID, in.ClientID, scopes)
+	if err != nil {
+		log.Printf("authorize consent check error: %v", err)
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "server_error", "Authorization failed", in.State)
+		return true
+	}
+	if prompt.forceConsent() {
+		check.Missing = scopes
+	}
+	if len(check.Missing) == 0 {
+		return false
+	}
+	if h.Templates == nil || prompt.none() {
+		writeAuthorizationError(w, r, in.RedirectURI, mode, "consent_required", "End-user consent required", in.State)
+		return true
+	}
+	action := h.Issuer.Path() + authorizePath
+	token, err := csrfToken(w, r, action)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return true
+	}
+	params := url.Values{}
+	for k, vs := range r.Form {
+		if k != "csrf_token" && k != "consent" {
+			params[k] = vs
+		}
+	}
+	page := struct {
+		Action, CSRFToken, ClientName string
+		Scopes                        []dservice.ScopeDefinition
+		Params                        url.Values
+	}{action, token, check.ClientName, h.describeScopes(check.Missing), params}
+	if err := h.Templates.Render(w, http.StatusOK, "consent.html", page); err != nil {
+		log.Printf("authorize consent render: %v", err)
+	}
+	return true
+}
+
+// describeScopes pairs scope names with their registry descriptions for display.
+func (h *AuthorizeHandler) describeScopes(names []string) []dservice.ScopeDefinition {
+	out := make([]dservice.ScopeDefinition, 0, len(names))
+	for _, n := range names {
+		def := dservice.ScopeDefinition{Name: n}
+		if h.Scopes != nil {
+			if d, ok := h.Scopes.Lookup(n); ok {
+				def = d
+			}
+		}
+		out = append(out, def)
+	}
+	return out
+}
+
+// clientAccepts applies the client's own session policy (step-up): a session idle or older than the
+// client allows counts as no session, so the user logs in again.
+func (h *AuthorizeHandler) clientAccepts(ctx context.Context, clientID string, sess *entity.Session, now time.Time) (bool, error) {
+	if h.Clients == nil {
+		return true, nil
+	}
+	cli, err := h.Clients.GetByClientID(ctx, clientID)
+	if err != nil {
+		return false, err
+	}
+	if cli == nil {
+		return true, nil // Validate has already rejected unknown clients
+	}
+	return cli.SessionPolicy().Allows(sess.CreatedAt, sess.LastSeenAt, now), nil
 }
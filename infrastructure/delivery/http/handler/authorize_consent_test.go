@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestAuthorizeConsent(t *testing.T) {
+	templates, err := ui.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := NewIssuerResolver("https://sso.example.com", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	consentKey := sess.UserID.String() + "/rp"
+	setup := func(granted ...string) (*AuthorizeHandler, *fakeStart, *fakeConsents) {
+		start, consents := &fakeStart{}, &fakeConsents{granted: map[string][]string{consentKey: granted}}
+		return &AuthorizeHandler{Start: start, Sessions: testfake.NewSessions(sess), Consents: consents, Templates: templates, Issuer: issuer}, start, consents
+	}
+	params := func(extra url.Values) url.Values {
+		q := url.Values{"response_type": {"code"}, "client_id": {"rp"}, "redirect_uri": {"https://rp.example.com/cb"}, "scope": {"openid"}, "state": {"s1"}}
+		for k, vs := range extra {
+			q[k] = vs
+		}
+		return q
+	}
+	get := func(h *AuthorizeHandler, extra url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/authorize?"+params(extra).Encode(), nil)
+		r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	// decide posts a consent decision with the given form token against the given CSRF cookie.
+	decide := func(h *AuthorizeHandler, decision, cookie, token string) *httptest.ResponseRecorder {
+		form := params(url.Values{"consent": {decision}, "csrf_token": {token}})
+		r := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	callback := func(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+		t.Helper()
+		if w.Code != http.StatusFound {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		u, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || u.Host != "rp.example.com" || u.Query().Get("state") != "s1" {
+			t.Fatalf("Location %q", w.Header().Get("Location"))
+		}
+		return u.Query()
+	}
+
+	t.Run("missing consent renders the consent page", func(t *testing.T) {
+		h, start, _ := setup()
+		w := get(h, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="csrf_token"`) || len(start.issued) != 0 {
+			t.Fatalf("%d issued %d\n%s", w.Code, len(start.issued), w.Body)
+		}
+	})
+	t.Run("granted consent skips the page", func(t *testing.T) {
+		h, _, _ := setup("openid")
+		if q := callback(t, get(h, nil)); q.Get("code") == "" {
+			t.Fatalf("callback %v", q)
+		}
+	})
+	t.Run("prompt=consent asks again", func(t *testing.T) {
+		h, _, _ := setup("openid")
+		if w := get(h, url.Values{"prompt": {"consent"}}); w.Code != http.StatusOK {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("prompt=none without consent", func(t *testing.T) {
+		h, start, _ := setup()
+		if q := callback(t, get(h, url.Values{"prompt": {"none"}})); q.Get("error") != "consent_required" || len(start.issued) != 0 {
+			t.Fatalf("callback %v", q)
+		}
+	})
+	t.Run("allow records the grant", func(t *testing.T) {
+		h, _, consents := setup()
+		if q := callback(t, decide(h, "allow", "tok", "tok")); q.Get("code") == "" {
+			t.Fatalf("callback %v", q)
+		}
+		if got := consents.granted[consentKey]; len(got) != 1 || got[0] != "openid" {
+			t.Fatalf("granted %v", got)
+		}
+	})
+	t.Run("deny", func(t *testing.T) {
+		h, start, consents := setup()
+		if q := callback(t, decide(h, "deny", "tok", "tok")); q.Get("error") != "access_denied" || len(start.issued) != 0 {
+			t.Fatalf("callback %v", q)
+		}
+		if len(consents.granted[consentKey]) != 0 {
+			t.Fatal("denied consent was recorded")
+		}
+	})
+	for name, cookie := range map[string]string{"mismatched csrf token": "other", "missing csrf cookie": ""} {
+		t.Run(name, func(t *testing.T) {
+			h, start, consents := setup()
+			if w := decide(h, "allow", cookie, "tok"); w.Code != http.StatusForbidden || len(start.issued) != 0 || len(consents.granted[consentKey]) != 0 {
+				t.Fatalf("%d issued %d granted %v", w.Code, len(start.issued), consents.granted)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+// TestAuthorizeFlowUnderIssuerPath walks authorize → login → consent with routes mounted below
+// the issuer path, through a cookie jar so cookie paths are honoured as a browser would.
+func TestAuthorizeFlowUnderIssuerPath(t *testing.T) {
+	templates, err := ui.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := NewIssuerResolver("https://sso.example.com/sso", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := testfake.NewSessions()
+	mux := http.NewServeMux()
+	mux.Handle("/sso"+authorizePath, &AuthorizeHandler{
+		Start:     &fakeStart{},
+		Sessions:  sessions,
+		LoginPath: LoginPagePath,
+		Consents:  &fakeConsents{},
+		Templates: templates,
+		Issuer:    issuer,
+	})
+	mux.Handle("/sso"+LoginPagePath, &LoginPageHandler{
+		LoginUC:   &fakeLogin{passwords: map[string]string{"alice@example.com": "pw"}, users: map[string]uuid.UUID{"alice@example.com": uuid.New()}},
+		SessionUC: &fakeCreateSession{store: sessions},
+		Templates: templates,
+		Issuer:    issuer,
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := srv.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// send performs one request and returns the status, Location and body.
+	send := func(method, target string, form url.Values) (int, string, string) {
+		t.Helper()
+		var resp *http.Response
+		var err error
+		if method == http.MethodPost {
+			resp, err = client.PostForm(srv.URL+target, form)
+		} else {
+			resp, err = client.Get(srv.URL + target)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, resp.Header.Get("Location"), string(body)
+	}
+	formAction := regexp.MustCompile(`<form method="post" action="([^"]*)"`)
+	hidden := regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)">`)
+	// form returns the form action and hidden fields of a rendered page.
+	form := func(body string) (string, url.Values) {
+		t.Helper()
+		m := formAction.FindStringSubmatch(body)
+		if m == nil {
+			t.Fatalf("no form:\n%s", body)
+		}
+		fields := url.Values{}
+		for _, f := range hidden.FindAllStringSubmatch(body, -1) {
+			fields.Add(html.UnescapeString(f[1]), html.UnescapeString(f[2]))
+		}
+		return html.UnescapeString(m[1]), fields
+	}
+
+	authorize := "/sso/authorize?" + url.Values{
+		"response_type": {"code"},
+		"client_id":     {"rp"},
+		"redirect_uri":  {"https://rp.example.com/cb"},
+		"scope":         {"openid"},
+		"state":         {"s1"},
+	}.Encode()
+	code, loc, body := send(http.MethodGet, authorize, nil)
+	if code != http.StatusFound {
+		t.Fatalf("authorize: %d\n%s", code, body)
+	}
+	login, err := url.Parse(loc)
+	if err != nil || login.Path != "/sso"+LoginPagePath {
+		t.Fatalf("login redirect %q", loc)
+	}
+
+	code, _, body = send(http.MethodGet, login.RequestURI(), nil)
+	if code != http.StatusOK {
+		t.Fatalf("login page: %d\n%s", code, body)
+	}
+	action, fields := form(body)
+	if action != "/sso"+LoginPagePath || fields.Get("csrf_token") == "" {
+		t.Fatalf("login form action %q fields %v", action, fields)
+	}
+	fields.Set("email", "alice@example.com")
+	fields.Set("password", "pw")
+	code, loc, body = send(http.MethodPost, action, fields)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login: %d\n%s", code, body)
+	}
+	if resume, err := url.Parse(loc); err != nil || resume.Path != "/sso"+authorizePath {
+		t.Fatalf("login resumes at %q", loc)
+	}
+
+	code, _, body = send(http.MethodGet, loc, nil)
+	if code != http.StatusOK {
+		t.Fatalf("consent page: %d\n%s", code, body)
+	}
+	action, fields = form(body)
+	if action != "/sso"+authorizePath || fields.Get("csrf_token") == "" || fields.Get("client_id") != "rp" {
+		t.Fatalf("consent form action %q fields %v", action, fields)
+	}
+	fields.Set("consent", "allow")
+	code, loc, body = send(http.MethodPost, action, fields)
+	if code != http.StatusFound {
+		t.Fatalf("consent: %d\n%s", code, body)
+	}
+	cb, err := url.Parse(loc)
+	if err != nil || cb.Host != "rp.example.com" || cb.Query().Get("code") == "" || cb.Query().Get("state") != "s1" {
+		t.Fatalf("callback %q", loc)
+	}
+}
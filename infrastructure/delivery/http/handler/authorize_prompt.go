@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// OIDC prompt values (Core 1.0 §3.1.2.1).
+const (
+	promptNone          = "none"
+	promptLogin         = "login"
+	promptConsent       = "consent"
+	promptSelectAccount = "select_account"
+)
+
+var supportedPromptValues = []string{promptNone, promptLogin, promptConsent, promptSelectAccount}
+
+// reauthParams lists the request parameters that demand a fresh login. They are dropped from the
+// request resumed after the login page, which would otherwise ask for login again forever.
+// id_token_hint is kept: the user who signed in must still be the one it names.
+var reauthParams = []string{"prompt", "max_age"}
+
+// authPrompt holds the parsed prompt and max_age parameters of an authorization request.
+type authPrompt struct {
+	values map[string]bool
+	maxAge time.Duration
+	hasMax bool
+}
+
+// parseAuthPrompt parses the space-delimited prompt and the max_age parameters. "none" cannot be
+// combined with any other prompt value.
+func parseAuthPrompt(q url.Values) (authPrompt, error) {
+	p := authPrompt{values: map[string]bool{}}
+	for _, v := range strings.Fields(q.Get("prompt")) {
+		switch v {
+		case promptNone, promptLogin, promptConsent, promptSelectAccount:
+			p.values[v] = true
+		default:
+			return p, errors.New("unsupported prompt value")
+		}
+	}
+	if p.values[promptNone] && len(p.values) > 1 {
+		return p, errors.New("prompt=none cannot be combined with other values")
+	}
+	if raw := q.Get("max_age"); raw != "" {
+		secs, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || secs < 0 {
+			return p, errors.New("max_age must be a non-negative integer")
+		}
+		p.maxAge, p.hasMax = time.Duration(secs)*time.Second, true
+	}
+	return p, nil
+}
+
+func (p authPrompt) none() bool { return p.values[promptNone] }
+
+// forceLogin reports whether the request asks the user to authenticate again; select_account is
+// treated as login because a browser holds a single session.
+func (p authPrompt) forceLogin() bool { return p.values[promptLogin] || p.values[promptSelectAccount] }
+
+func (p authPrompt) forceConsent() bool { return p.values[promptConsent] }
+
+// satisfiedBy reports whether sess may answer the request without a new login: prompt=login was
+// not requested, the authentication is no older than max_age and, when an id_token_hint names a
+// subject, it is the session's user.
+func (p authPrompt) satisfiedBy(sess *entity.Session, hintSubject string, now time.Time) bool {
+	if sess == nil || p.forceLogin() {
+		return false
+	}
+	if p.hasMax && now.Sub(sess.AuthTime()) > p.maxAge {
+		return false
+	}
+	return hintSubject == "" || hintSubject == sess.UserID.String()
+}
+
+// wrongUser reports whether sess would answer the request but belongs to another user than the
+// id_token_hint names. Such a request fails with login_required instead of going to the login
+// page, which also ends the round-trip when the wrong user signed in.
+func (p authPrompt) wrongUser(sess *entity.Session, hintSubject string, now time.Time) bool {
+	return hintSubject != "" && p.satisfiedBy(sess, "", now) && hintSubject != sess.UserID.String()
+}
+
+// idTokenHintSubject returns the subject of a valid id_token_hint issued by issuer to clientID,
+// or "" when no hint was sent.
+func idTokenHintSubject(ctx context.Context, tokens dservice.TokenService, hint, clientID, issuer string) (string, error) {
+	if hint == "" || tokens == nil {
+		return "", nil
+	}
+	claims, err := tokens.ValidateIDTokenHint(ctx, hint)
+	if err != nil {
+		return "", err
+	}
+	if claims.Issuer != issuer {
+		return "", errors.New("id_token_hint was issued by a different issuer")
+	}
+	for _, aud := range claims.Audience {
+		if aud == clientID {
+			return claims.Subject, nil
+		}
+	}
+	return "", errors.New("id_token_hint was not issued to this client")
+}
+
+// withoutReauth copies the authorization parameters minus those that force re-authentication,
+// keeping prompt=consent when it was combined with login.
+func withoutReauth(q url.Values) url.Values {
+	out := url.Values{}
+	for k, vs := range q {
+		out[k] = vs
+	}
+	for _, k := range reauthParams {
+		out.Del(k)
+	}
+	if strings.Contains(" "+q.Get("prompt")+" ", " "+promptConsent+" ") {
+		out.Set("prompt", promptConsent)
+	}
+	return out
+}
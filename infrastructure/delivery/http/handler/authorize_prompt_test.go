@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestAuthorizeIDTokenHint(t *testing.T) {
+	const issuer = "https://sso.example.com"
+	// No issuer in the token service, as when it is derived per request: the handler checks iss.
+	tokens := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), iservice.TokenValidation{})
+	alice, bob := uuid.New(), uuid.New()
+	aliceSess, _ := entity.NewSession(alice, time.Hour, "", "")
+	bobSess, _ := entity.NewSession(bob, time.Hour, "", "")
+	start := &fakeStart{}
+	issuers, _ := NewIssuerResolver(issuer, nil, nil)
+	h := &AuthorizeHandler{Start: start, Sessions: testfake.NewSessions(aliceSess, bobSess), LoginPath: LoginPagePath, Tokens: tokens, Issuer: issuers}
+
+	hintClaims := func(iss, aud string) vo.JWTClaims {
+		now := time.Now()
+		return vo.JWTClaims{Subject: alice.String(), Issuer: iss, Audience: []string{aud}, ClientID: aud, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	}
+	idTokenFor := func(iss, aud string) string {
+		tok, err := tokens.IssueIDToken(context.Background(), hintClaims(iss, aud), time.Minute, dservice.IDTokenOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	idToken := func(iss string) string { return idTokenFor(iss, "rp") }
+	hint := idToken(issuer)
+	authorizeURL := func(extra url.Values) string {
+		q := url.Values{"response_type": {"code"}, "client_id": {"rp"}, "redirect_uri": {"https://rp.example.com/cb"}, "scope": {"openid"}, "state": {"s1"}, "id_token_hint": {hint}}
+		for k, vs := range extra {
+			q[k] = vs
+		}
+		return "/authorize?" + q.Encode()
+	}
+	do := func(target string, sess *entity.Session) (*url.URL, int) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if sess != nil {
+			r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		loc, _ := url.Parse(w.Header().Get("Location"))
+		return loc, w.Code
+	}
+	// resumed follows the login redirect to the /authorize request it resumes.
+	resumed := func(t *testing.T, loc *url.URL) string {
+		t.Helper()
+		if loc.Path != LoginPagePath {
+			t.Fatalf("not sent to login: %s", loc)
+		}
+		returnTo := safeReturnTo("", loc.Query().Get("return_to"))
+		if authorizeParam(returnTo, "id_token_hint") != hint || authorizeParam(returnTo, "prompt") != "" {
+			t.Fatalf("resumed request %q", returnTo)
+		}
+		return returnTo
+	}
+
+	t.Run("hinted user signs in", func(t *testing.T) {
+		loc, _ := do(authorizeURL(nil), nil)
+		loc, _ = do(resumed(t, loc), aliceSess)
+		if loc.Host != "rp.example.com" || loc.Query().Get("code") == "" {
+			t.Fatalf("no code: %s", loc)
+		}
+	})
+	t.Run("another user signs in", func(t *testing.T) {
+		issued := len(start.issued)
+		loc, _ := do(authorizeURL(url.Values{"prompt": {"login"}}), bobSess)
+		loc, _ = do(resumed(t, loc), bobSess)
+		if loc.Host != "rp.example.com" || loc.Query().Get("error") != "login_required" || len(start.issued) != issued {
+			t.Fatalf("want login_required, got %s", loc)
+		}
+	})
+	t.Run("session of another user", func(t *testing.T) {
+		loc, _ := do(authorizeURL(nil), bobSess)
+		if loc.Query().Get("error") != "login_required" || loc.Query().Get("state") != "s1" {
+			t.Fatalf("want login_required, got %s", loc)
+		}
+	})
+	t.Run("hint from another issuer", func(t *testing.T) {
+		loc, _ := do(authorizeURL(url.Values{"id_token_hint": {idToken("https://other.example.com")}}), aliceSess)
+		if loc.Query().Get("error") != "invalid_request" {
+			t.Fatalf("want invalid_request, got %s", loc)
+		}
+	})
+	t.Run("hint issued to another client", func(t *testing.T) {
+		loc, _ := do(authorizeURL(url.Values{"id_token_hint": {idTokenFor(issuer, "other")}}), aliceSess)
+		if loc.Query().Get("error") != "invalid_request" {
+			t.Fatalf("want invalid_request, got %s", loc)
+		}
+	})
+	t.Run("access token as hint", func(t *testing.T) {
+		access, err := tokens.IssueAccessToken(context.Background(), hintClaims(issuer, "rp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		loc, _ := do(authorizeURL(url.Values{"id_token_hint": {access.AccessToken}}), aliceSess)
+		if loc.Query().Get("error") != "invalid_request" {
+			t.Fatalf("want invalid_request, got %s", loc)
+		}
+	})
+	t.Run("session of the hinted user", func(t *testing.T) {
+		if loc, _ := do(authorizeURL(nil), aliceSess); loc.Query().Get("code") == "" {
+			t.Fatalf("no code: %s", loc)
+		}
+	})
+}
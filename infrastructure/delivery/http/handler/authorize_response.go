@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// Response modes for the authorization response (OAuth 2.0 Multiple Response Type Encoding
+// Practices and OAuth 2.0 Form Post Response Mode).
+const (
+	responseModeQuery    = "query"
+	responseModeFragment = "fragment"
+	responseModeFormPost = "form_post"
+)
+
+// defaultResponseMode is query for the code flow; anything else is rejected before use.
+func defaultResponseMode(mode string) string {
+	if mode == "" {
+		return responseModeQuery
+	}
+	return mode
+}
+
+func isSupportedResponseMode(mode string) bool {
+	switch mode {
+	case responseModeQuery, responseModeFragment, responseModeFormPost:
+		return true
+	}
+	return false
+}
+
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Submit</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+{{range $k, $vs := .Params}}{{range $vs}}<input type="hidden" name="{{$k}}" value="{{.}}">
+{{end}}{{end}}<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+// writeAuthorizationResponse delivers params to an already validated redirectURI using mode.
+func writeAuthorizationResponse(w http.ResponseWriter, r *http.Request, redirectURI, mode string, params url.Values) {
+	w.Header().Set("Cache-Control", "no-store")
+	switch mode {
+	case responseModeFormPost:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = formPostTemplate.Execute(w, struct {
+			Action string
+			Params url.Values
+		}{redirectURI, params})
+	case responseModeFragment:
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid redirect_uri", "")
+			return
+		}
+		u.Fragment = ""
+		http.Redirect(w, r, u.String()+"#"+params.Encode(), http.StatusFound)
+	default:
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid redirect_uri", "")
+			return
+		}
+		// Keep any query the client registered and add the response parameters to it.
+		q := u.Query()
+		for k, vs := range params {
+			q[k] = vs
+		}
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusFound)
+	}
+}
+
+// writeAuthorizationError sends an RFC 6749 section 4.1.2.1 error back to the client.
+func writeAuthorizationError(w http.ResponseWriter, r *http.Request, redirectURI, mode, code, description, state string) {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	writeAuthorizationResponse(w, r, redirectURI, mode, params)
+}
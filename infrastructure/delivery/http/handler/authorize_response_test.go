@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestAuthorizeResponseModes(t *testing.T) {
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	h := &AuthorizeHandler{Start: &fakeStart{}, Sessions: testfake.NewSessions(sess)}
+	do := func(extra url.Values, withSession bool) *httptest.ResponseRecorder {
+		q := url.Values{"response_type": {"code"}, "client_id": {"rp"}, "redirect_uri": {"https://rp.example.com/cb"}, "scope": {"openid"}, "state": {"s&1"}}
+		for k, vs := range extra {
+			q[k] = vs
+		}
+		r := httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil)
+		if withSession {
+			r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	location := func(t *testing.T, w *httptest.ResponseRecorder) *url.URL {
+		t.Helper()
+		if w.Code != http.StatusFound {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		u, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || u.Host != "rp.example.com" || u.Path != "/cb" {
+			t.Fatalf("Location %q", w.Header().Get("Location"))
+		}
+		return u
+	}
+
+	t.Run("query by default", func(t *testing.T) {
+		u := location(t, do(nil, true))
+		if u.Query().Get("code") == "" || u.Query().Get("state") != "s&1" || u.Fragment != "" {
+			t.Fatalf("Location %s", u)
+		}
+	})
+	t.Run("fragment", func(t *testing.T) {
+		u := location(t, do(url.Values{"response_mode": {"fragment"}}, true))
+		params, _ := url.ParseQuery(u.EscapedFragment())
+		if u.RawQuery != "" || params.Get("code") == "" || params.Get("state") != "s&1" {
+			t.Fatalf("Location %s", u)
+		}
+	})
+	t.Run("form_post", func(t *testing.T) {
+		w := do(url.Values{"response_mode": {"form_post"}}, true)
+		body := w.Body.String()
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || w.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("%d %v", w.Code, w.Header())
+		}
+		if !strings.Contains(body, `action="https://rp.example.com/cb"`) || !strings.Contains(body, `name="code" value="code-`) || !strings.Contains(body, `name="state" value="s&amp;1"`) {
+			t.Fatalf("form:\n%s", body)
+		}
+	})
+	t.Run("errors use the requested mode", func(t *testing.T) {
+		u := location(t, do(url.Values{"response_mode": {"fragment"}, "prompt": {"none"}}, false))
+		params, _ := url.ParseQuery(u.EscapedFragment())
+		if params.Get("error") != "login_required" || params.Get("state") != "s&1" {
+			t.Fatalf("Location %s", u)
+		}
+	})
+	t.Run("unsupported response_mode falls back to query", func(t *testing.T) {
+		u := location(t, do(url.Values{"response_mode": {"web_message"}}, true))
+		if u.Query().Get("error") != "invalid_request" || u.Query().Get("code") != "" {
+			t.Fatalf("Location %s", u)
+		}
+	})
+	for name, extra := range map[string]url.Values{
+		"unknown client":          {"client_id": {"ghost"}},
+		"unregistered redirect":   {"redirect_uri": {"https://evil.example.com/cb"}},
+		"unregistered with error": {"redirect_uri": {"https://evil.example.com/cb"}, "response_mode": {"form_post"}, "prompt": {"none"}},
+	} {
+		t.Run(name+" is not redirected", func(t *testing.T) {
+			w := do(extra, false)
+			if w.Code != http.StatusBadRequest || w.Header().Get("Location") != "" || strings.Contains(w.Body.String(), "evil.example.com") {
+				t.Fatalf("%d Location %q\n%s", w.Code, w.Header().Get("Location"), w.Body)
+			}
+		})
+	}
+}
+
+func TestWriteAuthorizationResponseKeepsRegisteredQuery(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeAuthorizationResponse(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), "https://rp.example.com/cb?tenant=a", responseModeQuery, url.Values{"code": {"c1"}})
+	u, _ := url.Parse(w.Header().Get("Location"))
+	if u.Query().Get("tenant") != "a" || u.Query().Get("code") != "c1" {
+		t.Fatalf("Location %s", u)
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// errClientAuthRequest marks malformed client authentication (e.g. several methods at once),
+// which RFC 6749 section 2.3 treats as invalid_request rather than invalid_client.
+var errClientAuthRequest = errors.New("malformed client authentication")
+
+// clientAuthFromRequest extracts the credentials a client presented at an authenticated endpoint.
+// The form must already be parsed. Exactly one method may be used per request.
+func clientAuthFromRequest(r *http.Request, audience []string) (dservice.ClientAuthRequest, error) {
+	formID := r.PostForm.Get("client_id")
+	formSecret := r.PostForm.Get("client_secret")
+	assertionType := r.PostForm.Get("client_assertion_type")
+	assertion := r.PostForm.Get("client_assertion")
+	basicID, basicSecret, hasBasic := r.BasicAuth()
+
+	methods := 0
+	for _, present := range []bool{hasBasic, formSecret != "", assertion != "" || assertionType != ""} {
+		if present {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return dservice.ClientAuthRequest{}, errClientAuthRequest
+	}
+	req := dservice.ClientAuthRequest{ClientID: formID, Audience: audience}
+	switch {
+	case hasBasic:
+		// Credentials are form-encoded before base64 (RFC 6749 section 2.3.1).
+		id, err1 := url.QueryUnescape(basicID)
+		secret, err2 := url.QueryUnescape(basicSecret)
+		if err1 != nil || err2 != nil || id == "" {
+			return dservice.ClientAuthRequest{}, errClientAuthRequest
+		}
+		if formID != "" && formID != id {
+			return dservice.ClientAuthRequest{}, errClientAuthRequest
+		}
+		req.Method, req.ClientID, req.ClientSecret = enum.ClientAuthSecretBasic, id, secret
+	case formSecret != "":
+		if formID == "" {
+			return dservice.ClientAuthRequest{}, errClientAuthRequest
+		}
+		req.Method, req.ClientSecret = enum.ClientAuthSecretPost, formSecret
+	case assertion != "" || assertionType != "":
+		req.Method, req.AssertionType, req.Assertion = enum.ClientAuthPrivateKeyJWT, assertionType, assertion
+	default:
+		if formID == "" {
+			return dservice.ClientAuthRequest{}, errClientAuthRequest
+		}
+		req.Method = enum.ClientAuthNone
+	}
+	return req, nil
+}
+
+// authenticateClient resolves the calling client at an authenticated endpoint (path relative to the
+// issuer). Assertions may name that endpoint, the token endpoint or the issuer as aud. The form must
+// already be parsed; on failure the error response has already been written.
+func authenticateClient(w http.ResponseWriter, r *http.Request, auth dservice.ClientAuthenticator, issuers *IssuerResolver, endpoint string) (*entity.Client, bool) {
+	if auth == nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Client authentication not configured", "")
+		return nil, false
+	}
+	issuer := resolveIssuer(issuers, r)
+	audience := []string{issuer + "/token", issuer}
+	if endpoint != "/token" {
+		audience = append(audience, issuer+endpoint)
+	}
+	creds, err := clientAuthFromRequest(r, audience)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Exactly one client authentication method is required", "")
+		return nil, false
+	}
+	client, err := auth.Authenticate(r.Context(), creds)
+	if err != nil {
+		if errors.Is(err, dservice.ErrInvalidClient) {
+			writeInvalidClient(w, "Client authentication failed")
+			return nil, false
+		}
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Client authentication failed", "")
+		return nil, false
+	}
+	return client, true
+}
+
+// writeInvalidClient answers a failed client authentication per RFC 6749 section 5.2:
+// 401 with a WWW-Authenticate challenge.
+func writeInvalidClient(w http.ResponseWriter, description string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="sso", error="invalid_client"`)
+	writeOAuthError(w, http.StatusUnauthorized, "invalid_client", description, "")
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+func TestAuthenticateClient(t *testing.T) {
+	auth := &fakeClientAuth{
+		clients: map[string]*entity.Client{"rp": newTestClient("rp", true), "spa": newTestClient("spa", false)},
+		secrets: map[string]string{"rp": "s3cret"},
+	}
+	cases := []struct {
+		name      string
+		form      url.Values
+		basic     []string // client_id, secret
+		status    int
+		errorCode string
+		challenge bool
+	}{
+		{name: "basic", basic: []string{"rp", "s3cret"}, status: http.StatusOK},
+		{name: "post", form: url.Values{"client_id": {"rp"}, "client_secret": {"s3cret"}}, status: http.StatusOK},
+		{name: "none", form: url.Values{"client_id": {"spa"}}, status: http.StatusOK},
+		{name: "basic wrong secret", basic: []string{"rp", "guess"}, status: http.StatusUnauthorized, errorCode: "invalid_client", challenge: true},
+		{name: "post wrong secret", form: url.Values{"client_id": {"rp"}, "client_secret": {"guess"}}, status: http.StatusUnauthorized, errorCode: "invalid_client", challenge: true},
+		{name: "two methods", form: url.Values{"client_secret": {"s3cret"}}, basic: []string{"rp", "s3cret"}, status: http.StatusBadRequest, errorCode: "invalid_request"},
+		{name: "basic and form client_id disagree", form: url.Values{"client_id": {"spa"}}, basic: []string{"rp", "s3cret"}, status: http.StatusBadRequest, errorCode: "invalid_request"},
+		{name: "no credentials", status: http.StatusBadRequest, errorCode: "invalid_request"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(tc.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basic != nil {
+				r.SetBasicAuth(url.QueryEscape(tc.basic[0]), url.QueryEscape(tc.basic[1]))
+			}
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			c, ok := authenticateClient(w, r, auth, nil, "/introspect")
+			if tc.status == http.StatusOK {
+				if !ok || c == nil {
+					t.Fatalf("authentication failed: %d %s", w.Code, w.Body)
+				}
+				return
+			}
+			if ok || w.Code != tc.status {
+				t.Fatalf("status %d, want %d", w.Code, tc.status)
+			}
+			var body OAuthError
+			_ = json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Error != tc.errorCode {
+				t.Fatalf("error %q, want %q", body.Error, tc.errorCode)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); tc.challenge != strings.HasPrefix(got, "Basic ") {
+				t.Fatalf("WWW-Authenticate = %q", got)
+			}
+		})
+	}
+}
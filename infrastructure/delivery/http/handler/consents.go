@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/RanguraGIT/sso/domain/repository"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	resp "github.com/RanguraGIT/sso/infrastructure/delivery/http/response"
+	"github.com/google/uuid"
+)
+
+// ConsentsHandler lets the signed-in user (sid cookie) list their consents with GET and withdraw
+// one with DELETE ?client_id=...; withdrawing also revokes that client's tokens.
+type ConsentsHandler struct {
+	Consents usecase.Consents
+	Sessions repository.SessionRepository
+}
+
+func (h *ConsentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID := sessionUser(r, h.Sessions)
+	if userID == uuid.Nil {
+		resp.JSON(w, http.StatusUnauthorized, map[string]string{"error": "login required"})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.Method {
+	case http.MethodGet:
+		grants, err := h.Consents.List(r.Context(), userID)
+		if err != nil {
+			log.Printf("consents: list user=%s err=%v", userID, err)
+			resp.JSON(w, http.StatusInternalServerError, map[string]string{"error": "consent lookup failed"})
+			return
+		}
+		resp.JSON(w, http.StatusOK, map[string]any{"consents": grants})
+	case http.MethodDelete:
+		clientID := r.URL.Query().Get("client_id")
+		if clientID == "" {
+			resp.JSON(w, http.StatusBadRequest, map[string]string{"error": "client_id required"})
+			return
+		}
+		if err := h.Consents.Withdraw(r.Context(), userID, clientID); err != nil {
+			if errors.Is(err, usecase.ErrConsentNotFound) {
+				resp.JSON(w, http.StatusNotFound, map[string]string{"error": "consent not found"})
+				return
+			}
+			log.Printf("consents: withdraw user=%s client=%s err=%v", userID, clientID, err)
+			resp.JSON(w, http.StatusInternalServerError, map[string]string{"error": "consent withdrawal failed"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		resp.JSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
@@ -1,19 +1,167 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/usecase"
 )
 
-type DiscoveryHandler struct{ Issuer string }
+// DiscoveryPaths are the endpoint paths the server has registered, relative to the issuer. An
+// empty path leaves the endpoint out of the document.
+type DiscoveryPaths struct {
+	Authorization string
+	Token         string
+	UserInfo      string
+	JWKS          string
+	Revocation    string
+	Introspection string
+	EndSession    string
+	CheckSession  string // check_session_iframe
+}
+
+// DiscoveryHandler serves OpenID Provider Configuration (OIDC Discovery 1.0) and OAuth
+// Authorization Server Metadata (RFC 8414). The document is assembled on every request from the
+// registered endpoints and live services, so it never advertises something the server cannot do.
+type DiscoveryHandler struct {
+	Issuer     *IssuerResolver
+	Paths      DiscoveryPaths
+	Keys       service.KeyRotationService // algorithms with an active signing key
+	Scopes     service.ScopeRegistry
+	ClientAuth service.ClientAuthenticator
+	GrantTypes []string // grants the token endpoint accepts
+	// BackchannelLogout advertises Back-Channel Logout 1.0, with sid in logout tokens and ID tokens.
+	BackchannelLogout bool
+	// FrontchannelLogout advertises Front-Channel Logout 1.0, with iss and sid on request.
+	FrontchannelLogout bool
+	MaxAge             time.Duration // Cache-Control max-age; defaults to defaultDiscoveryMaxAge
+}
+
+const defaultDiscoveryMaxAge = time.Hour
+
+// idTokenClaims are the claims the server itself puts in ID tokens; user claims are added from the
+// scope registry.
+var idTokenClaims = []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "acr", "sid"}
 
 func (h *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(h.metadata(resolveIssuer(h.Issuer, r))); err != nil {
+		log.Printf("discovery encode: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	maxAge := h.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultDiscoveryMaxAge
+	}
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Host, X-Forwarded-Host, X-Forwarded-Proto, Forwarded")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"issuer":                 h.Issuer,
-		"jwks_uri":               h.Issuer + "/jwks.json",
-		"authorization_endpoint": h.Issuer + "/authorize",
-		"token_endpoint":         h.Issuer + "/token",
-		"userinfo_endpoint":      h.Issuer + "/userinfo",
-	})
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// metadata builds the provider metadata for issuer.
+func (h *DiscoveryHandler) metadata(issuer string) map[string]any {
+	md := map[string]any{
+		"issuer":                           issuer,
+		"response_types_supported":         []string{"code"},
+		"response_modes_supported":         []string{responseModeQuery, responseModeFragment, responseModeFormPost},
+		"subject_types_supported":          []string{"public"},
+		"code_challenge_methods_supported": codeChallengeMethods,
+		"prompt_values_supported":          supportedPromptValues,
+		"claims_parameter_supported":       true,
+		"acr_values_supported":             []string{usecase.PasswordACR},
+		"request_parameter_supported":      false,
+		"request_uri_parameter_supported":  false,
+	}
+	endpoint := func(name, path string) {
+		if path != "" {
+			md[name] = issuer + path
+		}
+	}
+	endpoint("authorization_endpoint", h.Paths.Authorization)
+	endpoint("token_endpoint", h.Paths.Token)
+	endpoint("userinfo_endpoint", h.Paths.UserInfo)
+	endpoint("jwks_uri", h.Paths.JWKS)
+	endpoint("revocation_endpoint", h.Paths.Revocation)
+	endpoint("introspection_endpoint", h.Paths.Introspection)
+	endpoint("end_session_endpoint", h.Paths.EndSession)
+	endpoint("check_session_iframe", h.Paths.CheckSession)
+
+	if h.Keys != nil {
+		md["id_token_signing_alg_values_supported"] = h.Keys.SupportedAlgorithms()
+		if h.Paths.UserInfo != "" {
+			md["userinfo_signing_alg_values_supported"] = h.Keys.SupportedAlgorithms()
+		}
+	}
+	md["id_token_encryption_alg_values_supported"] = service.SupportedEncryptionAlgs
+	md["id_token_encryption_enc_values_supported"] = service.SupportedEncryptionEncs
+	claims := append([]string(nil), idTokenClaims...)
+	if h.Scopes != nil {
+		var scopes []string
+		seen := map[string]bool{}
+		for _, c := range claims {
+			seen[c] = true
+		}
+		for _, d := range h.Scopes.All() {
+			scopes = append(scopes, d.Name)
+			for _, c := range d.Claims {
+				if !seen[c] {
+					seen[c] = true
+					claims = append(claims, c)
+				}
+			}
+		}
+		md["scopes_supported"] = scopes
+	}
+	md["claims_supported"] = claims
+	if h.BackchannelLogout {
+		md["backchannel_logout_supported"] = true
+		md["backchannel_logout_session_supported"] = true
+	}
+	if h.FrontchannelLogout {
+		md["frontchannel_logout_supported"] = true
+		md["frontchannel_logout_session_supported"] = true
+	}
+	if len(h.GrantTypes) > 0 {
+		md["grant_types_supported"] = h.GrantTypes
+	}
+	if h.ClientAuth != nil {
+		methods := make([]string, 0, 4)
+		for _, m := range h.ClientAuth.Methods() {
+			methods = append(methods, m.String())
+		}
+		algs := h.ClientAuth.AssertionSigningAlgs()
+		// The same authenticator guards token, revocation and introspection.
+		for _, ep := range []struct{ prefix, path string }{{"token_endpoint", h.Paths.Token}, {"revocation_endpoint", h.Paths.Revocation}, {"introspection_endpoint", h.Paths.Introspection}} {
+			if ep.path == "" {
+				continue
+			}
+			md[ep.prefix+"_auth_methods_supported"] = methods
+			md[ep.prefix+"_auth_signing_alg_values_supported"] = algs
+		}
+	}
+	return md
 }
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+// fakeClientAuth accepts a client when the presented secret equals secrets[client_id]; clients
+// registered without a secret authenticate with method none.
+type fakeClientAuth struct {
+	clients map[string]*entity.Client
+	secrets map[string]string
+}
+
+func (f *fakeClientAuth) Authenticate(_ context.Context, req dservice.ClientAuthRequest) (*entity.Client, error) {
+	c := f.clients[req.ClientID]
+	if c == nil {
+		return nil, fmt.Errorf("%w: unknown client", dservice.ErrInvalidClient)
+	}
+	want, ok := f.secrets[req.ClientID]
+	switch {
+	case !ok && req.Method == enum.ClientAuthNone:
+		return c, nil
+	case ok && req.Method != enum.ClientAuthNone && req.ClientSecret == want:
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: bad credentials", dservice.ErrInvalidClient)
+}
+
+func (f *fakeClientAuth) Methods() []enum.ClientAuthMethod {
+	return []enum.ClientAuthMethod{enum.ClientAuthSecretBasic, enum.ClientAuthSecretPost, enum.ClientAuthNone}
+}
+
+func (f *fakeClientAuth) AssertionSigningAlgs() []string { return nil }
+
+func newTestClient(clientID string, confidential bool) *entity.Client {
+	secret := ""
+	if confidential {
+		secret = "hashed"
+	}
+	c, err := entity.NewClient(clientID, clientID, secret, []string{"https://rp.example.com/cb"}, []string{"openid", "profile"}, confidential, !confidential)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// fakeIntrospect reports the tokens in active as active and everything else as inactive.
+type fakeIntrospect struct {
+	active map[string]*usecase.IntrospectOutput
+	last   usecase.IntrospectInput
+}
+
+func (f *fakeIntrospect) Execute(_ context.Context, in usecase.IntrospectInput) (*usecase.IntrospectOutput, error) {
+	f.last = in
+	if out, ok := f.active[in.Token]; ok {
+		return out, nil
+	}
+	return &usecase.IntrospectOutput{}, nil
+}
+
+// fakeRevoke owns the tokens in owner (token -> client_id); any other token is unknown.
+type fakeRevoke struct {
+	owner   map[string]string
+	revoked []string
+}
+
+func (f *fakeRevoke) Execute(_ context.Context, in usecase.RevokeTokenInput) error {
+	owner, ok := f.owner[in.Token]
+	switch {
+	case !ok:
+		return nil
+	case owner != in.ClientID:
+		return usecase.ErrUnauthorizedClient
+	}
+	f.revoked = append(f.revoked, in.Token)
+	return nil
+}
+
+// fakeLogin accepts the email/password pairs in passwords.
+type fakeLogin struct {
+	passwords map[string]string
+	users     map[string]uuid.UUID
+}
+
+func (f *fakeLogin) Execute(_ context.Context, in usecase.UserLoginInput) (*usecase.UserLoginOutput, error) {
+	if pw, ok := f.passwords[in.Email]; !ok || pw != in.Password {
+		return nil, errors.New("invalid credentials")
+	}
+	return &usecase.UserLoginOutput{UserID: f.users[in.Email]}, nil
+}
+
+// fakeCreateSession records the sessions it creates, and stores them in store when set.
+type fakeCreateSession struct {
+	created []usecase.CreateSessionInput
+	store   *testfake.Sessions
+}
+
+func (f *fakeCreateSession) Execute(ctx context.Context, in usecase.CreateSessionInput) (*usecase.CreateSessionOutput, error) {
+	f.created = append(f.created, in)
+	if f.store == nil {
+		return &usecase.CreateSessionOutput{SessionID: uuid.New()}, nil
+	}
+	sess, err := entity.NewSession(in.UserID, in.TTL, in.IP, in.UA)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.store.Create(ctx, sess); err != nil {
+		return nil, err
+	}
+	return &usecase.CreateSessionOutput{SessionID: sess.ID}, nil
+}
+
+// fakeConsents remembers granted scopes per user and client.
+type fakeConsents struct{ granted map[string][]string }
+
+func (f *fakeConsents) Check(_ context.Context, userID uuid.UUID, clientID string, scopes []string) (*usecase.ConsentCheck, error) {
+	check := &usecase.ConsentCheck{ClientName: clientID}
+	for _, s := range scopes {
+		if !slices.Contains(f.granted[userID.String()+"/"+clientID], s) {
+			check.Missing = append(check.Missing, s)
+		}
+	}
+	return check, nil
+}
+
+func (f *fakeConsents) Grant(_ context.Context, userID uuid.UUID, clientID string, scopes []string) error {
+	if f.granted == nil {
+		f.granted = map[string][]string{}
+	}
+	key := userID.String() + "/" + clientID
+	f.granted[key] = append(f.granted[key], scopes...)
+	return nil
+}
+
+func (f *fakeConsents) List(context.Context, uuid.UUID) ([]usecase.ConsentGrant, error) {
+	return nil, nil
+}
+
+func (f *fakeConsents) Withdraw(context.Context, uuid.UUID, string) error { return nil }
+
+// fakeStart accepts client rp with https://rp.example.com/cb and issues numbered codes.
+type fakeStart struct{ issued []usecase.StartAuthInput }
+
+func (f *fakeStart) Validate(_ context.Context, in usecase.StartAuthInput) error {
+	switch {
+	case in.ClientID != "rp":
+		return usecase.ErrInvalidClient
+	case in.RedirectURI != "https://rp.example.com/cb":
+		return usecase.ErrInvalidRedirectURI
+	}
+	return nil
+}
+
+func (f *fakeStart) GrantedScopes(context.Context, usecase.StartAuthInput) ([]string, error) {
+	return []string{"openid"}, nil
+}
+
+func (f *fakeStart) ClaimScopes(context.Context, usecase.StartAuthInput) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeStart) Execute(_ context.Context, in usecase.StartAuthInput) (*usecase.StartAuthResult, error) {
+	f.issued = append(f.issued, in)
+	return &usecase.StartAuthResult{Code: fmt.Sprintf("code-%d", len(f.issued)), State: in.State}, nil
+}
+
+// fakeEndSession accepts post_logout_redirect_uri https://rp.example.com/bye for client rp,
+// records the sessions it ends and answers with the frontchannel logout URIs.
+type fakeEndSession struct {
+	ended        []usecase.EndSessionInput
+	frontchannel []string
+}
+
+func (f *fakeEndSession) Validate(_ context.Context, in usecase.EndSessionInput) error {
+	switch {
+	case in.PostLogoutRedirectURI == "":
+		return nil
+	case in.ClientID != "rp":
+		return usecase.ErrInvalidClient
+	case in.PostLogoutRedirectURI != "https://rp.example.com/bye":
+		return usecase.ErrInvalidRedirectURI
+	}
+	return nil
+}
+
+func (f *fakeEndSession) Execute(_ context.Context, in usecase.EndSessionInput) (*usecase.EndSessionOutput, error) {
+	f.ended = append(f.ended, in)
+	return &usecase.EndSessionOutput{FrontchannelLogoutURIs: f.frontchannel}, nil
+}
+
+// fakeIssue issues numbered access tokens and records its inputs.
+type fakeIssue struct {
+	mu     sync.Mutex
+	issued []usecase.IssueTokenInput
+}
+
+func (f *fakeIssue) Execute(_ context.Context, in usecase.IssueTokenInput) (*usecase.IssueTokenOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.issued = append(f.issued, in)
+	return &usecase.IssueTokenOutput{AccessToken: fmt.Sprintf("access-%d", len(f.issued)), TokenType: "Bearer", ExpiresIn: 600, Scope: in.Scope}, nil
+}
+
+// fakeRefresh fails with errs[refresh token id] and otherwise rotates.
+type fakeRefresh struct{ errs map[string]error }
+
+func (f *fakeRefresh) Execute(_ context.Context, in usecase.RefreshTokenInput) (*usecase.RefreshTokenOutput, error) {
+	if err := f.errs[in.RefreshTokenID]; err != nil {
+		return nil, err
+	}
+	return &usecase.RefreshTokenOutput{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 600}, nil
+}
+
+// tokenMeta serves token metadata by access token jti; the other repository methods are not used
+// by the handlers under test and panic.
+type tokenMeta struct {
+	repository.TokenRepository
+	byJTI map[string]*entity.Token
+}
+
+func (m tokenMeta) GetByAccessJTI(_ context.Context, jti string) (*entity.Token, error) {
+	return m.byJTI[jti], nil
+}
+
+// fakeUserSessions lists the sessions it holds and records terminations.
+type fakeUserSessions struct {
+	sessions   []usecase.SessionInfo
+	terminated []uuid.UUID
+	kept       []uuid.UUID // keep argument of each TerminateAll
+}
+
+func (f *fakeUserSessions) List(context.Context, uuid.UUID) ([]usecase.SessionInfo, error) {
+	return append([]usecase.SessionInfo(nil), f.sessions...), nil
+}
+
+func (f *fakeUserSessions) Terminate(_ context.Context, _, sessionID uuid.UUID, _ string) error {
+	for _, s := range f.sessions {
+		if s.ID == sessionID {
+			f.terminated = append(f.terminated, sessionID)
+			return nil
+		}
+	}
+	return usecase.ErrSessionNotFound
+}
+
+func (f *fakeUserSessions) TerminateAll(_ context.Context, _, keep uuid.UUID, _ string) (int, error) {
+	f.kept = append(f.kept, keep)
+	n := 0
+	for _, s := range f.sessions {
+		if s.ID != keep {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/usecase"
+)
+
+// introspectionJWTType is the media type (and JOSE typ, minus "application/") of RFC 9701 responses.
+const introspectionJWTType = "application/token-introspection+jwt"
+
+// IntrospectHandler implements RFC 7662 token introspection for authenticated (confidential) clients.
+type IntrospectHandler struct {
+	Introspect   usecase.Introspect
+	ClientAuth   dservice.ClientAuthenticator
+	TokenService dservice.TokenService // signs RFC 9701 JWT responses
+	Issuer       *IssuerResolver
+}
+
+func (h *IntrospectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "POST required", "")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed form body", "")
+		return
+	}
+	client, ok := authenticateClient(w, r, h.ClientAuth, h.Issuer, "/introspect")
+	if !ok {
+		return
+	}
+	if !client.Confidential {
+		writeInvalidClient(w, "Public clients may not introspect tokens")
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing token", "")
+		return
+	}
+	out, err := h.Introspect.Execute(r.Context(), usecase.IntrospectInput{Token: token, TokenTypeHint: r.PostForm.Get("token_type_hint"), Issuer: resolveIssuer(h.Issuer, r)})
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Introspection failed", "")
+		return
+	}
+	body := introspectionBody(out)
+	w.Header().Set("Cache-Control", "no-store")
+	if strings.Contains(r.Header.Get("Accept"), introspectionJWTType) {
+		h.writeJWT(w, r, client.ClientID, body)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeJWT answers with a signed introspection response (RFC 9701 section 5) addressed to the caller.
+func (h *IntrospectHandler) writeJWT(w http.ResponseWriter, r *http.Request, clientID string, body map[string]any) {
+	if h.TokenService == nil {
+		writeOAuthError(w, http.StatusNotAcceptable, "invalid_request", "JWT introspection responses not supported", "")
+		return
+	}
+	signed, err := h.TokenService.SignClaims(r.Context(), strings.TrimPrefix(introspectionJWTType, "application/"), map[string]any{
+		"iss":                 resolveIssuer(h.Issuer, r),
+		"aud":                 clientID,
+		"iat":                 time.Now().Unix(),
+		"token_introspection": body,
+	}, "")
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Introspection failed", "")
+		return
+	}
+	w.Header().Set("Content-Type", introspectionJWTType)
+	_, _ = w.Write([]byte(signed))
+}
+
+// introspectionBody renders out as RFC 7662 members, omitting everything but active when inactive.
+func introspectionBody(out *usecase.IntrospectOutput) map[string]any {
+	if !out.Active {
+		return map[string]any{"active": false}
+	}
+	body := map[string]any{
+		"active":     true,
+		"client_id":  out.ClientID,
+		"token_type": out.TokenType,
+		"exp":        out.ExpiresAt,
+		"iat":        out.IssuedAt,
+	}
+	if out.Scope != "" {
+		body["scope"] = out.Scope
+	}
+	if out.Subject != "" {
+		body["sub"] = out.Subject
+	}
+	if out.Issuer != "" {
+		body["iss"] = out.Issuer
+	}
+	if len(out.Audience) > 0 {
+		body["aud"] = out.Audience
+	}
+	return body
+}
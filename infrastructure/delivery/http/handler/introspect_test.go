@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+)
+
+func TestIntrospectHandler(t *testing.T) {
+	const issuer = "https://sso.example.com"
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	issuers, _ := NewIssuerResolver(issuer, nil, nil)
+	uc := &fakeIntrospect{active: map[string]*usecase.IntrospectOutput{
+		"live": {Active: true, TokenType: "Bearer", ClientID: "rp", Subject: "user-1", Scope: "openid", Issuer: issuer, ExpiresAt: 2, IssuedAt: 1},
+	}}
+	h := &IntrospectHandler{
+		Introspect: uc,
+		ClientAuth: &fakeClientAuth{
+			clients: map[string]*entity.Client{"rs": newTestClient("rs", true), "spa": newTestClient("spa", false)},
+			secrets: map[string]string{"rs": "s3cret"},
+		},
+		TokenService: iservice.NewJWTTokenService(keys, iservice.TokenValidation{Issuer: issuer}),
+		Issuer:       issuers,
+	}
+	do := func(form url.Values, user, pass, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if user != "" {
+			r.SetBasicAuth(user, pass)
+		}
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	errorOf := func(w *httptest.ResponseRecorder) string {
+		var body OAuthError
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Error
+	}
+	token := func(tok string) url.Values { return url.Values{"token": {tok}} }
+
+	t.Run("unauthenticated caller", func(t *testing.T) {
+		if w := do(token("live"), "", "", ""); w.Code != http.StatusBadRequest || errorOf(w) != "invalid_request" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		if w := do(token("live"), "rs", "guess", ""); w.Code != http.StatusUnauthorized || errorOf(w) != "invalid_client" || w.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("public caller", func(t *testing.T) {
+		form := token("live")
+		form.Set("client_id", "spa")
+		if w := do(form, "", "", ""); w.Code != http.StatusUnauthorized || errorOf(w) != "invalid_client" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		if uc.last.Token != "" {
+			t.Fatal("public client reached the introspection usecase")
+		}
+	})
+	t.Run("missing token", func(t *testing.T) {
+		if w := do(url.Values{}, "rs", "s3cret", ""); w.Code != http.StatusBadRequest || errorOf(w) != "invalid_request" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("active token", func(t *testing.T) {
+		form := token("live")
+		form.Set("token_type_hint", "refresh_token")
+		w := do(form, "rs", "s3cret", "")
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		if body["active"] != true || body["sub"] != "user-1" || body["client_id"] != "rp" || body["token_type"] != "Bearer" {
+			t.Fatalf("body %v", body)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" || uc.last.TokenTypeHint != "refresh_token" || uc.last.Issuer != issuer {
+			t.Fatalf("headers %v, input %+v", w.Header(), uc.last)
+		}
+	})
+	t.Run("inactive token", func(t *testing.T) {
+		w := do(token("gone"), "rs", "s3cret", "")
+		if strings.TrimSpace(w.Body.String()) != `{"active":false}` {
+			t.Fatalf("inactive body %s", w.Body)
+		}
+	})
+	t.Run("JWT response", func(t *testing.T) {
+		w := do(token("live"), "rs", "s3cret", "application/token-introspection+jwt")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != introspectionJWTType {
+			t.Fatalf("%d %s %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		claims := jwt.MapClaims{}
+		parsed, err := jwt.ParseWithClaims(w.Body.String(), claims, func(tok *jwt.Token) (any, error) {
+			pub, _, err := keys.VerificationKey(tok.Header["kid"].(string))
+			return pub, err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header["typ"] != "token-introspection+jwt" || claims["iss"] != issuer || claims["aud"] != "rs" {
+			t.Fatalf("header %v claims %v", parsed.Header, claims)
+		}
+		inner, _ := claims["token_introspection"].(map[string]any)
+		if inner["active"] != true || inner["sub"] != "user-1" {
+			t.Fatalf("token_introspection %v", inner)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// IssuerResolver is the single source of the issuer identifier used in tokens, discovery and
+// client assertion audiences. A configured canonical issuer always wins. Without one the issuer is
+// derived from X-Forwarded-Proto / X-Forwarded-Host / Forwarded, honoured only when the connection
+// comes from a trusted proxy and the host is on the allow list, so clients cannot steer iss. The
+// Host header itself is never used.
+type IssuerResolver struct {
+	canonical string
+	path      string // path component of canonical, without trailing slash
+	trusted   []netip.Prefix
+	allowed   []string // hosts a derived issuer may name; the first is the default
+}
+
+// NewIssuerResolver builds a resolver. trustedProxies holds CIDRs or bare IP addresses and
+// allowedHosts the hosts (host[:port]) a derived issuer may name. Without a canonical issuer both
+// are required.
+func NewIssuerResolver(canonical string, trustedProxies, allowedHosts []string) (*IssuerResolver, error) {
+	res := &IssuerResolver{canonical: strings.TrimSuffix(canonical, "/")}
+	if res.canonical != "" {
+		u, err := url.Parse(res.canonical)
+		if err != nil {
+			return nil, fmt.Errorf("issuer %q: %w", canonical, err)
+		}
+		res.path = u.Path
+	}
+	for _, p := range trustedProxies {
+		prefix, err := parseProxy(p)
+		if err != nil {
+			return nil, err
+		}
+		res.trusted = append(res.trusted, prefix)
+	}
+	for _, h := range allowedHosts {
+		if h == "" || strings.ContainsAny(h, "/?#@ ") {
+			return nil, fmt.Errorf("allowed host %q is not a host", h)
+		}
+		res.allowed = append(res.allowed, strings.ToLower(h))
+	}
+	if res.canonical == "" && (len(res.trusted) == 0 || len(res.allowed) == 0) {
+		return nil, errors.New("issuer required unless trusted proxies and allowed hosts are both set")
+	}
+	return res, nil
+}
+
+func parseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("trusted proxy %q: %w", s, err)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("trusted proxy %q: %w", s, err)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Canonical returns the configured issuer, or "" when it is derived per request.
+func (ir *IssuerResolver) Canonical() string {
+	if ir == nil {
+		return ""
+	}
+	return ir.canonical
+}
+
+// Path returns the path component of the configured issuer ("" at the host root or when the issuer
+// is derived per request). Routes are mounted below it, so URLs the server hands to the user agent
+// (form actions, redirects, cookie paths) must start with it too.
+func (ir *IssuerResolver) Path() string {
+	if ir == nil {
+		return ""
+	}
+	return ir.path
+}
+
+// Issuer returns the issuer identifier for r. A derived issuer names the host forwarded by a
+// trusted proxy when it is allowed, else the first allowed host. A nil resolver has no issuer.
+func (ir *IssuerResolver) Issuer(r *http.Request) string {
+	if ir == nil {
+		return ""
+	}
+	if ir.canonical != "" {
+		return ir.canonical
+	}
+	scheme, host := "http", ir.allowed[0]
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if ir.fromTrustedProxy(r) {
+		proto, fwdHost := forwardedFor(r)
+		if proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwdHost = strings.ToLower(fwdHost); slices.Contains(ir.allowed, fwdHost) {
+			host = fwdHost
+		}
+	}
+	return scheme + "://" + host
+}
+
+func (ir *IssuerResolver) fromTrustedProxy(r *http.Request) bool {
+	if len(ir.trusted) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range ir.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor reads the proto and host seen by the trusted proxy in front of us. Each proxy
+// appends its own entry, so only the last element of the RFC 7239 Forwarded header (else the last
+// values of X-Forwarded-Proto / X-Forwarded-Host) is ours; earlier ones come from the client.
+// Repeated header lines count as one comma-separated list.
+func forwardedFor(r *http.Request) (proto, host string) {
+	if fwd := lastListValue(r.Header.Values("Forwarded")); fwd != "" {
+		for _, pair := range strings.Split(fwd, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			v = strings.Trim(v, `"`)
+			switch strings.ToLower(k) {
+			case "proto":
+				proto = strings.ToLower(v)
+			case "host":
+				host = v
+			}
+		}
+		return proto, host
+	}
+	proto = lastListValue(r.Header.Values("X-Forwarded-Proto"))
+	host = lastListValue(r.Header.Values("X-Forwarded-Host"))
+	return strings.ToLower(proto), host
+}
+
+// lastListValue returns the last element of a comma-separated header that may span several lines.
+func lastListValue(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	all := strings.Split(lines[len(lines)-1], ",")
+	return strings.TrimSpace(all[len(all)-1])
+}
+
+// resolveIssuer returns the issuer for r; a nil resolver yields "".
+func resolveIssuer(res *IssuerResolver, r *http.Request) string {
+	return res.Issuer(r)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIssuerResolver(t *testing.T) {
+	derived, err := NewIssuerResolver("", []string{"10.0.0.0/8", "192.0.2.1"}, []string{"sso.example.com", "SSO.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{name: "direct request names the first allowed host, not Host", remote: "203.0.113.5:4000", want: "http://sso.example.com"},
+		{name: "untrusted peer cannot steer the issuer", remote: "203.0.113.5:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.example.com"}}, want: "http://sso.example.com"},
+		{name: "untrusted peer Forwarded", remote: "203.0.113.5:4000",
+			headers: map[string][]string{"Forwarded": {"proto=https;host=evil.example.com"}}, want: "http://sso.example.com"},
+		{name: "trusted proxy", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"sso.example.com"}}, want: "https://sso.example.com"},
+		{name: "trusted proxy forwarding another allowed host", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"Sso.Example.Org"}}, want: "https://sso.example.org"},
+		{name: "trusted proxy forwarding a host not allowed", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.example.com"}}, want: "https://sso.example.com"},
+		{name: "trusted single address over IPv4-mapped IPv6", remote: "[::ffff:192.0.2.1]:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"sso.example.com"}}, want: "https://sso.example.com"},
+		{name: "client-supplied values before the proxy's are ignored", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"http, https"}, "X-Forwarded-Host": {"evil.example.com, sso.example.com"}}, want: "https://sso.example.com"},
+		{name: "repeated header lines", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"http", "https"}, "X-Forwarded-Host": {"evil.example.com", "sso.example.com"}}, want: "https://sso.example.com"},
+		{name: "Forwarded uses the last element", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"Forwarded": {`for=1.2.3.4;host=evil.example.com;proto=http, for=10.9.9.9;host="sso.example.com";proto=https`}}, want: "https://sso.example.com"},
+		{name: "Forwarded over several lines", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"Forwarded": {"host=evil.example.com;proto=http", "host=sso.example.com;proto=https"}}, want: "https://sso.example.com"},
+		{name: "Forwarded wins over X-Forwarded", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"Forwarded": {"host=sso.example.com;proto=https"}, "X-Forwarded-Host": {"other.example.com"}}, want: "https://sso.example.com"},
+		{name: "malformed forwarded host", remote: "10.1.2.3:4000",
+			headers: map[string][]string{"X-Forwarded-Proto": {"ftp"}, "X-Forwarded-Host": {"sso.example.com/path"}}, want: "http://sso.example.com"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://sso.internal/authorize", nil)
+			r.RemoteAddr = tc.remote
+			for k, vs := range tc.headers {
+				for _, v := range vs {
+					r.Header.Add(k, v)
+				}
+			}
+			if got := derived.Issuer(r); got != tc.want {
+				t.Fatalf("Issuer = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("canonical issuer ignores the request", func(t *testing.T) {
+		canonical, _ := NewIssuerResolver("https://sso.example.com/", []string{"10.0.0.0/8"}, nil)
+		r := httptest.NewRequest(http.MethodGet, "http://sso.internal/authorize", nil)
+		r.RemoteAddr = "10.1.2.3:4000"
+		r.Header.Set("X-Forwarded-Host", "other.example.com")
+		if got := canonical.Issuer(r); got != "https://sso.example.com" {
+			t.Fatalf("Issuer = %q", got)
+		}
+	})
+	t.Run("bad trusted proxy", func(t *testing.T) {
+		if _, err := NewIssuerResolver("", []string{"proxy.local"}, []string{"sso.example.com"}); err == nil {
+			t.Fatal("want an error")
+		}
+	})
+	t.Run("derived issuer needs trusted proxies and allowed hosts", func(t *testing.T) {
+		for _, c := range []struct{ proxies, hosts []string }{{nil, nil}, {[]string{"10.0.0.0/8"}, nil}, {nil, []string{"sso.example.com"}}} {
+			if _, err := NewIssuerResolver("", c.proxies, c.hosts); err == nil {
+				t.Fatalf("%v %v: want an error", c.proxies, c.hosts)
+			}
+		}
+	})
+}
@@ -10,14 +10,18 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
 	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
 	req "github.com/RanguraGIT/sso/infrastructure/delivery/http/request"
 	resp "github.com/RanguraGIT/sso/infrastructure/delivery/http/response"
 )
 
 type LoginHandler struct {
-	LoginUC   usecase.UserLogin
-	SessionUC usecase.CreateSession
+	LoginUC       usecase.UserLogin
+	SessionUC     usecase.CreateSession
+	SessionPolicy vo.SessionPolicy // MaxLifetime defaults to defaultSessionTTL
 }
 
 func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -37,18 +41,65 @@ func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ip := extractIP(r.RemoteAddr)
-	sessOut, err := h.SessionUC.Execute(r.Context(), usecase.CreateSessionInput{UserID: loginOut.UserID, TTL: 8 * time.Hour, IP: ip, UA: r.UserAgent()})
+	ttl, cookieTTL := sessionLifetimes(h.SessionPolicy)
+	sessOut, err := h.SessionUC.Execute(r.Context(), usecase.CreateSessionInput{UserID: loginOut.UserID, TTL: ttl, IP: ip, UA: r.UserAgent()})
 	if err != nil {
 		log.Printf("login: session create failed user=%s err=%v", loginOut.UserID, err)
 		resp.JSON(w, http.StatusInternalServerError, map[string]string{"error": "session error"})
 		return
 	}
-	cookie := &http.Cookie{Name: "sid", Value: sessOut.SessionID.String(), Path: "/", HttpOnly: true, Secure: false, SameSite: http.SameSiteLaxMode, Expires: time.Now().Add(8 * time.Hour)}
-	// NOTE: Secure: true recommended behind HTTPS; left false for local dev.
-	http.SetCookie(w, cookie)
+	setSessionCookie(w, r, sessOut.SessionID.String(), cookieTTL)
 	resp.JSON(w, http.StatusOK, map[string]string{"session_id": sessOut.SessionID.String(), "user_id": loginOut.UserID.String()})
 }
 
+const defaultSessionTTL = 8 * time.Hour
+
+// sessionLifetimes returns how long a new session lasts until first used under p, and how long its
+// cookie lasts: as long as use could keep the session alive.
+func sessionLifetimes(p vo.SessionPolicy) (session, cookie time.Duration) {
+	if p.MaxLifetime <= 0 {
+		p.MaxLifetime = defaultSessionTTL
+	}
+	now := time.Now()
+	return p.ExpiresAt(now, now).Sub(now), p.MaxLifetime
+}
+
+// setSessionCookie issues the sid cookie and the matching browser state cookie. Secure is set
+// when the request arrived over TLS so plain-HTTP local development keeps working.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, sid string, ttl time.Duration) {
+	expires := time.Now().Add(ttl)
+	http.SetCookie(w, &http.Cookie{Name: "sid", Value: sid, Path: "/", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode, Expires: expires})
+	setBrowserStateCookie(w, r, browserState(sid), 0, expires)
+}
+
+// clearSessionCookie expires the sid cookie and the browser state cookie, which turns every
+// session_state issued for the session into "changed".
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: "sid", Value: "", Path: "/", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode, MaxAge: -1})
+	setBrowserStateCookie(w, r, "", -1, time.Time{})
+}
+
+// sessionUser returns the user behind a live sid cookie, or uuid.Nil.
+func sessionUser(r *http.Request, sessions repository.SessionRepository) uuid.UUID {
+	if sess := sessionFor(r, sessions); sess != nil {
+		return sess.UserID
+	}
+	return uuid.Nil
+}
+
+// sessionFor returns the live session behind the sid cookie, or nil.
+func sessionFor(r *http.Request, sessions repository.SessionRepository) *entity.Session {
+	sid, err := sessionIDFromCookie(r)
+	if err != nil || sessions == nil {
+		return nil
+	}
+	sess, _ := sessions.Get(r.Context(), sid)
+	if sess == nil || sess.Revoked || sess.IsExpired(time.Now().UTC()) {
+		return nil
+	}
+	return sess
+}
+
 // Helper to parse UUID cookie (might be used by authorize refactor)
 func sessionIDFromCookie(r *http.Request) (uuid.UUID, error) {
 	c, err := r.Cookie("sid")
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+)
+
+// LoginPagePath is where /authorize sends users without a session.
+const LoginPagePath = "/ui/login"
+
+// LoginPageHandler serves the hosted HTML login form. return_to carries the pending /authorize
+// request, which is resumed after a successful sign-in.
+type LoginPageHandler struct {
+	LoginUC       usecase.UserLogin
+	SessionUC     usecase.CreateSession
+	Templates     *ui.Templates
+	SessionPolicy vo.SessionPolicy // MaxLifetime defaults to defaultSessionTTL
+	Issuer        *IssuerResolver  // its path prefixes the form action, cookie path and return_to
+}
+
+type loginPage struct {
+	Action    string
+	CSRFToken string
+	ReturnTo  string
+	Email     string
+	Error     string
+	Locale    string // first ui_locales tag of the pending request, for <html lang>
+}
+
+func (h *LoginPageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		page := loginPage{ReturnTo: safeReturnTo(h.Issuer.Path(), r.URL.Query().Get("return_to"))}
+		page.Email = authorizeParam(page.ReturnTo, "login_hint")
+		h.render(w, r, http.StatusOK, page)
+	case http.MethodPost:
+		h.submit(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *LoginPageHandler) submit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "malformed form", http.StatusBadRequest)
+		return
+	}
+	page := loginPage{ReturnTo: safeReturnTo(h.Issuer.Path(), r.PostForm.Get("return_to")), Email: r.PostForm.Get("email")}
+	if !validCSRF(r) {
+		page.Error = "Your session expired. Please try again."
+		h.render(w, r, http.StatusForbidden, page)
+		return
+	}
+	out, err := h.LoginUC.Execute(r.Context(), usecase.UserLoginInput{Email: page.Email, Password: r.PostForm.Get("password")})
+	if err != nil {
+		log.Printf("ui login: auth failed email=%s err=%v", page.Email, err)
+		page.Error = "Invalid email or password."
+		h.render(w, r, http.StatusUnauthorized, page)
+		return
+	}
+	ttl, cookieTTL := sessionLifetimes(h.SessionPolicy)
+	sess, err := h.SessionUC.Execute(r.Context(), usecase.CreateSessionInput{UserID: out.UserID, TTL: ttl, IP: extractIP(r.RemoteAddr), UA: r.UserAgent()})
+	if err != nil {
+		log.Printf("ui login: session create failed user=%s err=%v", out.UserID, err)
+		page.Error = "Sign-in failed. Please try again."
+		h.render(w, r, http.StatusInternalServerError, page)
+		return
+	}
+	setSessionCookie(w, r, sess.SessionID.String(), cookieTTL)
+	target := page.ReturnTo
+	if target == "" {
+		target = h.Issuer.Path() + "/"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (h *LoginPageHandler) render(w http.ResponseWriter, r *http.Request, status int, page loginPage) {
+	page.Action = h.Issuer.Path() + LoginPagePath
+	token, err := csrfToken(w, r, page.Action)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	page.CSRFToken = token
+	page.Locale = uiLocale(authorizeParam(page.ReturnTo, "ui_locales"))
+	if err := h.Templates.Render(w, status, "login.html", page); err != nil {
+		log.Printf("ui login: render: %v", err)
+	}
+}
+
+// safeReturnTo only accepts a local /authorize URL below basePath so the form cannot be used as an
+// open redirect.
+func safeReturnTo(basePath, raw string) string {
+	if raw == "" || strings.HasPrefix(raw, "//") || strings.Contains(raw, `\`) {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.IsAbs() || u.Host != "" || u.Path != basePath+"/authorize" {
+		return ""
+	}
+	return u.String()
+}
+
+// authorizeParam reads a parameter of the pending /authorize request carried in return_to.
+func authorizeParam(returnTo, name string) string {
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get(name)
+}
+
+// uiLocale picks the first well-formed language tag from ui_locales, defaulting to "en".
+func uiLocale(uiLocales string) string {
+	for _, tag := range strings.Fields(uiLocales) {
+		if len(tag) <= 35 && strings.Trim(tag, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") == "" {
+			return tag
+		}
+	}
+	return "en"
+}
+
+// loginRedirect builds the login page URL that resumes the given authorization request, both
+// below basePath.
+func loginRedirect(basePath, loginPath string, authorizeParams url.Values) string {
+	returnTo := basePath + "/authorize?" + authorizeParams.Encode()
+	return basePath + loginPath + "?" + url.Values{"return_to": {returnTo}}.Encode()
+}
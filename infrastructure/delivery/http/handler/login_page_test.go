@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+)
+
+func TestSafeReturnTo(t *testing.T) {
+	cases := map[string]string{
+		"":                                     "",
+		"/authorize?client_id=rp&state=x":      "/authorize?client_id=rp&state=x",
+		"//evil.example.com/authorize":         "",
+		`/\evil.example.com/authorize`:         "",
+		`/authorize\..\evil`:                   "",
+		"https://evil.example.com/authorize":   "",
+		"javascript:alert(1)":                  "",
+		"/admin":                               "",
+		"/authorize/../admin":                  "",
+		"%2F%2Fevil.example.com":               "",
+		"http://sso.example.com/authorize?x=1": "",
+	}
+	for in, want := range cases {
+		if got := safeReturnTo("", in); got != want {
+			t.Errorf("safeReturnTo(%q) = %q, want %q", in, got, want)
+		}
+	}
+	// Below an issuer path only that path's /authorize is local.
+	for in, want := range map[string]string{
+		"/sso/authorize?client_id=rp": "/sso/authorize?client_id=rp",
+		"/authorize?client_id=rp":     "",
+		"/other/authorize":            "",
+	} {
+		if got := safeReturnTo("/sso", in); got != want {
+			t.Errorf("safeReturnTo(/sso, %q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoginPageHandler(t *testing.T) {
+	templates, err := ui.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := uuid.New()
+	sessions := &fakeCreateSession{}
+	h := &LoginPageHandler{
+		LoginUC:   &fakeLogin{passwords: map[string]string{"alice@example.com": "pw"}, users: map[string]uuid.UUID{"alice@example.com": alice}},
+		SessionUC: sessions,
+		Templates: templates,
+	}
+	returnTo := "/authorize?" + url.Values{"client_id": {"rp"}, "login_hint": {"alice@example.com"}, "state": {"s1"}}.Encode()
+	csrfField := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+	// get renders the form and returns the CSRF cookie with the token embedded in the page.
+	get := func(t *testing.T) (*httptest.ResponseRecorder, *http.Cookie, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, LoginPagePath+"?"+url.Values{"return_to": {returnTo}}.Encode(), nil))
+		var cookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == csrfCookie {
+				cookie = c
+			}
+		}
+		m := csrfField.FindStringSubmatch(w.Body.String())
+		if w.Code != http.StatusOK || cookie == nil || m == nil || m[1] != cookie.Value {
+			t.Fatalf("form: %d cookie %v\n%s", w.Code, cookie, w.Body)
+		}
+		return w, cookie, m[1]
+	}
+	post := func(cookie *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, LoginPagePath, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	login := func(token string) url.Values {
+		return url.Values{"email": {"alice@example.com"}, "password": {"pw"}, "return_to": {returnTo}, "csrf_token": {token}}
+	}
+
+	t.Run("login_hint prefills the email", func(t *testing.T) {
+		w, _, _ := get(t)
+		if !strings.Contains(w.Body.String(), `name="email" value="alice@example.com"`) {
+			t.Fatalf("email not prefilled:\n%s", w.Body)
+		}
+	})
+	t.Run("missing CSRF token", func(t *testing.T) {
+		_, cookie, _ := get(t)
+		if w := post(cookie, login("")); w.Code != http.StatusForbidden {
+			t.Fatalf("%d", w.Code)
+		}
+		if w := post(nil, login(cookie.Value)); w.Code != http.StatusForbidden {
+			t.Fatalf("no cookie: %d", w.Code)
+		}
+	})
+	t.Run("mismatched CSRF token", func(t *testing.T) {
+		_, cookie, _ := get(t)
+		if w := post(cookie, login("forged")); w.Code != http.StatusForbidden || len(sessions.created) != 0 {
+			t.Fatalf("%d, sessions %d", w.Code, len(sessions.created))
+		}
+	})
+	t.Run("wrong password", func(t *testing.T) {
+		_, cookie, token := get(t)
+		form := login(token)
+		form.Set("password", "nope")
+		if w := post(cookie, form); w.Code != http.StatusUnauthorized || len(sessions.created) != 0 {
+			t.Fatalf("%d", w.Code)
+		}
+	})
+	t.Run("success resumes the authorization request", func(t *testing.T) {
+		_, cookie, token := get(t)
+		w := post(cookie, login(token))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != returnTo {
+			t.Fatalf("%d Location %q", w.Code, w.Header().Get("Location"))
+		}
+		if len(sessions.created) != 1 || sessions.created[0].UserID != alice {
+			t.Fatalf("sessions %+v", sessions.created)
+		}
+		var sid bool
+		for _, c := range w.Result().Cookies() {
+			sid = sid || (c.Name == "sid" && c.Value != "")
+		}
+		if !sid {
+			t.Fatal("no session cookie")
+		}
+	})
+	t.Run("unsafe return_to falls back to the root", func(t *testing.T) {
+		_, cookie, token := get(t)
+		form := login(token)
+		form.Set("return_to", "//evil.example.com/authorize")
+		if w := post(cookie, form); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+			t.Fatalf("%d Location %q", w.Code, w.Header().Get("Location"))
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+)
+
+// LogoutPath is the end_session_endpoint.
+const LogoutPath = "/logout"
+
+// LogoutHandler implements OIDC RP-Initiated Logout 1.0. It ends the browser session behind the
+// sid cookie and sends the user agent to a registered post_logout_redirect_uri, or shows a
+// signed-out page. With templates the signed-out page also performs Front-Channel Logout 1.0,
+// loading each participating client's frontchannel_logout_uri before any redirect.
+type LogoutHandler struct {
+	End      usecase.EndSession
+	Sessions repository.SessionRepository
+	Tokens   dservice.TokenService // verifies id_token_hint; the hint is ignored when nil
+	Issuer   *IssuerResolver
+	// Templates render the confirmation and signed-out pages. Without them the session is ended
+	// without asking and front-channel logout is skipped.
+	Templates    *ui.Templates
+	RevokeTokens bool // also revoke the tokens granted within the session
+}
+
+type logoutPage struct {
+	Action    string
+	CSRFToken string
+	Confirm   bool // ask before ending the session
+	SignedOut bool
+	Params    url.Values
+	Locale    string
+	// FrontchannelURIs are loaded in hidden iframes; once they have loaded the page continues to
+	// RedirectTo, if set.
+	FrontchannelURIs []string
+	RedirectTo       string
+}
+
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "GET or POST required", "")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed request", "")
+		return
+	}
+	q := r.Form
+	state := q.Get("state")
+	in := usecase.EndSessionInput{ClientID: q.Get("client_id"), PostLogoutRedirectURI: q.Get("post_logout_redirect_uri"), RevokeTokens: h.RevokeTokens, Issuer: resolveIssuer(h.Issuer, r)}
+	hintSubject := ""
+	if hint := q.Get("id_token_hint"); hint != "" && h.Tokens != nil {
+		claims, err := h.Tokens.ValidateIDTokenHint(r.Context(), hint)
+		if err == nil && claims.Issuer != in.Issuer {
+			err = errors.New("issuer mismatch")
+		}
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid id_token_hint", state)
+			return
+		}
+		switch {
+		case in.ClientID == "" && len(claims.Audience) == 1:
+			in.ClientID = claims.Audience[0]
+		case in.ClientID != "" && !slices.Contains(claims.Audience, in.ClientID):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "id_token_hint was not issued to client_id", state)
+			return
+		}
+		hintSubject = claims.Subject
+	}
+	if err := h.End.Validate(r.Context(), in); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidClient):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "post_logout_redirect_uri requires a known client_id or id_token_hint", state)
+		case errors.Is(err, usecase.ErrInvalidRedirectURI):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "post_logout_redirect_uri not registered for client", state)
+		default:
+			log.Printf("logout validate error: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Logout failed", state)
+		}
+		return
+	}
+
+	sess := sessionFor(r, h.Sessions)
+	// Anyone can link to /logout, so unless an id_token_hint names the session's user the user is
+	// asked first (section 2 of the spec).
+	if sess != nil && hintSubject != sess.UserID.String() && h.Templates != nil {
+		decision := ""
+		if r.Method == http.MethodPost {
+			decision = r.PostForm.Get("logout")
+		}
+		switch decision {
+		case "":
+			h.render(w, r, logoutPage{Confirm: true})
+			return
+		case "confirm":
+			if !validCSRF(r) {
+				http.Error(w, "invalid csrf token", http.StatusForbidden)
+				return
+			}
+		default:
+			h.finish(w, r, in.PostLogoutRedirectURI, state, nil)
+			return
+		}
+	}
+	if sess != nil {
+		in.SessionID = sess.ID
+	}
+	out, err := h.End.Execute(r.Context(), in)
+	if err != nil {
+		log.Printf("logout error: %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Logout failed", state)
+		return
+	}
+	clearSessionCookie(w, r)
+	h.finish(w, r, in.PostLogoutRedirectURI, state, out)
+}
+
+// finish redirects to the validated post_logout_redirect_uri with state, or shows the outcome.
+// out is nil when the user chose to stay signed in. Front-channel notifications need the page, so
+// with any to send the redirect happens from the page instead.
+func (h *LogoutHandler) finish(w http.ResponseWriter, r *http.Request, redirectURI, state string, out *usecase.EndSessionOutput) {
+	w.Header().Set("Cache-Control", "no-store")
+	page := logoutPage{SignedOut: out != nil}
+	if out != nil && h.Templates != nil {
+		page.FrontchannelURIs = out.FrontchannelLogoutURIs
+	}
+	if redirectURI != "" {
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed post_logout_redirect_uri", "")
+			return
+		}
+		if state != "" {
+			v := u.Query()
+			v.Set("state", state)
+			u.RawQuery = v.Encode()
+		}
+		if len(page.FrontchannelURIs) == 0 {
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+		page.RedirectTo = u.String()
+	}
+	if h.Templates == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Signed out\n"))
+		return
+	}
+	h.render(w, r, page)
+}
+
+func (h *LogoutHandler) render(w http.ResponseWriter, r *http.Request, page logoutPage) {
+	page.Action = h.Issuer.Path() + LogoutPath
+	token, err := csrfToken(w, r, page.Action)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	page.CSRFToken = token
+	page.Locale = uiLocale(r.Form.Get("ui_locales"))
+	page.Params = url.Values{}
+	for k, vs := range r.Form {
+		if k != "csrf_token" && k != "logout" {
+			page.Params[k] = vs
+		}
+	}
+	if err := h.Templates.Render(w, http.StatusOK, "logout.html", page); err != nil {
+		log.Printf("ui logout: render: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestLogoutIDTokenHint(t *testing.T) {
+	const issuer = "https://sso.example.com"
+	// No issuer in the token service, as when it is derived per request: the handler checks iss.
+	tokens := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), iservice.TokenValidation{})
+	issuers, _ := NewIssuerResolver(issuer, nil, nil)
+	end := &fakeEndSession{}
+	h := &LogoutHandler{End: end, Sessions: testfake.NewSessions(), Tokens: tokens, Issuer: issuers}
+
+	hintClaims := func(iss, aud string) vo.JWTClaims {
+		now := time.Now()
+		return vo.JWTClaims{Subject: uuid.NewString(), Issuer: iss, Audience: []string{aud}, ClientID: aud, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	}
+	idTokenFor := func(iss, aud string) string {
+		tok, err := tokens.IssueIDToken(context.Background(), hintClaims(iss, aud), time.Minute, dservice.IDTokenOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	idToken := func(iss string) string { return idTokenFor(iss, "rp") }
+	// doFor sends a logout request from client_id with the hint; an empty clientID omits it.
+	doFor := func(clientID, hint string) *httptest.ResponseRecorder {
+		q := url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://rp.example.com/bye"}, "state": {"s1"}}
+		if clientID != "" {
+			q.Set("client_id", clientID)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, LogoutPath+"?"+q.Encode(), nil))
+		return w
+	}
+	do := func(hint string) *httptest.ResponseRecorder { return doFor("", hint) }
+
+	if w := do(idToken("https://other.example.com")); w.Code != http.StatusBadRequest || len(end.ended) != 0 {
+		t.Fatalf("hint from another issuer: %d %s", w.Code, w.Body)
+	}
+	if w := doFor("rp", idTokenFor(issuer, "other")); w.Code != http.StatusBadRequest || len(end.ended) != 0 {
+		t.Fatalf("hint issued to another client: %d %s", w.Code, w.Body)
+	}
+	access, err := tokens.IssueAccessToken(context.Background(), hintClaims(issuer, "rp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := do(access.AccessToken); w.Code != http.StatusBadRequest || len(end.ended) != 0 {
+		t.Fatalf("access token as hint: %d %s", w.Code, w.Body)
+	}
+	w := do(idToken(issuer))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://rp.example.com/bye?state=s1" {
+		t.Fatalf("hint from this issuer: %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if len(end.ended) != 1 || end.ended[0].ClientID != "rp" || end.ended[0].Issuer != issuer {
+		t.Fatalf("ended %+v", end.ended)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	templates, err := ui.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuers, _ := NewIssuerResolver("https://sso.example.com", nil, nil)
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	// do sends a logout request with the session cookie; form is posted when non-nil.
+	do := func(h *LogoutHandler, query, form url.Values, csrf string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if form != nil {
+			r = httptest.NewRequest(http.MethodPost, LogoutPath+"?"+query.Encode(), strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			r = httptest.NewRequest(http.MethodGet, LogoutPath+"?"+query.Encode(), nil)
+		}
+		r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		if csrf != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrf})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	clearsSession := func(w *httptest.ResponseRecorder) bool {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "sid" && c.MaxAge < 0 {
+				return true
+			}
+		}
+		return false
+	}
+	redirect := url.Values{"client_id": {"rp"}, "post_logout_redirect_uri": {"https://rp.example.com/bye"}, "state": {"s1"}}
+
+	t.Run("ends the session and clears the cookie", func(t *testing.T) {
+		end := &fakeEndSession{}
+		h := &LogoutHandler{End: end, Sessions: testfake.NewSessions(sess), Issuer: issuers, RevokeTokens: true}
+		w := do(h, redirect, nil, "")
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "https://rp.example.com/bye?state=s1" {
+			t.Fatalf("%d %q", w.Code, w.Header().Get("Location"))
+		}
+		if len(end.ended) != 1 || end.ended[0].SessionID != sess.ID || !end.ended[0].RevokeTokens {
+			t.Fatalf("ended %+v", end.ended)
+		}
+		if !clearsSession(w) {
+			t.Fatal("sid cookie not cleared")
+		}
+	})
+	t.Run("without a redirect shows the signed-out page", func(t *testing.T) {
+		end := &fakeEndSession{}
+		w := do(&LogoutHandler{End: end, Sessions: testfake.NewSessions(sess), Issuer: issuers}, url.Values{}, nil, "")
+		if w.Code != http.StatusOK || len(end.ended) != 1 || end.ended[0].RevokeTokens {
+			t.Fatalf("%d ended %+v", w.Code, end.ended)
+		}
+	})
+	for name, q := range map[string]url.Values{
+		"unregistered redirect":    {"client_id": {"rp"}, "post_logout_redirect_uri": {"https://evil.example.com/"}},
+		"redirect without client":  {"post_logout_redirect_uri": {"https://rp.example.com/bye"}},
+		"redirect of other client": {"client_id": {"other"}, "post_logout_redirect_uri": {"https://rp.example.com/bye"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			end := &fakeEndSession{}
+			w := do(&LogoutHandler{End: end, Sessions: testfake.NewSessions(sess), Issuer: issuers}, q, nil, "")
+			if w.Code != http.StatusBadRequest || len(end.ended) != 0 || clearsSession(w) || w.Header().Get("Location") != "" {
+				t.Fatalf("%d ended %d %s", w.Code, len(end.ended), w.Body)
+			}
+		})
+	}
+
+	t.Run("asks before ending a session without a hint", func(t *testing.T) {
+		end := &fakeEndSession{}
+		h := &LogoutHandler{End: end, Sessions: testfake.NewSessions(sess), Issuer: issuers, Templates: templates}
+		if w := do(h, redirect, nil, ""); w.Code != http.StatusOK || len(end.ended) != 0 || !strings.Contains(w.Body.String(), `name="csrf_token"`) {
+			t.Fatalf("%d ended %d", w.Code, len(end.ended))
+		}
+		if w := do(h, redirect, url.Values{"logout": {"confirm"}, "csrf_token": {"tok"}}, "other"); w.Code != http.StatusForbidden || len(end.ended) != 0 {
+			t.Fatalf("bad csrf: %d ended %d", w.Code, len(end.ended))
+		}
+		w := do(h, redirect, url.Values{"logout": {"cancel"}, "csrf_token": {"tok"}}, "tok")
+		if w.Code != http.StatusFound || len(end.ended) != 0 || clearsSession(w) {
+			t.Fatalf("cancel: %d ended %d", w.Code, len(end.ended))
+		}
+		w = do(h, redirect, url.Values{"logout": {"confirm"}, "csrf_token": {"tok"}}, "tok")
+		if w.Code != http.StatusFound || len(end.ended) != 1 || !clearsSession(w) {
+			t.Fatalf("confirm: %d ended %d", w.Code, len(end.ended))
+		}
+	})
+}
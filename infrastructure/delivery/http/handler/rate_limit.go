@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit caps each client IP at rpm requests per fixed one-minute window and answers the
+// excess with 429. A non-positive rpm returns next unchanged.
+func RateLimit(next http.Handler, rpm int) http.Handler {
+	if rpm <= 0 {
+		return next
+	}
+	l := &rateLimiter{rpm: rpm, counts: map[string]int{}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retry, ok := l.allow(extractIP(r.RemoteAddr), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+			writeOAuthError(w, http.StatusTooManyRequests, "temporarily_unavailable", "Too many requests", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type rateLimiter struct {
+	mu     sync.Mutex
+	rpm    int
+	window time.Time
+	counts map[string]int
+}
+
+// allow counts a request from ip; when over the limit it reports the time left in the window.
+func (l *rateLimiter) allow(ip string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if start := now.Truncate(time.Minute); !start.Equal(l.window) {
+		l.window = start
+		l.counts = map[string]int{}
+	}
+	l.counts[ip]++
+	if l.counts[ip] > l.rpm {
+		return l.window.Add(time.Minute).Sub(now), false
+	}
+	return 0, true
+}
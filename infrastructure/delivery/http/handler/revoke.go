@@ -1,52 +1,53 @@
 package handler
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
-	"io"
+	"errors"
 	"net/http"
-	"strings"
 
-	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/usecase"
 )
 
-type RevokeHandler struct{ Tokens repository.TokenRepository }
+// RevokeHandler implements RFC 7009 token revocation for authenticated clients.
+type RevokeHandler struct {
+	Revoke     usecase.RevokeToken
+	ClientAuth dservice.ClientAuthenticator
+	Issuer     *IssuerResolver
+}
 
 func (h *RevokeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	_ = r.ParseForm()
-	// Accept token or refresh_token param; accept form, query or JSON body.
-	rt := r.Form.Get("refresh_token")
-	if rt == "" {
-		rt = r.Form.Get("token")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "POST required", "")
+		return
 	}
-	// If still empty, try JSON body
-	if rt == "" {
-		// Read body into a small buffer
-		body, _ := io.ReadAll(r.Body)
-		// allow subsequent handlers to not rely on body (we don't reuse r.Body here)
-		if len(body) > 0 {
-			var m map[string]string
-			if err := json.Unmarshal(body, &m); err == nil {
-				if v, ok := m["refresh_token"]; ok && v != "" {
-					rt = v
-				} else if v, ok := m["token"]; ok && v != "" {
-					rt = v
-				}
-			}
-		}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed form body", "")
+		return
 	}
-	if strings.TrimSpace(rt) == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(map[string]any{"error": "missing token"})
+	client, ok := authenticateClient(w, r, h.ClientAuth, h.Issuer, "/revoke")
+	if !ok {
 		return
 	}
-
-	// If the client supplied the raw refresh token (opaque base64), convert to stored id via sha256 hex.
-	hash := sha256.Sum256([]byte(rt))
-	refreshID := hex.EncodeToString(hash[:])
-
-	_ = h.Tokens.RevokeByRefreshID(r.Context(), refreshID)
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing token", "")
+		return
+	}
+	err := h.Revoke.Execute(r.Context(), usecase.RevokeTokenInput{
+		Token:         token,
+		TokenTypeHint: r.PostForm.Get("token_type_hint"),
+		ClientID:      client.ClientID,
+		Issuer:        resolveIssuer(h.Issuer, r),
+	})
+	switch {
+	case errors.Is(err, usecase.ErrUnauthorizedClient):
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "Token was not issued to this client", "")
+		return
+	case err != nil:
+		writeOAuthError(w, http.StatusServiceUnavailable, "server_error", "Revocation failed", "")
+		return
+	}
+	// Unknown or already invalid tokens are answered the same way (RFC 7009 section 2.2).
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(map[string]any{"revoked": true})
 }
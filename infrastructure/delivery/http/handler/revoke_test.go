@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+func TestRevokeHandler(t *testing.T) {
+	uc := &fakeRevoke{owner: map[string]string{"rp-token": "rp"}}
+	h := &RevokeHandler{
+		Revoke: uc,
+		ClientAuth: &fakeClientAuth{
+			clients: map[string]*entity.Client{"rp": newTestClient("rp", true), "other": newTestClient("other", true)},
+			secrets: map[string]string{"rp": "s3cret", "other": "s3cret"},
+		},
+	}
+	do := func(user, tok string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {tok}}
+		r := httptest.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth(user, "s3cret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	errorOf := func(w *httptest.ResponseRecorder) string {
+		var body OAuthError
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Error
+	}
+
+	if w := do("rp", "unknown"); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("unknown token: %d %s", w.Code, w.Body)
+	}
+	if w := do("other", "rp-token"); w.Code != http.StatusBadRequest || errorOf(w) != "unauthorized_client" || len(uc.revoked) != 0 {
+		t.Fatalf("another client's token: %d %s, revoked %v", w.Code, w.Body, uc.revoked)
+	}
+	if w := do("rp", "rp-token"); w.Code != http.StatusOK || len(uc.revoked) != 1 {
+		t.Fatalf("own token: %d %s, revoked %v", w.Code, w.Body, uc.revoked)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CheckSessionPath is the check_session_iframe of OIDC Session Management 1.0.
+const CheckSessionPath = "/check_session"
+
+// browserStateCookie holds the OP browser state the check_session iframe hashes into
+// session_state. Script in the iframe reads it, so unlike sid it is not HttpOnly; its value is
+// derived from the session ID and reveals nothing that would let it be used in place of sid.
+const browserStateCookie = "sso_bs"
+
+// browserState derives the OP browser state of the session sid.
+func browserState(sid string) string {
+	sum := sha256.Sum256([]byte("sso browser state " + sid))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// setBrowserStateCookie writes the browser state cookie next to the sid cookie. The iframe runs
+// in the relying party's page, where a cross-site cookie is only visible with SameSite=None,
+// which browsers accept only on Secure cookies; plain-HTTP development falls back to Lax.
+func setBrowserStateCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int, expires time.Time) {
+	sameSite := http.SameSiteLaxMode
+	if r.TLS != nil {
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, &http.Cookie{Name: browserStateCookie, Value: value, Path: "/", Secure: r.TLS != nil, SameSite: sameSite, MaxAge: maxAge, Expires: expires})
+}
+
+// sessionState computes the session_state returned with an authorization response (section 3):
+// a salted hash of the client, the redirect_uri's origin and the browser state. The iframe repeats
+// the computation with the origin of the page asking and the current cookie.
+func sessionState(clientID, redirectURI, sid string) string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	salt := hex.EncodeToString(b[:])
+	sum := sha256.Sum256([]byte(clientID + " " + origin(redirectURI) + " " + browserState(sid) + " " + salt))
+	return hex.EncodeToString(sum[:]) + "." + salt
+}
+
+// origin serializes the origin of rawURL the way browsers report MessageEvent.origin.
+func origin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if p := u.Port(); p != "" && !(scheme == "http" && p == "80") && !(scheme == "https" && p == "443") {
+		host += ":" + p
+	}
+	return scheme + "://" + host
+}
+
+// CheckSessionHandler serves the check_session_iframe. Relying parties embed it and post
+// "client_id session_state"; it answers "unchanged", "changed" or "error" without a round trip to
+// the server. Unlike the other pages it must be frameable by any origin.
+type CheckSessionHandler struct{}
+
+func (CheckSessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write([]byte(checkSessionPage))
+}
+
+const checkSessionPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>check_session</title></head>
+<body>
+<script>
+(function () {
+  function browserState() {
+    var name = "` + browserStateCookie + `=", parts = document.cookie.split("; ");
+    for (var i = 0; i < parts.length; i++) {
+      if (parts[i].indexOf(name) === 0) {
+        return parts[i].substring(name.length);
+      }
+    }
+    return "";
+  }
+  function hex(buf) {
+    return Array.prototype.map.call(new Uint8Array(buf), function (b) {
+      return ("0" + b.toString(16)).slice(-2);
+    }).join("");
+  }
+  window.addEventListener("message", function (e) {
+    var reply = function (status) { e.source.postMessage(status, e.origin); };
+    var msg = typeof e.data === "string" ? e.data.split(" ") : [];
+    var dot = msg.length === 2 ? msg[1].lastIndexOf(".") : -1;
+    if (dot < 0 || !window.crypto || !crypto.subtle) {
+      reply("error");
+      return;
+    }
+    var salt = msg[1].substring(dot + 1);
+    var data = new TextEncoder().encode(msg[0] + " " + e.origin + " " + browserState() + " " + salt);
+    crypto.subtle.digest("SHA-256", data).then(function (sum) {
+      reply(hex(sum) + "." + salt === msg[1] ? "unchanged" : "changed");
+    }, function () { reply("error"); });
+  });
+})();
+</script>
+</body>
+</html>
+`
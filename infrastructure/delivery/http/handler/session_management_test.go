@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestOrigin(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://rp.example.com/cb":         "https://rp.example.com",
+		"https://RP.Example.com:443/cb?x=1": "https://rp.example.com",
+		"http://rp.example.com:80/cb":       "http://rp.example.com",
+		"http://localhost:8080/cb":          "http://localhost:8080",
+		"https://rp.example.com:80/cb":      "https://rp.example.com:80",
+		"https://[::1]:8443/cb":             "https://[::1]:8443",
+	} {
+		if got := origin(in); got != want {
+			t.Errorf("origin(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// checkSession repeats the check_session iframe's computation for a message from pageOrigin.
+func checkSession(clientID, pageOrigin, browserState, state string) bool {
+	dot := strings.LastIndex(state, ".")
+	if dot < 0 {
+		return false
+	}
+	salt := state[dot+1:]
+	sum := sha256.Sum256([]byte(clientID + " " + pageOrigin + " " + browserState + " " + salt))
+	return hex.EncodeToString(sum[:])+"."+salt == state
+}
+
+func TestSessionState(t *testing.T) {
+	sid := uuid.NewString()
+	state := sessionState("rp", "https://rp.example.com/cb", sid)
+	if !checkSession("rp", "https://rp.example.com", browserState(sid), state) {
+		t.Fatalf("the iframe cannot verify %q", state)
+	}
+	if again := sessionState("rp", "https://rp.example.com/cb", sid); again == state {
+		t.Fatal("session_state must be salted")
+	}
+	for name, ok := range map[string]bool{
+		"other client":  checkSession("other", "https://rp.example.com", browserState(sid), state),
+		"other origin":  checkSession("rp", "https://evil.example.com", browserState(sid), state),
+		"other session": checkSession("rp", "https://rp.example.com", browserState(uuid.NewString()), state),
+		"signed out":    checkSession("rp", "https://rp.example.com", "", state),
+	} {
+		if ok {
+			t.Errorf("%s: reported unchanged", name)
+		}
+	}
+}
+
+func TestAuthorizeSessionState(t *testing.T) {
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	h := &AuthorizeHandler{Start: &fakeStart{}, Sessions: testfake.NewSessions(sess)}
+	q := url.Values{"response_type": {"code"}, "client_id": {"rp"}, "redirect_uri": {"https://rp.example.com/cb"}, "scope": {"openid"}}
+	r := httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil)
+	r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || w.Code != http.StatusFound {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	var bs *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == browserStateCookie {
+			bs = c
+		}
+	}
+	if bs == nil || bs.HttpOnly || bs.Value != browserState(sess.ID.String()) {
+		t.Fatalf("browser state cookie %+v: the iframe must be able to read it", bs)
+	}
+	if state := u.Query().Get("session_state"); !checkSession("rp", "https://rp.example.com", bs.Value, state) {
+		t.Fatalf("session_state %q does not verify against the cookie", state)
+	}
+}
+
+func TestCheckSessionHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	CheckSessionHandler{}.ServeHTTP(w, httptest.NewRequest(http.MethodGet, CheckSessionPath, nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), browserStateCookie+"=") {
+		t.Fatalf("%d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("X-Frame-Options") != "" {
+		t.Fatal("relying parties must be able to frame the iframe")
+	}
+	w = httptest.NewRecorder()
+	CheckSessionHandler{}.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CheckSessionPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: %d", w.Code)
+	}
+}
+
+func TestLogoutFrontchannel(t *testing.T) {
+	templates, err := ui.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuers, _ := NewIssuerResolver("https://sso.example.com", nil, nil)
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	frontchannel := []string{"https://rp.example.com/fc?iss=https%3A%2F%2Fsso.example.com&sid=" + sess.ID.String(), "https://legacy.example.com/logout"}
+	do := func(h *LogoutHandler) *httptest.ResponseRecorder {
+		q := url.Values{"client_id": {"rp"}, "post_logout_redirect_uri": {"https://rp.example.com/bye"}, "state": {"s1"}}
+		form := url.Values{"logout": {"confirm"}, "csrf_token": {"tok"}}
+		r := httptest.NewRequest(http.MethodPost, LogoutPath+"?"+q.Encode(), strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "tok"})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(&LogoutHandler{End: &fakeEndSession{frontchannel: frontchannel}, Sessions: testfake.NewSessions(sess), Issuer: issuers, Templates: templates})
+	body := html.UnescapeString(w.Body.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d: the redirect waits for the iframes", w.Code)
+	}
+	for _, uri := range frontchannel {
+		if !strings.Contains(body, `<iframe src="`+uri+`"`) {
+			t.Errorf("no iframe for %s:\n%s", uri, body)
+		}
+	}
+	if !strings.Contains(body, "https://rp.example.com/bye?state=s1") {
+		t.Errorf("the page does not continue to the post_logout_redirect_uri:\n%s", body)
+	}
+	var cleared []string
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			cleared = append(cleared, c.Name)
+		}
+	}
+	if strings.Join(cleared, " ") != "sid "+browserStateCookie {
+		t.Errorf("cleared cookies %v, want sid and %s", cleared, browserStateCookie)
+	}
+
+	// Without templates there is no page to load the iframes from.
+	w = do(&LogoutHandler{End: &fakeEndSession{frontchannel: frontchannel}, Sessions: testfake.NewSessions(sess), Issuer: issuers})
+	if w.Code != http.StatusFound {
+		t.Fatalf("without templates: %d", w.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	resp "github.com/RanguraGIT/sso/infrastructure/delivery/http/response"
+)
+
+// AdminSessionsScope must be granted to an access token used on AdminSessionsHandler, typically
+// through the client_credentials grant of a support tool registered with that scope.
+const AdminSessionsScope = "sessions:admin"
+
+// SessionsHandler lets the signed-in user (sid cookie) list their live sessions with GET, end one
+// with DELETE ?id=... and end all but the current one with DELETE without id.
+type SessionsHandler struct {
+	UserSessions usecase.UserSessions
+	Sessions     repository.SessionRepository
+	Issuer       *IssuerResolver
+}
+
+func (h *SessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	current := sessionFor(r, h.Sessions)
+	if current == nil {
+		resp.JSON(w, http.StatusUnauthorized, map[string]string{"error": "login required"})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.Method {
+	case http.MethodGet:
+		list, ok := listSessions(w, r, h.UserSessions, current.UserID)
+		if !ok {
+			return
+		}
+		for i := range list {
+			list[i].Current = list[i].ID == current.ID
+		}
+		resp.JSON(w, http.StatusOK, map[string]any{"sessions": list})
+	case http.MethodDelete:
+		if r.URL.Query().Get("id") == "" {
+			terminateAllSessions(w, r, h.UserSessions, current.UserID, current.ID, resolveIssuer(h.Issuer, r))
+			return
+		}
+		id, ok := terminateSession(w, r, h.UserSessions, current.UserID, resolveIssuer(h.Issuer, r))
+		if !ok {
+			return
+		}
+		if id == current.ID {
+			clearSessionCookie(w, r)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		resp.JSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// AdminSessionsHandler lets support staff act on any user's sessions: GET ?user_id=... lists them,
+// DELETE ?user_id=...&id=... ends one and DELETE ?user_id=... ends them all. Requests carry a
+// bearer access token granted AdminSessionsScope.
+type AdminSessionsHandler struct {
+	UserSessions usecase.UserSessions
+	TokenService dservice.TokenService
+	Issuer       *IssuerResolver
+}
+
+func (h *AdminSessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		resp.JSON(w, http.StatusBadRequest, map[string]string{"error": "user_id required"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if list, ok := listSessions(w, r, h.UserSessions, userID); ok {
+			resp.JSON(w, http.StatusOK, map[string]any{"sessions": list})
+		}
+	case http.MethodDelete:
+		if r.URL.Query().Get("id") == "" {
+			log.Printf("admin sessions: client=%s terminating all sessions of user=%s", caller, userID)
+			terminateAllSessions(w, r, h.UserSessions, userID, uuid.Nil, resolveIssuer(h.Issuer, r))
+			return
+		}
+		if id, ok := terminateSession(w, r, h.UserSessions, userID, resolveIssuer(h.Issuer, r)); ok {
+			log.Printf("admin sessions: client=%s terminated session=%s of user=%s", caller, id, userID)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		resp.JSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// authorize checks the bearer token and returns the client it was issued to.
+func (h *AdminSessionsHandler) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	parts := strings.Fields(r.Header.Get("Authorization"))
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		w.Header().Set("WWW-Authenticate", `Bearer scope="`+AdminSessionsScope+`"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "Bearer token required", "")
+		return "", false
+	}
+	claims, err := h.TokenService.ValidateAccessToken(r.Context(), parts[1])
+	if err != nil {
+		writeBearerError(w, "invalid_token", accessTokenErrorDescription(err))
+		return "", false
+	}
+	if claims.Issuer != resolveIssuer(h.Issuer, r) {
+		writeBearerError(w, "invalid_token", "Token was issued by a different issuer")
+		return "", false
+	}
+	if !enum.ParseScopeString(claims.Scope).Has(AdminSessionsScope) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+AdminSessionsScope+`"`)
+		writeOAuthError(w, http.StatusForbidden, "insufficient_scope", "Token lacks the "+AdminSessionsScope+" scope", "")
+		return "", false
+	}
+	return claims.ClientID, true
+}
+
+func listSessions(w http.ResponseWriter, r *http.Request, uc usecase.UserSessions, userID uuid.UUID) ([]usecase.SessionInfo, bool) {
+	list, err := uc.List(r.Context(), userID)
+	if err != nil {
+		log.Printf("sessions: list user=%s err=%v", userID, err)
+		resp.JSON(w, http.StatusInternalServerError, map[string]string{"error": "session lookup failed"})
+		return nil, false
+	}
+	return list, true
+}
+
+// terminateSession ends the session named by the id parameter, answering errors itself.
+func terminateSession(w http.ResponseWriter, r *http.Request, uc usecase.UserSessions, userID uuid.UUID, issuer string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		resp.JSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return uuid.Nil, false
+	}
+	if err := uc.Terminate(r.Context(), userID, id, issuer); err != nil {
+		if errors.Is(err, usecase.ErrSessionNotFound) {
+			resp.JSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+			return uuid.Nil, false
+		}
+		log.Printf("sessions: terminate user=%s session=%s err=%v", userID, id, err)
+		resp.JSON(w, http.StatusInternalServerError, map[string]string{"error": "session termination failed"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func terminateAllSessions(w http.ResponseWriter, r *http.Request, uc usecase.UserSessions, userID, keep uuid.UUID, issuer string) {
+	n, err := uc.TerminateAll(r.Context(), userID, keep, issuer)
+	if err != nil {
+		log.Printf("sessions: terminate all user=%s err=%v", userID, err)
+		resp.JSON(w, http.StatusInternalServerError, map[string]string{"error": "session termination failed"})
+		return
+	}
+	resp.JSON(w, http.StatusOK, map[string]int{"terminated": n})
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestSessionsHandler(t *testing.T) {
+	current, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	other := uuid.New()
+	setup := func() (*SessionsHandler, *fakeUserSessions) {
+		uc := &fakeUserSessions{sessions: []usecase.SessionInfo{{ID: current.ID}, {ID: other}}}
+		issuers, _ := NewIssuerResolver("https://sso.example.com", nil, nil)
+		return &SessionsHandler{UserSessions: uc, Sessions: testfake.NewSessions(current), Issuer: issuers}, uc
+	}
+	do := func(h *SessionsHandler, method, target string, withSession bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if withSession {
+			r.AddCookie(&http.Cookie{Name: "sid", Value: current.ID.String()})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	clearsSession := func(w *httptest.ResponseRecorder) bool {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "sid" && c.MaxAge < 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("login required", func(t *testing.T) {
+		h, _ := setup()
+		if w := do(h, http.MethodGet, "/sessions", false); w.Code != http.StatusUnauthorized {
+			t.Fatalf("%d", w.Code)
+		}
+	})
+	t.Run("list marks the current session", func(t *testing.T) {
+		h, _ := setup()
+		w := do(h, http.MethodGet, "/sessions", true)
+		var body struct{ Sessions []usecase.SessionInfo }
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil || w.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		if len(body.Sessions) != 2 || !body.Sessions[0].Current || body.Sessions[1].Current {
+			t.Fatalf("sessions %+v", body.Sessions)
+		}
+	})
+	t.Run("terminate another session", func(t *testing.T) {
+		h, uc := setup()
+		w := do(h, http.MethodDelete, "/sessions?id="+other.String(), true)
+		if w.Code != http.StatusNoContent || !slices.Equal(uc.terminated, []uuid.UUID{other}) || clearsSession(w) {
+			t.Fatalf("%d terminated %v", w.Code, uc.terminated)
+		}
+	})
+	t.Run("terminate the current session signs out", func(t *testing.T) {
+		h, _ := setup()
+		if w := do(h, http.MethodDelete, "/sessions?id="+current.ID.String(), true); w.Code != http.StatusNoContent || !clearsSession(w) {
+			t.Fatalf("%d", w.Code)
+		}
+	})
+	for name, id := range map[string]string{"unknown": uuid.NewString(), "malformed": "nope"} {
+		t.Run("terminate "+name+" session", func(t *testing.T) {
+			h, uc := setup()
+			if w := do(h, http.MethodDelete, "/sessions?id="+id, true); w.Code != http.StatusNotFound || len(uc.terminated) != 0 {
+				t.Fatalf("%d", w.Code)
+			}
+		})
+	}
+	t.Run("terminate all keeps the current session", func(t *testing.T) {
+		h, uc := setup()
+		w := do(h, http.MethodDelete, "/sessions", true)
+		var body struct{ Terminated int }
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil || body.Terminated != 1 {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		if !slices.Equal(uc.kept, []uuid.UUID{current.ID}) || clearsSession(w) {
+			t.Fatalf("kept %v", uc.kept)
+		}
+	})
+}
+
+func TestAdminSessionsHandler(t *testing.T) {
+	const issuer = "https://sso.example.com"
+	tokens := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), iservice.TokenValidation{})
+	issuers, _ := NewIssuerResolver(issuer, nil, nil)
+	user, sess := uuid.New(), uuid.New()
+	setup := func() (*AdminSessionsHandler, *fakeUserSessions) {
+		uc := &fakeUserSessions{sessions: []usecase.SessionInfo{{ID: sess}}}
+		return &AdminSessionsHandler{UserSessions: uc, TokenService: tokens, Issuer: issuers}, uc
+	}
+	bearer := func(iss, scope string) string {
+		now := time.Now()
+		res, err := tokens.IssueAccessToken(context.Background(), vo.JWTClaims{Subject: "support", ClientID: "support", Issuer: iss, Audience: []string{"sso"}, Scope: scope, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.AccessToken
+	}
+	do := func(h *AdminSessionsHandler, method, target, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	admin := bearer(issuer, "openid "+AdminSessionsScope)
+	list := "/admin/sessions?user_id=" + user.String()
+
+	for name, tc := range map[string]struct {
+		token string
+		code  int
+	}{
+		"no token":          {"", http.StatusUnauthorized},
+		"malformed token":   {"nope", http.StatusUnauthorized},
+		"other issuer":      {bearer("https://other.example.com", AdminSessionsScope), http.StatusUnauthorized},
+		"missing the scope": {bearer(issuer, "openid"), http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h, _ := setup()
+			w := do(h, http.MethodGet, list, tc.token)
+			if w.Code != tc.code || w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatalf("%d %v", w.Code, w.Header())
+			}
+		})
+	}
+	t.Run("list", func(t *testing.T) {
+		h, _ := setup()
+		w := do(h, http.MethodGet, list, admin)
+		var body struct{ Sessions []usecase.SessionInfo }
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil || len(body.Sessions) != 1 || body.Sessions[0].Current {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("user_id required", func(t *testing.T) {
+		h, _ := setup()
+		if w := do(h, http.MethodGet, "/admin/sessions", admin); w.Code != http.StatusBadRequest {
+			t.Fatalf("%d", w.Code)
+		}
+	})
+	t.Run("terminate one", func(t *testing.T) {
+		h, uc := setup()
+		if w := do(h, http.MethodDelete, list+"&id="+sess.String(), admin); w.Code != http.StatusNoContent || !slices.Equal(uc.terminated, []uuid.UUID{sess}) {
+			t.Fatalf("%d terminated %v", w.Code, uc.terminated)
+		}
+	})
+	t.Run("terminate all", func(t *testing.T) {
+		h, uc := setup()
+		if w := do(h, http.MethodDelete, list, admin); w.Code != http.StatusOK || !slices.Equal(uc.kept, []uuid.UUID{uuid.Nil}) {
+			t.Fatalf("%d kept %v", w.Code, uc.kept)
+		}
+	})
+}
@@ -6,19 +6,62 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
 	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
 	"github.com/RanguraGIT/sso/domain/usecase"
 	"github.com/google/uuid"
 )
 
 type TokenHandler struct {
-	Issue   usecase.IssueToken
-	Refresh usecase.RefreshToken
-	Codes   repository.AuthorizationCodeRepository
+	Issue             usecase.IssueToken
+	Refresh           usecase.RefreshToken
+	ClientCredentials usecase.ClientCredentials
+	Codes             repository.AuthorizationCodeRepository
+	ClientAuth        dservice.ClientAuthenticator
+	Issuer            *IssuerResolver
+	AccessTTL         time.Duration // defaults to defaultAccessTTL
+	RefreshTTL        time.Duration // defaults to defaultRefreshTTL
+}
+
+const (
+	defaultAccessTTL  = 10 * time.Minute
+	defaultRefreshTTL = 24 * time.Hour
+)
+
+func (h *TokenHandler) accessTTL() time.Duration {
+	if h.AccessTTL > 0 {
+		return h.AccessTTL
+	}
+	return defaultAccessTTL
+}
+
+func (h *TokenHandler) refreshTTL() time.Duration {
+	if h.RefreshTTL > 0 {
+		return h.RefreshTTL
+	}
+	return defaultRefreshTTL
+}
+
+// GrantTypes lists the grants this handler has a usecase for, as advertised in discovery.
+func (h *TokenHandler) GrantTypes() []string {
+	var out []string
+	if h.Issue != nil {
+		out = append(out, enum.GrantTypeAuthorizationCode.String())
+	}
+	if h.Refresh != nil {
+		out = append(out, enum.GrantTypeRefreshToken.String())
+	}
+	if h.ClientCredentials != nil {
+		out = append(out, enum.GrantTypeClientCredentials.String())
+	}
+	return out
 }
 
 func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -26,22 +69,34 @@ func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed form body", "")
 		return
 	}
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
 	grantType := r.Form.Get("grant_type")
 	switch grantType {
 	case "authorization_code":
-		h.handleAuthorizationCode(w, r)
+		h.handleAuthorizationCode(w, r, client)
 	case "refresh_token":
-		h.handleRefreshToken(w, r)
+		h.handleRefreshToken(w, r, client)
+	case "client_credentials":
+		h.handleClientCredentials(w, r, client)
 	default:
 		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Grant type not supported", "")
 	}
 }
 
-func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+// authenticateClient resolves the calling client before any grant is processed. On failure the
+// error response has already been written.
+func (h *TokenHandler) authenticateClient(w http.ResponseWriter, r *http.Request) (*entity.Client, bool) {
+	return authenticateClient(w, r, h.ClientAuth, h.Issuer, "/token")
+}
+
+func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request, client *entity.Client) {
 	raw := r.Form.Get("refresh_token")
-	clientID := r.Form.Get("client_id")
-	if raw == "" || clientID == "" {
-		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing refresh_token or client_id", "")
+	clientID := client.ClientID
+	if raw == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing refresh_token", "")
 		return
 	}
 	// Hash the provided refresh token (base64url) with SHA-256 hex to obtain stored identifier.
@@ -49,19 +104,24 @@ func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request
 	refreshID := hex.EncodeToString(hash[:])
 	out, err := h.Refresh.Execute(r.Context(), usecase.RefreshTokenInput{
 		RefreshTokenID: refreshID,
-		Issuer:         issuerFromRequest(r),
+		ClientID:       clientID,
+		Issuer:         resolveIssuer(h.Issuer, r),
 		Audience:       []string{clientID},
-		AccessTTL:      10 * time.Minute,
-		RefreshTTL:     24 * time.Hour,
+		AccessTTL:      h.accessTTL(),
+		RefreshTTL:     h.refreshTTL(),
 	})
 	if err != nil {
-		// Avoid leaking internal SQL / system errors: map to generic messages unless recognized.
-		desc := "refresh token invalid or expired"
-		lower := strings.ToLower(err.Error())
-		if strings.Contains(lower, "reuse") || strings.Contains(lower, "rotated") {
-			desc = "refresh token reuse detected"
+		switch {
+		case errors.Is(err, usecase.ErrRefreshTokenReused), errors.Is(err, usecase.ErrRefreshTokenRotated):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token reuse detected", "")
+		case errors.Is(err, usecase.ErrRefreshClientMismatch):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token was issued to another client", "")
+		case errors.Is(err, usecase.ErrRefreshTokenInvalid), errors.Is(err, usecase.ErrRefreshTokenExpired):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token invalid or expired", "")
+		default:
+			log.Printf("token refresh error: %v", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Token refresh failed", "")
 		}
-		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", desc, "")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -74,13 +134,47 @@ func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request
 	})
 }
 
-func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Request) {
+func (h *TokenHandler) handleClientCredentials(w http.ResponseWriter, r *http.Request, client *entity.Client) {
+	if h.ClientCredentials == nil {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Grant type not supported", "")
+		return
+	}
+	out, err := h.ClientCredentials.Execute(r.Context(), usecase.ClientCredentialsInput{
+		ClientID:  client.ClientID,
+		Scope:     r.Form.Get("scope"),
+		Audience:  []string{client.ClientID},
+		Issuer:    resolveIssuer(h.Issuer, r),
+		AccessTTL: h.accessTTL(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidClient):
+			writeInvalidClient(w, "Client authentication failed")
+		case errors.Is(err, usecase.ErrUnauthorizedClient):
+			writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "Client not allowed to use client_credentials", "")
+		case errors.Is(err, usecase.ErrInvalidScope):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Requested scope exceeds client registration", "")
+		default:
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Token issuance failed", "")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": out.AccessToken,
+		"token_type":   out.TokenType,
+		"expires_in":   out.ExpiresIn,
+		"scope":        out.Scope,
+	})
+}
+
+func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Request, client *entity.Client) {
 	code := r.Form.Get("code")
-	clientID := r.Form.Get("client_id")
+	clientID := client.ClientID
 	redirectURI := r.Form.Get("redirect_uri")
 	codeVerifier := r.Form.Get("code_verifier")
-	if code == "" || clientID == "" || redirectURI == "" {
-		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing code, client_id or redirect_uri", "")
+	if code == "" || redirectURI == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing code or redirect_uri", "")
 		return
 	}
 	ac, err := h.Codes.Get(r.Context(), code)
@@ -103,35 +197,56 @@ func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Re
 			return
 		}
 	}
-	// Mark used (best-effort; if fails we still continue for demo)
-	_ = h.Codes.MarkUsed(r.Context(), code)
+	// Redeem the code before issuing anything: of concurrent requests for one code only the one
+	// that flips used gets tokens.
+	redeemed, err := h.Codes.MarkUsed(r.Context(), code)
+	if err != nil {
+		log.Printf("token mark code used error: %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Token issuance failed", "")
+		return
+	}
+	if !redeemed {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code expired or already used", "")
+		return
+	}
 
 	userUUID := deriveUserUUID(ac.UserID)
+	sessionID, _ := uuid.Parse(ac.SessionID) // codes from before session tracking carry none
 	scopeStr := strings.Join(ac.Scope, " ")
 	out, err := h.Issue.Execute(r.Context(), usecase.IssueTokenInput{
 		UserID:     userUUID,
 		ClientID:   clientID,
 		Scope:      scopeStr,
 		Audience:   []string{clientID},
-		Issuer:     issuerFromRequest(r),
-		AccessTTL:  10 * time.Minute,
-		RefreshTTL: 24 * time.Hour,
+		Issuer:     resolveIssuer(h.Issuer, r),
+		AccessTTL:  h.accessTTL(),
+		RefreshTTL: h.refreshTTL(),
+		Nonce:      ac.Nonce,
+		AuthTime:   ac.AuthTime,
+		Claims:     ac.Claims,
+		SessionID:  sessionID,
 	})
 	if err != nil {
 		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error(), "")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
+	body := map[string]any{
 		"access_token":  out.AccessToken,
 		"refresh_token": out.RefreshToken,
 		"token_type":    out.TokenType,
 		"expires_in":    out.ExpiresIn,
-		"scope":         out.Scope,
-		"id_token":      out.IDToken,
-	})
+		"scope":         out.Scope, // the granted scope, which may be narrower than requested
+	}
+	if out.IDToken != "" {
+		body["id_token"] = out.IDToken
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
 }
 
+// codeChallengeMethods are the PKCE methods verifyPKCE understands, strongest first.
+var codeChallengeMethods = []string{"S256", "plain"}
+
 func verifyPKCE(method, challenge, verifier string) error {
 	if challenge == "" {
 		return nil
@@ -165,11 +280,3 @@ func deriveUserUUID(id string) uuid.UUID {
 	fixed, _ := uuid.Parse("00000000-0000-0000-0000-000000000001")
 	return fixed
 }
-
-func issuerFromRequest(r *http.Request) string {
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
-	}
-	return scheme + "://" + r.Host
-}
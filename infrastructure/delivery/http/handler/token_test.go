@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestTokenAuthorizationCodeRedeemedOnce(t *testing.T) {
+	newCode := func(t *testing.T, code string) *entity.AuthorizationCode {
+		t.Helper()
+		c, err := entity.NewAuthorizationCode(code, "rp", "user-1", "https://rp.example.com/cb", []string{"openid"}, "", "", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	newHandler := func(codes *testfake.Codes, issue *fakeIssue) *TokenHandler {
+		return &TokenHandler{
+			Issue: issue,
+			Codes: codes,
+			ClientAuth: &fakeClientAuth{
+				clients: map[string]*entity.Client{"rp": newTestClient("rp", true)},
+				secrets: map[string]string{"rp": "s3cret"},
+			},
+		}
+	}
+	redeem := func(h *TokenHandler, code string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"https://rp.example.com/cb"}}
+		r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("rp", "s3cret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	errorOf := func(w *httptest.ResponseRecorder) string {
+		var body OAuthError
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Error
+	}
+
+	t.Run("second redemption", func(t *testing.T) {
+		issue := &fakeIssue{}
+		h := newHandler(testfake.NewCodes(newCode(t, "c1")), issue)
+		if w := redeem(h, "c1"); w.Code != http.StatusOK {
+			t.Fatalf("first: %d %s", w.Code, w.Body)
+		}
+		if w := redeem(h, "c1"); w.Code != http.StatusBadRequest || errorOf(w) != "invalid_grant" || len(issue.issued) != 1 {
+			t.Fatalf("second: %d %s, issued %d", w.Code, w.Body, len(issue.issued))
+		}
+	})
+	t.Run("response carries the granted scope", func(t *testing.T) {
+		c := newCode(t, "c1")
+		c.Scope = []string{"openid", "profile"} // narrowed at /authorize
+		w := redeem(newHandler(testfake.NewCodes(c), &fakeIssue{}), "c1")
+		var body struct{ Scope string }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Scope != "openid profile" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+	})
+	t.Run("concurrent redemptions", func(t *testing.T) {
+		const n = 8
+		issue := &fakeIssue{}
+		codes := testfake.NewCodes(newCode(t, "c1"))
+		codes.Gate = &sync.WaitGroup{}
+		codes.Gate.Add(n) // every request reads the code as unused before any redeems it
+		h := newHandler(codes, issue)
+		results := make([]*httptest.ResponseRecorder, n)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = redeem(h, "c1")
+			}()
+		}
+		wg.Wait()
+		ok := 0
+		for _, w := range results {
+			switch {
+			case w.Code == http.StatusOK:
+				ok++
+			case errorOf(w) != "invalid_grant":
+				t.Errorf("loser: %d %s", w.Code, w.Body)
+			}
+		}
+		if ok != 1 || len(issue.issued) != 1 {
+			t.Fatalf("%d requests got tokens, %d issued", ok, len(issue.issued))
+		}
+	})
+	t.Run("store failure", func(t *testing.T) {
+		issue := &fakeIssue{}
+		codes := testfake.NewCodes(newCode(t, "c1"))
+		codes.MarkErr = errors.New("db down")
+		if w := redeem(newHandler(codes, issue), "c1"); w.Code != http.StatusInternalServerError || errorOf(w) != "server_error" || len(issue.issued) != 0 {
+			t.Fatalf("%d %s, issued %d", w.Code, w.Body, len(issue.issued))
+		}
+	})
+}
+
+func TestTokenRefreshErrors(t *testing.T) {
+	id := func(raw string) string {
+		sum := sha256.Sum256([]byte(raw))
+		return hex.EncodeToString(sum[:])
+	}
+	h := &TokenHandler{
+		Refresh: &fakeRefresh{errs: map[string]error{
+			id("reused"):   usecase.ErrRefreshTokenReused,
+			id("raced"):    usecase.ErrRefreshTokenRotated,
+			id("foreign"):  usecase.ErrRefreshClientMismatch,
+			id("expired"):  fmt.Errorf("wrapped: %w", usecase.ErrRefreshTokenExpired),
+			id("revoked"):  usecase.ErrRefreshTokenInvalid,
+			id("db-error"): errors.New("connection reset while rotating"),
+		}},
+		ClientAuth: &fakeClientAuth{
+			clients: map[string]*entity.Client{"rp": newTestClient("rp", true)},
+			secrets: map[string]string{"rp": "s3cret"},
+		},
+	}
+	cases := []struct {
+		token, errCode, desc string
+		status               int
+	}{
+		{"good", "", "", http.StatusOK},
+		{"reused", "invalid_grant", "refresh token reuse detected", http.StatusBadRequest},
+		{"raced", "invalid_grant", "refresh token reuse detected", http.StatusBadRequest},
+		{"foreign", "invalid_grant", "refresh token was issued to another client", http.StatusBadRequest},
+		{"expired", "invalid_grant", "refresh token invalid or expired", http.StatusBadRequest},
+		{"revoked", "invalid_grant", "refresh token invalid or expired", http.StatusBadRequest},
+		{"db-error", "server_error", "Token refresh failed", http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.token, func(t *testing.T) {
+			form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tc.token}}
+			r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.SetBasicAuth("rp", "s3cret")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			var body OAuthError
+			_ = json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != tc.status || body.Error != tc.errCode || body.ErrorDescription != tc.desc {
+				t.Fatalf("%d %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -2,19 +2,29 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/RanguraGIT/sso/domain/repository"
 	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
 	"github.com/google/uuid"
 )
 
 type UserInfoHandler struct {
 	Users        repository.UserRepository
 	TokenService dservice.TokenService
+	Issuer       *IssuerResolver            // tokens whose iss differs are rejected
+	Scopes       dservice.ScopeRegistry     // decides which claims each granted scope releases
+	Tokens       repository.TokenRepository // finds the grant's claims request by jti; optional
+	// Clients looks up userinfo_signed_response_alg; nil always answers with plain JSON.
+	Clients repository.ClientRepository
 }
 
+// userInfoJWTType is the media type of signed userinfo responses (OIDC Core section 5.3.2).
+const userInfoJWTType = "application/jwt"
+
 func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Extract Bearer token from Authorization header
 	authHeader := r.Header.Get("Authorization")
@@ -34,7 +44,11 @@ func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Validate and parse the access token
 	claims, err := h.TokenService.ValidateAccessToken(r.Context(), accessToken)
 	if err != nil {
-		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "Access token invalid or expired", "")
+		writeBearerError(w, "invalid_token", accessTokenErrorDescription(err))
+		return
+	}
+	if claims.Issuer != resolveIssuer(h.Issuer, r) {
+		writeBearerError(w, "invalid_token", "Token was issued by a different issuer")
 		return
 	}
 
@@ -56,19 +70,74 @@ func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build OIDC standard userinfo response
-	response := map[string]any{
-		"sub":   user.ID.String(),
-		"email": user.Email,
+	// Release only the claims of the scopes granted to this token (OIDC Core section 5.4).
+	available := user.StandardClaims()
+	response := dservice.ReleaseClaims(h.Scopes, strings.Fields(claims.Scope), available)
+	// Claims asked for individually through the claims parameter (OIDC Core section 5.5).
+	if h.Tokens != nil && claims.ID != "" {
+		meta, err := h.Tokens.GetByAccessJTI(r.Context(), claims.ID)
+		if err != nil {
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Failed to retrieve token", "")
+			return
+		}
+		if meta != nil {
+			for k, v := range vo.Select(meta.Claims.UserInfo, available) {
+				response[k] = v
+			}
+		}
 	}
+	response["sub"] = user.ID.String()
 
-	// Add email_verified if available
-	if user.EmailVerified {
-		response["email_verified"] = true
-	} else {
-		response["email_verified"] = false
+	if h.Clients != nil && claims.ClientID != "" {
+		client, err := h.Clients.GetByClientID(r.Context(), claims.ClientID)
+		if err != nil {
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "Failed to retrieve client", "")
+			return
+		}
+		if client != nil && client.UserinfoSignedResponseAlg != "" {
+			h.writeJWT(w, r, client.ClientID, client.UserinfoSignedResponseAlg, response)
+			return
+		}
 	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// writeJWT answers with the userinfo claims signed for the client that registered
+// userinfo_signed_response_alg, adding iss and aud as OIDC Core section 5.3.2 requires.
+func (h *UserInfoHandler) writeJWT(w http.ResponseWriter, r *http.Request, clientID, alg string, response map[string]any) {
+	response["iss"] = resolveIssuer(h.Issuer, r)
+	response["aud"] = clientID
+	signed, err := h.TokenService.SignClaims(r.Context(), "", response, alg)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Failed to sign userinfo response", "")
+		return
+	}
+	w.Header().Set("Content-Type", userInfoJWTType)
+	_, _ = w.Write([]byte(signed))
+}
+
+// accessTokenErrorDescription explains a validation failure without echoing internals.
+func accessTokenErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, dservice.ErrTokenExpired):
+		return "Access token expired"
+	case errors.Is(err, dservice.ErrTokenNotYetValid):
+		return "Access token not yet valid"
+	case errors.Is(err, dservice.ErrTokenSignatureInvalid), errors.Is(err, dservice.ErrTokenUnknownKey):
+		return "Access token signature invalid"
+	case errors.Is(err, dservice.ErrTokenClaimsInvalid):
+		return "Access token not issued for this server"
+	case errors.Is(err, dservice.ErrTokenRevoked):
+		return "Access token revoked"
+	default:
+		return "Access token malformed"
+	}
+}
+
+// writeBearerError answers a protected resource failure per RFC 6750 section 3 with a
+// WWW-Authenticate challenge carrying the error code.
+func writeBearerError(w http.ResponseWriter, code, description string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="`+code+`", error_description="`+description+`"`)
+	writeOAuthError(w, http.StatusUnauthorized, code, description, "")
+}
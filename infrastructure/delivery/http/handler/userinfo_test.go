@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+const userInfoIssuer = "https://sso.example.com"
+
+// newProfileUser returns a user with every standard profile claim set.
+func newProfileUser() *entity.User {
+	u, err := entity.NewUser("alice@example.com", "hash")
+	if err != nil {
+		panic(err)
+	}
+	u.EmailVerified = true
+	u.Profile = entity.UserProfile{
+		Name: "Alice Liddell", GivenName: "Alice", FamilyName: "Liddell", Locale: "en-GB",
+		PhoneNumber: "+441234567890", PhoneNumberVerified: true,
+		Address: entity.Address{Locality: "Oxford", Country: "GB"},
+	}
+	return u
+}
+
+// accessTokenFor signs an access token for user with the given scope, issued to client rp.
+func accessTokenFor(t *testing.T, tokens dservice.TokenService, user *entity.User, scope string) string {
+	t.Helper()
+	now := time.Now()
+	res, err := tokens.IssueAccessAndRefresh(context.Background(), vo.JWTClaims{
+		Subject: user.ID.String(), Issuer: userInfoIssuer, Audience: []string{"rp"}, ClientID: "rp", Scope: scope,
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(),
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.AccessToken
+}
+
+func getUserInfo(h *UserInfoHandler, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestUserInfoClaimsPerScope(t *testing.T) {
+	user := newProfileUser()
+	tokens := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), iservice.TokenValidation{Issuer: userInfoIssuer})
+	issuers, _ := NewIssuerResolver(userInfoIssuer, nil, nil)
+	h := &UserInfoHandler{Users: testfake.NewUsers(user), TokenService: tokens, Issuer: issuers, Scopes: iservice.NewScopeRegistry()}
+
+	cases := []struct {
+		scope      string
+		want, deny []string
+	}{
+		{scope: "openid", deny: []string{"name", "email", "phone_number", "address"}},
+		{scope: "openid profile", want: []string{"name", "given_name", "family_name", "locale", "updated_at"}, deny: []string{"email", "phone_number", "address"}},
+		{scope: "openid email", want: []string{"email", "email_verified"}, deny: []string{"name", "phone_number"}},
+		{scope: "openid phone address", want: []string{"phone_number", "phone_number_verified", "address"}, deny: []string{"name", "email"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.scope, func(t *testing.T) {
+			w := getUserInfo(h, accessTokenFor(t, tokens, user, tc.scope))
+			var body map[string]any
+			if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+				t.Fatalf("%d %s", w.Code, w.Body)
+			}
+			if body["sub"] != user.ID.String() {
+				t.Errorf("sub %v", body["sub"])
+			}
+			for _, c := range tc.want {
+				if _, ok := body[c]; !ok {
+					t.Errorf("missing %s in %v", c, body)
+				}
+			}
+			for _, c := range tc.deny {
+				if _, ok := body[c]; ok {
+					t.Errorf("%s released without its scope", c)
+				}
+			}
+		})
+	}
+	t.Run("address is the structured claim", func(t *testing.T) {
+		var body struct{ Address map[string]string }
+		_ = json.Unmarshal(getUserInfo(h, accessTokenFor(t, tokens, user, "openid address")).Body.Bytes(), &body)
+		if body.Address["locality"] != "Oxford" || body.Address["country"] != "GB" {
+			t.Fatalf("address %v", body.Address)
+		}
+	})
+}
+
+func TestUserInfoClaimsRequest(t *testing.T) {
+	user := newProfileUser()
+	tokens := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), iservice.TokenValidation{Issuer: userInfoIssuer})
+	issuers, _ := NewIssuerResolver(userInfoIssuer, nil, nil)
+	access := accessTokenFor(t, tokens, user, "openid")
+	claims, err := tokens.ValidateAccessToken(context.Background(), access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As narrowed at /authorize and stored with the grant.
+	req, err := vo.ParseClaimsRequest(`{"userinfo":{"email":null,"family_name":{"value":"Smith"}},"id_token":{"name":null}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &UserInfoHandler{
+		Users: testfake.NewUsers(user), TokenService: tokens, Issuer: issuers, Scopes: iservice.NewScopeRegistry(),
+		Tokens: tokenMeta{byJTI: map[string]*entity.Token{claims.ID: {Claims: req}}},
+	}
+	var body map[string]any
+	w := getUserInfo(h, access)
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if body["email"] != user.Email {
+		t.Fatalf("requested email missing: %v", body)
+	}
+	for _, c := range []string{"family_name", "name", "email_verified"} {
+		if _, ok := body[c]; ok {
+			t.Errorf("%s released: %v", c, body)
+		}
+	}
+}
+
+func TestUserInfoSignedResponse(t *testing.T) {
+	user := newProfileUser()
+	keys := iservice.NewInMemoryKeyRotation(time.Hour, "RS256", "ES256")
+	tokens := iservice.NewJWTTokenService(keys, iservice.TokenValidation{Issuer: userInfoIssuer})
+	issuers, _ := NewIssuerResolver(userInfoIssuer, nil, nil)
+	signed := newTestClient("rp", true)
+	signed.UserinfoSignedResponseAlg = "ES256"
+	clients := testfake.NewClients(signed)
+	h := &UserInfoHandler{Users: testfake.NewUsers(user), TokenService: tokens, Issuer: issuers, Scopes: iservice.NewScopeRegistry(), Clients: clients}
+
+	w := getUserInfo(h, accessTokenFor(t, tokens, user, "openid email"))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/jwt" {
+		t.Fatalf("%d %v %s", w.Code, w.Header(), w.Body)
+	}
+	parsed, err := jwt.Parse(w.Body.String(), func(tok *jwt.Token) (any, error) {
+		kid, _ := tok.Header["kid"].(string)
+		pub, _, err := keys.VerificationKey(kid)
+		return pub, err
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuer(userInfoIssuer), jwt.WithAudience("rp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+	if claims["sub"] != user.ID.String() || claims["email"] != user.Email {
+		t.Fatalf("claims %v", claims)
+	}
+	if _, ok := claims["name"]; ok {
+		t.Fatal("signing must not widen the released claims")
+	}
+
+	signed.UserinfoSignedResponseAlg = ""
+	_ = clients.Update(context.Background(), signed)
+	if w := getUserInfo(h, accessTokenFor(t, tokens, user, "openid email")); w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("a client without userinfo_signed_response_alg gets JSON, got %q", w.Header().Get("Content-Type"))
+	}
+}
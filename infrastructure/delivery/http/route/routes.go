@@ -2,29 +2,97 @@ package route
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/RanguraGIT/sso/domain/repository"
 	dsvc "github.com/RanguraGIT/sso/domain/service"
 	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
 	handler "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
 )
 
+// Options carries the deployment settings handlers need. Zero TTLs fall back to handler defaults.
+type Options struct {
+	Issuer          *handler.IssuerResolver // nil derives the issuer from each request's Host
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	AuthCodeTTL     time.Duration
+	AuthorizeRPM    int              // per-IP requests per minute on /authorize; 0 disables
+	TokenRPM        int              // per-IP requests per minute on /token; 0 disables
+	SessionPolicy   vo.SessionPolicy // idle timeout and absolute lifetime of browser sessions
+	Templates       *ui.Templates    // hosted pages; nil disables them and /authorize answers login_required
+	DiscoveryMaxAge time.Duration    // Cache-Control max-age of the discovery documents
+	// LogoutRevokesTokens makes /logout revoke the tokens granted within the ended session.
+	LogoutRevokesTokens bool
+}
+
 // RegisterRoutes wires HTTP endpoints to handler implementations. It accepts domain wrappers
 // so the wiring remains independent of concrete infra implementations.
-func RegisterRoutes(mux *http.ServeMux, uc du.UsecaseWrapper, authCodes repository.AuthorizationCodeRepository, sessions repository.SessionRepository, users repository.UserRepository, tokens repository.TokenRepository, svcs dsvc.ServiceWrapper, issuer string) {
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+func RegisterRoutes(mux *http.ServeMux, uc du.UsecaseWrapper, authCodes repository.AuthorizationCodeRepository, sessions repository.SessionRepository, users repository.UserRepository, clients repository.ClientRepository, tokens repository.TokenRepository, svcs dsvc.ServiceWrapper, opts Options) {
+	issuerPath := opts.Issuer.Path()
+	// Discovery advertises endpoints under the issuer, so an issuer with a path component gets every
+	// route mounted below that path as well. The root routes stay for proxies that strip the prefix.
+	handle := func(pattern string, h http.Handler) {
+		mux.Handle(pattern, h)
+		if issuerPath != "" {
+			mux.Handle(issuerPath+pattern, h)
+		}
+	}
+
+	handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
-	})
-
-	mux.Handle("/.well-known/openid-configuration", &handler.DiscoveryHandler{Issuer: issuer})
-	mux.Handle("/authorize", &handler.AuthorizeHandler{Start: uc.StartAuth, Sessions: sessions})
-	mux.Handle("/register", &handler.RegisterHandler{UC: uc.RegisterUser})
-	mux.Handle("/login", &handler.LoginHandler{LoginUC: uc.UserLogin, SessionUC: uc.CreateSess})
-	mux.Handle("/jwks.json", &handler.JWKSHandler{Keys: svcs.KeyRotationService})
-	mux.Handle("/token", &handler.TokenHandler{Issue: uc.IssueToken, Refresh: uc.Refresh, Codes: authCodes})
-	mux.Handle("/userinfo", &handler.UserInfoHandler{Users: users, TokenService: svcs.TokenService})
-	mux.Handle("/revoke", &handler.RevokeHandler{Tokens: tokens})
+	}))
+
+	loginPath := ""
+	if opts.Templates != nil {
+		loginPath = handler.LoginPagePath
+		handle(handler.LoginPagePath, &handler.LoginPageHandler{LoginUC: uc.UserLogin, SessionUC: uc.CreateSess, Templates: opts.Templates, SessionPolicy: opts.SessionPolicy, Issuer: opts.Issuer})
+	}
+	handle("/authorize", handler.RateLimit(&handler.AuthorizeHandler{Start: uc.StartAuth, Sessions: sessions, CodeTTL: opts.AuthCodeTTL, LoginPath: loginPath, Consents: uc.Consents, Templates: opts.Templates, Scopes: svcs.ScopeRegistry, Tokens: svcs.TokenService, Issuer: opts.Issuer, Clients: clients, Activity: svcs.SessionActivity}, opts.AuthorizeRPM))
+	handle(handler.LogoutPath, &handler.LogoutHandler{End: uc.EndSession, Sessions: sessions, Tokens: svcs.TokenService, Issuer: opts.Issuer, Templates: opts.Templates, RevokeTokens: opts.LogoutRevokesTokens})
+	handle(handler.CheckSessionPath, handler.CheckSessionHandler{})
+	handle("/sessions", &handler.SessionsHandler{UserSessions: uc.UserSessions, Sessions: sessions, Issuer: opts.Issuer})
+	handle("/admin/sessions", &handler.AdminSessionsHandler{UserSessions: uc.UserSessions, TokenService: svcs.TokenService, Issuer: opts.Issuer})
+	handle("/consents", &handler.ConsentsHandler{Consents: uc.Consents, Sessions: sessions})
+	handle("/register", &handler.RegisterHandler{UC: uc.RegisterUser})
+	handle("/login", &handler.LoginHandler{LoginUC: uc.UserLogin, SessionUC: uc.CreateSess, SessionPolicy: opts.SessionPolicy})
+	handle("/jwks.json", &handler.JWKSHandler{Keys: svcs.KeyRotationService})
+	tokenHandler := &handler.TokenHandler{Issue: uc.IssueToken, Refresh: uc.Refresh, ClientCredentials: uc.ClientCredentials, Codes: authCodes, ClientAuth: svcs.ClientAuthenticator, Issuer: opts.Issuer, AccessTTL: opts.AccessTokenTTL, RefreshTTL: opts.RefreshTokenTTL}
+	handle("/token", handler.RateLimit(tokenHandler, opts.TokenRPM))
+	handle("/userinfo", &handler.UserInfoHandler{Users: users, TokenService: svcs.TokenService, Issuer: opts.Issuer, Scopes: svcs.ScopeRegistry, Tokens: tokens, Clients: clients})
+	handle("/revoke", &handler.RevokeHandler{Revoke: uc.Revoke, ClientAuth: svcs.ClientAuthenticator, Issuer: opts.Issuer})
+	handle("/introspect", &handler.IntrospectHandler{Introspect: uc.Introspect, ClientAuth: svcs.ClientAuthenticator, TokenService: svcs.TokenService, Issuer: opts.Issuer})
+
+	discovery := &handler.DiscoveryHandler{
+		Issuer: opts.Issuer,
+		Paths: handler.DiscoveryPaths{
+			Authorization: "/authorize",
+			Token:         "/token",
+			UserInfo:      "/userinfo",
+			JWKS:          "/jwks.json",
+			Revocation:    "/revoke",
+			Introspection: "/introspect",
+			EndSession:    handler.LogoutPath,
+			CheckSession:  handler.CheckSessionPath,
+		},
+		Keys:       svcs.KeyRotationService,
+		Scopes:     svcs.ScopeRegistry,
+		ClientAuth: svcs.ClientAuthenticator,
+		GrantTypes: tokenHandler.GrantTypes(),
+		// Logout tokens carry sid, so session-scoped back-channel logout is supported as well.
+		BackchannelLogout: svcs.BackchannelLogout != nil,
+		// The logout page loads frontchannel_logout_uri iframes, so it needs the templates.
+		FrontchannelLogout: opts.Templates != nil,
+		MaxAge:             opts.DiscoveryMaxAge,
+	}
+	handle("/.well-known/openid-configuration", discovery)
+	handle("/.well-known/oauth-authorization-server", discovery)
+	// RFC 8414 section 3: an issuer with a path component is looked up with the path appended.
+	if issuerPath != "" {
+		mux.Handle("/.well-known/oauth-authorization-server"+issuerPath, discovery)
+	}
 
 	// debug and root left to callers to register if desired
 }
@@ -0,0 +1,66 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	dsvc "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	handler "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
+)
+
+// TestDiscoveryMatchesRoutes checks that every URL the metadata advertises reaches a registered
+// handler, with and without a path in the issuer.
+func TestDiscoveryMatchesRoutes(t *testing.T) {
+	for _, issuer := range []string{"https://sso.example.com", "https://example.com/sso", "https://example.com/tenants/a/"} {
+		t.Run(issuer, func(t *testing.T) {
+			res, err := handler.NewIssuerResolver(issuer, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mux := http.NewServeMux()
+			RegisterRoutes(mux, du.UsecaseWrapper{}, nil, nil, nil, nil, nil, dsvc.ServiceWrapper{}, Options{Issuer: res})
+			routed := func(rawURL string) bool {
+				_, pattern := mux.Handler(httptest.NewRequest(http.MethodGet, rawURL, nil))
+				return pattern != ""
+			}
+
+			canonical := strings.TrimSuffix(issuer, "/")
+			u, _ := url.Parse(canonical)
+			wellKnown := []string{canonical + "/.well-known/openid-configuration", canonical + "/.well-known/oauth-authorization-server"}
+			if strings.Trim(u.Path, "/") != "" {
+				// RFC 8414 section 3 inserts the well-known segment before the issuer path.
+				wellKnown = append(wellKnown, u.Scheme+"://"+u.Host+"/.well-known/oauth-authorization-server"+u.Path)
+			}
+			for _, doc := range wellKnown {
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, doc, nil))
+				var md map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &md); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("%s: %d %s", doc, w.Code, w.Body)
+				}
+				if md["issuer"] != canonical {
+					t.Fatalf("%s: issuer %v", doc, md["issuer"])
+				}
+				endpoints := 0
+				for name, v := range md {
+					s, ok := v.(string)
+					if !ok || (!strings.HasSuffix(name, "_endpoint") && name != "jwks_uri" && name != "check_session_iframe") {
+						continue
+					}
+					endpoints++
+					if !strings.HasPrefix(s, canonical+"/") || !routed(s) {
+						t.Errorf("%s = %s is not served", name, s)
+					}
+				}
+				if endpoints < 8 {
+					t.Fatalf("%s advertises only %d endpoints", doc, endpoints)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Package ui renders the hosted HTML pages (login, consent, logout) from html/template files.
+// Defaults are embedded; a deployment can override any of them by placing a file with the
+// same name in its template directory.
+package ui
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+//go:embed templates/*.html
+var embedded embed.FS
+
+// Templates holds the parsed page set.
+type Templates struct {
+	t *template.Template
+}
+
+// Load parses the embedded templates, then re-parses any same-named *.html files found in dir
+// so they take precedence. An empty dir uses the embedded set only.
+func Load(dir string) (*Templates, error) {
+	t, err := template.New("ui").ParseFS(embedded, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return &Templates{t: t}, nil
+	}
+	overrides, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range overrides {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(filepath.Base(path)).Parse(string(raw)); err != nil {
+			return nil, fmt.Errorf("template %s: %w", path, err)
+		}
+	}
+	return &Templates{t: t}, nil
+}
+
+// Render executes the named template into a buffer first so a failing template never produces
+// a half-written page.
+func (t *Templates) Render(w http.ResponseWriter, status int, name string, data any) error {
+	var buf bytes.Buffer
+	if err := t.t.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
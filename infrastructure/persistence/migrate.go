@@ -20,6 +20,15 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			password_hash VARCHAR(255) NOT NULL,
 			email_verified TINYINT(1) NOT NULL DEFAULT 0,
 			locked TINYINT(1) NOT NULL DEFAULT 0,
+			name VARCHAR(255) NULL,
+			given_name VARCHAR(255) NULL,
+			family_name VARCHAR(255) NULL,
+			picture VARCHAR(1024) NULL,
+			locale VARCHAR(35) NULL,
+			zoneinfo VARCHAR(64) NULL,
+			phone_number VARCHAR(32) NULL,
+			phone_number_verified TINYINT(1) NOT NULL DEFAULT 0,
+			address TEXT NULL,
 			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
 			updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
@@ -33,6 +42,19 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			scopes TEXT NOT NULL,
 			confidential TINYINT(1) NOT NULL DEFAULT 0,
 			pkce_required TINYINT(1) NOT NULL DEFAULT 1,
+			token_endpoint_auth_method VARCHAR(32) NULL,
+			jwks TEXT NULL,
+			id_token_signed_response_alg VARCHAR(16) NULL,
+			id_token_encrypted_response_alg VARCHAR(32) NULL,
+			id_token_encrypted_response_enc VARCHAR(32) NULL,
+			userinfo_signed_response_alg VARCHAR(16) NULL,
+			post_logout_redirect_uris TEXT NULL,
+			backchannel_logout_uri VARCHAR(2048) NULL,
+			frontchannel_logout_uri VARCHAR(2048) NULL,
+			frontchannel_logout_session_required TINYINT(1) NOT NULL DEFAULT 0,
+			session_idle_timeout INT NOT NULL DEFAULT 0,
+			session_max_lifetime INT NOT NULL DEFAULT 0,
+			first_party TINYINT(1) NOT NULL DEFAULT 0,
 			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
 			updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
@@ -45,6 +67,10 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			scope TEXT NULL,
 			code_challenge TEXT NULL,
 			code_challenge_method VARCHAR(10) NULL,
+			nonce VARCHAR(255) NULL,
+			auth_time TIMESTAMP(6) NULL,
+			claims_request TEXT NULL,
+			session_id CHAR(36) NULL,
 			expires_at TIMESTAMP(6) NOT NULL,
 			used TINYINT(1) NOT NULL DEFAULT 0,
 			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
@@ -58,18 +84,26 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			user_id CHAR(36) NULL,
 			client_id CHAR(36) NOT NULL,
 			client_public_id VARCHAR(128) NOT NULL,
+			scopes TEXT NULL,
 			access_jwt TEXT NOT NULL,
-			refresh_token_id VARCHAR(255) NOT NULL,
+			access_jti VARCHAR(64) NULL,
+			refresh_token_id VARCHAR(255) NULL,
 			parent_refresh_id VARCHAR(255) NULL,
+			family_id CHAR(36) NULL,
 			rotated TINYINT(1) NOT NULL DEFAULT 0,
 			revoked TINYINT(1) NOT NULL DEFAULT 0,
 			expires_at TIMESTAMP(6) NOT NULL,
 			refresh_expires TIMESTAMP(6) NOT NULL,
 			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			claims_request TEXT NULL,
+			session_id CHAR(36) NULL,
 			INDEX (client_id),
 			INDEX (user_id),
 			INDEX (refresh_token_id),
+			INDEX idx_tokens_access_jti (access_jti),
 			INDEX (parent_refresh_id),
+			INDEX idx_tokens_family_id (family_id),
+			INDEX idx_tokens_session_id (session_id),
 			INDEX (expires_at)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 
@@ -82,9 +116,52 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 			expires_at TIMESTAMP(6) NOT NULL,
 			revoked TINYINT(1) NOT NULL DEFAULT 0,
 			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			last_seen_at TIMESTAMP(6) NULL,
 			INDEX (user_id),
 			INDEX (expires_at)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+
+		`CREATE TABLE IF NOT EXISTS signing_keys (
+			kid VARCHAR(64) PRIMARY KEY,
+			algorithm VARCHAR(16) NOT NULL,
+			state VARCHAR(16) NOT NULL,
+			encrypted_key BLOB NOT NULL,
+			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			activated_at TIMESTAMP(6) NULL,
+			retired_at TIMESTAMP(6) NULL,
+			INDEX (state)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+
+		`CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+			jti VARCHAR(64) PRIMARY KEY,
+			expires_at TIMESTAMP(6) NOT NULL,
+			revoked_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			INDEX (expires_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+
+		`CREATE TABLE IF NOT EXISTS consents (
+			user_id CHAR(36) NOT NULL,
+			client_id VARCHAR(128) NOT NULL,
+			scopes TEXT NOT NULL,
+			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			PRIMARY KEY (user_id, client_id),
+			INDEX (client_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+
+		`CREATE TABLE IF NOT EXISTS logout_deliveries (
+			id CHAR(36) PRIMARY KEY,
+			client_id VARCHAR(128) NOT NULL,
+			uri VARCHAR(2048) NOT NULL,
+			issuer VARCHAR(1024) NOT NULL,
+			subject VARCHAR(255) NULL,
+			session_id CHAR(36) NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP(6) NOT NULL,
+			last_error TEXT NULL,
+			created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			INDEX (next_attempt_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 	}
 
 	for i, stmt := range stmts {
@@ -100,9 +177,89 @@ func Migrate(ctx context.Context, db *sql.DB) error {
 	if err := ensureTokensIDColumn(ctx, db); err != nil {
 		return fmt.Errorf("ensure tokens.id: %w", err)
 	}
+	if err := ensureTokensRefreshIDNullable(ctx, db); err != nil {
+		return fmt.Errorf("ensure tokens.refresh_token_id nullable: %w", err)
+	}
+	for _, c := range addedColumns {
+		if err := ensureColumn(ctx, db, c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("ensure %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	if err := backfillTokenFamilies(ctx, db); err != nil {
+		return fmt.Errorf("backfill tokens.family_id: %w", err)
+	}
 	return nil
 }
 
+// backfillTokenFamilies assigns family_id to rows written before it existed: roots get their own id,
+// then each rotation inherits its parent's family one generation at a time.
+func backfillTokenFamilies(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `UPDATE tokens SET family_id=id WHERE family_id IS NULL AND parent_refresh_id IS NULL`); err != nil {
+		return err
+	}
+	for {
+		res, err := db.ExecContext(ctx, `UPDATE tokens c JOIN tokens p ON c.parent_refresh_id = p.refresh_token_id
+			SET c.family_id = p.family_id WHERE c.family_id IS NULL AND p.family_id IS NOT NULL`)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+	}
+}
+
+// addedColumns lists columns introduced after a table's first release. CREATE TABLE above already
+// contains them; this list upgrades databases created by older builds.
+var addedColumns = []struct{ table, column, definition string }{
+	{"users", "name", "VARCHAR(255) NULL"},
+	{"users", "given_name", "VARCHAR(255) NULL"},
+	{"users", "family_name", "VARCHAR(255) NULL"},
+	{"users", "picture", "VARCHAR(1024) NULL"},
+	{"users", "locale", "VARCHAR(35) NULL"},
+	{"users", "zoneinfo", "VARCHAR(64) NULL"},
+	{"users", "phone_number", "VARCHAR(32) NULL"},
+	{"users", "phone_number_verified", "TINYINT(1) NOT NULL DEFAULT 0"},
+	{"users", "address", "TEXT NULL"},
+	{"clients", "token_endpoint_auth_method", "VARCHAR(32) NULL"},
+	{"clients", "jwks", "TEXT NULL"},
+	{"clients", "id_token_signed_response_alg", "VARCHAR(16) NULL"},
+	{"clients", "id_token_encrypted_response_alg", "VARCHAR(32) NULL"},
+	{"clients", "id_token_encrypted_response_enc", "VARCHAR(32) NULL"},
+	{"clients", "userinfo_signed_response_alg", "VARCHAR(16) NULL"},
+	{"clients", "post_logout_redirect_uris", "TEXT NULL"},
+	{"clients", "backchannel_logout_uri", "VARCHAR(2048) NULL"},
+	{"clients", "frontchannel_logout_uri", "VARCHAR(2048) NULL"},
+	{"clients", "frontchannel_logout_session_required", "TINYINT(1) NOT NULL DEFAULT 0"},
+	{"clients", "session_idle_timeout", "INT NOT NULL DEFAULT 0"}, // seconds
+	{"clients", "session_max_lifetime", "INT NOT NULL DEFAULT 0"}, // seconds
+	{"clients", "first_party", "TINYINT(1) NOT NULL DEFAULT 0"},
+	{"authorization_codes", "nonce", "VARCHAR(255) NULL"},
+	{"authorization_codes", "auth_time", "TIMESTAMP(6) NULL"},
+	{"authorization_codes", "claims_request", "TEXT NULL"},
+	{"authorization_codes", "session_id", "CHAR(36) NULL"},
+	{"tokens", "claims_request", "TEXT NULL"},
+	{"tokens", "scopes", "TEXT NULL"},
+	{"tokens", "access_jti", "VARCHAR(64) NULL, ADD INDEX idx_tokens_access_jti (access_jti)"},
+	{"tokens", "family_id", "CHAR(36) NULL, ADD INDEX idx_tokens_family_id (family_id)"},
+	{"tokens", "session_id", "CHAR(36) NULL, ADD INDEX idx_tokens_session_id (session_id)"},
+	{"sessions", "last_seen_at", "TIMESTAMP(6) NULL"},
+}
+
+// ensureColumn adds a column to table when information_schema reports it missing.
+func ensureColumn(ctx context.Context, db *sql.DB, table, column, definition string) error {
+	const check = `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME=? AND COLUMN_NAME=?`
+	var count int
+	if err := db.QueryRowContext(ctx, check, table, column).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func execRetry(ctx context.Context, db *sql.DB, stmt string, attempts int) error {
 	var last error
 	for i := 0; i < attempts; i++ {
@@ -129,7 +286,7 @@ func isRetryable(err error) bool {
 
 // Helper to truncate tables during tests (not used in production paths yet)
 func TruncateAll(ctx context.Context, db *sql.DB) error {
-	stmts := []string{"SET FOREIGN_KEY_CHECKS=0", "TRUNCATE TABLE users", "TRUNCATE TABLE clients", "TRUNCATE TABLE authorization_codes", "TRUNCATE TABLE tokens", "TRUNCATE TABLE sessions", "SET FOREIGN_KEY_CHECKS=1"}
+	stmts := []string{"SET FOREIGN_KEY_CHECKS=0", "TRUNCATE TABLE users", "TRUNCATE TABLE clients", "TRUNCATE TABLE authorization_codes", "TRUNCATE TABLE tokens", "TRUNCATE TABLE sessions", "TRUNCATE TABLE signing_keys", "TRUNCATE TABLE revoked_access_tokens", "TRUNCATE TABLE consents", "SET FOREIGN_KEY_CHECKS=1"}
 	for _, s := range stmts {
 		if _, err := db.ExecContext(ctx, s); err != nil {
 			return err
@@ -196,3 +353,18 @@ func ensureTokensIDColumn(ctx context.Context, db *sql.DB) error {
 	}
 	return nil
 }
+
+// ensureTokensRefreshIDNullable relaxes refresh_token_id on legacy schemas so access-only
+// (client_credentials) tokens can be stored without a refresh identifier.
+func ensureTokensRefreshIDNullable(ctx context.Context, db *sql.DB) error {
+	const check = `SELECT IS_NULLABLE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME='tokens' AND COLUMN_NAME='refresh_token_id'`
+	var nullable string
+	if err := db.QueryRowContext(ctx, check).Scan(&nullable); err != nil {
+		return err
+	}
+	if nullable == "YES" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, `ALTER TABLE tokens MODIFY refresh_token_id VARCHAR(255) NULL`)
+	return err
+}
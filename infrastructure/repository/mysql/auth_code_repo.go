@@ -8,6 +8,7 @@ import (
 
 	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
+	"github.com/RanguraGIT/sso/domain/vo"
 )
 
 type AuthCodeRepo struct{ db *sql.DB }
@@ -15,29 +16,46 @@ type AuthCodeRepo struct{ db *sql.DB }
 func NewAuthCodeRepo(db *sql.DB) repository.AuthorizationCodeRepository { return &AuthCodeRepo{db: db} }
 
 func (r *AuthCodeRepo) Create(ctx context.Context, c *entity.AuthorizationCode) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO authorization_codes(code,client_id,user_id,redirect_uri,scope,code_challenge,code_challenge_method,expires_at,used,created_at) VALUES (?,?,?,?,?,?,?,?,?,?)`, c.Code, c.ClientID, c.UserID, c.RedirectURI, strings.Join(c.Scope, " "), c.CodeChallenge, c.CodeChallengeMethod, c.ExpiresAt, c.Used, c.CreatedAt)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO authorization_codes(code,client_id,user_id,redirect_uri,scope,code_challenge,code_challenge_method,nonce,auth_time,claims_request,session_id,expires_at,used,created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, c.Code, c.ClientID, c.UserID, c.RedirectURI, strings.Join(c.Scope, " "), c.CodeChallenge, c.CodeChallengeMethod, nullString(c.Nonce), nullTime(c.AuthTime), nullString(c.Claims.String()), nullString(c.SessionID), c.ExpiresAt, c.Used, c.CreatedAt)
 	return err
 }
 
 func (r *AuthCodeRepo) Get(ctx context.Context, code string) (*entity.AuthorizationCode, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT code,client_id,user_id,redirect_uri,scope,code_challenge,code_challenge_method,expires_at,used,created_at FROM authorization_codes WHERE code=?`, code)
+	row := r.db.QueryRowContext(ctx, `SELECT code,client_id,user_id,redirect_uri,scope,code_challenge,code_challenge_method,nonce,auth_time,claims_request,session_id,expires_at,used,created_at FROM authorization_codes WHERE code=?`, code)
 	c := &entity.AuthorizationCode{}
 	var scopeStr string
-	if err := row.Scan(&c.Code, &c.ClientID, &c.UserID, &c.RedirectURI, &scopeStr, &c.CodeChallenge, &c.CodeChallengeMethod, &c.ExpiresAt, &c.Used, &c.CreatedAt); err != nil {
+	var nonce, claims, sessionID sql.NullString
+	var authTime sql.NullTime
+	if err := row.Scan(&c.Code, &c.ClientID, &c.UserID, &c.RedirectURI, &scopeStr, &c.CodeChallenge, &c.CodeChallengeMethod, &nonce, &authTime, &claims, &sessionID, &c.ExpiresAt, &c.Used, &c.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
+	c.Nonce = nonce.String
+	c.SessionID = sessionID.String
+	cr, err := vo.ParseClaimsRequest(claims.String)
+	if err != nil {
+		return nil, err
+	}
+	c.Claims = cr
+	if authTime.Valid {
+		c.AuthTime = authTime.Time
+	}
 	if strings.TrimSpace(scopeStr) != "" {
 		c.Scope = strings.Fields(scopeStr)
 	}
 	return c, nil
 }
 
-func (r *AuthCodeRepo) MarkUsed(ctx context.Context, code string) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE authorization_codes SET used=1 WHERE code=?`, code)
-	return err
+// MarkUsed flips used 0 -> 1 and reports whether this call won.
+func (r *AuthCodeRepo) MarkUsed(ctx context.Context, code string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE authorization_codes SET used=1 WHERE code=? AND used=0`, code)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
 }
 
 // Optionally purge expired codes (can be called on a timer)
@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
 	"github.com/RanguraGIT/sso/domain/repository"
 )
 
@@ -13,11 +17,23 @@ type ClientRepo struct{ db *sql.DB }
 
 func NewClientRepo(db *sql.DB) repository.ClientRepository { return &ClientRepo{db: db} }
 
+const clientColumns = `id,client_id,name,hashed_secret,redirect_uris,scopes,confidential,pkce_required,token_endpoint_auth_method,jwks,id_token_signed_response_alg,id_token_encrypted_response_alg,id_token_encrypted_response_enc,userinfo_signed_response_alg,post_logout_redirect_uris,backchannel_logout_uri,frontchannel_logout_uri,frontchannel_logout_session_required,session_idle_timeout,session_max_lifetime,first_party,created_at,updated_at`
+
 func (r *ClientRepo) GetByClientID(ctx context.Context, clientID string) (*entity.Client, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,client_id,name,hashed_secret,redirect_uris,scopes,confidential,pkce_required,created_at,updated_at FROM clients WHERE client_id=?`, clientID)
+	return scanClient(r.db.QueryRowContext(ctx, `SELECT `+clientColumns+` FROM clients WHERE client_id=?`, clientID))
+}
+
+// GetByID looks a client up by its internal id, as recorded in sessions and tokens.
+func (r *ClientRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.Client, error) {
+	return scanClient(r.db.QueryRowContext(ctx, `SELECT `+clientColumns+` FROM clients WHERE id=?`, id.String()))
+}
+
+func scanClient(row rowScanner) (*entity.Client, error) {
 	c := &entity.Client{}
 	var redirectURIs, scopes string
-	if err := row.Scan(&c.ID, &c.ClientID, &c.Name, &c.HashedSecret, &redirectURIs, &scopes, &c.Confidential, &c.PKCERequired, &c.CreatedAt, &c.UpdatedAt); err != nil {
+	var idleTimeout, maxLifetime int64
+	var authMethod, jwks, idTokenAlg, idTokenEncAlg, idTokenEnc, userinfoAlg, postLogoutURIs, backchannelURI, frontchannelURI sql.NullString
+	if err := row.Scan(&c.ID, &c.ClientID, &c.Name, &c.HashedSecret, &redirectURIs, &scopes, &c.Confidential, &c.PKCERequired, &authMethod, &jwks, &idTokenAlg, &idTokenEncAlg, &idTokenEnc, &userinfoAlg, &postLogoutURIs, &backchannelURI, &frontchannelURI, &c.FrontchannelLogoutSessionRequired, &idleTimeout, &maxLifetime, &c.FirstParty, &c.CreatedAt, &c.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -25,19 +41,33 @@ func (r *ClientRepo) GetByClientID(ctx context.Context, clientID string) (*entit
 	}
 	c.RedirectURIs = splitNonEmpty(redirectURIs)
 	c.Scopes = splitNonEmpty(scopes)
+	c.TokenEndpointAuthMethod = enum.ClientAuthMethod(authMethod.String)
+	c.JWKS = jwks.String
+	c.IDTokenSignedResponseAlg = idTokenAlg.String
+	c.IDTokenEncryptedResponseAlg = idTokenEncAlg.String
+	c.IDTokenEncryptedResponseEnc = idTokenEnc.String
+	c.UserinfoSignedResponseAlg = userinfoAlg.String
+	c.PostLogoutRedirectURIs = splitNonEmpty(postLogoutURIs.String)
+	c.BackchannelLogoutURI = backchannelURI.String
+	c.FrontchannelLogoutURI = frontchannelURI.String
+	c.SessionIdleTimeout = time.Duration(idleTimeout) * time.Second
+	c.SessionMaxLifetime = time.Duration(maxLifetime) * time.Second
 	return c, nil
 }
 
 func (r *ClientRepo) Create(ctx context.Context, c *entity.Client) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO clients(id,client_id,name,hashed_secret,redirect_uris,scopes,confidential,pkce_required,created_at,updated_at) VALUES (?,?,?,?,?,?,?,?,?,?)`, c.ID.String(), c.ClientID, c.Name, c.HashedSecret, strings.Join(c.RedirectURIs, " "), strings.Join(c.Scopes, " "), c.Confidential, c.PKCERequired, c.CreatedAt, c.UpdatedAt)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO clients(`+clientColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, c.ID.String(), c.ClientID, c.Name, c.HashedSecret, strings.Join(c.RedirectURIs, " "), strings.Join(c.Scopes, " "), c.Confidential, c.PKCERequired, nullString(string(c.TokenEndpointAuthMethod)), nullString(c.JWKS), nullString(c.IDTokenSignedResponseAlg), nullString(c.IDTokenEncryptedResponseAlg), nullString(c.IDTokenEncryptedResponseEnc), nullString(c.UserinfoSignedResponseAlg), nullString(strings.Join(c.PostLogoutRedirectURIs, " ")), nullString(c.BackchannelLogoutURI), nullString(c.FrontchannelLogoutURI), c.FrontchannelLogoutSessionRequired, seconds(c.SessionIdleTimeout), seconds(c.SessionMaxLifetime), c.FirstParty, c.CreatedAt, c.UpdatedAt)
 	return err
 }
 
 func (r *ClientRepo) Update(ctx context.Context, c *entity.Client) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE clients SET name=?, hashed_secret=?, redirect_uris=?, scopes=?, confidential=?, pkce_required=?, updated_at=NOW(6) WHERE client_id=?`, c.Name, c.HashedSecret, strings.Join(c.RedirectURIs, " "), strings.Join(c.Scopes, " "), c.Confidential, c.PKCERequired, c.ClientID)
+	_, err := r.db.ExecContext(ctx, `UPDATE clients SET name=?, hashed_secret=?, redirect_uris=?, scopes=?, confidential=?, pkce_required=?, token_endpoint_auth_method=?, jwks=?, id_token_signed_response_alg=?, id_token_encrypted_response_alg=?, id_token_encrypted_response_enc=?, userinfo_signed_response_alg=?, post_logout_redirect_uris=?, backchannel_logout_uri=?, frontchannel_logout_uri=?, frontchannel_logout_session_required=?, session_idle_timeout=?, session_max_lifetime=?, first_party=?, updated_at=NOW(6) WHERE client_id=?`, c.Name, c.HashedSecret, strings.Join(c.RedirectURIs, " "), strings.Join(c.Scopes, " "), c.Confidential, c.PKCERequired, nullString(string(c.TokenEndpointAuthMethod)), nullString(c.JWKS), nullString(c.IDTokenSignedResponseAlg), nullString(c.IDTokenEncryptedResponseAlg), nullString(c.IDTokenEncryptedResponseEnc), nullString(c.UserinfoSignedResponseAlg), nullString(strings.Join(c.PostLogoutRedirectURIs, " ")), nullString(c.BackchannelLogoutURI), nullString(c.FrontchannelLogoutURI), c.FrontchannelLogoutSessionRequired, seconds(c.SessionIdleTimeout), seconds(c.SessionMaxLifetime), c.FirstParty, c.ClientID)
 	return err
 }
 
+// seconds stores a duration as whole seconds.
+func seconds(d time.Duration) int64 { return int64(d / time.Second) }
+
 func splitNonEmpty(s string) []string {
 	parts := strings.Fields(s)
 	out := make([]string, 0, len(parts))
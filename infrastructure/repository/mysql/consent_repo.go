@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+)
+
+type ConsentRepo struct{ db *sql.DB }
+
+func NewConsentRepo(db *sql.DB) repository.ConsentRepository { return &ConsentRepo{db: db} }
+
+const consentColumns = `user_id,client_id,scopes,created_at,updated_at`
+
+func (r *ConsentRepo) Get(ctx context.Context, userID uuid.UUID, clientID string) (*entity.Consent, error) {
+	c, err := scanConsent(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+consentColumns+` FROM consents WHERE user_id=? AND client_id=?`, userID.String(), clientID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (r *ConsentRepo) Save(ctx context.Context, c *entity.Consent) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `INSERT INTO consents(`+consentColumns+`) VALUES (?,?,?,?,?)
+		ON DUPLICATE KEY UPDATE scopes=VALUES(scopes), updated_at=VALUES(updated_at)`,
+		c.UserID.String(), c.ClientID, strings.Join(c.Scopes, " "), c.CreatedAt, c.UpdatedAt)
+	return err
+}
+
+func (r *ConsentRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Consent, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+consentColumns+` FROM consents WHERE user_id=? ORDER BY created_at`, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*entity.Consent
+	for rows.Next() {
+		c, err := scanConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *ConsentRepo) Delete(ctx context.Context, userID uuid.UUID, clientID string) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM consents WHERE user_id=? AND client_id=?`, userID.String(), clientID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanConsent(row rowScanner) (*entity.Consent, error) {
+	c := &entity.Consent{}
+	var userID, scopes string
+	if err := row.Scan(&userID, &c.ClientID, &scopes, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	uid, err := uuidParse(userID)
+	if err != nil {
+		return nil, err
+	}
+	c.UserID = uid
+	c.Scopes = splitNonEmpty(scopes)
+	return c, nil
+}
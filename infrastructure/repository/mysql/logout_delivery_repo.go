@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+)
+
+type LogoutDeliveryRepo struct{ db *sql.DB }
+
+func NewLogoutDeliveryRepo(db *sql.DB) repository.LogoutDeliveryRepository {
+	return &LogoutDeliveryRepo{db: db}
+}
+
+const logoutDeliveryColumns = `id,client_id,uri,issuer,subject,session_id,attempts,next_attempt_at,last_error,created_at`
+
+func (r *LogoutDeliveryRepo) Enqueue(ctx context.Context, d *entity.LogoutDelivery) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `INSERT INTO logout_deliveries(`+logoutDeliveryColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?)`, d.ID.String(), d.ClientID, d.URI, d.Issuer, nullString(d.Subject), nullableUUID(d.SessionID), d.Attempts, d.NextAttemptAt, nullString(d.LastError), d.CreatedAt)
+	return err
+}
+
+func (r *LogoutDeliveryRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*entity.LogoutDelivery, error) {
+	var out []*entity.LogoutDelivery
+	err := NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		c := conn(ctx, r.db)
+		// SKIP LOCKED lets replicas claim disjoint batches instead of queueing behind each other.
+		rows, err := c.QueryContext(ctx, `SELECT `+logoutDeliveryColumns+` FROM logout_deliveries WHERE next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ? FOR UPDATE SKIP LOCKED`, now, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d := &entity.LogoutDelivery{}
+			var subject, sessionID, lastError sql.NullString
+			if err := rows.Scan(&d.ID, &d.ClientID, &d.URI, &d.Issuer, &subject, &sessionID, &d.Attempts, &d.NextAttemptAt, &lastError, &d.CreatedAt); err != nil {
+				return err
+			}
+			d.Subject = subject.String
+			d.LastError = lastError.String
+			if sessionID.Valid {
+				if sid, err := uuidParse(sessionID.String); err == nil {
+					d.SessionID = sid
+				}
+			}
+			out = append(out, d)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, d := range out {
+			if _, err := c.ExecContext(ctx, `UPDATE logout_deliveries SET next_attempt_at=? WHERE id=?`, now.Add(lease), d.ID.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *LogoutDeliveryRepo) Reschedule(ctx context.Context, id uuid.UUID, attempts int, next time.Time, lastError string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE logout_deliveries SET attempts=?, next_attempt_at=?, last_error=? WHERE id=?`, attempts, next, nullString(lastError), id.String())
+	return err
+}
+
+func (r *LogoutDeliveryRepo) Complete(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM logout_deliveries WHERE id=?`, id.String())
+	return err
+}
@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/repository"
+)
+
+type RevokedAccessTokenRepo struct{ db *sql.DB }
+
+func NewRevokedAccessTokenRepo(db *sql.DB) repository.RevokedAccessTokenRepository {
+	return &RevokedAccessTokenRepo{db: db}
+}
+
+func (r *RevokedAccessTokenRepo) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	// Re-revoking is a no-op.
+	_, err := conn(ctx, r.db).ExecContext(ctx, `INSERT IGNORE INTO revoked_access_tokens(jti,expires_at,revoked_at) VALUES (?,?,?)`, jti, expiresAt, time.Now().UTC())
+	return err
+}
+
+func (r *RevokedAccessTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var one int
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT 1 FROM revoked_access_tokens WHERE jti=? AND expires_at > ?`, jti, time.Now().UTC()).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *RevokedAccessTokenRepo) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+	"time"
 
 	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
@@ -14,29 +15,106 @@ type SessionRepo struct{ db *sql.DB }
 
 func NewSessionRepo(db *sql.DB) repository.SessionRepository { return &SessionRepo{db: db} }
 
+const sessionColumns = `id,user_id,client_ids,ip,user_agent,expires_at,revoked,created_at,last_seen_at`
+
 func (r *SessionRepo) Create(ctx context.Context, s *entity.Session) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO sessions(id,user_id,client_ids,ip,user_agent,expires_at,revoked,created_at) VALUES (?,?,?,?,?,?,?,?)`, s.ID.String(), s.UserID.String(), joinUUIDs(s.ClientIDs), s.IP, s.UserAgent, s.ExpiresAt, s.Revoked, s.CreatedAt)
+	_, err := r.db.ExecContext(ctx, `INSERT INTO sessions(`+sessionColumns+`) VALUES (?,?,?,?,?,?,?,?,?)`, s.ID.String(), s.UserID.String(), joinUUIDs(s.ClientIDs), s.IP, s.UserAgent, s.ExpiresAt, s.Revoked, s.CreatedAt, nullTime(s.LastSeenAt))
 	return err
 }
 
 func (r *SessionRepo) Get(ctx context.Context, id uuid.UUID) (*entity.Session, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,user_id,client_ids,ip,user_agent,expires_at,revoked,created_at FROM sessions WHERE id=?`, id.String())
+	s, err := scanSession(r.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id=?`, id.String()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+func scanSession(row rowScanner) (*entity.Session, error) {
 	s := &entity.Session{}
-	var clientIDs string
-	if err := row.Scan(&s.ID, &s.UserID, &clientIDs, &s.IP, &s.UserAgent, &s.ExpiresAt, &s.Revoked, &s.CreatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+	var clientIDs, ip, userAgent sql.NullString
+	var lastSeen sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &clientIDs, &ip, &userAgent, &s.ExpiresAt, &s.Revoked, &s.CreatedAt, &lastSeen); err != nil {
 		return nil, err
 	}
-	if strings.TrimSpace(clientIDs) != "" {
-		s.ClientIDs = parseUUIDs(clientIDs)
+	if strings.TrimSpace(clientIDs.String) != "" {
+		s.ClientIDs = parseUUIDs(clientIDs.String)
+	}
+	s.IP = ip.String
+	s.UserAgent = userAgent.String
+	s.LastSeenAt = s.CreatedAt // sessions from before last_seen_at was recorded
+	if lastSeen.Valid {
+		s.LastSeenAt = lastSeen.Time
 	}
 	return s, nil
 }
 
+func (r *SessionRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error) {
+	return r.live(ctx, conn(ctx, r.db), userID, uuid.Nil, "")
+}
+
+func (r *SessionRepo) RevokeAllForUser(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error) {
+	return r.RevokeAllExcept(ctx, userID, uuid.Nil)
+}
+
+// RevokeAllExcept locks the sessions it lists, so the sessions returned are exactly those revoked.
+func (r *SessionRepo) RevokeAllExcept(ctx context.Context, userID uuid.UUID, keep uuid.UUID) ([]*entity.Session, error) {
+	var out []*entity.Session
+	err := NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		c := conn(ctx, r.db)
+		var err error
+		if out, err = r.live(ctx, c, userID, keep, " FOR UPDATE"); err != nil {
+			return err
+		}
+		for _, s := range out {
+			if _, err := c.ExecContext(ctx, `UPDATE sessions SET revoked=1 WHERE id=?`, s.ID.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *SessionRepo) SaveActivity(ctx context.Context, sessions []*entity.Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	return NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		c := conn(ctx, r.db)
+		for _, s := range sessions {
+			if _, err := c.ExecContext(ctx, `UPDATE sessions SET last_seen_at=?, expires_at=? WHERE id=? AND revoked=0 AND (last_seen_at IS NULL OR last_seen_at < ?)`, s.LastSeenAt, s.ExpiresAt, s.ID.String(), s.LastSeenAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// live lists the user's sessions that are neither revoked nor expired, leaving out skip.
+func (r *SessionRepo) live(ctx context.Context, c dbConn, userID, skip uuid.UUID, lock string) ([]*entity.Session, error) {
+	rows, err := c.QueryContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE user_id=? AND revoked=0 AND expires_at > ? AND id <> ? ORDER BY COALESCE(last_seen_at, created_at) DESC`+lock, userID.String(), time.Now().UTC(), skip.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*entity.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
 func (r *SessionRepo) AddClient(ctx context.Context, id uuid.UUID, clientID uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET client_ids=CONCAT(IFNULL(client_ids,''), ' ', ?) WHERE id=?`, clientID.String(), id.String())
+	// A client already recorded is left alone so repeated authorizations do not grow the list.
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET client_ids=CONCAT(IFNULL(client_ids,''), ' ', ?) WHERE id=? AND LOCATE(?, IFNULL(client_ids,''))=0`, clientID.String(), id.String(), clientID.String())
 	return err
 }
 
@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+)
+
+type SigningKeyRepo struct{ db *sql.DB }
+
+func NewSigningKeyRepo(db *sql.DB) repository.SigningKeyRepository { return &SigningKeyRepo{db: db} }
+
+func (r *SigningKeyRepo) Create(ctx context.Context, k *entity.SigningKey) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO signing_keys(kid,algorithm,state,encrypted_key,created_at,activated_at,retired_at) VALUES (?,?,?,?,?,?,?)`, k.KID, k.Algorithm, k.State.String(), k.EncryptedKey, k.CreatedAt, nullTime(k.ActivatedAt), nullTime(k.RetiredAt))
+	return err
+}
+
+func (r *SigningKeyRepo) List(ctx context.Context, retiredAfter time.Time) ([]*entity.SigningKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT kid,algorithm,state,encrypted_key,created_at,activated_at,retired_at FROM signing_keys WHERE state IN ('pending','active') OR (state='retired' AND retired_at > ?) ORDER BY created_at`, retiredAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*entity.SigningKey
+	for rows.Next() {
+		k := &entity.SigningKey{}
+		var state string
+		var activated, retired sql.NullTime
+		if err := rows.Scan(&k.KID, &k.Algorithm, &state, &k.EncryptedKey, &k.CreatedAt, &activated, &retired); err != nil {
+			return nil, err
+		}
+		if k.State, err = enum.ParseKeyState(state); err != nil {
+			return nil, err
+		}
+		k.ActivatedAt = activated.Time
+		k.RetiredAt = retired.Time
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *SigningKeyRepo) Promote(ctx context.Context, kid string, staleBefore time.Time) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	// Keys rotate independently per algorithm; only the promoted key's algorithm is affected.
+	var alg string
+	if err := tx.QueryRowContext(ctx, `SELECT algorithm FROM signing_keys WHERE kid=?`, kid).Scan(&alg); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	// Lock the active row(s) so concurrent replicas serialize on rotation.
+	rows, err := tx.QueryContext(ctx, `SELECT activated_at FROM signing_keys WHERE state='active' AND algorithm=? FOR UPDATE`, alg)
+	if err != nil {
+		return false, err
+	}
+	fresh := false
+	for rows.Next() {
+		var activated sql.NullTime
+		if err := rows.Scan(&activated); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if activated.Time.After(staleBefore) {
+			fresh = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if fresh {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx, `UPDATE signing_keys SET state='active', activated_at=? WHERE kid=? AND state='pending'`, now, kid)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE signing_keys SET state='retired', retired_at=? WHERE state='active' AND algorithm=? AND kid<>?`, now, alg, kid); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *SigningKeyRepo) Revoke(ctx context.Context, kid string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE signing_keys SET state='revoked' WHERE kid=?`, kid)
+	return err
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -3,27 +3,43 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
+	"github.com/RanguraGIT/sso/domain/vo"
 	"github.com/google/uuid"
-
 )
 
 type TokenRepo struct{ db *sql.DB }
 
 func NewTokenRepo(db *sql.DB) repository.TokenRepository { return &TokenRepo{db: db} }
 
+const tokenColumns = `id,user_id,client_id,client_public_id,scopes,access_jwt,access_jti,refresh_token_id,parent_refresh_id,family_id,rotated,revoked,expires_at,refresh_expires,created_at,claims_request,session_id`
+
 func (r *TokenRepo) Store(ctx context.Context, t *entity.Token) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO tokens(id,user_id,client_id,client_public_id,access_jwt,refresh_token_id,parent_refresh_id,rotated,revoked,expires_at,refresh_expires,created_at) VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`, t.ID.String(), nullableUUID(t.UserID), t.ClientID.String(), t.ClientPublicID, t.AccessJWT, t.RefreshTokenID, nullString(t.ParentRefreshID), t.Rotated, t.Revoked, t.ExpiresAt, t.RefreshExpires, t.CreatedAt)
+	_, err := conn(ctx, r.db).ExecContext(ctx, `INSERT INTO tokens(`+tokenColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, t.ID.String(), nullableUUID(t.UserID), t.ClientID.String(), t.ClientPublicID, strings.Join(t.Scopes, " "), t.AccessJWT, nullString(t.AccessJTI), nullString(t.RefreshTokenID), nullString(t.ParentRefreshID), nullableUUID(t.FamilyID), t.Rotated, t.Revoked, t.ExpiresAt, t.RefreshExpires, t.CreatedAt, nullString(t.Claims.String()), nullableUUID(t.SessionID))
 	return err
 }
 
 func (r *TokenRepo) GetByRefreshID(ctx context.Context, refreshTokenID string) (*entity.Token, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,user_id,client_id,client_public_id,access_jwt,refresh_token_id,parent_refresh_id,rotated,revoked,expires_at,refresh_expires,created_at FROM tokens WHERE refresh_token_id=?`, refreshTokenID)
+	return scanToken(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+tokenColumns+` FROM tokens WHERE refresh_token_id=?`, refreshTokenID))
+}
+
+func (r *TokenRepo) GetByAccessJTI(ctx context.Context, jti string) (*entity.Token, error) {
+	return scanToken(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+tokenColumns+` FROM tokens WHERE access_jti=?`, jti))
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanToken(row rowScanner) (*entity.Token, error) {
 	t := &entity.Token{}
-	var userID, parent sql.NullString
-	if err := row.Scan(&t.ID, &userID, &t.ClientID, &t.ClientPublicID, &t.AccessJWT, &t.RefreshTokenID, &parent, &t.Rotated, &t.Revoked, &t.ExpiresAt, &t.RefreshExpires, &t.CreatedAt); err != nil {
+	var userID, scopes, jti, refreshID, parent, family, claims, sessionID sql.NullString
+	if err := row.Scan(&t.ID, &userID, &t.ClientID, &t.ClientPublicID, &scopes, &t.AccessJWT, &jti, &refreshID, &parent, &family, &t.Rotated, &t.Revoked, &t.ExpiresAt, &t.RefreshExpires, &t.CreatedAt, &claims, &sessionID); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -34,27 +50,119 @@ func (r *TokenRepo) GetByRefreshID(ctx context.Context, refreshTokenID string) (
 			t.UserID = uid
 		}
 	}
-	if parent.Valid {
-		t.ParentRefreshID = parent.String
+	t.Scopes = splitNonEmpty(scopes.String)
+	t.AccessJTI = jti.String
+	t.RefreshTokenID = refreshID.String
+	t.ParentRefreshID = parent.String
+	if family.Valid {
+		if fid, err := uuidParse(family.String); err == nil {
+			t.FamilyID = fid
+		}
 	}
+	if sessionID.Valid {
+		if sid, err := uuidParse(sessionID.String); err == nil {
+			t.SessionID = sid
+		}
+	}
+	cr, err := vo.ParseClaimsRequest(claims.String)
+	if err != nil {
+		return nil, err
+	}
+	t.Claims = cr
 	return t, nil
 }
 
 func (r *TokenRepo) RevokeByRefreshID(ctx context.Context, refreshTokenID string) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE refresh_token_id=?`, refreshTokenID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE refresh_token_id=?`, refreshTokenID)
 	return err
 }
 
-// RevokeChain naive implementation: revoke all sharing same initial prefix (future: add parent pointer)
+// ListFamily follows parent_refresh_id from the family root with a recursive CTE (MySQL 8+).
+func (r *TokenRepo) ListFamily(ctx context.Context, familyID uuid.UUID) ([]*entity.Token, error) {
+	cols := "t." + strings.ReplaceAll(tokenColumns, ",", ",t.")
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `WITH RECURSIVE lineage AS (
+			SELECT `+cols+`, 0 AS depth FROM tokens t WHERE t.id=?
+			UNION ALL
+			SELECT `+cols+`, l.depth+1 FROM tokens t JOIN lineage l ON t.parent_refresh_id = l.refresh_token_id
+		)
+		SELECT `+tokenColumns+` FROM lineage ORDER BY depth, created_at`, familyID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*entity.Token
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// RevokeChain revokes every token of refreshTokenID's family and denylists the family's
+// unexpired access tokens, atomically (joining the caller's unit of work if there is one).
 func (r *TokenRepo) RevokeChain(ctx context.Context, refreshTokenID string) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE refresh_token_id=?`, refreshTokenID)
-	return err
+	return NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		c := conn(ctx, r.db)
+		var familyID sql.NullString
+		err := c.QueryRowContext(ctx, `SELECT family_id FROM tokens WHERE refresh_token_id=? FOR UPDATE`, refreshTokenID).Scan(&familyID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !familyID.Valid {
+			// Row predates family tracking and was not backfilled: revoke just this token.
+			_, err := c.ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE refresh_token_id=?`, refreshTokenID)
+			return err
+		}
+		now := time.Now().UTC()
+		if _, err := c.ExecContext(ctx, `INSERT IGNORE INTO revoked_access_tokens(jti,expires_at,revoked_at)
+			SELECT access_jti, expires_at, ? FROM tokens WHERE family_id=? AND access_jti IS NOT NULL AND expires_at > ?`, now, familyID.String, now); err != nil {
+			return err
+		}
+		_, err = c.ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE family_id=?`, familyID.String)
+		return err
+	})
 }
 
-// MarkRotated marks a refresh token as rotated so future reuse attempts can be detected.
-func (r *TokenRepo) MarkRotated(ctx context.Context, refreshTokenID string) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE tokens SET rotated=1 WHERE refresh_token_id=?`, refreshTokenID)
-	return err
+// MarkRotated flips rotated 0 -> 1 for a live refresh token and reports whether this call won.
+func (r *TokenRepo) MarkRotated(ctx context.Context, refreshTokenID string) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE tokens SET rotated=1 WHERE refresh_token_id=? AND rotated=0 AND revoked=0`, refreshTokenID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *TokenRepo) RevokeByUserClient(ctx context.Context, userID uuid.UUID, clientID string) error {
+	return NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		c := conn(ctx, r.db)
+		now := time.Now().UTC()
+		if _, err := c.ExecContext(ctx, `INSERT IGNORE INTO revoked_access_tokens(jti,expires_at,revoked_at)
+			SELECT access_jti, expires_at, ? FROM tokens WHERE user_id=? AND client_public_id=? AND revoked=0 AND access_jti IS NOT NULL AND expires_at > ?`, now, userID.String(), clientID, now); err != nil {
+			return err
+		}
+		_, err := c.ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE user_id=? AND client_public_id=? AND revoked=0`, userID.String(), clientID)
+		return err
+	})
+}
+
+func (r *TokenRepo) RevokeBySession(ctx context.Context, sessionID uuid.UUID) error {
+	return NewUnitOfWork(r.db).Do(ctx, func(ctx context.Context) error {
+		c := conn(ctx, r.db)
+		now := time.Now().UTC()
+		if _, err := c.ExecContext(ctx, `INSERT IGNORE INTO revoked_access_tokens(jti,expires_at,revoked_at)
+			SELECT access_jti, expires_at, ? FROM tokens WHERE session_id=? AND revoked=0 AND access_jti IS NOT NULL AND expires_at > ?`, now, sessionID.String(), now); err != nil {
+			return err
+		}
+		_, err := c.ExecContext(ctx, `UPDATE tokens SET revoked=1 WHERE session_id=? AND revoked=0`, sessionID.String())
+		return err
+	})
 }
 
 // Helpers
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
@@ -13,36 +14,71 @@ type UserRepo struct{ db *sql.DB }
 
 func NewUserRepo(db *sql.DB) repository.UserRepository { return &UserRepo{db: db} }
 
+const userColumns = `id,email,password_hash,email_verified,locked,name,given_name,family_name,picture,locale,zoneinfo,phone_number,phone_number_verified,address,created_at,updated_at`
+
 func (r *UserRepo) GetByID(ctx context.Context, id uuid.UUID) (*entity.User, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,email,password_hash,email_verified,locked,created_at,updated_at FROM users WHERE id=?`, id.String())
+	return scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id=?`, id.String()))
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email=?`, email))
+}
+
+func scanUser(row rowScanner) (*entity.User, error) {
 	u := &entity.User{}
-	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.EmailVerified, &u.Locked, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	var name, given, family, picture, locale, zoneinfo, phone, address sql.NullString
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.EmailVerified, &u.Locked, &name, &given, &family, &picture, &locale, &zoneinfo, &phone, &u.Profile.PhoneNumberVerified, &address, &u.CreatedAt, &u.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
+	u.Profile.Name = name.String
+	u.Profile.GivenName = given.String
+	u.Profile.FamilyName = family.String
+	u.Profile.Picture = picture.String
+	u.Profile.Locale = locale.String
+	u.Profile.Zoneinfo = zoneinfo.String
+	u.Profile.PhoneNumber = phone.String
+	if address.String != "" {
+		if err := json.Unmarshal([]byte(address.String), &u.Profile.Address); err != nil {
+			return nil, err
+		}
+	}
 	return u, nil
 }
 
-func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id,email,password_hash,email_verified,locked,created_at,updated_at FROM users WHERE email=?`, email)
-	u := &entity.User{}
-	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.EmailVerified, &u.Locked, &u.CreatedAt, &u.UpdatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+// profileArgs returns the profile column values in userColumns order, address as JSON.
+func profileArgs(p entity.UserProfile) ([]any, error) {
+	var address any
+	if !p.Address.IsZero() {
+		b, err := json.Marshal(p.Address)
+		if err != nil {
+			return nil, err
 		}
-		return nil, err
+		address = string(b)
 	}
-	return u, nil
+	return []any{nullString(p.Name), nullString(p.GivenName), nullString(p.FamilyName), nullString(p.Picture), nullString(p.Locale), nullString(p.Zoneinfo), nullString(p.PhoneNumber), p.PhoneNumberVerified, address}, nil
 }
 
 func (r *UserRepo) Create(ctx context.Context, u *entity.User) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO users(id,email,password_hash,email_verified,locked,created_at,updated_at) VALUES (?,?,?,?,?,?,?)`, u.ID.String(), u.Email, u.PasswordHash, u.EmailVerified, u.Locked, u.CreatedAt, u.UpdatedAt)
+	profile, err := profileArgs(u.Profile)
+	if err != nil {
+		return err
+	}
+	args := append([]any{u.ID.String(), u.Email, u.PasswordHash, u.EmailVerified, u.Locked}, profile...)
+	args = append(args, u.CreatedAt, u.UpdatedAt)
+	_, err = r.db.ExecContext(ctx, `INSERT INTO users(`+userColumns+`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`, args...)
 	return err
 }
 
 func (r *UserRepo) Update(ctx context.Context, u *entity.User) error {
-	_, err := r.db.ExecContext(ctx, `UPDATE users SET email=?, password_hash=?, email_verified=?, locked=?, updated_at=NOW(6) WHERE id=?`, u.Email, u.PasswordHash, u.EmailVerified, u.Locked, u.ID.String())
+	profile, err := profileArgs(u.Profile)
+	if err != nil {
+		return err
+	}
+	args := append([]any{u.Email, u.PasswordHash, u.EmailVerified, u.Locked}, profile...)
+	args = append(args, u.ID.String())
+	_, err = r.db.ExecContext(ctx, `UPDATE users SET email=?, password_hash=?, email_verified=?, locked=?, name=?, given_name=?, family_name=?, picture=?, locale=?, zoneinfo=?, phone_number=?, phone_number_verified=?, address=?, updated_at=NOW(6) WHERE id=?`, args...)
 	return err
 }
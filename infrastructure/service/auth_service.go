@@ -19,7 +19,7 @@ func (s *SimpleAuthService) VerifyUserPassword(ctx context.Context, userID uuid.
 	return true, nil
 }
 func (s *SimpleAuthService) VerifyClientSecret(ctx context.Context, clientID string, providedSecret string) (bool, error) {
-	return true, nil
+	return false, errors.New("client secret verification not supported")
 }
 func (s *SimpleAuthService) ValidatePKCE(method, challenge, verifier string) error {
 	if method == "S256" {
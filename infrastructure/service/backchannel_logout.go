@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// Back-channel delivery tuning. Attempts back off exponentially from logoutRetryBase up to
+// logoutRetryMax; a delivery still failing after logoutMaxAttempts is dropped.
+const (
+	logoutTokenTTL    = 2 * time.Minute
+	logoutRetryBase   = 10 * time.Second
+	logoutRetryMax    = time.Hour
+	logoutMaxAttempts = 10
+	logoutBatchSize   = 50
+	logoutClaimLease  = time.Minute // longer than one batch of requests can take
+)
+
+// BackchannelLogoutService queues logout_tokens in a LogoutDeliveryRepository and delivers them
+// from Run. Queueing wakes the worker, so notifications normally go out immediately; the persisted
+// queue only matters for retries and restarts.
+type BackchannelLogoutService struct {
+	clients repository.ClientRepository
+	queue   repository.LogoutDeliveryRepository
+	tokens  dservice.TokenService
+	client  *http.Client
+	wake    chan struct{}
+}
+
+func NewBackchannelLogoutService(clients repository.ClientRepository, queue repository.LogoutDeliveryRepository, tokens dservice.TokenService) *BackchannelLogoutService {
+	return &BackchannelLogoutService{
+		clients: clients,
+		queue:   queue,
+		tokens:  tokens,
+		// Relying parties must not hold the worker up; redirects are not followed (section 2.5).
+		client: &http.Client{
+			Timeout:       10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// SessionEnded implements dservice.BackchannelLogout.
+func (s *BackchannelLogoutService) SessionEnded(ctx context.Context, sess *entity.Session, issuer string) error {
+	queued := 0
+	for _, id := range sess.ClientIDs {
+		cli, err := s.clients.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cli == nil || cli.BackchannelLogoutURI == "" {
+			continue
+		}
+		d, err := entity.NewLogoutDelivery(cli.ClientID, cli.BackchannelLogoutURI, issuer, sess.UserID.String(), sess.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.queue.Enqueue(ctx, d); err != nil {
+			return err
+		}
+		queued++
+	}
+	if queued > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run delivers queued notifications until ctx is done, polling every interval for retries and for
+// work queued by other replicas.
+func (s *BackchannelLogoutService) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// deliverDue sends every delivery that is due, batch by batch.
+func (s *BackchannelLogoutService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := s.queue.ClaimDue(ctx, time.Now().UTC(), logoutClaimLease, logoutBatchSize)
+		if err != nil {
+			log.Printf("backchannel logout: claim: %v", err)
+			return
+		}
+		for _, d := range batch {
+			s.attempt(ctx, d)
+		}
+		if len(batch) < logoutBatchSize {
+			return
+		}
+	}
+}
+
+func (s *BackchannelLogoutService) attempt(ctx context.Context, d *entity.LogoutDelivery) {
+	err := s.send(ctx, d)
+	if err == nil {
+		if err := s.queue.Complete(ctx, d.ID); err != nil {
+			log.Printf("backchannel logout: complete %s: %v", d.ID, err)
+		}
+		return
+	}
+	d.Attempts++
+	if d.Attempts >= logoutMaxAttempts {
+		log.Printf("backchannel logout: giving up on client=%s after %d attempts: %v", d.ClientID, d.Attempts, err)
+		if err := s.queue.Complete(ctx, d.ID); err != nil {
+			log.Printf("backchannel logout: complete %s: %v", d.ID, err)
+		}
+		return
+	}
+	backoff := logoutRetryBase << (d.Attempts - 1)
+	if backoff > logoutRetryMax || backoff <= 0 {
+		backoff = logoutRetryMax
+	}
+	if err := s.queue.Reschedule(ctx, d.ID, d.Attempts, time.Now().UTC().Add(backoff), err.Error()); err != nil {
+		log.Printf("backchannel logout: reschedule %s: %v", d.ID, err)
+	}
+}
+
+// send signs a fresh logout_token for d and POSTs it to the client.
+func (s *BackchannelLogoutService) send(ctx context.Context, d *entity.LogoutDelivery) error {
+	cli, err := s.clients.GetByClientID(ctx, d.ClientID)
+	if err != nil {
+		return err
+	}
+	alg := ""
+	if cli != nil {
+		alg = cli.IDTokenSignedResponseAlg // verified with the same keys as the client's ID tokens
+	}
+	token, err := s.tokens.SignClaims(ctx, "logout+jwt", logoutTokenClaims(d, time.Now().UTC()), alg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URI, strings.NewReader(url.Values{"logout_token": {token}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// logoutTokenClaims builds the claim set of a logout_token (Back-Channel Logout 1.0 section 2.4).
+// It never carries a nonce, which keeps it from being mistaken for an ID token.
+func logoutTokenClaims(d *entity.LogoutDelivery, now time.Time) map[string]any {
+	claims := map[string]any{
+		"iss":    d.Issuer,
+		"aud":    d.ClientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(logoutTokenTTL).Unix(),
+		"jti":    uuid.NewString(),
+		"events": map[string]any{dservice.BackchannelLogoutEvent: map[string]any{}},
+	}
+	if d.Subject != "" {
+		claims["sub"] = d.Subject
+	}
+	if d.SessionID != uuid.Nil {
+		claims["sid"] = d.SessionID.String()
+	}
+	return claims
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+// logoutReceiver is a relying party's backchannel_logout_uri answering with status.
+type logoutReceiver struct {
+	mu     sync.Mutex
+	status int
+	tokens []string
+}
+
+func (rp *logoutReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if r.Method == http.MethodPost && r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		rp.tokens = append(rp.tokens, r.PostFormValue("logout_token"))
+	}
+	w.WriteHeader(rp.status)
+}
+
+func TestBackchannelLogout(t *testing.T) {
+	ctx := context.Background()
+	const issuer = "https://sso.example.com"
+	keys := NewInMemoryKeyRotation(time.Hour, "RS256", "ES256")
+	receiver := &logoutReceiver{status: http.StatusOK}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	newClient := func(id, logoutURI string) *entity.Client {
+		c, err := entity.NewClient(id, id, "hashed", []string{"https://rp.example.com/cb"}, []string{"openid"}, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.BackchannelLogoutURI = logoutURI
+		return c
+	}
+	rp := newClient("rp", srv.URL+"/logout")
+	rp.IDTokenSignedResponseAlg = "ES256"
+	silent := newClient("silent", "")
+	user := uuid.New()
+	sess, _ := entity.NewSession(user, time.Hour, "", "")
+	sess.ClientIDs = []uuid.UUID{rp.ID, silent.ID, uuid.New()}
+
+	setup := func(status int) (*BackchannelLogoutService, *memLogoutQueue) {
+		receiver.mu.Lock()
+		receiver.status, receiver.tokens = status, nil
+		receiver.mu.Unlock()
+		queue := newMemLogoutQueue()
+		svc := NewBackchannelLogoutService(testfake.NewClients(rp, silent), queue, NewJWTTokenService(keys, TokenValidation{}))
+		if err := svc.SessionEnded(ctx, sess, issuer); err != nil {
+			t.Fatal(err)
+		}
+		return svc, queue
+	}
+
+	t.Run("queues clients with a backchannel_logout_uri", func(t *testing.T) {
+		_, queue := setup(http.StatusOK)
+		pending := queue.list()
+		if len(pending) != 1 || pending[0].ClientID != "rp" || pending[0].URI != srv.URL+"/logout" || pending[0].Subject != user.String() || pending[0].SessionID != sess.ID || pending[0].Issuer != issuer {
+			t.Fatalf("pending %+v", pending)
+		}
+	})
+	t.Run("logout token contents", func(t *testing.T) {
+		svc, queue := setup(http.StatusOK)
+		svc.deliverDue(ctx)
+		if len(queue.list()) != 0 || len(queue.complete) != 1 || len(receiver.tokens) != 1 {
+			t.Fatalf("pending %d complete %d received %d", len(queue.list()), len(queue.complete), len(receiver.tokens))
+		}
+		claims := jwt.MapClaims{}
+		tok, err := jwt.ParseWithClaims(receiver.tokens[0], claims, func(tok *jwt.Token) (any, error) {
+			pub, _, err := keys.VerificationKey(tok.Header["kid"].(string))
+			return pub, err
+		}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuer(issuer), jwt.WithAudience("rp"), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.Header["typ"] != "logout+jwt" {
+			t.Errorf("typ %v", tok.Header["typ"])
+		}
+		if claims["sub"] != user.String() || claims["sid"] != sess.ID.String() || claims["jti"] == "" {
+			t.Errorf("claims %v", claims)
+		}
+		events, _ := claims["events"].(map[string]any)
+		if _, ok := events[dservice.BackchannelLogoutEvent]; !ok || len(events) != 1 {
+			t.Errorf("events %v", claims["events"])
+		}
+		if _, ok := claims["nonce"]; ok {
+			t.Error("a logout token must not carry a nonce")
+		}
+	})
+	for name, status := range map[string]int{"error status": http.StatusInternalServerError, "redirects are not followed": http.StatusFound} {
+		t.Run(name, func(t *testing.T) {
+			svc, queue := setup(status)
+			before := time.Now()
+			svc.deliverDue(ctx)
+			pending := queue.list()
+			if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" {
+				t.Fatalf("pending %+v", pending)
+			}
+			if wait := pending[0].NextAttemptAt.Sub(before); wait < logoutRetryBase || wait > logoutRetryBase+time.Minute {
+				t.Fatalf("first retry in %v, want about %v", wait, logoutRetryBase)
+			}
+			svc.deliverDue(ctx)
+			if pending := queue.list(); pending[0].Attempts != 2 || pending[0].NextAttemptAt.Sub(before) < 2*logoutRetryBase {
+				t.Fatalf("second attempt %+v: backoff must grow", pending[0])
+			}
+		})
+	}
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		svc, queue := setup(http.StatusServiceUnavailable)
+		for range logoutMaxAttempts {
+			svc.deliverDue(ctx)
+		}
+		if len(queue.list()) != 0 || len(queue.complete) != 1 || len(receiver.tokens) != logoutMaxAttempts {
+			t.Fatalf("pending %d complete %d attempts %d", len(queue.list()), len(queue.complete), len(receiver.tokens))
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -13,17 +14,24 @@ import (
 )
 
 // BcryptAuthService implements password verification using bcrypt and the user repository.
-// It does not perform timing attack mitigations beyond bcrypt cost; later add constant-time email existence checks.
+// Secret checks for unknown clients spend a bcrypt comparison too; user lookups are not yet
+// padded the same way.
 type BcryptAuthService struct {
-	users repository.UserRepository
-	cost  int
+	users   repository.UserRepository
+	clients repository.ClientRepository
+	cost    int
+
+	dummyOnce sync.Once
+	dummyHash []byte // hash at cost compared against for clients without a stored secret
 }
 
-func NewBcryptAuthService(users repository.UserRepository, cost int) service.AuthService {
+// NewBcryptAuthService builds the bcrypt-backed service. clients may be nil when only
+// password verification is needed; client secret checks then always fail.
+func NewBcryptAuthService(users repository.UserRepository, clients repository.ClientRepository, cost int) service.AuthService {
 	if cost <= 0 {
 		cost = bcrypt.DefaultCost
 	}
-	return &BcryptAuthService{users: users, cost: cost}
+	return &BcryptAuthService{users: users, clients: clients, cost: cost}
 }
 
 func (s *BcryptAuthService) VerifyUserPassword(ctx context.Context, userID uuid.UUID, providedPassword string) (bool, error) {
@@ -43,11 +51,38 @@ func (s *BcryptAuthService) VerifyUserPassword(ctx context.Context, userID uuid.
 	return true, nil
 }
 
+// VerifyClientSecret compares the presented secret with the client's bcrypt-hashed secret.
+// Public clients (no stored hash) never verify.
 func (s *BcryptAuthService) VerifyClientSecret(ctx context.Context, clientID string, providedSecret string) (bool, error) {
-	// Client secret verification delegated elsewhere (not implemented yet)
+	if s.clients == nil {
+		return false, errors.New("client repository not configured")
+	}
+	if clientID == "" || providedSecret == "" {
+		return false, nil
+	}
+	c, err := s.clients.GetByClientID(ctx, clientID)
+	if err != nil {
+		return false, err
+	}
+	if c == nil || c.HashedSecret == "" {
+		s.dummyCompare(providedSecret)
+		return false, nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(c.HashedSecret), []byte(providedSecret)); err != nil {
+		return false, nil
+	}
 	return true, nil
 }
 
+// dummyCompare spends one bcrypt comparison at the service cost, so a check against an unknown
+// client takes as long as one against a known client and does not reveal which client_ids exist.
+func (s *BcryptAuthService) dummyCompare(secret string) {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("no-such-client"), s.cost)
+	})
+	_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(secret))
+}
+
 func (s *BcryptAuthService) ValidatePKCE(method, challenge, verifier string) error {
 	// Reuse logic from SimpleAuthService for now (duplicate small logic to avoid dependency)
 	if challenge == "" {
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// JWTBearerAssertionType is the client_assertion_type for private_key_jwt (RFC 7523 section 2.2).
+const JWTBearerAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// assertionAlgs lists the JWS algorithms accepted for private_key_jwt assertions.
+var assertionAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// ClientAuthenticatorImpl authenticates clients with the method they registered:
+// shared secrets are delegated to AuthService, private_key_jwt assertions are verified
+// against the JWKS stored on the client. Assertion replay is detected per replica (see
+// assertionReplayCache); Run must be started to drop expired entries.
+type ClientAuthenticatorImpl struct {
+	clients repository.ClientRepository
+	auth    dservice.AuthService
+	leeway  time.Duration
+	seen    *assertionReplayCache
+}
+
+func NewClientAuthenticator(clients repository.ClientRepository, auth dservice.AuthService) *ClientAuthenticatorImpl {
+	return &ClientAuthenticatorImpl{clients: clients, auth: auth, leeway: 30 * time.Second, seen: newAssertionReplayCache()}
+}
+
+func (a *ClientAuthenticatorImpl) Authenticate(ctx context.Context, req dservice.ClientAuthRequest) (*entity.Client, error) {
+	clientID := req.ClientID
+	if req.Method == enum.ClientAuthPrivateKeyJWT && clientID == "" {
+		// client_id is optional alongside an assertion; take it from the (not yet verified) sub.
+		sub, err := unverifiedSubject(req.Assertion)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", dservice.ErrInvalidClient, err)
+		}
+		clientID = sub
+	}
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: missing client_id", dservice.ErrInvalidClient)
+	}
+	c, err := a.clients.GetByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		a.spendSecretCheck(ctx, clientID, req)
+		return nil, fmt.Errorf("%w: unknown client", dservice.ErrInvalidClient)
+	}
+	if registered := c.AuthMethod(); registered != req.Method {
+		a.spendSecretCheck(ctx, clientID, req)
+		return nil, fmt.Errorf("%w: client registered for %s, presented %s", dservice.ErrInvalidClient, registered, req.Method)
+	}
+	switch req.Method {
+	case enum.ClientAuthNone:
+		if c.Confidential {
+			return nil, fmt.Errorf("%w: confidential client must authenticate", dservice.ErrInvalidClient)
+		}
+	case enum.ClientAuthSecretBasic, enum.ClientAuthSecretPost:
+		ok, err := a.auth.VerifyClientSecret(ctx, c.ClientID, req.ClientSecret)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("%w: secret mismatch", dservice.ErrInvalidClient)
+		}
+	case enum.ClientAuthPrivateKeyJWT:
+		if err := a.verifyAssertion(c, req); err != nil {
+			return nil, fmt.Errorf("%w: %v", dservice.ErrInvalidClient, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported method %s", dservice.ErrInvalidClient, req.Method)
+	}
+	return c, nil
+}
+
+// spendSecretCheck runs the secret comparison a rejected request would have cost had it named a
+// client with a secret, so response times do not tell which client_ids exist or how they
+// authenticate. The result is ignored.
+func (a *ClientAuthenticatorImpl) spendSecretCheck(ctx context.Context, clientID string, req dservice.ClientAuthRequest) {
+	if req.Method == enum.ClientAuthSecretBasic || req.Method == enum.ClientAuthSecretPost {
+		_, _ = a.auth.VerifyClientSecret(ctx, clientID, req.ClientSecret)
+	}
+}
+
+// Run drops expired assertion jti values every interval until ctx is done.
+func (a *ClientAuthenticatorImpl) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			a.seen.sweep(now)
+		}
+	}
+}
+
+func (a *ClientAuthenticatorImpl) Methods() []enum.ClientAuthMethod {
+	return []enum.ClientAuthMethod{enum.ClientAuthSecretBasic, enum.ClientAuthSecretPost, enum.ClientAuthPrivateKeyJWT, enum.ClientAuthNone}
+}
+
+func (a *ClientAuthenticatorImpl) AssertionSigningAlgs() []string {
+	return append([]string(nil), assertionAlgs...)
+}
+
+// verifyAssertion checks a private_key_jwt assertion: signature against the client's JWKS,
+// iss == sub == client_id, an accepted aud, exp, and a jti that has not been seen before.
+func (a *ClientAuthenticatorImpl) verifyAssertion(c *entity.Client, req dservice.ClientAuthRequest) error {
+	if req.AssertionType != JWTBearerAssertionType || req.Assertion == "" {
+		return errors.New("unsupported client_assertion_type")
+	}
+	set, err := parseJWKS(c.JWKS)
+	if err != nil {
+		return err
+	}
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		jwk, err := set.find(kid, "sig")
+		if err != nil {
+			return nil, err
+		}
+		pub, err := jwk.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		if !keyMatchesMethod(pub, t.Method) {
+			return nil, errors.New("key type does not match alg")
+		}
+		return pub, nil
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(req.Assertion, claims, keyFunc,
+		jwt.WithValidMethods(assertionAlgs),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(c.ClientID),
+		jwt.WithSubject(c.ClientID),
+		jwt.WithLeeway(a.leeway),
+	)
+	if err != nil {
+		return err
+	}
+	aud, _ := claims.GetAudience()
+	if !audienceAccepted(aud, req.Audience) {
+		return errors.New("assertion audience mismatch")
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("assertion jti required")
+	}
+	exp, _ := claims.GetExpirationTime()
+	if !a.seen.add(c.ClientID+"|"+jti, exp.Time.Add(a.leeway)) {
+		return errors.New("assertion replayed")
+	}
+	return nil
+}
+
+func keyMatchesMethod(pub crypto.PublicKey, m jwt.SigningMethod) bool {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		switch m.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		_, ok := m.(*jwt.SigningMethodECDSA)
+		return ok
+	case ed25519.PublicKey:
+		_, ok := m.(*jwt.SigningMethodEd25519)
+		return ok
+	}
+	return false
+}
+
+func audienceAccepted(aud, accepted []string) bool {
+	for _, a := range aud {
+		for _, b := range accepted {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unverifiedSubject(assertion string) (string, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(assertion, claims); err != nil {
+		return "", err
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return "", errors.New("assertion missing sub")
+	}
+	return sub, nil
+}
+
+// assertionReplayCache remembers assertion jti values until they expire. It lives in process
+// memory, so replay protection is per replica: behind a load balancer an assertion can be accepted
+// once by each replica within its lifetime. Clients should keep assertion lifetimes short.
+type assertionReplayCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func newAssertionReplayCache() *assertionReplayCache {
+	return &assertionReplayCache{entries: map[string]time.Time{}}
+}
+
+// add records key until expiry and reports false if it is already recorded and not yet expired.
+func (c *assertionReplayCache) add(key string, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if exp, ok := c.entries[key]; ok && time.Now().Before(exp) {
+		return false
+	}
+	c.entries[key] = expiry
+	return true
+}
+
+// sweep forgets the entries expired at now.
+func (c *assertionReplayCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, exp := range c.entries {
+		if now.After(exp) {
+			delete(c.entries, k)
+		}
+	}
+}
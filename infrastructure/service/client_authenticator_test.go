@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+const assertionAudience = "https://sso.example.com/token"
+
+// ecJWKS publishes key under kid as a client JWK Set.
+func ecJWKS(t *testing.T, kid string, key *ecdsa.PublicKey) string {
+	t.Helper()
+	raw, err := json.Marshal(jsonWebKeySet{Keys: []jsonWebKey{{
+		Kty: "EC", Kid: kid, Use: "sig", Alg: "ES256", Crv: "P-256",
+		X: base64url(key.X.FillBytes(make([]byte, 32))),
+		Y: base64url(key.Y.FillBytes(make([]byte, 32))),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestClientAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	newClient := func(id string, confidential bool, method enum.ClientAuthMethod) *entity.Client {
+		secret := ""
+		if confidential && method != enum.ClientAuthPrivateKeyJWT {
+			secret = string(hash)
+		}
+		c, err := entity.NewClient(id, id, secret, []string{"https://rp.example.com/cb"}, []string{"openid"}, confidential, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.TokenEndpointAuthMethod = method
+		return c
+	}
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwtClient := newClient("jwt-client", true, enum.ClientAuthPrivateKeyJWT)
+	jwtClient.JWKS = ecJWKS(t, "k1", &clientKey.PublicKey)
+	clients := testfake.NewClients(
+		newClient("basic-client", true, ""),
+		newClient("post-client", true, enum.ClientAuthSecretPost),
+		newClient("public-client", false, ""),
+		jwtClient,
+	)
+	secrets := &countingAuth{AuthService: NewBcryptAuthService(nil, clients, bcrypt.MinCost)}
+	auth := NewClientAuthenticator(clients, secrets)
+
+	assertion := func(key *ecdsa.PrivateKey, edit func(jwt.MapClaims)) string {
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss": "jwt-client",
+			"sub": "jwt-client",
+			"aud": assertionAudience,
+			"jti": randomKID(8),
+			"iat": now.Unix(),
+			"exp": now.Add(time.Minute).Unix(),
+		}
+		if edit != nil {
+			edit(claims)
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		tok.Header["kid"] = "k1"
+		signed, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	jwtReq := func(a string) dservice.ClientAuthRequest {
+		return dservice.ClientAuthRequest{Method: enum.ClientAuthPrivateKeyJWT, AssertionType: JWTBearerAssertionType, Assertion: a, Audience: []string{assertionAudience, "https://sso.example.com"}}
+	}
+	replayed := assertion(clientKey, nil)
+	if _, err := auth.Authenticate(ctx, jwtReq(replayed)); err != nil {
+		t.Fatalf("first use of assertion: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		req  dservice.ClientAuthRequest
+		want string // authenticated client_id; empty expects ErrInvalidClient
+	}{
+		{"client_secret_basic", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretBasic, ClientID: "basic-client", ClientSecret: "s3cret"}, "basic-client"},
+		{"client_secret_basic wrong secret", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretBasic, ClientID: "basic-client", ClientSecret: "guess"}, ""},
+		{"client_secret_post", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretPost, ClientID: "post-client", ClientSecret: "s3cret"}, "post-client"},
+		{"post presented by basic client", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretPost, ClientID: "basic-client", ClientSecret: "s3cret"}, ""},
+		{"basic presented by post client", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretBasic, ClientID: "post-client", ClientSecret: "s3cret"}, ""},
+		{"none for public client", dservice.ClientAuthRequest{Method: enum.ClientAuthNone, ClientID: "public-client"}, "public-client"},
+		{"none for confidential client", dservice.ClientAuthRequest{Method: enum.ClientAuthNone, ClientID: "basic-client"}, ""},
+		{"unknown client", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretBasic, ClientID: "ghost", ClientSecret: "s3cret"}, ""},
+		{"secret presented by private_key_jwt client", dservice.ClientAuthRequest{Method: enum.ClientAuthSecretBasic, ClientID: "jwt-client", ClientSecret: "s3cret"}, ""},
+		{"private_key_jwt", jwtReq(assertion(clientKey, nil)), "jwt-client"},
+		{"private_key_jwt with client_id", func() dservice.ClientAuthRequest {
+			r := jwtReq(assertion(clientKey, nil))
+			r.ClientID = "jwt-client"
+			return r
+		}(), "jwt-client"},
+		{"issuer audience", jwtReq(assertion(clientKey, func(c jwt.MapClaims) { c["aud"] = []string{"https://sso.example.com"} })), "jwt-client"},
+		{"foreign audience", jwtReq(assertion(clientKey, func(c jwt.MapClaims) { c["aud"] = "https://other.example.com/token" })), ""},
+		{"iss is not the client", jwtReq(assertion(clientKey, func(c jwt.MapClaims) { c["iss"] = "someone-else" })), ""},
+		{"sub is not the client", func() dservice.ClientAuthRequest {
+			r := jwtReq(assertion(clientKey, func(c jwt.MapClaims) { c["sub"] = "basic-client" }))
+			r.ClientID = "jwt-client"
+			return r
+		}(), ""},
+		{"expired", jwtReq(assertion(clientKey, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), ""},
+		{"no exp", jwtReq(assertion(clientKey, func(c jwt.MapClaims) { delete(c, "exp") })), ""},
+		{"no jti", jwtReq(assertion(clientKey, func(c jwt.MapClaims) { delete(c, "jti") })), ""},
+		{"replayed jti", jwtReq(replayed), ""},
+		{"signed by an unregistered key", jwtReq(assertion(otherKey, nil)), ""},
+		{"wrong assertion type", func() dservice.ClientAuthRequest {
+			r := jwtReq(assertion(clientKey, nil))
+			r.AssertionType = "urn:example:saml"
+			return r
+		}(), ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := auth.Authenticate(ctx, tc.req)
+			if tc.want == "" {
+				if !errors.Is(err, dservice.ErrInvalidClient) {
+					t.Fatalf("err = %v, want invalid_client", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.ClientID != tc.want {
+				t.Fatalf("authenticated %q, want %q", c.ClientID, tc.want)
+			}
+		})
+	}
+
+	// Rejections before the secret is looked at still pay for a comparison.
+	for _, req := range []dservice.ClientAuthRequest{
+		{Method: enum.ClientAuthSecretBasic, ClientID: "ghost", ClientSecret: "s3cret"},
+		{Method: enum.ClientAuthSecretPost, ClientID: "basic-client", ClientSecret: "s3cret"},
+	} {
+		before := secrets.calls
+		if _, err := auth.Authenticate(ctx, req); !errors.Is(err, dservice.ErrInvalidClient) || secrets.calls != before+1 {
+			t.Fatalf("%s %s: err %v, %d secret checks", req.Method, req.ClientID, err, secrets.calls-before)
+		}
+	}
+}
+
+// countingAuth counts the client secret checks it passes on.
+type countingAuth struct {
+	dservice.AuthService
+	calls int
+}
+
+func (c *countingAuth) VerifyClientSecret(ctx context.Context, clientID, secret string) (bool, error) {
+	c.calls++
+	return c.AuthService.VerifyClientSecret(ctx, clientID, secret)
+}
+
+func TestVerifyClientSecretUnknownClient(t *testing.T) {
+	svc := NewBcryptAuthService(nil, testfake.NewClients(), bcrypt.MinCost).(*BcryptAuthService)
+	if ok, err := svc.VerifyClientSecret(context.Background(), "ghost", "s3cret"); ok || err != nil {
+		t.Fatalf("ok %v err %v", ok, err)
+	}
+	if cost, err := bcrypt.Cost(svc.dummyHash); err != nil || cost != bcrypt.MinCost {
+		t.Fatalf("no comparison at the service cost: %v", err)
+	}
+}
+
+func TestAssertionReplayCacheSweep(t *testing.T) {
+	c := newAssertionReplayCache()
+	now := time.Now()
+	c.add("old", now.Add(-time.Minute))
+	c.add("live", now.Add(time.Minute))
+	if !c.add("old", now.Add(time.Minute)) {
+		t.Fatal("an expired entry must not block its key")
+	}
+	if c.add("live", now.Add(time.Minute)) {
+		t.Fatal("a live entry must be reported as replayed")
+	}
+	c.add("stale", now.Add(-time.Second))
+	c.sweep(now)
+	if _, ok := c.entries["stale"]; ok || len(c.entries) != 2 {
+		t.Fatalf("sweep left %v", c.entries)
+	}
+}
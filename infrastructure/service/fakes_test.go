@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+)
+
+// memSigningKeys is an in-memory repository.SigningKeyRepository shared by several key services,
+// standing in for the signing_keys table of a multi-replica deployment.
+type memSigningKeys struct {
+	mu    sync.Mutex
+	keys  []*entity.SigningKey
+	lists int // List calls, i.e. reloads
+}
+
+func (m *memSigningKeys) Create(_ context.Context, k *entity.SigningKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *k
+	m.keys = append(m.keys, &cp)
+	return nil
+}
+
+func (m *memSigningKeys) List(_ context.Context, retiredAfter time.Time) ([]*entity.SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lists++
+	var out []*entity.SigningKey
+	for _, k := range m.keys {
+		if k.State == enum.KeyStatePending || k.State == enum.KeyStateActive || (k.State == enum.KeyStateRetired && k.RetiredAt.After(retiredAfter)) {
+			cp := *k
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memSigningKeys) Promote(_ context.Context, kid string, staleBefore time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var target *entity.SigningKey
+	for _, k := range m.keys {
+		if k.KID == kid {
+			target = k
+		}
+	}
+	if target == nil || target.State != enum.KeyStatePending {
+		return false, nil
+	}
+	for _, k := range m.keys {
+		if k.Algorithm == target.Algorithm && k.State == enum.KeyStateActive && k.ActivatedAt.After(staleBefore) {
+			return false, nil
+		}
+	}
+	now := time.Now().UTC()
+	for _, k := range m.keys {
+		if k.Algorithm == target.Algorithm && k.State == enum.KeyStateActive {
+			k.State, k.RetiredAt = enum.KeyStateRetired, now
+		}
+	}
+	target.State, target.ActivatedAt = enum.KeyStateActive, now
+	return true, nil
+}
+
+func (m *memSigningKeys) Revoke(_ context.Context, kid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if k.KID == kid {
+			k.State = enum.KeyStateRevoked
+		}
+	}
+	return nil
+}
+
+// age moves every activation and retirement back by d, as if that much time had passed.
+func (m *memSigningKeys) age(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if !k.ActivatedAt.IsZero() {
+			k.ActivatedAt = k.ActivatedAt.Add(-d)
+		}
+		if !k.RetiredAt.IsZero() {
+			k.RetiredAt = k.RetiredAt.Add(-d)
+		}
+	}
+}
+
+func (m *memSigningKeys) listCalls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lists
+}
+
+// memSessionActivity records SaveActivity batches; the rest of the repository is not used.
+type memSessionActivity struct {
+	repository.SessionRepository
+	saved []*entity.Session
+}
+
+func (m *memSessionActivity) SaveActivity(_ context.Context, sessions []*entity.Session) error {
+	m.saved = append(m.saved, sessions...)
+	return nil
+}
+
+// memLogoutQueue is an in-memory repository.LogoutDeliveryRepository. ClaimDue ignores the lease
+// and returns every pending delivery; tests drive retries by calling deliverDue again.
+type memLogoutQueue struct {
+	mu       sync.Mutex
+	pending  map[uuid.UUID]*entity.LogoutDelivery
+	complete []uuid.UUID
+}
+
+func newMemLogoutQueue() *memLogoutQueue {
+	return &memLogoutQueue{pending: map[uuid.UUID]*entity.LogoutDelivery{}}
+}
+
+func (m *memLogoutQueue) Enqueue(_ context.Context, d *entity.LogoutDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *d
+	m.pending[d.ID] = &cp
+	return nil
+}
+
+func (m *memLogoutQueue) ClaimDue(_ context.Context, _ time.Time, _ time.Duration, limit int) ([]*entity.LogoutDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*entity.LogoutDelivery
+	for _, d := range m.pending {
+		if len(out) == limit {
+			break
+		}
+		cp := *d
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (m *memLogoutQueue) Reschedule(_ context.Context, id uuid.UUID, attempts int, next time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d := m.pending[id]; d != nil {
+		d.Attempts, d.NextAttemptAt, d.LastError = attempts, next, lastError
+	}
+	return nil
+}
+
+func (m *memLogoutQueue) Complete(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, id)
+	m.complete = append(m.complete, id)
+	return nil
+}
+
+// list returns a snapshot of the pending deliveries.
+func (m *memLogoutQueue) list() []entity.LogoutDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []entity.LogoutDelivery
+	for _, d := range m.pending {
+		out = append(out, *d)
+	}
+	return out
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// encryptJWE wraps payload in a compact JWE (RFC 7516) for the client's encryption key. Only the
+// algorithms in dservice.SupportedEncryptionAlgs/Encs are implemented: RSA-OAEP-256 and ECDH-ES
+// (direct key agreement) for the key, AES-CBC-HMAC-SHA2 and AES-GCM for the content.
+func encryptJWE(payload []byte, rawJWKS, alg, enc, cty string) (string, error) {
+	if enc == "" {
+		enc = dservice.DefaultEncryptionEnc
+	}
+	cekLen, err := contentKeySize(enc)
+	if err != nil {
+		return "", err
+	}
+	set, err := parseJWKS(rawJWKS)
+	if err != nil {
+		return "", err
+	}
+	jwk, err := set.encryptionKey(alg)
+	if err != nil {
+		return "", err
+	}
+	pub, err := jwk.publicKey()
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]any{"alg": alg, "enc": enc}
+	if jwk.Kid != "" {
+		header["kid"] = jwk.Kid
+	}
+	if cty != "" {
+		header["cty"] = cty
+	}
+	var cek, encryptedKey []byte
+	switch alg {
+	case "RSA-OAEP-256":
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return "", errors.New("RSA-OAEP-256 requires an RSA key")
+		}
+		cek = make([]byte, cekLen)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaPub, cek, nil); err != nil {
+			return "", fmt.Errorf("wrap cek: %w", err)
+		}
+	case "ECDH-ES":
+		epk, z, err := ecdhAgree(pub, jwk.Crv)
+		if err != nil {
+			return "", err
+		}
+		header["epk"] = epk
+		// Direct key agreement: the derived key is the CEK and the encrypted key is empty.
+		cek = concatKDF(z, enc, cekLen)
+	default:
+		return "", fmt.Errorf("unsupported jwe alg %q", alg)
+	}
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(rawHeader)
+	iv, ciphertext, tag, err := encryptContent(enc, cek, payload, []byte(protected))
+	if err != nil {
+		return "", err
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	return strings.Join([]string{protected, b64(encryptedKey), b64(iv), b64(ciphertext), b64(tag)}, "."), nil
+}
+
+// encryptionKey picks the first key usable for alg: use "enc" (or unset), a matching kty and, when
+// the key pins an alg, the same alg.
+func (s *jsonWebKeySet) encryptionKey(alg string) (*jsonWebKey, error) {
+	kty := map[string]string{"RSA-OAEP-256": "RSA", "ECDH-ES": "EC"}[alg]
+	for i := range s.Keys {
+		k := &s.Keys[i]
+		if (k.Use == "" || k.Use == "enc") && k.Kty == kty && (k.Alg == "" || k.Alg == alg) {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s encryption key in client jwks", alg)
+}
+
+// contentKeySize is the CEK length in bytes for enc.
+func contentKeySize(enc string) (int, error) {
+	switch enc {
+	case "A128GCM":
+		return 16, nil
+	case "A256GCM", "A128CBC-HS256":
+		return 32, nil
+	case "A256CBC-HS512":
+		return 64, nil
+	default:
+		return 0, fmt.Errorf("unsupported jwe enc %q", enc)
+	}
+}
+
+// ecdhAgree generates an ephemeral key on the recipient's curve and returns its public JWK and the
+// shared secret Z.
+func ecdhAgree(pub crypto.PublicKey, crv string) (map[string]string, []byte, error) {
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("ECDH-ES requires an EC key")
+	}
+	remote, err := ecPub.ECDH()
+	if err != nil {
+		return nil, nil, err
+	}
+	ephemeral, err := remote.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := ephemeral.ECDH(remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Uncompressed point: 0x04 || X || Y, each coordinate padded to the field size.
+	point := ephemeral.PublicKey().Bytes()[1:]
+	size := len(point) / 2
+	return map[string]string{
+		"kty": "EC",
+		"crv": crv,
+		"x":   base64.RawURLEncoding.EncodeToString(point[:size]),
+		"y":   base64.RawURLEncoding.EncodeToString(point[size:]),
+	}, z, nil
+}
+
+// concatKDF derives keyLen bytes from z with the Concat KDF of NIST SP 800-56A using SHA-256, as
+// profiled for ECDH-ES in RFC 7518 section 4.6.2 (AlgorithmID is enc, PartyU/V info empty).
+func concatKDF(z []byte, algID string, keyLen int) []byte {
+	var other []byte
+	other = binary.BigEndian.AppendUint32(other, uint32(len(algID)))
+	other = append(other, algID...)
+	other = binary.BigEndian.AppendUint32(other, 0) // PartyUInfo
+	other = binary.BigEndian.AppendUint32(other, 0) // PartyVInfo
+	other = binary.BigEndian.AppendUint32(other, uint32(keyLen*8))
+
+	out := make([]byte, 0, keyLen+sha256.Size)
+	for counter := uint32(1); len(out) < keyLen; counter++ {
+		h := sha256.New()
+		_ = binary.Write(h, binary.BigEndian, counter)
+		h.Write(z)
+		h.Write(other)
+		out = h.Sum(out)
+	}
+	return out[:keyLen]
+}
+
+// encryptContent encrypts plaintext under cek with enc, authenticating aad.
+func encryptContent(enc string, cek, plaintext, aad []byte) (iv, ciphertext, tag []byte, err error) {
+	if strings.HasSuffix(enc, "GCM") {
+		block, err := aes.NewCipher(cek)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		iv = make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(iv); err != nil {
+			return nil, nil, nil, err
+		}
+		sealed := gcm.Seal(nil, iv, plaintext, aad)
+		split := len(sealed) - gcm.Overhead()
+		return iv, sealed[:split], sealed[split:], nil
+	}
+
+	// AES_CBC_HMAC_SHA2 (RFC 7518 section 5.2): the first half of the CEK is the MAC key.
+	var newHash func() hash.Hash
+	switch enc {
+	case "A128CBC-HS256":
+		newHash = sha256.New
+	case "A256CBC-HS512":
+		newHash = sha512.New
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported jwe enc %q", enc)
+	}
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	iv = make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, nil, err
+	}
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte(nil), plaintext...), make([]byte, pad)...)
+	for i := len(plaintext); i < len(padded); i++ {
+		padded[i] = byte(pad)
+	}
+	ciphertext = make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	mac := hmac.New(newHash, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	_ = binary.Write(mac, binary.BigEndian, uint64(len(aad))*8)
+	return iv, ciphertext, mac.Sum(nil)[:len(macKey)], nil
+}
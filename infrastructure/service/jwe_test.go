@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// clientEncKeys is a client holding an RSA and a P-256 encryption key, registered as jwks.
+type clientEncKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	jwks string
+}
+
+func newClientEncKeys(t *testing.T) *clientEncKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	pad := func(n *big.Int) string { return b64(n.FillBytes(make([]byte, 32))) }
+	set, _ := json.Marshal(jsonWebKeySet{Keys: []jsonWebKey{
+		{Kty: "RSA", Kid: "rsa-enc", Use: "enc", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec-enc", Use: "enc", Crv: "P-256", X: pad(ecKey.X), Y: pad(ecKey.Y)},
+	}})
+	return &clientEncKeys{rsa: rsaKey, ec: ecKey, jwks: string(set)}
+}
+
+// decrypt opens a compact JWE the way a client would, returning the protected header and payload.
+func (c *clientEncKeys) decrypt(t *testing.T, compact string) (map[string]any, []byte) {
+	t.Helper()
+	parts := strings.Split(compact, ".")
+	if len(parts) != 5 {
+		t.Fatalf("not a compact JWE: %d parts", len(parts))
+	}
+	raw := make([][]byte, 5)
+	for i, p := range parts {
+		var err error
+		if raw[i], err = base64.RawURLEncoding.DecodeString(p); err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+	}
+	var header map[string]any
+	if err := json.Unmarshal(raw[0], &header); err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := header["enc"].(string)
+	cekLen, err := contentKeySize(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cek []byte
+	switch header["alg"] {
+	case "RSA-OAEP-256":
+		if cek, err = rsa.DecryptOAEP(sha256.New(), nil, c.rsa, raw[1], nil); err != nil {
+			t.Fatal(err)
+		}
+	case "ECDH-ES":
+		if len(raw[1]) != 0 {
+			t.Fatal("ECDH-ES direct agreement must have an empty encrypted key")
+		}
+		epk, _ := header["epk"].(map[string]any)
+		x, _ := base64.RawURLEncoding.DecodeString(epk["x"].(string))
+		y, _ := base64.RawURLEncoding.DecodeString(epk["y"].(string))
+		ephemeral, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, err := c.ec.ECDH()
+		if err != nil {
+			t.Fatal(err)
+		}
+		z, err := priv.ECDH(ephemeral)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cek = concatKDF(z, enc, cekLen)
+	default:
+		t.Fatalf("alg %v", header["alg"])
+	}
+	aad, iv, ciphertext, tag := []byte(parts[0]), raw[2], raw[3], raw[4]
+
+	if strings.HasSuffix(enc, "GCM") {
+		block, _ := aes.NewCipher(cek)
+		gcm, _ := cipher.NewGCM(block)
+		plain, err := gcm.Open(nil, iv, append(append([]byte(nil), ciphertext...), tag...), aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return header, plain
+	}
+	newHash := map[string]func() hash.Hash{"A128CBC-HS256": sha256.New, "A256CBC-HS512": sha512.New}[enc]
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+	mac := hmac.New(newHash, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	_ = binary.Write(mac, binary.BigEndian, uint64(len(aad))*8)
+	if !hmac.Equal(mac.Sum(nil)[:len(macKey)], tag) {
+		t.Fatal("authentication tag mismatch")
+	}
+	block, _ := aes.NewCipher(encKey)
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ciphertext)
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		t.Fatal("bad padding")
+	}
+	return header, plain[:len(plain)-n]
+}
+
+func TestEncryptJWE(t *testing.T) {
+	client := newClientEncKeys(t)
+	payload := []byte(`{"sub":"user-1"}`)
+	for _, alg := range dservice.SupportedEncryptionAlgs {
+		for _, enc := range dservice.SupportedEncryptionEncs {
+			t.Run(alg+"/"+enc, func(t *testing.T) {
+				compact, err := encryptJWE(payload, client.jwks, alg, enc, "JWT")
+				if err != nil {
+					t.Fatal(err)
+				}
+				header, plain := client.decrypt(t, compact)
+				if header["enc"] != enc || header["cty"] != "JWT" || header["kid"] == nil {
+					t.Fatalf("header %v", header)
+				}
+				if !bytes.Equal(plain, payload) {
+					t.Fatalf("payload %q", plain)
+				}
+			})
+		}
+	}
+	t.Run("enc defaults", func(t *testing.T) {
+		compact, err := encryptJWE(payload, client.jwks, "RSA-OAEP-256", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header, _ := client.decrypt(t, compact); header["enc"] != dservice.DefaultEncryptionEnc {
+			t.Fatalf("header %v", header)
+		}
+	})
+	sigOnly := `{"keys":[{"kty":"RSA","use":"sig","n":"` + base64.RawURLEncoding.EncodeToString(client.rsa.N.Bytes()) + `","e":"AQAB"}]}`
+	for name, tc := range map[string]struct{ jwks, alg, enc string }{
+		"unsupported alg":    {client.jwks, "RSA1_5", "A128GCM"},
+		"unsupported enc":    {client.jwks, "RSA-OAEP-256", "A192GCM"},
+		"no encryption key":  {sigOnly, "RSA-OAEP-256", "A128GCM"},
+		"no key for the alg": {sigOnly, "ECDH-ES", "A128GCM"},
+		"client has no jwks": {"", "RSA-OAEP-256", "A128GCM"},
+		"jwks is not json":   {"{", "RSA-OAEP-256", "A128GCM"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := encryptJWE(payload, tc.jwks, tc.alg, tc.enc, ""); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestIssueIDTokenEncrypted(t *testing.T) {
+	ctx := context.Background()
+	const issuer = "https://sso.example.com"
+	keys := NewInMemoryKeyRotation(time.Hour, "RS256", "ES256")
+	svc := NewJWTTokenService(keys, TokenValidation{Issuer: issuer})
+	client := newClientEncKeys(t)
+	claims := vo.JWTClaims{Issuer: issuer, Subject: "user-1", Audience: []string{"rp"}, Nonce: "n-1"}
+
+	idToken, err := svc.IssueIDToken(ctx, claims, time.Minute, dservice.IDTokenOptions{
+		SigningAlg: "ES256", EncryptionAlg: "ECDH-ES", EncryptionEnc: "A256GCM", ClientJWKS: client.jwks,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, nested := client.decrypt(t, idToken)
+	if header["cty"] != "JWT" {
+		t.Fatalf("header %v: a nested JWT must declare cty JWT", header)
+	}
+	// The payload is the signed ID token (sign, then encrypt).
+	parsed, err := jwt.Parse(string(nested), func(tok *jwt.Token) (any, error) {
+		pub, _, err := keys.VerificationKey(tok.Header["kid"].(string))
+		return pub, err
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := parsed.Claims.(jwt.MapClaims)
+	if mc["sub"] != "user-1" || mc["nonce"] != "n-1" || mc["iss"] != issuer {
+		t.Fatalf("claims %v", mc)
+	}
+
+	plain, err := svc.IssueIDToken(ctx, claims, time.Minute, dservice.IDTokenOptions{})
+	if err != nil || strings.Count(plain, ".") != 2 {
+		t.Fatalf("without an encryption alg the ID token is only signed: %q %v", plain, err)
+	}
+	if _, err := svc.IssueIDToken(ctx, claims, time.Minute, dservice.IDTokenOptions{EncryptionAlg: "RSA-OAEP-256"}); err == nil {
+		t.Fatal("encrypting without a client jwks must fail rather than fall back to a signed token")
+	}
+}
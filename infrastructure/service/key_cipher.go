@@ -0,0 +1,66 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// MasterKeyEnv names the environment variable holding the base64-encoded 32-byte key
+// used to encrypt signing keys at rest.
+const MasterKeyEnv = "SIGNING_KEY_MASTER_KEY"
+
+// keyCipher seals private key material with AES-256-GCM. The kid is bound as additional
+// data so a ciphertext cannot be swapped onto another key row.
+type keyCipher struct{ aead cipher.AEAD }
+
+// MasterKeyFromEnv reads and decodes the signing key master key.
+func MasterKeyFromEnv() ([]byte, error) {
+	v := os.Getenv(MasterKeyEnv)
+	if v == "" {
+		return nil, fmt.Errorf("%s not set", MasterKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		if key, err = base64.RawURLEncoding.DecodeString(v); err != nil {
+			return nil, fmt.Errorf("%s: invalid base64", MasterKeyEnv)
+		}
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s must decode to 32 bytes, got %d", MasterKeyEnv, len(key))
+	}
+	return key, nil
+}
+
+func newKeyCipher(masterKey []byte) (*keyCipher, error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyCipher{aead: aead}, nil
+}
+
+// seal returns nonce || ciphertext.
+func (c *keyCipher) seal(kid string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, []byte(kid)), nil
+}
+
+func (c *keyCipher) open(kid string, sealed []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("sealed key too short")
+	}
+	return c.aead.Open(nil, sealed[:n], sealed[n:], []byte(kid))
+}
@@ -2,62 +2,163 @@ package service
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	dservice "github.com/RanguraGIT/sso/domain/service"
 	"github.com/RanguraGIT/sso/domain/vo"
 	"github.com/golang-jwt/jwt/v5"
-
 )
 
-// jwkKey minimal JWK representation for RSA public key (modulus/exponent base64url) for JWKS endpoint.
-type jwkKey struct {
-	Kty string `json:"kty"`
-	Alg string `json:"alg"`
-	Use string `json:"use"`
-	Kid string `json:"kid"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+// DefaultSigningAlg signs access tokens and ID tokens for clients that did not register
+// id_token_signed_response_alg. It is always managed.
+const DefaultSigningAlg = dservice.DefaultSigningAlg
+
+// SupportedSigningAlgs lists the JWS algorithms signing keys can be generated for.
+var SupportedSigningAlgs = dservice.SupportedSigningAlgs
+
+// ActiveKey is the private key currently signing for one algorithm.
+type ActiveKey struct {
+	KID    string
+	Alg    string
+	Key    crypto.Signer // *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+	Method jwt.SigningMethod
 }
 
+// SigningKeySource exposes key material to the token service. Implemented by
+// InMemoryKeyRotation and PersistentKeyRotation.
+type SigningKeySource interface {
+	// SigningKey returns the active key for alg; an empty alg selects DefaultSigningAlg.
+	SigningKey(alg string) (*ActiveKey, error)
+	// VerificationKey returns the public key and its algorithm for kid if it may still verify
+	// tokens (an active key or a retired key within its overlap window).
+	VerificationKey(kid string) (crypto.PublicKey, string, error)
+}
+
+// errUnknownKID is returned by VerificationKey when kid is not (or no longer) trusted.
+var errUnknownKID = errors.New("unknown kid")
+
 type JWKS struct {
-	Keys []jwkKey `json:"keys"`
+	Keys []jsonWebKey `json:"keys"`
 }
 
 type keyRecord struct {
 	kid       string
-	key       *rsa.PrivateKey
+	alg       string
+	key       crypto.Signer
 	createdAt time.Time
 }
 
+func (rec *keyRecord) activeKey() *ActiveKey {
+	return &ActiveKey{KID: rec.kid, Alg: rec.alg, Key: rec.key, Method: jwt.GetSigningMethod(rec.alg)}
+}
+
+// publicJWK renders the record's public half: RSA (n, e), EC (crv, x, y) or OKP (crv, x).
+func (rec *keyRecord) publicJWK() jsonWebKey {
+	k := jsonWebKey{Alg: rec.alg, Use: "sig", Kid: rec.kid}
+	switch pub := rec.key.Public().(type) {
+	case *rsa.PublicKey:
+		k.Kty, k.N, k.E = "RSA", base64url(pub.N.Bytes()), base64urlFromInt(pub.E)
+	case *ecdsa.PublicKey:
+		// Coordinates are left-padded to the curve size (RFC 7518 section 6.2.1.2).
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		k.Kty, k.Crv = "EC", pub.Curve.Params().Name
+		k.X = base64url(pub.X.FillBytes(make([]byte, size)))
+		k.Y = base64url(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		k.Kty, k.Crv, k.X = "OKP", "Ed25519", base64url(pub)
+	}
+	return k
+}
+
+// newKeyRecord generates a fresh key for alg.
+func newKeyRecord(alg string) (*keyRecord, error) {
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case "RS256", "PS256":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing alg %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keyRecord{kid: randomKID(8), alg: alg, key: key, createdAt: time.Now().UTC()}, nil
+}
+
+// normalizeAlgs puts DefaultSigningAlg first, drops duplicates and rejects unsupported algorithms.
+func normalizeAlgs(algs []string) ([]string, error) {
+	out := []string{DefaultSigningAlg}
+	seen := map[string]bool{DefaultSigningAlg: true}
+	for _, a := range algs {
+		if seen[a] {
+			continue
+		}
+		if !isSupportedAlg(a) {
+			return nil, fmt.Errorf("unsupported signing alg %q", a)
+		}
+		seen[a] = true
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func isSupportedAlg(alg string) bool {
+	for _, a := range SupportedSigningAlgs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
 type InMemoryKeyRotation struct {
 	mu          sync.RWMutex
-	active      *keyRecord
+	algs        []string
+	active      map[string]*keyRecord // alg -> signing key
 	previous    []*keyRecord
 	rotateAfter time.Duration
 	lastRotate  time.Time
 }
 
-func NewInMemoryKeyRotation(ttl time.Duration) *InMemoryKeyRotation {
-	kr := &InMemoryKeyRotation{rotateAfter: ttl}
-	_ = kr.generateNew() // initial key
+// NewInMemoryKeyRotation keeps one key per algorithm in process memory. Without algs only
+// DefaultSigningAlg is managed; unsupported algorithms are ignored.
+func NewInMemoryKeyRotation(ttl time.Duration, algs ...string) *InMemoryKeyRotation {
+	normalized, err := normalizeAlgs(algs)
+	if err != nil {
+		normalized = []string{DefaultSigningAlg}
+	}
+	kr := &InMemoryKeyRotation{rotateAfter: ttl, algs: normalized, active: map[string]*keyRecord{}}
+	_ = kr.generateNew() // initial keys
 	return kr
 }
 
 func (k *InMemoryKeyRotation) generateNew() error {
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return err
-	}
-	rec := &keyRecord{kid: randomKID(8), key: priv, createdAt: time.Now().UTC()}
-	if k.active != nil {
-		k.previous = append(k.previous, k.active)
+	for _, alg := range k.algs {
+		rec, err := newKeyRecord(alg)
+		if err != nil {
+			return err
+		}
+		if prev := k.active[alg]; prev != nil {
+			k.previous = append(k.previous, prev)
+		}
+		k.active[alg] = rec
 	}
-	k.active = rec
 	k.lastRotate = time.Now().UTC()
 	return nil
 }
@@ -65,10 +166,14 @@ func (k *InMemoryKeyRotation) generateNew() error {
 func (k *InMemoryKeyRotation) CurrentKeyID() string {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
-	if k.active == nil {
-		return ""
+	if rec := k.active[DefaultSigningAlg]; rec != nil {
+		return rec.kid
 	}
-	return k.active.kid
+	return ""
+}
+
+func (k *InMemoryKeyRotation) SupportedAlgorithms() []string {
+	return append([]string(nil), k.algs...)
 }
 
 func (k *InMemoryKeyRotation) RotateIfNeeded(_ context.Context) error {
@@ -80,35 +185,47 @@ func (k *InMemoryKeyRotation) RotateIfNeeded(_ context.Context) error {
 	return k.generateNew()
 }
 
-func (k *InMemoryKeyRotation) SigningKey() (*rsa.PrivateKey, string, error) {
+func (k *InMemoryKeyRotation) SigningKey(alg string) (*ActiveKey, error) {
+	if alg == "" {
+		alg = DefaultSigningAlg
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	rec := k.active[alg]
+	if rec == nil {
+		return nil, fmt.Errorf("no active key for %s", alg)
+	}
+	return rec.activeKey(), nil
+}
+
+func (k *InMemoryKeyRotation) VerificationKey(kid string) (crypto.PublicKey, string, error) {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
-	if k.active == nil {
-		return nil, "", errors.New("no active key")
+	for _, rec := range k.active {
+		if rec.kid == kid {
+			return rec.key.Public(), rec.alg, nil
+		}
+	}
+	for _, p := range k.previous {
+		if p.kid == kid {
+			return p.key.Public(), p.alg, nil
+		}
 	}
-	return k.active.key, k.active.kid, nil
+	return nil, "", errUnknownKID
 }
 
 func (k *InMemoryKeyRotation) GetPublicJWKS(_ context.Context) (any, error) {
 	k.mu.RLock()
 	defer k.mu.RUnlock()
-	var keys []jwkKey
-	add := func(rec *keyRecord) {
-		if rec == nil {
-			return
+	var keys []jsonWebKey
+	for _, alg := range k.algs {
+		if rec := k.active[alg]; rec != nil {
+			keys = append(keys, rec.publicJWK())
 		}
-		pub := rec.key.PublicKey
-		nBytes := pub.N.Bytes()
-		e := pub.E
-		keys = append(keys, jwkKey{
-			Kty: "RSA", Alg: "RS256", Use: "sig", Kid: rec.kid,
-			N: base64url(nBytes), E: base64urlFromInt(e),
-		})
-	}
-	add(k.active)
+	}
 	// Optionally include previous keys until tokens expire (not time-limited in this simple impl)
 	for _, p := range k.previous {
-		add(p)
+		keys = append(keys, p.publicJWK())
 	}
 	return JWKS{Keys: keys}, nil
 }
@@ -119,21 +236,30 @@ func base64urlFromInt(i int) string { return base64url(intToBytes(i)) }
 
 func intToBytes(i int) []byte { return []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)} }
 
-// randomKID naive placeholder.
-func randomKID(n int) string { return time.Now().Format("20060102150405") }
+// randomKID returns n random bytes hex-encoded.
+func randomKID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000")
+	}
+	return hex.EncodeToString(b)
+}
 
-// SignJWT helper to satisfy interface (delegated in token service; here just returns error because we sign in token service).
-// For a richer design, move signing here and have token service build claims only.
-// SignJWT not used directly (token service signs); returning error keeps interface explicit.
+// SignJWT signs a minimal claim set with the active key. The token service builds richer tokens itself.
 func (k *InMemoryKeyRotation) SignJWT(cl vo.JWTClaims, ttl time.Duration) (string, error) {
-	priv, kid, err := k.SigningKey()
+	return signClaimsWith(k, cl, ttl)
+}
+
+// signClaimsWith signs cl with the source's default key; shared by the KeyRotationService implementations.
+func signClaimsWith(src SigningKeySource, cl vo.JWTClaims, ttl time.Duration) (string, error) {
+	key, err := src.SigningKey("")
 	if err != nil {
 		return "", err
 	}
 	now := time.Now().UTC()
 	exp := now.Add(ttl).Unix()
-	token := jwt.New(jwt.SigningMethodRS256)
-	token.Header["kid"] = kid
+	token := jwt.New(key.Method)
+	token.Header["kid"] = key.KID
 	token.Claims = jwt.MapClaims{
 		"sub":       cl.Subject,
 		"aud":       cl.Audience,
@@ -144,5 +270,5 @@ func (k *InMemoryKeyRotation) SignJWT(cl vo.JWTClaims, ttl time.Duration) (strin
 		"client_id": cl.ClientID,
 		"nonce":     cl.Nonce,
 	}
-	return token.SignedString(priv)
+	return token.SignedString(key.Key)
 }
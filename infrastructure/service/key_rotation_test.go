@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// TestSigningAlgorithms signs an ID token with each supported alg and verifies it with the key as
+// relying parties see it: decoded from the published JWK.
+func TestSigningAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	keys := NewInMemoryKeyRotation(time.Hour, SupportedSigningAlgs...)
+	svc := NewJWTTokenService(keys, TokenValidation{})
+	jwks, _ := keys.GetPublicJWKS(ctx)
+	published := map[string]jsonWebKey{}
+	for _, k := range jwks.(JWKS).Keys {
+		published[k.Kid] = k
+	}
+	decoded := func(t *testing.T, s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("member not base64url: %v", err)
+		}
+		return b
+	}
+
+	for _, alg := range SupportedSigningAlgs {
+		t.Run(alg, func(t *testing.T) {
+			signed, err := svc.IssueIDToken(ctx, vo.JWTClaims{Subject: "u", Issuer: "https://sso.example.com", Audience: []string{"rp"}}, time.Minute, dservice.IDTokenOptions{SigningAlg: alg})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tok, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			kid, _ := tok.Header["kid"].(string)
+			if tok.Header["alg"] != alg {
+				t.Fatalf("header alg %v, want %s", tok.Header["alg"], alg)
+			}
+			jwk, ok := published[kid]
+			if !ok || jwk.Alg != alg || jwk.Use != "sig" {
+				t.Fatalf("kid %q not published for %s: %+v", kid, alg, jwk)
+			}
+			switch alg {
+			case "RS256", "PS256":
+				if jwk.Kty != "RSA" || jwk.N == "" || jwk.E == "" || jwk.Crv != "" || jwk.X != "" {
+					t.Fatalf("RSA JWK shape: %+v", jwk)
+				}
+			case "ES256":
+				if jwk.Kty != "EC" || jwk.Crv != "P-256" || len(decoded(t, jwk.X)) != 32 || len(decoded(t, jwk.Y)) != 32 || jwk.N != "" {
+					t.Fatalf("EC JWK shape: %+v", jwk)
+				}
+			case "EdDSA":
+				if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || len(decoded(t, jwk.X)) != ed25519.PublicKeySize || jwk.Y != "" {
+					t.Fatalf("OKP JWK shape: %+v", jwk)
+				}
+			}
+			pub, err := jwk.publicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch pub.(type) {
+			case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+			default:
+				t.Fatalf("unexpected key type %T", pub)
+			}
+			if _, err := jwt.Parse(signed, func(*jwt.Token) (any, error) { return pub, nil }, jwt.WithValidMethods([]string{alg})); err != nil {
+				t.Fatalf("published key does not verify the token: %v", err)
+			}
+			if _, gotAlg, err := keys.VerificationKey(kid); err != nil || gotAlg != alg {
+				t.Fatalf("VerificationKey(%s) = %s, %v", kid, gotAlg, err)
+			}
+		})
+	}
+}
+
+func TestUnsupportedSigningAlg(t *testing.T) {
+	ctx := context.Background()
+	svc := NewJWTTokenService(NewInMemoryKeyRotation(time.Hour), TokenValidation{})
+	for _, alg := range []string{"HS256", "none", "ES256"} { // ES256 is supported but not managed here
+		if _, err := svc.IssueIDToken(ctx, vo.JWTClaims{Subject: "u"}, time.Minute, dservice.IDTokenOptions{SigningAlg: alg}); err == nil {
+			t.Fatalf("ID token signed with unmanaged alg %s", alg)
+		}
+	}
+	if _, err := normalizeAlgs([]string{"ES256", "HS256"}); err == nil {
+		t.Fatal("normalizeAlgs accepted HS256")
+	}
+	master := make([]byte, 32)
+	_, _ = rand.Read(master)
+	if _, err := NewPersistentKeyRotation(ctx, &memSigningKeys{}, master, time.Hour, time.Minute, []string{"HS256"}); err == nil {
+		t.Fatal("persistent key rotation accepted HS256")
+	}
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// PersistentKeyRotation is a KeyRotationService backed by the signing_keys table, so every replica
+// signs with and publishes the same keys and restarts keep outstanding tokens valid.
+//
+// Each configured algorithm has its own key and lifecycle: a pending key is generated and
+// published `overlap` before the active key reaches `rotateAfter`; at `rotateAfter` it is promoted
+// and the old key retired. Retired keys stay published for `overlap` so tokens signed just before
+// rotation still verify.
+type PersistentKeyRotation struct {
+	repo         repository.SigningKeyRepository
+	cipher       *keyCipher
+	rotateAfter  time.Duration
+	overlap      time.Duration
+	refreshEvery time.Duration
+	algs         []string
+
+	syncMu    sync.Mutex // serializes reload/rotation within the process
+	mu        sync.RWMutex
+	active    map[string]*persistedKey // alg -> signing key
+	published []*persistedKey
+	decrypted map[string]crypto.Signer // kid -> key, avoids re-decrypting on every reload
+	lastLoad  time.Time
+	lastMiss  time.Time // last reload caused by an unknown kid
+}
+
+// missReloadEvery bounds the reloads VerificationKey triggers for kids it does not know, so tokens
+// with made-up kids cannot turn into a query each.
+const missReloadEvery = 5 * time.Second
+
+type persistedKey struct {
+	meta *entity.SigningKey
+	rec  *keyRecord
+}
+
+// NewPersistentKeyRotation loads the shared key set, creating and activating the first key of each
+// algorithm in algs (DefaultSigningAlg is always included) if none exists.
+func NewPersistentKeyRotation(ctx context.Context, repo repository.SigningKeyRepository, masterKey []byte, rotateAfter, overlap time.Duration, algs []string) (*PersistentKeyRotation, error) {
+	c, err := newKeyCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := normalizeAlgs(algs)
+	if err != nil {
+		return nil, err
+	}
+	if rotateAfter <= 0 {
+		return nil, errors.New("rotateAfter must be positive")
+	}
+	if overlap < 0 || overlap >= rotateAfter {
+		return nil, errors.New("overlap must be non-negative and shorter than rotateAfter")
+	}
+	k := &PersistentKeyRotation{
+		repo:         repo,
+		cipher:       c,
+		rotateAfter:  rotateAfter,
+		overlap:      overlap,
+		refreshEvery: time.Minute,
+		algs:         normalized,
+		decrypted:    map[string]crypto.Signer{},
+	}
+	if err := k.sync(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *PersistentKeyRotation) CurrentKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if pk := k.active[DefaultSigningAlg]; pk != nil {
+		return pk.meta.KID
+	}
+	return ""
+}
+
+func (k *PersistentKeyRotation) SupportedAlgorithms() []string {
+	return append([]string(nil), k.algs...)
+}
+
+// RotateIfNeeded reloads the shared key set at most once per refresh interval and drives the
+// pending -> active -> retired lifecycle. Safe to call from every replica.
+func (k *PersistentKeyRotation) RotateIfNeeded(ctx context.Context) error {
+	k.mu.RLock()
+	fresh := time.Since(k.lastLoad) < k.refreshEvery
+	k.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	return k.sync(ctx)
+}
+
+// reload swaps in the stored key set without driving the lifecycle.
+func (k *PersistentKeyRotation) reload(ctx context.Context) error {
+	k.syncMu.Lock()
+	defer k.syncMu.Unlock()
+	keys, err := k.load(ctx)
+	if err != nil {
+		return err
+	}
+	return k.apply(keys)
+}
+
+func (k *PersistentKeyRotation) sync(ctx context.Context) error {
+	k.syncMu.Lock()
+	defer k.syncMu.Unlock()
+	keys, err := k.load(ctx)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, alg := range k.algs {
+		c, err := k.advance(ctx, alg, keys)
+		if err != nil {
+			return err
+		}
+		changed = changed || c
+	}
+	if changed {
+		if keys, err = k.load(ctx); err != nil {
+			return err
+		}
+	}
+	return k.apply(keys)
+}
+
+// advance drives one algorithm's lifecycle and reports whether the stored key set changed.
+func (k *PersistentKeyRotation) advance(ctx context.Context, alg string, keys []*entity.SigningKey) (bool, error) {
+	active, pending := splitKeys(keys, alg)
+	now := time.Now().UTC()
+	var err error
+	switch {
+	case active == nil:
+		// Bootstrap: activate a pending key (creating one if needed) unless another replica wins.
+		if pending == nil {
+			if pending, err = k.createPending(ctx, alg); err != nil {
+				return false, err
+			}
+		}
+		if _, err := k.repo.Promote(ctx, pending.KID, time.Time{}); err != nil {
+			return false, fmt.Errorf("activate %s signing key: %w", alg, err)
+		}
+		return true, nil
+	case now.Sub(active.ActivatedAt) >= k.rotateAfter && pending != nil:
+		if _, err := k.repo.Promote(ctx, pending.KID, now.Add(-k.rotateAfter)); err != nil {
+			return false, fmt.Errorf("rotate %s signing key: %w", alg, err)
+		}
+		return true, nil
+	case now.Sub(active.ActivatedAt) >= k.rotateAfter-k.overlap && pending == nil:
+		// Pre-publish the successor so relying parties cache it before it signs anything.
+		if _, err := k.createPending(ctx, alg); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (k *PersistentKeyRotation) load(ctx context.Context) ([]*entity.SigningKey, error) {
+	return k.repo.List(ctx, time.Now().UTC().Add(-k.overlap))
+}
+
+// splitKeys returns the newest active key and the oldest pending key for alg.
+func splitKeys(keys []*entity.SigningKey, alg string) (active, pending *entity.SigningKey) {
+	for _, key := range keys {
+		if key.Algorithm != alg {
+			continue
+		}
+		switch key.State {
+		case enum.KeyStateActive:
+			if active == nil || key.ActivatedAt.After(active.ActivatedAt) {
+				active = key
+			}
+		case enum.KeyStatePending:
+			if pending == nil {
+				pending = key
+			}
+		}
+	}
+	return active, pending
+}
+
+func (k *PersistentKeyRotation) createPending(ctx context.Context, alg string) (*entity.SigningKey, error) {
+	rec, err := newKeyRecord(alg)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(rec.key)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := k.cipher.seal(rec.kid, der)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := entity.NewSigningKey(rec.kid, rec.alg, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.repo.Create(ctx, meta); err != nil {
+		return nil, fmt.Errorf("store signing key: %w", err)
+	}
+	k.mu.Lock()
+	k.decrypted[rec.kid] = rec.key
+	k.mu.Unlock()
+	return meta, nil
+}
+
+// apply decrypts the loaded keys and swaps them in as the local view.
+func (k *PersistentKeyRotation) apply(keys []*entity.SigningKey) error {
+	now := time.Now().UTC()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	active := map[string]*persistedKey{}
+	var published []*persistedKey
+	seen := map[string]bool{}
+	for _, meta := range keys {
+		if !meta.IsPublishable(now, k.overlap) {
+			continue
+		}
+		priv, ok := k.decrypted[meta.KID]
+		if !ok {
+			der, err := k.cipher.open(meta.KID, meta.EncryptedKey)
+			if err != nil {
+				return fmt.Errorf("decrypt signing key %s: %w", meta.KID, err)
+			}
+			parsed, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return fmt.Errorf("parse signing key %s: %w", meta.KID, err)
+			}
+			if priv, ok = parsed.(crypto.Signer); !ok {
+				return fmt.Errorf("signing key %s: unsupported key type %T", meta.KID, parsed)
+			}
+		}
+		seen[meta.KID] = true
+		pk := &persistedKey{meta: meta, rec: &keyRecord{kid: meta.KID, alg: meta.Algorithm, key: priv, createdAt: meta.CreatedAt}}
+		published = append(published, pk)
+		if cur := active[meta.Algorithm]; meta.State == enum.KeyStateActive && (cur == nil || meta.ActivatedAt.After(cur.meta.ActivatedAt)) {
+			active[meta.Algorithm] = pk
+		}
+	}
+	for _, alg := range k.algs {
+		if active[alg] == nil {
+			return fmt.Errorf("no active %s signing key", alg)
+		}
+	}
+	for kid := range k.decrypted {
+		if !seen[kid] {
+			delete(k.decrypted, kid)
+		}
+	}
+	for _, pk := range published {
+		k.decrypted[pk.meta.KID] = pk.rec.key
+	}
+	k.active = active
+	k.published = published
+	k.lastLoad = now
+	return nil
+}
+
+func (k *PersistentKeyRotation) SigningKey(alg string) (*ActiveKey, error) {
+	if alg == "" {
+		alg = DefaultSigningAlg
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	pk := k.active[alg]
+	if pk == nil {
+		return nil, fmt.Errorf("no active key for %s", alg)
+	}
+	return pk.rec.activeKey(), nil
+}
+
+// VerificationKey trusts the active key and retired keys still inside the overlap window.
+// Pending keys are published but never sign, so they are not accepted. An unknown kid may belong
+// to a key a peer promoted since the last reload, so the key set is reloaded once (at most every
+// missReloadEvery) before the kid is rejected.
+func (k *PersistentKeyRotation) VerificationKey(kid string) (crypto.PublicKey, string, error) {
+	if pub, alg, ok := k.verificationKey(kid); ok {
+		return pub, alg, nil
+	}
+	k.mu.Lock()
+	due := time.Since(k.lastMiss) >= missReloadEvery
+	if due {
+		k.lastMiss = time.Now()
+	}
+	k.mu.Unlock()
+	if !due {
+		return nil, "", errUnknownKID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := k.reload(ctx); err != nil {
+		return nil, "", fmt.Errorf("%w: reload: %v", errUnknownKID, err)
+	}
+	if pub, alg, ok := k.verificationKey(kid); ok {
+		return pub, alg, nil
+	}
+	return nil, "", errUnknownKID
+}
+
+func (k *PersistentKeyRotation) verificationKey(kid string) (crypto.PublicKey, string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, pk := range k.published {
+		if pk.meta.KID == kid && pk.meta.State != enum.KeyStatePending {
+			return pk.rec.key.Public(), pk.rec.alg, true
+		}
+	}
+	return nil, "", false
+}
+
+func (k *PersistentKeyRotation) GetPublicJWKS(_ context.Context) (any, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]jsonWebKey, 0, len(k.published))
+	for _, pk := range k.published {
+		keys = append(keys, pk.rec.publicJWK())
+	}
+	return JWKS{Keys: keys}, nil
+}
+
+func (k *PersistentKeyRotation) SignJWT(cl vo.JWTClaims, ttl time.Duration) (string, error) {
+	return signClaimsWith(k, cl, ttl)
+}
+
+// RevokeKey withdraws a key immediately (e.g. on compromise) and reloads the key set.
+// Revoking an active key forces a new one of that algorithm to be generated and activated.
+func (k *PersistentKeyRotation) RevokeKey(ctx context.Context, kid string) error {
+	if err := k.repo.Revoke(ctx, kid); err != nil {
+		return err
+	}
+	return k.sync(ctx)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// TestPersistentKeyRotationAcrossReplicas runs two key services on one repository: a rotates, b
+// must verify a's tokens under the new kid without a JWKS request, then drop the retired key.
+func TestPersistentKeyRotationAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	repo := &memSigningKeys{}
+	master := make([]byte, 32)
+	_, _ = rand.Read(master)
+	a, err := NewPersistentKeyRotation(ctx, repo, master, time.Hour, 10*time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPersistentKeyRotation(ctx, repo, master, time.Hour, 10*time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := a.CurrentKeyID()
+	if first == "" || b.CurrentKeyID() != first {
+		t.Fatalf("replicas disagree on the first key: %q vs %q", first, b.CurrentKeyID())
+	}
+	const issuer = "https://sso.example.com"
+	signer := NewJWTTokenService(a, TokenValidation{Issuer: issuer})
+	verifier := NewJWTTokenService(b, TokenValidation{Issuer: issuer})
+	issue := func() string {
+		now := time.Now()
+		res, err := signer.IssueAccessToken(ctx, vo.JWTClaims{Subject: "u", Issuer: issuer, IssuedAt: now.Unix(), ExpiresAt: now.Add(3 * time.Hour).Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.AccessToken
+	}
+	before := issue()
+
+	// a pre-publishes a successor, then promotes it once the active key reaches rotateAfter.
+	a.refreshEvery = 0
+	repo.age(55 * time.Minute)
+	if err := a.RotateIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	repo.age(10 * time.Minute)
+	if err := a.RotateIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	second := a.CurrentKeyID()
+	if second == first {
+		t.Fatal("a did not rotate")
+	}
+
+	lists := repo.listCalls()
+	if _, err := verifier.ValidateAccessToken(ctx, issue()); err != nil {
+		t.Fatalf("b rejected a token under the promoted kid: %v", err)
+	}
+	if repo.listCalls() != lists+1 {
+		t.Fatalf("unknown kid caused %d reloads, want 1", repo.listCalls()-lists)
+	}
+	if b.CurrentKeyID() != second {
+		t.Fatalf("b signs with %q after reloading, want %q", b.CurrentKeyID(), second)
+	}
+	if _, err := verifier.ValidateAccessToken(ctx, before); err != nil {
+		t.Fatalf("token signed before rotation must verify during the overlap: %v", err)
+	}
+	if _, _, err := b.VerificationKey("made-up"); !errors.Is(err, errUnknownKID) {
+		t.Fatalf("made-up kid: %v", err)
+	}
+	if repo.listCalls() != lists+1 {
+		t.Fatal("unknown kids must not reload more than once per missReloadEvery")
+	}
+
+	// Past the overlap the periodic reload drops the retired key.
+	repo.age(20 * time.Minute)
+	b.refreshEvery = 0
+	if err := b.RotateIfNeeded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.ValidateAccessToken(ctx, before); !errors.Is(err, dservice.ErrTokenUnknownKey) {
+		t.Fatalf("token under the dropped key: err = %v, want unknown key", err)
+	}
+	jwks, _ := b.GetPublicJWKS(ctx)
+	for _, k := range jwks.(JWKS).Keys {
+		if k.Kid == first {
+			t.Fatal("retired key still published after the overlap")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"sort"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+)
+
+// DefaultScopes are the OpenID Connect scopes every deployment knows (OIDC Core section 5.4).
+var DefaultScopes = []dservice.ScopeDefinition{
+	{Name: "openid", Description: "Sign you in with your account"},
+	{Name: "profile", Description: "Read your basic profile (name, picture, locale)", Claims: []string{"name", "given_name", "family_name", "picture", "locale", "zoneinfo", "updated_at"}},
+	{Name: "email", Description: "Read your email address", Claims: []string{"email", "email_verified"}},
+	{Name: "address", Description: "Read your postal address", Claims: []string{"address"}},
+	{Name: "phone", Description: "Read your phone number", Claims: []string{"phone_number", "phone_number_verified"}},
+	{Name: "offline_access", Description: "Keep access while you are signed out"},
+}
+
+// ScopeRegistry is an immutable in-memory dservice.ScopeRegistry.
+type ScopeRegistry struct {
+	defs map[string]dservice.ScopeDefinition
+}
+
+// NewScopeRegistry returns DefaultScopes plus extra; an extra entry with a default name replaces
+// its description, and its claims when it lists any.
+func NewScopeRegistry(extra ...dservice.ScopeDefinition) *ScopeRegistry {
+	r := &ScopeRegistry{defs: map[string]dservice.ScopeDefinition{}}
+	for _, d := range DefaultScopes {
+		r.defs[d.Name] = d
+	}
+	for _, d := range extra {
+		if prev, ok := r.defs[d.Name]; ok && len(d.Claims) == 0 {
+			d.Claims = prev.Claims
+		}
+		r.defs[d.Name] = d
+	}
+	return r
+}
+
+func (r *ScopeRegistry) Lookup(name string) (dservice.ScopeDefinition, bool) {
+	d, ok := r.defs[name]
+	return d, ok
+}
+
+func (r *ScopeRegistry) All() []dservice.ScopeDefinition {
+	out := make([]dservice.ScopeDefinition, 0, len(r.defs))
+	for _, d := range r.defs {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
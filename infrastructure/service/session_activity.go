@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// sessionActivityGranularity is how stale a stored LastSeenAt may get before use is written again.
+// Together with the Run interval it bounds how far stored expiry trails actual use, which is why
+// idle timeouts shorter than a few minutes are rejected by the config.
+const sessionActivityGranularity = time.Minute
+
+// SessionActivityService implements dservice.SessionActivity with batched writes: Seen queues a
+// session at most once per sessionActivityGranularity and Run flushes the queue, so a burst of
+// requests on one session costs a single UPDATE.
+//
+// Queued activity lives only in memory. A crash loses whatever was seen since the last flush, so
+// stored expiry can trail real use by the granularity plus the Run interval; a session idle for
+// almost its whole timeout may then end that much early. This is accepted as long as the window
+// stays small against the idle timeout, which the config's minimum enforces. The absolute
+// lifetime is never affected: it is fixed at login.
+type SessionActivityService struct {
+	sessions repository.SessionRepository
+	policy   vo.SessionPolicy
+
+	mu      sync.Mutex
+	pending map[uuid.UUID]*entity.Session
+}
+
+func NewSessionActivityService(sessions repository.SessionRepository, policy vo.SessionPolicy) *SessionActivityService {
+	return &SessionActivityService{sessions: sessions, policy: policy, pending: map[uuid.UUID]*entity.Session{}}
+}
+
+// Seen implements dservice.SessionActivity.
+func (s *SessionActivityService) Seen(sess *entity.Session, now time.Time) {
+	stored := sess.LastSeenAt
+	sess.Touch(now, s.policy)
+	if now.Sub(stored) < sessionActivityGranularity {
+		return
+	}
+	cp := *sess
+	cp.ClientIDs = nil // only LastSeenAt and ExpiresAt are written
+	s.mu.Lock()
+	s.pending[sess.ID] = &cp
+	s.mu.Unlock()
+}
+
+// Run writes queued activity every interval until ctx is done. Call Flush once requests have
+// stopped to write what is left.
+func (s *SessionActivityService) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes the queued activity now.
+func (s *SessionActivityService) Flush(ctx context.Context) {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	batch := make([]*entity.Session, 0, len(s.pending))
+	for _, sess := range s.pending {
+		batch = append(batch, sess)
+	}
+	s.pending = map[uuid.UUID]*entity.Session{}
+	s.mu.Unlock()
+	if err := s.sessions.SaveActivity(ctx, batch); err != nil {
+		// Dropped rather than retried: the next use of each session queues it again.
+		log.Printf("session activity: save %d sessions: %v", len(batch), err)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+func TestSessionActivityService(t *testing.T) {
+	ctx := context.Background()
+	policy := vo.SessionPolicy{IdleTimeout: 30 * time.Minute, MaxLifetime: 2 * time.Hour}
+	repo := &memSessionActivity{}
+	svc := NewSessionActivityService(repo, policy)
+	sess, _ := entity.NewSession(uuid.New(), 2*time.Hour, "", "")
+	sess.ExpiresAt = policy.ExpiresAt(sess.CreatedAt, sess.CreatedAt)
+	created := sess.CreatedAt
+
+	svc.Seen(sess, created.Add(10*time.Second))
+	svc.Flush(ctx)
+	if len(repo.saved) != 0 {
+		t.Fatal("use within the write granularity must not be queued")
+	}
+	if !sess.ExpiresAt.Equal(created.Add(10*time.Second + 30*time.Minute)) {
+		t.Fatalf("expiry not slid in memory: %s", sess.ExpiresAt.Sub(created))
+	}
+
+	svc.Seen(sess, created.Add(20*time.Minute))
+	if len(repo.saved) != 0 {
+		t.Fatal("activity must be buffered until the next flush")
+	}
+	svc.Flush(ctx)
+	if len(repo.saved) != 1 || !repo.saved[0].LastSeenAt.Equal(created.Add(20*time.Minute)) || !repo.saved[0].ExpiresAt.Equal(created.Add(50*time.Minute)) {
+		t.Fatalf("flushed %+v", repo.saved)
+	}
+
+	svc.Seen(sess, created.Add(110*time.Minute))
+	svc.Flush(ctx)
+	if got := repo.saved[len(repo.saved)-1].ExpiresAt; !got.Equal(created.Add(2 * time.Hour)) {
+		t.Fatalf("expiry slid past the absolute lifetime: %s", got.Sub(created))
+	}
+}
@@ -4,47 +4,45 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/RanguraGIT/sso/domain/repository"
 	dservice "github.com/RanguraGIT/sso/domain/service"
 	"github.com/RanguraGIT/sso/domain/vo"
 )
 
+// TokenValidation configures access token validation. Empty Issuer / Audience skip that check;
+// with an issuer derived per request, callers compare the returned Issuer with the request's.
+type TokenValidation struct {
+	Issuer    string
+	Audience  []string // token must carry at least one of these in aud
+	ClockSkew time.Duration
+	Denylist  repository.RevokedAccessTokenRepository // when set, revoked jti values are rejected
+}
+
+// accessTokenType is the JOSE typ of access tokens (RFC 9068 section 2.1). It keeps ID tokens,
+// logout tokens and other JWTs signed with the same keys from being accepted as access tokens.
+const accessTokenType = "at+jwt"
+
 type JWTTokenService struct {
-	keys *InMemoryKeyRotation
+	keys       SigningKeySource
+	validation TokenValidation
 }
 
-func NewJWTTokenService(keys *InMemoryKeyRotation) dservice.TokenService {
-	return &JWTTokenService{keys: keys}
+func NewJWTTokenService(keys SigningKeySource, validation TokenValidation) dservice.TokenService {
+	return &JWTTokenService{keys: keys, validation: validation}
 }
 
 func (s *JWTTokenService) IssueAccessAndRefresh(_ context.Context, claims vo.JWTClaims, refreshTTL time.Duration) (*dservice.TokenIssueResult, error) {
-	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
-	if ttl <= 0 {
-		return nil, errors.New("claims already expired")
-	}
-	priv, kid, err := s.keys.SigningKey()
-	if err != nil {
-		return nil, err
-	}
-	token := jwt.New(jwt.SigningMethodRS256)
-	token.Header["kid"] = kid
-	token.Claims = jwt.MapClaims{
-		"sub":       claims.Subject,
-		"aud":       claims.Audience,
-		"iss":       claims.Issuer,
-		"iat":       claims.IssuedAt,
-		"exp":       claims.ExpiresAt,
-		"scope":     claims.Scope,
-		"client_id": claims.ClientID,
-		"nonce":     claims.Nonce,
-	}
-	signed, err := token.SignedString(priv)
+	signed, err := s.signAccessToken(&claims)
 	if err != nil {
 		return nil, err
 	}
@@ -67,61 +65,201 @@ func (s *JWTTokenService) IssueAccessAndRefresh(_ context.Context, claims vo.JWT
 	}, nil
 }
 
-// IssueIDToken creates an ID Token (subset of claims; can diverge from access token claims if needed).
-func (s *JWTTokenService) IssueIDToken(ctx context.Context, claims vo.JWTClaims, ttl time.Duration) (string, error) {
-	priv, kid, err := s.keys.SigningKey()
+// IssueAccessToken signs an access token without minting a refresh token (client_credentials grant).
+func (s *JWTTokenService) IssueAccessToken(_ context.Context, claims vo.JWTClaims) (*dservice.TokenIssueResult, error) {
+	signed, err := s.signAccessToken(&claims)
+	if err != nil {
+		return nil, err
+	}
+	return &dservice.TokenIssueResult{
+		AccessToken:     signed,
+		AccessExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		Claims:          claims,
+	}, nil
+}
+
+// signAccessToken signs claims with the default key, assigning a fresh jti when claims.ID is empty.
+// The nonce belongs in the ID token only and is left out.
+func (s *JWTTokenService) signAccessToken(claims *vo.JWTClaims) (string, error) {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return "", errors.New("claims already expired")
+	}
+	if claims.ID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		claims.ID = base64.RawURLEncoding.EncodeToString(id)
+	}
+	return s.sign("", accessTokenType, jwt.MapClaims{
+		"sub":       claims.Subject,
+		"aud":       claims.Audience,
+		"iss":       claims.Issuer,
+		"iat":       claims.IssuedAt,
+		"nbf":       claims.IssuedAt,
+		"exp":       claims.ExpiresAt,
+		"scope":     claims.Scope,
+		"client_id": claims.ClientID,
+		"jti":       claims.ID,
+	})
+}
+
+// SignClaims signs an arbitrary claim set with the active key for alg and sets typ in the JOSE
+// header (e.g. "token-introspection+jwt" for RFC 9701 responses).
+func (s *JWTTokenService) SignClaims(_ context.Context, typ string, claims map[string]any, alg string) (string, error) {
+	return s.sign(alg, typ, jwt.MapClaims(claims))
+}
+
+// sign signs claims with the active key for alg (empty selects the default algorithm), setting
+// the typ header when typ is non-empty.
+func (s *JWTTokenService) sign(alg, typ string, claims jwt.MapClaims) (string, error) {
+	key, err := s.keys.SigningKey(alg)
 	if err != nil {
 		return "", err
 	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.KID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(key.Key)
+}
+
+// IssueIDToken creates an ID Token (subset of claims; can diverge from access token claims if needed),
+// signed with opts.SigningAlg when the client registered one and encrypted when it registered an
+// encryption alg.
+func (s *JWTTokenService) IssueIDToken(ctx context.Context, claims vo.JWTClaims, ttl time.Duration, opts dservice.IDTokenOptions) (string, error) {
+	alg := opts.SigningAlg
+	if alg == "" {
+		alg = DefaultSigningAlg
+	}
 	now := time.Now().UTC()
 	exp := now.Add(ttl).Unix()
-	tok := jwt.New(jwt.SigningMethodRS256)
-	tok.Header["kid"] = kid
-	mc := jwt.MapClaims{
-		"iss": claims.Issuer,
-		"sub": claims.Subject,
-		"aud": claims.Audience,
-		"iat": now.Unix(),
-		"exp": exp,
+	mc := jwt.MapClaims{}
+	for k, v := range claims.Extra {
+		mc[k] = v
 	}
+	mc["iss"] = claims.Issuer
+	mc["sub"] = claims.Subject
+	mc["aud"] = claims.Audience
+	mc["iat"] = now.Unix()
+	mc["exp"] = exp
 	if claims.Nonce != "" {
 		mc["nonce"] = claims.Nonce
 	}
+	if claims.AuthTime != 0 {
+		mc["auth_time"] = claims.AuthTime
+	}
+	if claims.SessionID != "" {
+		mc["sid"] = claims.SessionID
+	}
 	// at_hash (OPTIONAL) - include when access token present; we hash later if raw access token supplied via context.
 	if rawAccess, ok := ctx.Value("raw_access_token").(string); ok && rawAccess != "" {
-		sum := sha256.Sum256([]byte(rawAccess))
-		left := sum[:16]
-		mc["at_hash"] = base64.RawURLEncoding.EncodeToString(left)
+		mc["at_hash"] = leftHalfHash(alg, rawAccess)
+	}
+	signed, err := s.sign(alg, "", mc)
+	if err != nil || opts.EncryptionAlg == "" {
+		return signed, err
 	}
-	tok.Claims = mc
-	return tok.SignedString(priv)
+	// Nested JWT (OIDC Core section 10.2): sign first, then encrypt to the client.
+	return encryptJWE([]byte(signed), opts.ClientJWKS, opts.EncryptionAlg, opts.EncryptionEnc, "JWT")
 }
 
-func (s *JWTTokenService) ValidateAccessToken(_ context.Context, tokenString string) (*vo.JWTClaims, error) {
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		// Ensure expected signing method
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, errors.New("unexpected_signing_method")
-		}
-		kid, _ := t.Header["kid"].(string)
-		// Lookup key from active or previous keys
-		priv, _, err := s.keys.SigningKey() // simplistic: only active key; improvement: expose public set & match kid
-		if err != nil {
-			return nil, err
-		}
-		pub := &priv.PublicKey
-		_ = kid // placeholder until multiple key lookup implemented
-		return pub, nil
+// leftHalfHash computes at_hash / c_hash: the left half of the digest of value, using the hash
+// that belongs to the JWS alg (SHA-512 for EdDSA over Ed25519, SHA-256 for the *256 algorithms).
+func leftHalfHash(alg, value string) string {
+	var sum []byte
+	if alg == "EdDSA" {
+		d := sha512.Sum512([]byte(value))
+		sum = d[:]
+	} else {
+		d := sha256.Sum256([]byte(value))
+		sum = d[:]
 	}
-	parsed, err := jwt.Parse(tokenString, keyFunc)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// ValidateIDTokenHint verifies the signature of an ID token we issued and its iss, ignoring exp and
+// nbf. Other JWTs signed with the same keys (access tokens, logout tokens) are refused by their typ
+// header. The audience is returned for the caller to match against the requesting client.
+func (s *JWTTokenService) ValidateIDTokenHint(_ context.Context, tokenString string) (*vo.JWTClaims, error) {
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey, jwt.WithValidMethods(SupportedSigningAlgs), jwt.WithoutClaimsValidation())
 	if err != nil {
-		return nil, err
+		return nil, classifyJWTError(err)
+	}
+	if !parsed.Valid {
+		return nil, dservice.ErrTokenSignatureInvalid
+	}
+	if typ, ok := parsed.Header["typ"]; ok && !strings.EqualFold(fmt.Sprint(typ), "JWT") {
+		return nil, fmt.Errorf("%w: typ %v is not an ID token", dservice.ErrTokenClaimsInvalid, typ)
 	}
-	claims, ok := parsed.Claims.(jwt.MapClaims)
-	if !ok || !parsed.Valid {
-		return nil, errors.New("invalid_token")
+	iss, _ := claims["iss"].(string)
+	if s.validation.Issuer != "" && iss != s.validation.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", dservice.ErrTokenClaimsInvalid)
 	}
-	vc := vo.JWTClaims{}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, fmt.Errorf("%w: missing sub", dservice.ErrTokenClaimsInvalid)
+	}
+	aud, _ := claims.GetAudience()
+	vc := vo.JWTClaims{Subject: sub, Issuer: iss, Audience: aud}
+	vc.SessionID, _ = claims["sid"].(string)
+	if at, ok := claims["auth_time"].(float64); ok {
+		vc.AuthTime = int64(at)
+	}
+	return &vc, nil
+}
+
+// verificationKey resolves the public key named by the kid header (active or a still-trusted
+// retired key), refusing any alg other than the one the key was generated for.
+func (s *JWTTokenService) verificationKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		return nil, dservice.ErrTokenUnknownKey
+	}
+	pub, alg, err := s.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, dservice.ErrTokenUnknownKey
+	}
+	if t.Method.Alg() != alg {
+		return nil, dservice.ErrTokenSignatureInvalid
+	}
+	return pub, nil
+}
+
+// ValidateAccessToken verifies the signature with the key named by the kid header (active or a
+// still-trusted retired key), then exp / nbf (with clock skew), iss and aud. The typ header must be
+// at+jwt and ID-token or logout-token claims (nonce, events) are refused, so no other JWT we sign
+// passes as an access token. Failures wrap the dservice.ErrToken* sentinels. With a Denylist
+// configured, revoked tokens fail with ErrTokenRevoked.
+func (s *JWTTokenService) ValidateAccessToken(ctx context.Context, tokenString string) (*vo.JWTClaims, error) {
+	opts := []jwt.ParserOption{jwt.WithValidMethods(SupportedSigningAlgs), jwt.WithExpirationRequired(), jwt.WithLeeway(s.validation.ClockSkew)}
+	if s.validation.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(s.validation.Issuer))
+	}
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey, opts...)
+	if err != nil {
+		return nil, classifyJWTError(err)
+	}
+	if !parsed.Valid {
+		return nil, dservice.ErrTokenSignatureInvalid
+	}
+	if !isAccessTokenType(parsed.Header["typ"]) {
+		return nil, fmt.Errorf("%w: typ %v is not %s", dservice.ErrTokenClaimsInvalid, parsed.Header["typ"], accessTokenType)
+	}
+	for _, c := range []string{"nonce", "events"} {
+		if _, ok := claims[c]; ok {
+			return nil, fmt.Errorf("%w: access token carries %s", dservice.ErrTokenClaimsInvalid, c)
+		}
+	}
+	aud, _ := claims.GetAudience()
+	if len(s.validation.Audience) > 0 && !audienceAccepted(aud, s.validation.Audience) {
+		return nil, fmt.Errorf("%w: audience mismatch", dservice.ErrTokenClaimsInvalid)
+	}
+	vc := vo.JWTClaims{Audience: aud}
 	if sub, ok := claims["sub"].(string); ok {
 		vc.Subject = sub
 	}
@@ -134,5 +272,53 @@ func (s *JWTTokenService) ValidateAccessToken(_ context.Context, tokenString str
 	if cid, ok := claims["client_id"].(string); ok {
 		vc.ClientID = cid
 	}
+	if jti, ok := claims["jti"].(string); ok {
+		vc.ID = jti
+	}
+	if s.validation.Denylist != nil && vc.ID != "" {
+		revoked, err := s.validation.Denylist.IsRevoked(ctx, vc.ID)
+		if err != nil {
+			return nil, fmt.Errorf("check revocation: %w", err)
+		}
+		if revoked {
+			return nil, dservice.ErrTokenRevoked
+		}
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		vc.IssuedAt = iat.Unix()
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		vc.ExpiresAt = exp.Unix()
+	}
 	return &vc, nil
 }
+
+// isAccessTokenType reports whether a typ header value names an RFC 9068 access token. The media
+// type form application/at+jwt is accepted too and the comparison ignores case (RFC 7515 4.1.9).
+func isAccessTokenType(typ any) bool {
+	s, _ := typ.(string)
+	if len(s) > len("application/") && strings.EqualFold(s[:len("application/")], "application/") {
+		s = s[len("application/"):]
+	}
+	return strings.EqualFold(s, accessTokenType)
+}
+
+// classifyJWTError maps jwt library errors onto the domain validation sentinels.
+func classifyJWTError(err error) error {
+	switch {
+	case errors.Is(err, dservice.ErrTokenUnknownKey):
+		return fmt.Errorf("%w: %v", dservice.ErrTokenUnknownKey, err)
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: %v", dservice.ErrTokenMalformed, err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: %v", dservice.ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return fmt.Errorf("%w: %v", dservice.ErrTokenNotYetValid, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, dservice.ErrTokenSignatureInvalid):
+		return fmt.Errorf("%w: %v", dservice.ErrTokenSignatureInvalid, err)
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer), errors.Is(err, jwt.ErrTokenInvalidAudience), errors.Is(err, jwt.ErrTokenRequiredClaimMissing), errors.Is(err, jwt.ErrTokenInvalidClaims):
+		return fmt.Errorf("%w: %v", dservice.ErrTokenClaimsInvalid, err)
+	default:
+		return fmt.Errorf("%w: %v", dservice.ErrTokenMalformed, err)
+	}
+}
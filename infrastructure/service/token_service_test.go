@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	"github.com/RanguraGIT/sso/domain/vo"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestValidateAccessToken(t *testing.T) {
+	ctx := context.Background()
+	const issuer = "https://sso.example.com"
+	keys := NewInMemoryKeyRotation(0, "RS256", "ES256")
+	foreign := NewInMemoryKeyRotation(time.Hour)
+	deny := testfake.NewDenylist()
+	svc := NewJWTTokenService(keys, TokenValidation{Issuer: issuer, Audience: []string{"api"}, ClockSkew: 30 * time.Second, Denylist: deny})
+
+	now := time.Now()
+	claims := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": issuer, "sub": "user-1", "aud": []string{"api"}, "client_id": "rp", "scope": "openid",
+			"jti": randomKID(8), "iat": now.Unix(), "nbf": now.Unix(), "exp": now.Add(time.Minute).Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+	// signTyp signs c with the src key for alg and typ header typ; a non-nil kid replaces the
+	// key's own ("" omits it).
+	signTyp := func(src SigningKeySource, alg, typ string, kid *string, c jwt.MapClaims) string {
+		key, err := src.SigningKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok := jwt.NewWithClaims(key.Method, c)
+		if typ == "" {
+			delete(tok.Header, "typ")
+		} else {
+			tok.Header["typ"] = typ
+		}
+		if kid == nil {
+			tok.Header["kid"] = key.KID
+		} else if *kid != "" {
+			tok.Header["kid"] = *kid
+		}
+		signed, err := tok.SignedString(key.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	sign := func(src SigningKeySource, alg string, kid *string, c jwt.MapClaims) string {
+		return signTyp(src, alg, "at+jwt", kid, c)
+	}
+	noKID := ""
+	esKey, _ := keys.SigningKey("ES256")
+
+	retiredToken := sign(keys, "", nil, claims(nil))
+	if err := keys.RotateIfNeeded(ctx); err != nil { // ttl 0: every call rotates
+		t.Fatal(err)
+	}
+	revoked := claims(nil)
+	_ = deny.Add(ctx, revoked["jti"].(string), now.Add(time.Minute))
+
+	cases := []struct {
+		name  string
+		token string
+		err   error // nil expects a valid token
+	}{
+		{"active RS256 key", sign(keys, "", nil, claims(nil)), nil},
+		{"active ES256 key", sign(keys, "ES256", nil, claims(nil)), nil},
+		{"retired key still trusted", retiredToken, nil},
+		{"unknown kid", sign(foreign, "", nil, claims(nil)), dservice.ErrTokenUnknownKey},
+		{"missing kid", sign(keys, "", &noKID, claims(nil)), dservice.ErrTokenUnknownKey},
+		{"alg differs from the kid's key", sign(keys, "", &esKey.KID, claims(nil)), dservice.ErrTokenSignatureInvalid},
+		{"tampered signature", sign(keys, "", nil, claims(nil)) + "x", dservice.ErrTokenSignatureInvalid},
+		{"malformed", "not.a.jwt", dservice.ErrTokenMalformed},
+		{"foreign issuer", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })), dservice.ErrTokenClaimsInvalid},
+		{"foreign audience", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["aud"] = []string{"other"} })), dservice.ErrTokenClaimsInvalid},
+		{"no exp", sign(keys, "", nil, claims(func(c jwt.MapClaims) { delete(c, "exp") })), dservice.ErrTokenClaimsInvalid},
+		{"expired", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() })), dservice.ErrTokenExpired},
+		{"expired within clock skew", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["exp"] = now.Add(-10 * time.Second).Unix() })), nil},
+		{"not yet valid", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["nbf"] = now.Add(time.Minute).Unix() })), dservice.ErrTokenNotYetValid},
+		{"nbf within clock skew", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["nbf"] = now.Add(10 * time.Second).Unix() })), nil},
+		{"denylisted jti", sign(keys, "", nil, revoked), dservice.ErrTokenRevoked},
+		{"media type typ", signTyp(keys, "", "application/AT+JWT", nil, claims(nil)), nil},
+		{"typ JWT", signTyp(keys, "", "JWT", nil, claims(nil)), dservice.ErrTokenClaimsInvalid},
+		{"no typ", signTyp(keys, "", "", nil, claims(nil)), dservice.ErrTokenClaimsInvalid},
+		{"logout token typ", signTyp(keys, "", "logout+jwt", nil, claims(nil)), dservice.ErrTokenClaimsInvalid},
+		{"nonce claim", sign(keys, "", nil, claims(func(c jwt.MapClaims) { c["nonce"] = "n-1" })), dservice.ErrTokenClaimsInvalid},
+		{"events claim", sign(keys, "", nil, claims(func(c jwt.MapClaims) {
+			c["events"] = map[string]any{"http://schemas.openid.net/event/backchannel-logout": map[string]any{}}
+		})), dservice.ErrTokenClaimsInvalid},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := svc.ValidateAccessToken(ctx, tc.token)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("err = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != "user-1" || got.ClientID != "rp" || got.Issuer != issuer || got.ID == "" {
+				t.Fatalf("claims not carried over: %+v", got)
+			}
+		})
+	}
+}
+
+// TestAccessTokenType checks that issued access tokens carry typ at+jwt and no nonce, and that
+// neither an ID token nor an access token is accepted in place of the other.
+func TestAccessTokenType(t *testing.T) {
+	ctx := context.Background()
+	const issuer = "https://sso.example.com"
+	svc := NewJWTTokenService(NewInMemoryKeyRotation(time.Hour), TokenValidation{Issuer: issuer})
+	claims := vo.JWTClaims{
+		Subject: "user-1", Issuer: issuer, Audience: []string{"rp"}, ClientID: "rp", Nonce: "n-1",
+		IssuedAt: time.Now().Unix(), ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}
+	issued, err := svc.IssueAccessToken(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(issued.AccessToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["typ"] != "at+jwt" {
+		t.Fatalf("typ = %v", parsed.Header["typ"])
+	}
+	if _, ok := parsed.Claims.(jwt.MapClaims)["nonce"]; ok {
+		t.Fatal("access token carries nonce")
+	}
+	if _, err := svc.ValidateAccessToken(ctx, issued.AccessToken); err != nil {
+		t.Fatalf("issued access token rejected: %v", err)
+	}
+	idToken, err := svc.IssueIDToken(ctx, claims, time.Minute, dservice.IDTokenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateAccessToken(ctx, idToken); !errors.Is(err, dservice.ErrTokenClaimsInvalid) {
+		t.Fatalf("ID token as access token: %v", err)
+	}
+	if _, err := svc.ValidateIDTokenHint(ctx, idToken); err != nil {
+		t.Fatalf("ID token as hint: %v", err)
+	}
+	if _, err := svc.ValidateIDTokenHint(ctx, issued.AccessToken); !errors.Is(err, dservice.ErrTokenClaimsInvalid) {
+		t.Fatalf("access token as id_token_hint: %v", err)
+	}
+}
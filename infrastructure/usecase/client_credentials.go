@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+type ClientCredentials struct {
+	clients      repository.ClientRepository
+	tokens       repository.TokenRepository
+	tokenService dservice.TokenService
+	scopes       dservice.ScopeRegistry
+}
+
+func NewClientCredentials(clients repository.ClientRepository, tokens repository.TokenRepository, tokenService dservice.TokenService, scopes dservice.ScopeRegistry) *ClientCredentials {
+	return &ClientCredentials{clients: clients, tokens: tokens, tokenService: tokenService, scopes: scopes}
+}
+
+func (uc *ClientCredentials) Execute(ctx context.Context, in du.ClientCredentialsInput) (*du.ClientCredentialsOutput, error) {
+	c, err := uc.clients.GetByClientID(ctx, in.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, du.ErrInvalidClient
+	}
+	// Only confidential clients may act on their own behalf.
+	if !c.Confidential {
+		return nil, du.ErrUnauthorizedClient
+	}
+	// The token has no user, so user scopes are invalid_scope when requested and left out of the
+	// default grant.
+	if !userScopes(uc.scopes, enum.ParseScopeString(in.Scope)).IsEmpty() {
+		return nil, du.ErrInvalidScope
+	}
+	// Same policy as /authorize: unknown scopes are invalid_scope, unregistered ones are dropped.
+	scope, err := narrowScopes(uc.scopes, c, in.Scope)
+	if err != nil {
+		return nil, err
+	}
+	if scope = scope.Without(userScopes(uc.scopes, scope)); scope.IsEmpty() {
+		return nil, du.ErrInvalidScope
+	}
+	now := time.Now().UTC()
+	claims := vo.JWTClaims{
+		Subject:   c.ClientID,
+		Audience:  in.Audience,
+		Issuer:    in.Issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(in.AccessTTL).Unix(),
+		Scope:     scope.String(),
+		ClientID:  c.ClientID,
+	}
+	res, err := uc.tokenService.IssueAccessToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := entity.NewClientToken(c.ID, scope.Slice(), res.AccessToken, res.AccessExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	meta.ClientPublicID = c.ClientID
+	meta.AccessJTI = res.Claims.ID
+	if err := uc.tokens.Store(ctx, meta); err != nil {
+		return nil, err
+	}
+	return &du.ClientCredentialsOutput{
+		AccessToken: res.AccessToken,
+		ExpiresIn:   int64(res.AccessExpiresAt.Sub(now).Seconds()),
+		Scope:       claims.Scope,
+		TokenType:   "Bearer",
+	}, nil
+}
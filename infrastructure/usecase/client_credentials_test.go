@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestClientCredentialsGrant(t *testing.T) {
+	ctx := context.Background()
+	registry := iservice.NewScopeRegistry(
+		dservice.ScopeDefinition{Name: "reports:read"},
+		dservice.ScopeDefinition{Name: "reports:write"},
+		dservice.ScopeDefinition{Name: "billing"},
+	)
+	svc := newTestClient("svc", true, "reports:read", "reports:write", "openid", "profile", "offline_access")
+	users := newTestClient("users", true, "openid", "email")
+	spa := newTestClient("spa", false, "reports:read")
+	tokens := newMemTokens(nil)
+	tokenService := newTestTokenService(nil)
+	uc := NewClientCredentials(testfake.NewClients(svc, spa, users), tokens, tokenService, registry)
+	in := func(clientID, scope string) du.ClientCredentialsInput {
+		return du.ClientCredentialsInput{ClientID: clientID, Scope: scope, Audience: []string{"api"}, Issuer: testIssuer, AccessTTL: 5 * time.Minute}
+	}
+
+	t.Run("token for the client itself", func(t *testing.T) {
+		out, err := uc.Execute(ctx, in("svc", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.TokenType != "Bearer" || out.Scope != "reports:read reports:write" || out.ExpiresIn <= 0 {
+			t.Fatalf("unexpected output %+v", out)
+		}
+		claims, err := tokenService.ValidateAccessToken(ctx, out.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.Subject != "svc" || claims.ClientID != "svc" {
+			t.Fatalf("sub=%q client_id=%q, want the client", claims.Subject, claims.ClientID)
+		}
+		meta, _ := tokens.GetByAccessJTI(ctx, claims.ID)
+		if meta == nil {
+			t.Fatal("token not recorded")
+		}
+		if meta.UserID != uuid.Nil || meta.RefreshTokenID != "" || meta.ClientPublicID != "svc" {
+			t.Fatalf("client token must have no user and no refresh token: %+v", meta)
+		}
+	})
+
+	cases := []struct {
+		name, client, scope string
+		want                string
+		err                 error
+	}{
+		{name: "subset", client: "svc", scope: "reports:read", want: "reports:read"},
+		{name: "unregistered scope dropped", client: "svc", scope: "reports:read billing", want: "reports:read"},
+		{name: "only unregistered scopes", client: "svc", scope: "billing", err: du.ErrInvalidScope},
+		{name: "unknown scope", client: "svc", scope: "reports:read admin", err: du.ErrInvalidScope},
+		{name: "openid", client: "svc", scope: "reports:read openid", err: du.ErrInvalidScope},
+		{name: "user claim scope", client: "svc", scope: "profile", err: du.ErrInvalidScope},
+		{name: "offline_access", client: "svc", scope: "reports:read offline_access", err: du.ErrInvalidScope},
+		{name: "only user scopes registered", client: "users", err: du.ErrInvalidScope},
+		{name: "public client", client: "spa", err: du.ErrUnauthorizedClient},
+		{name: "unknown client", client: "ghost", err: du.ErrInvalidClient},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := uc.Execute(ctx, in(tc.client, tc.scope))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("err = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Scope != tc.want {
+				t.Fatalf("scope = %q, want %q", out.Scope, tc.want)
+			}
+		})
+	}
+}
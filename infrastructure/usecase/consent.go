@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+)
+
+type Consents struct {
+	consents repository.ConsentRepository
+	clients  repository.ClientRepository
+	tokens   repository.TokenRepository
+	uow      repository.UnitOfWork
+}
+
+func NewConsents(consents repository.ConsentRepository, clients repository.ClientRepository, tokens repository.TokenRepository, uow repository.UnitOfWork) *Consents {
+	return &Consents{consents: consents, clients: clients, tokens: tokens, uow: uow}
+}
+
+func (uc *Consents) Check(ctx context.Context, userID uuid.UUID, clientID string, scopes []string) (*du.ConsentCheck, error) {
+	client, err := uc.clients.GetByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, du.ErrInvalidClient
+	}
+	out := &du.ConsentCheck{ClientName: client.Name}
+	if client.FirstParty {
+		return out, nil
+	}
+	out.Missing, err = missingConsent(ctx, uc.consents, userID, clientID, scopes)
+	return out, err
+}
+
+func (uc *Consents) Grant(ctx context.Context, userID uuid.UUID, clientID string, scopes []string) error {
+	client, err := uc.clients.GetByClientID(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return du.ErrInvalidClient
+	}
+	return uc.uow.Do(ctx, func(ctx context.Context) error {
+		c, err := uc.consents.Get(ctx, userID, clientID)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			if c, err = entity.NewConsent(userID, clientID, scopes); err != nil {
+				return err
+			}
+		} else {
+			c.Grant(scopes)
+		}
+		return uc.consents.Save(ctx, c)
+	})
+}
+
+func (uc *Consents) List(ctx context.Context, userID uuid.UUID) ([]du.ConsentGrant, error) {
+	consents, err := uc.consents.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]du.ConsentGrant, 0, len(consents))
+	for _, c := range consents {
+		g := du.ConsentGrant{ClientID: c.ClientID, ClientName: c.ClientID, Scopes: c.Scopes, GrantedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt}
+		if client, err := uc.clients.GetByClientID(ctx, c.ClientID); err != nil {
+			return nil, err
+		} else if client != nil {
+			g.ClientName = client.Name
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// Withdraw removes the consent and the client's tokens together so a failure leaves both in place.
+func (uc *Consents) Withdraw(ctx context.Context, userID uuid.UUID, clientID string) error {
+	return uc.uow.Do(ctx, func(ctx context.Context) error {
+		deleted, err := uc.consents.Delete(ctx, userID, clientID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return du.ErrConsentNotFound
+		}
+		return uc.tokens.RevokeByUserClient(ctx, userID, clientID)
+	})
+}
+
+// missingConsent returns the scopes the user still has to approve for the client.
+func missingConsent(ctx context.Context, consents repository.ConsentRepository, userID uuid.UUID, clientID string, scopes []string) ([]string, error) {
+	c, err := consents.Get(ctx, userID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = &entity.Consent{}
+	}
+	return c.Missing(scopes), nil
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestConsents(t *testing.T) {
+	ctx := context.Background()
+	rp := newTestClient("rp", true, "openid", "profile", "email")
+	rp.Name = "Relying Party"
+	internal := newTestClient("internal", true, "openid", "profile")
+	internal.FirstParty = true
+	other := newTestClient("other", true, "openid")
+	clients := testfake.NewClients(rp, internal, other)
+	deny := testfake.NewDenylist()
+	tokens := newMemTokens(deny)
+	tokenService := newTestTokenService(deny)
+	issue := NewIssueToken(clients, tokens, tokenService, nil, nil)
+	uc := NewConsents(newMemConsents(), clients, tokens, inlineUOW{})
+	alice, bob := uuid.New(), uuid.New()
+
+	missing := func(t *testing.T, user uuid.UUID, client string, scopes ...string) []string {
+		t.Helper()
+		check, err := uc.Check(ctx, user, client, scopes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return check.Missing
+	}
+
+	t.Run("grants accumulate per user and client", func(t *testing.T) {
+		if got := missing(t, alice, "rp", "openid", "profile"); !slices.Equal(got, []string{"openid", "profile"}) {
+			t.Fatalf("before consent: %v", got)
+		}
+		if err := uc.Grant(ctx, alice, "rp", []string{"openid"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := uc.Grant(ctx, alice, "rp", []string{"profile"}); err != nil {
+			t.Fatal(err)
+		}
+		if got := missing(t, alice, "rp", "openid", "profile", "email"); !slices.Equal(got, []string{"email"}) {
+			t.Fatalf("after consent: %v", got)
+		}
+		if got := missing(t, bob, "rp", "openid"); !slices.Equal(got, []string{"openid"}) {
+			t.Fatalf("another user inherited the consent: %v", got)
+		}
+	})
+	t.Run("client name for the consent screen", func(t *testing.T) {
+		check, err := uc.Check(ctx, bob, "rp", []string{"openid"})
+		if err != nil || check.ClientName != "Relying Party" {
+			t.Fatalf("check %+v err %v", check, err)
+		}
+	})
+	t.Run("first-party clients skip consent", func(t *testing.T) {
+		if got := missing(t, alice, "internal", "openid", "profile"); len(got) != 0 {
+			t.Fatalf("missing %v", got)
+		}
+	})
+	t.Run("unknown client", func(t *testing.T) {
+		if _, err := uc.Check(ctx, alice, "ghost", []string{"openid"}); !errors.Is(err, du.ErrInvalidClient) {
+			t.Fatalf("check: %v", err)
+		}
+		if err := uc.Grant(ctx, alice, "ghost", []string{"openid"}); !errors.Is(err, du.ErrInvalidClient) {
+			t.Fatalf("grant: %v", err)
+		}
+	})
+	t.Run("list", func(t *testing.T) {
+		grants, err := uc.List(ctx, alice)
+		if err != nil || len(grants) != 1 || grants[0].ClientID != "rp" || grants[0].ClientName != "Relying Party" || !slices.Equal(grants[0].Scopes, []string{"openid", "profile"}) {
+			t.Fatalf("grants %+v err %v", grants, err)
+		}
+	})
+	t.Run("withdraw revokes the client's tokens", func(t *testing.T) {
+		grant := func(user uuid.UUID, client string) *du.IssueTokenOutput {
+			out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user, ClientID: client, Scope: "openid", Audience: []string{client}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return out
+		}
+		revoked, kept, keptOtherUser := grant(alice, "rp"), grant(alice, "other"), grant(bob, "rp")
+		if err := uc.Withdraw(ctx, alice, "rp"); err != nil {
+			t.Fatal(err)
+		}
+		if got := missing(t, alice, "rp", "openid"); !slices.Equal(got, []string{"openid"}) {
+			t.Fatalf("consent survived: %v", got)
+		}
+		if meta, _ := tokens.GetByRefreshID(ctx, refreshID(revoked.RefreshToken)); !meta.Revoked {
+			t.Fatal("refresh token of the withdrawn client survived")
+		}
+		if _, err := tokenService.ValidateAccessToken(ctx, revoked.AccessToken); err == nil {
+			t.Fatal("access token of the withdrawn client is still accepted")
+		}
+		for _, out := range []*du.IssueTokenOutput{kept, keptOtherUser} {
+			if meta, _ := tokens.GetByRefreshID(ctx, refreshID(out.RefreshToken)); meta.Revoked {
+				t.Fatal("a token outside the withdrawn consent was revoked")
+			}
+		}
+		if err := uc.Withdraw(ctx, alice, "rp"); !errors.Is(err, du.ErrConsentNotFound) {
+			t.Fatalf("second withdrawal: %v", err)
+		}
+	})
+}
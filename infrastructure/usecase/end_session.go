@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+)
+
+type EndSession struct {
+	clients  repository.ClientRepository
+	sessions repository.SessionRepository
+	tokens   repository.TokenRepository
+	logout   dservice.BackchannelLogout // optional
+}
+
+func NewEndSession(clients repository.ClientRepository, sessions repository.SessionRepository, tokens repository.TokenRepository, logout dservice.BackchannelLogout) *EndSession {
+	return &EndSession{clients: clients, sessions: sessions, tokens: tokens, logout: logout}
+}
+
+// Validate requires a known client whenever a post_logout_redirect_uri is given, and the URI to
+// match one of its registered values exactly.
+func (uc *EndSession) Validate(ctx context.Context, in du.EndSessionInput) error {
+	if in.PostLogoutRedirectURI == "" {
+		return nil
+	}
+	if in.ClientID == "" {
+		return du.ErrInvalidClient
+	}
+	cli, err := uc.clients.GetByClientID(ctx, in.ClientID)
+	if err != nil {
+		return err
+	}
+	if cli == nil {
+		return du.ErrInvalidClient
+	}
+	if !slices.Contains(cli.PostLogoutRedirectURIs, in.PostLogoutRedirectURI) {
+		return du.ErrInvalidRedirectURI
+	}
+	return nil
+}
+
+func (uc *EndSession) Execute(ctx context.Context, in du.EndSessionInput) (*du.EndSessionOutput, error) {
+	out := &du.EndSessionOutput{}
+	if err := uc.Validate(ctx, in); err != nil {
+		return nil, err
+	}
+	if in.SessionID == uuid.Nil {
+		return out, nil
+	}
+	sess, err := uc.sessions.Get(ctx, in.SessionID)
+	if err != nil || sess == nil {
+		return out, err
+	}
+	if in.RevokeTokens {
+		if err := uc.tokens.RevokeBySession(ctx, sess.ID); err != nil {
+			return nil, err
+		}
+	}
+	if sess.Revoked {
+		return out, nil // relying parties were told when it ended
+	}
+	if err := uc.sessions.Revoke(ctx, sess.ID); err != nil {
+		return nil, err
+	}
+	if uc.logout != nil {
+		if err := uc.logout.SessionEnded(ctx, sess, in.Issuer); err != nil {
+			return nil, fmt.Errorf("queue back-channel logout: %w", err)
+		}
+	}
+	if out.FrontchannelLogoutURIs, err = uc.frontchannelURIs(ctx, sess, in.Issuer); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// frontchannelURIs returns the frontchannel_logout_uri of each client in the session, adding iss
+// and sid when the client registered frontchannel_logout_session_required (section 2).
+func (uc *EndSession) frontchannelURIs(ctx context.Context, sess *entity.Session, issuer string) ([]string, error) {
+	var uris []string
+	for _, id := range sess.ClientIDs {
+		cli, err := uc.clients.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if cli == nil || cli.FrontchannelLogoutURI == "" {
+			continue
+		}
+		u, err := url.Parse(cli.FrontchannelLogoutURI)
+		if err != nil {
+			continue // rejected when the client was configured
+		}
+		if cli.FrontchannelLogoutSessionRequired {
+			q := u.Query()
+			q.Set("iss", issuer)
+			q.Set("sid", sess.ID.String())
+			u.RawQuery = q.Encode()
+		}
+		uris = append(uris, u.String())
+	}
+	return uris, nil
+}
@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestEndSessionValidate(t *testing.T) {
+	rp := newTestClient("rp", true, "openid")
+	rp.PostLogoutRedirectURIs = []string{"https://rp.example.com/bye"}
+	uc := NewEndSession(testfake.NewClients(rp), testfake.NewSessions(), newMemTokens(nil), nil)
+	cases := []struct {
+		name, client, uri string
+		err               error
+	}{
+		{name: "no redirect"},
+		{name: "registered redirect", client: "rp", uri: "https://rp.example.com/bye"},
+		{name: "redirect without a client", uri: "https://rp.example.com/bye", err: du.ErrInvalidClient},
+		{name: "unknown client", client: "ghost", uri: "https://rp.example.com/bye", err: du.ErrInvalidClient},
+		{name: "unregistered redirect", client: "rp", uri: "https://evil.example.com/bye", err: du.ErrInvalidRedirectURI},
+		{name: "prefix of a registered redirect", client: "rp", uri: "https://rp.example.com/by", err: du.ErrInvalidRedirectURI},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := uc.Validate(context.Background(), du.EndSessionInput{ClientID: tc.client, PostLogoutRedirectURI: tc.uri})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestEndSessionExecute(t *testing.T) {
+	ctx := context.Background()
+	rp := newTestClient("rp", true, "openid")
+	clients := testfake.NewClients(rp)
+	user := uuid.New()
+	// setup returns a live session with one token granted in it and one granted elsewhere.
+	setup := func(t *testing.T) (*EndSession, *entity.Session, *memTokens) {
+		t.Helper()
+		sess, err := entity.NewSession(user, time.Hour, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens := newMemTokens(testfake.NewDenylist())
+		for _, sid := range []uuid.UUID{sess.ID, uuid.New()} {
+			tok, _ := entity.NewToken(user, rp.ID, []string{"openid"}, "access", uuid.NewString(), time.Now().Add(time.Minute), time.Now().Add(time.Hour))
+			tok.SessionID = sid
+			_ = tokens.Store(ctx, tok)
+		}
+		return NewEndSession(clients, testfake.NewSessions(sess), tokens, nil), sess, tokens
+	}
+	revoked := func(tokens *memTokens) (inSession, elsewhere bool) {
+		return tokens.tokens[0].Revoked, tokens.tokens[1].Revoked
+	}
+
+	t.Run("revokes the session", func(t *testing.T) {
+		uc, sess, tokens := setup(t)
+		if _, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID}); err != nil {
+			t.Fatal(err)
+		}
+		if !sess.Revoked {
+			t.Fatal("session still live")
+		}
+		if in, _ := revoked(tokens); in {
+			t.Fatal("tokens revoked without RevokeTokens")
+		}
+	})
+	t.Run("revokes the session's tokens on request", func(t *testing.T) {
+		uc, sess, tokens := setup(t)
+		if _, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, RevokeTokens: true}); err != nil {
+			t.Fatal(err)
+		}
+		if in, elsewhere := revoked(tokens); !in || elsewhere {
+			t.Fatalf("revoked in session %v, elsewhere %v", in, elsewhere)
+		}
+	})
+	t.Run("ended session still has its tokens revoked", func(t *testing.T) {
+		uc, sess, tokens := setup(t)
+		sess.Revoked = true
+		if _, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, RevokeTokens: true}); err != nil {
+			t.Fatal(err)
+		}
+		if in, _ := revoked(tokens); !in {
+			t.Fatal("tokens of an already ended session survived")
+		}
+	})
+	t.Run("no session", func(t *testing.T) {
+		uc, _, _ := setup(t)
+		for _, sid := range []uuid.UUID{uuid.Nil, uuid.New()} {
+			if out, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sid}); err != nil || out == nil {
+				t.Fatalf("out %v err %v", out, err)
+			}
+		}
+	})
+	t.Run("invalid redirect ends nothing", func(t *testing.T) {
+		uc, sess, _ := setup(t)
+		if _, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, ClientID: "rp", PostLogoutRedirectURI: "https://evil.example.com"}); !errors.Is(err, du.ErrInvalidRedirectURI) {
+			t.Fatalf("err = %v", err)
+		}
+		if sess.Revoked {
+			t.Fatal("session revoked despite the invalid request")
+		}
+	})
+}
+
+func TestEndSessionNotifiesOnce(t *testing.T) {
+	ctx := context.Background()
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	logout := &fakeBackchannel{}
+	uc := NewEndSession(testfake.NewClients(), testfake.NewSessions(sess), newMemTokens(nil), logout)
+	for range 2 {
+		if _, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, Issuer: testIssuer}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(logout.ended) != 1 || logout.ended[0] != sess.ID {
+		t.Fatalf("notified %v, want the session once", logout.ended)
+	}
+}
+
+func TestEndSessionFrontchannelURIs(t *testing.T) {
+	ctx := context.Background()
+	withSID := newTestClient("rp", true, "openid")
+	withSID.FrontchannelLogoutURI = "https://rp.example.com/fc?app=1"
+	withSID.FrontchannelLogoutSessionRequired = true
+	plain := newTestClient("legacy", true, "openid")
+	plain.FrontchannelLogoutURI = "https://legacy.example.com/logout"
+	silent := newTestClient("silent", true, "openid")
+	sess, _ := entity.NewSession(uuid.New(), time.Hour, "", "")
+	sess.ClientIDs = []uuid.UUID{withSID.ID, plain.ID, silent.ID}
+	uc := NewEndSession(testfake.NewClients(withSID, plain, silent), testfake.NewSessions(sess), newMemTokens(nil), nil)
+
+	out, err := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, Issuer: testIssuer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://rp.example.com/fc?app=1&iss=https%3A%2F%2Fsso.example.com&sid=" + sess.ID.String(),
+		"https://legacy.example.com/logout",
+	}
+	if !slices.Equal(out.FrontchannelLogoutURIs, want) {
+		t.Fatalf("uris %v, want %v", out.FrontchannelLogoutURIs, want)
+	}
+	if out, _ := uc.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, Issuer: testIssuer}); len(out.FrontchannelLogoutURIs) != 0 {
+		t.Fatalf("an ended session notified again: %v", out.FrontchannelLogoutURIs)
+	}
+}
@@ -0,0 +1,214 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+const testIssuer = "https://sso.example.com"
+
+// memTokens is an in-memory repository.TokenRepository. Revocations that denylist access tokens
+// write to deny.
+type memTokens struct {
+	mu     sync.Mutex
+	tokens []*entity.Token
+	deny   *testfake.Denylist
+}
+
+func newMemTokens(deny *testfake.Denylist) *memTokens { return &memTokens{deny: deny} }
+
+func (m *memTokens) Store(_ context.Context, t *entity.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *t
+	m.tokens = append(m.tokens, &cp)
+	return nil
+}
+
+func (m *memTokens) find(match func(*entity.Token) bool) *entity.Token {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if match(t) {
+			cp := *t
+			return &cp
+		}
+	}
+	return nil
+}
+
+func (m *memTokens) GetByRefreshID(_ context.Context, refreshTokenID string) (*entity.Token, error) {
+	return m.find(func(t *entity.Token) bool { return t.RefreshTokenID != "" && t.RefreshTokenID == refreshTokenID }), nil
+}
+
+func (m *memTokens) GetByAccessJTI(_ context.Context, jti string) (*entity.Token, error) {
+	return m.find(func(t *entity.Token) bool { return t.AccessJTI == jti }), nil
+}
+
+// revokeWhere revokes the matching tokens and denylists their unexpired access tokens.
+func (m *memTokens) revokeWhere(match func(*entity.Token) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if !match(t) {
+			continue
+		}
+		t.Revoked = true
+		if m.deny != nil && t.AccessJTI != "" && time.Now().Before(t.ExpiresAt) {
+			_ = m.deny.Add(context.Background(), t.AccessJTI, t.ExpiresAt)
+		}
+	}
+}
+
+func (m *memTokens) RevokeByRefreshID(_ context.Context, refreshTokenID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.RefreshTokenID == refreshTokenID {
+			t.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (m *memTokens) ListFamily(_ context.Context, familyID uuid.UUID) ([]*entity.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*entity.Token
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memTokens) RevokeChain(ctx context.Context, refreshTokenID string) error {
+	t, _ := m.GetByRefreshID(ctx, refreshTokenID)
+	if t == nil {
+		return nil
+	}
+	m.revokeWhere(func(o *entity.Token) bool { return o.FamilyID == t.FamilyID })
+	return nil
+}
+
+func (m *memTokens) MarkRotated(_ context.Context, refreshTokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.RefreshTokenID == refreshTokenID && !t.Rotated && !t.Revoked {
+			t.Rotated = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memTokens) RevokeByUserClient(_ context.Context, userID uuid.UUID, clientID string) error {
+	m.revokeWhere(func(t *entity.Token) bool { return t.UserID == userID && t.ClientPublicID == clientID })
+	return nil
+}
+
+func (m *memTokens) RevokeBySession(_ context.Context, sessionID uuid.UUID) error {
+	m.revokeWhere(func(t *entity.Token) bool { return t.SessionID == sessionID })
+	return nil
+}
+
+// newTestTokenService signs with fresh in-memory keys and checks iss against testIssuer.
+func newTestTokenService(deny *testfake.Denylist) dservice.TokenService {
+	v := iservice.TokenValidation{Issuer: testIssuer, ClockSkew: 30 * time.Second}
+	if deny != nil {
+		v.Denylist = deny
+	}
+	return iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), v)
+}
+
+func newTestClient(clientID string, confidential bool, scopes ...string) *entity.Client {
+	secret := ""
+	if confidential {
+		secret = "hashed"
+	}
+	c, err := entity.NewClient(clientID, clientID, secret, []string{"https://rp.example.com/cb"}, scopes, confidential, !confidential)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// inlineUOW runs the unit of work directly, after before when set (to interleave a concurrent
+// writer).
+type inlineUOW struct{ before func(ctx context.Context) }
+
+func (u inlineUOW) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if u.before != nil {
+		u.before(ctx)
+	}
+	return fn(ctx)
+}
+
+// memConsents is an in-memory repository.ConsentRepository.
+type memConsents struct {
+	mu       sync.Mutex
+	consents map[string]entity.Consent // userID/clientID -> consent
+}
+
+func newMemConsents() *memConsents { return &memConsents{consents: map[string]entity.Consent{}} }
+
+func consentKey(userID uuid.UUID, clientID string) string { return userID.String() + "/" + clientID }
+
+func (m *memConsents) Get(_ context.Context, userID uuid.UUID, clientID string) (*entity.Consent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.consents[consentKey(userID, clientID)]
+	if !ok {
+		return nil, nil
+	}
+	c.Scopes = append([]string(nil), c.Scopes...)
+	return &c, nil
+}
+
+func (m *memConsents) Save(_ context.Context, c *entity.Consent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.consents[consentKey(c.UserID, c.ClientID)] = *c
+	return nil
+}
+
+func (m *memConsents) ListByUser(_ context.Context, userID uuid.UUID) ([]*entity.Consent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*entity.Consent
+	for _, c := range m.consents {
+		if c.UserID == userID {
+			cp := c
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (m *memConsents) Delete(_ context.Context, userID uuid.UUID, clientID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := consentKey(userID, clientID)
+	_, ok := m.consents[key]
+	delete(m.consents, key)
+	return ok, nil
+}
+
+// fakeBackchannel records the sessions it was told ended.
+type fakeBackchannel struct{ ended []uuid.UUID }
+
+func (f *fakeBackchannel) SessionEnded(_ context.Context, sess *entity.Session, _ string) error {
+	f.ended = append(f.ended, sess.ID)
+	return nil
+}
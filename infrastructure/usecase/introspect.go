@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+)
+
+type Introspect struct {
+	tokens       repository.TokenRepository
+	tokenService dservice.TokenService
+}
+
+func NewIntrospect(tokens repository.TokenRepository, tokenService dservice.TokenService) *Introspect {
+	return &Introspect{tokens: tokens, tokenService: tokenService}
+}
+
+// Execute tries the token as an access token (signature, expiry and issuer via TokenService, then
+// the stored record by jti) and as a refresh token (stored record by hash), in hint order.
+// Anything unknown, revoked, rotated or expired is reported as inactive rather than as an error.
+func (uc *Introspect) Execute(ctx context.Context, in du.IntrospectInput) (*du.IntrospectOutput, error) {
+	access := func(ctx context.Context, raw string) (*du.IntrospectOutput, error) {
+		return uc.accessToken(ctx, raw, in.Issuer)
+	}
+	lookups := []func(context.Context, string) (*du.IntrospectOutput, error){access, uc.refreshToken}
+	if in.TokenTypeHint == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		out, err := lookup(ctx, in.Token)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil {
+			return out, nil
+		}
+	}
+	return &du.IntrospectOutput{Active: false}, nil
+}
+
+// accessToken returns nil when raw is not a valid access token from issuer or its record is
+// missing / revoked.
+func (uc *Introspect) accessToken(ctx context.Context, raw, issuer string) (*du.IntrospectOutput, error) {
+	claims, err := uc.tokenService.ValidateAccessToken(ctx, raw)
+	if err != nil || claims.ID == "" || (issuer != "" && claims.Issuer != issuer) {
+		return nil, nil
+	}
+	meta, err := uc.tokens.GetByAccessJTI(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil || meta.Revoked || meta.ClientPublicID != claims.ClientID {
+		return nil, nil
+	}
+	return &du.IntrospectOutput{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Subject:   claims.Subject,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		TokenType: "Bearer",
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+	}, nil
+}
+
+// refreshToken returns nil when raw does not name an active refresh token.
+func (uc *Introspect) refreshToken(ctx context.Context, raw string) (*du.IntrospectOutput, error) {
+	// Refresh tokens are stored as the SHA-256 hex of the opaque value.
+	hash := sha256.Sum256([]byte(raw))
+	meta, err := uc.tokens.GetByRefreshID(ctx, hex.EncodeToString(hash[:]))
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil || meta.Revoked || meta.Rotated || meta.IsRefreshExpired(time.Now().UTC()) {
+		return nil, nil
+	}
+	out := &du.IntrospectOutput{
+		Active:    true,
+		Scope:     joinScopes(meta.Scopes),
+		ClientID:  meta.ClientPublicID,
+		ExpiresAt: meta.RefreshExpires.Unix(),
+		IssuedAt:  meta.CreatedAt.Unix(),
+		TokenType: "refresh_token",
+	}
+	if meta.UserID != uuid.Nil {
+		out.Subject = meta.UserID.String()
+	}
+	return out, nil
+}
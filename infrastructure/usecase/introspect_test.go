@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	deny := testfake.NewDenylist()
+	tokenService := iservice.NewJWTTokenService(keys, iservice.TokenValidation{Issuer: testIssuer, Denylist: deny})
+	tokens := newMemTokens(deny)
+	rp := newTestClient("rp", true, "openid", "profile")
+	userID := uuid.New()
+
+	// grant issues an access/refresh pair to client and records it; edit adjusts the record.
+	grant := func(client string, edit func(*entity.Token)) (access, refresh string) {
+		now := time.Now()
+		res, err := tokenService.IssueAccessAndRefresh(ctx, vo.JWTClaims{Subject: userID.String(), Issuer: testIssuer, Audience: []string{client}, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), Scope: "openid profile", ClientID: client}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, err := entity.NewToken(userID, rp.ID, []string{"openid", "profile"}, res.AccessToken, res.RefreshTokenID, res.AccessExpiresAt, res.RefreshExpiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta.ClientPublicID = "rp"
+		meta.AccessJTI = res.Claims.ID
+		if edit != nil {
+			edit(meta)
+		}
+		_ = tokens.Store(ctx, meta)
+		return res.AccessToken, res.RefreshToken
+	}
+	liveAccess, liveRefresh := grant("rp", nil)
+	revokedAccess, revokedRefresh := grant("rp", func(m *entity.Token) { m.Revoked = true })
+	_, rotatedRefresh := grant("rp", func(m *entity.Token) { m.Rotated = true })
+	_, expiredRefresh := grant("rp", func(m *entity.Token) { m.RefreshExpires = time.Now().Add(-time.Second) })
+	denylistedAccess, _ := grant("rp", nil)
+	if claims, err := tokenService.ValidateAccessToken(ctx, denylistedAccess); err == nil {
+		_ = deny.Add(ctx, claims.ID, time.Now().Add(time.Minute))
+	}
+	// The record says rp, the token claims another client: it was not issued as presented.
+	foreignAccess, _ := grant("other", nil)
+
+	key, _ := keys.SigningKey("")
+	expired := jwt.NewWithClaims(key.Method, jwt.MapClaims{"iss": testIssuer, "sub": userID.String(), "client_id": "rp", "jti": "expired-jti", "exp": time.Now().Add(-time.Hour).Unix()})
+	expired.Header["kid"] = key.KID
+	expiredAccess, _ := expired.SignedString(key.Key)
+	_ = tokens.Store(ctx, &entity.Token{ID: uuid.New(), ClientID: rp.ID, ClientPublicID: "rp", AccessJTI: "expired-jti", AccessJWT: expiredAccess})
+
+	uc := NewIntrospect(tokens, tokenService)
+	cases := []struct {
+		name, token, hint string
+		issuer            string
+		wantType          string // empty expects {"active":false}
+	}{
+		{name: "access token", token: liveAccess, wantType: "Bearer"},
+		{name: "access token with refresh hint", token: liveAccess, hint: "refresh_token", wantType: "Bearer"},
+		{name: "refresh token", token: liveRefresh, wantType: "refresh_token"},
+		{name: "refresh token with access hint", token: liveRefresh, hint: "access_token", wantType: "refresh_token"},
+		{name: "revoked access token", token: revokedAccess},
+		{name: "denylisted access token", token: denylistedAccess},
+		{name: "expired access token", token: expiredAccess},
+		{name: "access token of another client", token: foreignAccess},
+		{name: "access token from another issuer", token: liveAccess, issuer: "https://other.example.com"},
+		{name: "revoked refresh token", token: revokedRefresh},
+		{name: "rotated refresh token", token: rotatedRefresh},
+		{name: "expired refresh token", token: expiredRefresh},
+		{name: "unknown token", token: "not-a-token"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			issuer := tc.issuer
+			if issuer == "" {
+				issuer = testIssuer
+			}
+			out, err := uc.Execute(ctx, du.IntrospectInput{Token: tc.token, TokenTypeHint: tc.hint, Issuer: issuer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantType == "" {
+				if !reflect.DeepEqual(*out, du.IntrospectOutput{}) {
+					t.Fatalf("want inactive with no other members, got %+v", out)
+				}
+				return
+			}
+			if !out.Active || out.TokenType != tc.wantType || out.ClientID != "rp" || out.Subject != userID.String() || out.Scope != "openid profile" {
+				t.Fatalf("unexpected %+v", out)
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
 	"github.com/RanguraGIT/sso/domain/repository"
 	dservice "github.com/RanguraGIT/sso/domain/service"
 	du "github.com/RanguraGIT/sso/domain/usecase"
@@ -16,10 +19,14 @@ type IssueToken struct {
 	clients      repository.ClientRepository
 	tokens       repository.TokenRepository
 	tokenService dservice.TokenService
+	users        repository.UserRepository
+	scopes       dservice.ScopeRegistry
 }
 
-func NewIssueToken(clients repository.ClientRepository, tokens repository.TokenRepository, tokenService dservice.TokenService) *IssueToken {
-	return &IssueToken{clients: clients, tokens: tokens, tokenService: tokenService}
+// NewIssueToken builds the authorization_code token issuer. users and scopes supply the profile
+// claims released into ID tokens; either may be nil to issue ID tokens without them.
+func NewIssueToken(clients repository.ClientRepository, tokens repository.TokenRepository, tokenService dservice.TokenService, users repository.UserRepository, scopes dservice.ScopeRegistry) *IssueToken {
+	return &IssueToken{clients: clients, tokens: tokens, tokenService: tokenService, users: users, scopes: scopes}
 }
 
 func (uc *IssueToken) Execute(ctx context.Context, in du.IssueTokenInput) (*du.IssueTokenOutput, error) {
@@ -39,17 +46,42 @@ func (uc *IssueToken) Execute(ctx context.Context, in du.IssueTokenInput) (*du.I
 		ExpiresAt: now.Add(in.AccessTTL).Unix(),
 		Scope:     in.Scope,
 		ClientID:  in.ClientID,
+		Nonce:     in.Nonce,
 	}
 	res, err := uc.tokenService.IssueAccessAndRefresh(ctx, claims, in.RefreshTTL)
 	if err != nil {
 		return nil, err
 	}
-	// Also issue ID token (using access token TTL for now) - future: separate ID token TTL if needed.
-	// Provide access token to context for at_hash computation.
-	idCtx := context.WithValue(ctx, "raw_access_token", res.AccessToken)
-	idToken, err := uc.tokenService.IssueIDToken(idCtx, claims, in.AccessTTL)
-	if err != nil {
-		return nil, err
+	// An ID token is only issued for OpenID Connect requests (scope includes openid), using the
+	// access token TTL for now. The access token goes in the context for at_hash computation.
+	idToken := ""
+	if enum.ParseScopeString(in.Scope).Has(enum.ScopeOpenID) {
+		idCtx := context.WithValue(ctx, "raw_access_token", res.AccessToken)
+		idClaims := claims
+		if !in.AuthTime.IsZero() {
+			idClaims.AuthTime = in.AuthTime.Unix()
+		}
+		if in.SessionID != uuid.Nil {
+			idClaims.SessionID = in.SessionID.String()
+		}
+		if idClaims.Extra, err = uc.userClaims(ctx, in); err != nil {
+			return nil, err
+		}
+		if _, ok := in.Claims.IDToken["acr"]; ok {
+			if idClaims.Extra == nil {
+				idClaims.Extra = map[string]any{}
+			}
+			idClaims.Extra["acr"] = du.PasswordACR
+		}
+		idToken, err = uc.tokenService.IssueIDToken(idCtx, idClaims, in.AccessTTL, dservice.IDTokenOptions{
+			SigningAlg:    c.IDTokenSignedResponseAlg,
+			EncryptionAlg: c.IDTokenEncryptedResponseAlg,
+			EncryptionEnc: c.IDTokenEncryptedResponseEnc,
+			ClientJWKS:    c.JWKS,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 	// Persist token metadata (split scope string by spaces)
 	scopes := []string{}
@@ -61,9 +93,16 @@ func (uc *IssueToken) Execute(ctx context.Context, in du.IssueTokenInput) (*du.I
 		}
 	}
 	meta, err := entity.NewToken(in.UserID, c.ID, scopes, res.AccessToken, res.RefreshTokenID, time.Unix(claims.ExpiresAt, 0), res.RefreshExpiresAt)
-	if err == nil {
-		meta.ClientPublicID = c.ClientID
-		_ = uc.tokens.Store(ctx, meta)
+	if err != nil {
+		return nil, err
+	}
+	meta.ClientPublicID = c.ClientID
+	meta.AccessJTI = res.Claims.ID
+	meta.Claims = in.Claims
+	meta.SessionID = in.SessionID
+	// A refresh token that was never stored would be useless to the client.
+	if err := uc.tokens.Store(ctx, meta); err != nil {
+		return nil, err
 	}
 	return &du.IssueTokenOutput{
 		AccessToken:  res.AccessToken,
@@ -75,6 +114,24 @@ func (uc *IssueToken) Execute(ctx context.Context, in du.IssueTokenInput) (*du.I
 	}, nil
 }
 
+// userClaims returns the user's claims released by the granted scopes plus those the claims
+// request asks for in the ID token (already narrowed at authorization time).
+func (uc *IssueToken) userClaims(ctx context.Context, in du.IssueTokenInput) (map[string]any, error) {
+	if uc.users == nil {
+		return nil, nil
+	}
+	u, err := uc.users.GetByID(ctx, in.UserID)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	available := u.StandardClaims()
+	out := dservice.ReleaseClaims(uc.scopes, splitScopes(in.Scope), available)
+	for k, v := range vo.Select(in.Claims.IDToken, available) {
+		out[k] = v
+	}
+	return out, nil
+}
+
 func splitScopes(s string) []string {
 	out := []string{}
 	cur := ""
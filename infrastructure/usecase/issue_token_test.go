@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestIssueTokenSignsIDTokenWithClientAlg(t *testing.T) {
+	ctx := context.Background()
+	keys := iservice.NewInMemoryKeyRotation(time.Hour, iservice.SupportedSigningAlgs...)
+	tokenService := iservice.NewJWTTokenService(keys, iservice.TokenValidation{Issuer: testIssuer})
+	plain := newTestClient("plain", true, "openid")
+	edwards := newTestClient("edwards", true, "openid")
+	edwards.IDTokenSignedResponseAlg = "EdDSA"
+	uc := NewIssueToken(testfake.NewClients(plain, edwards), newMemTokens(nil), tokenService, nil, nil)
+
+	for client, want := range map[string]string{"plain": iservice.DefaultSigningAlg, "edwards": "EdDSA"} {
+		out, err := uc.Execute(ctx, du.IssueTokenInput{UserID: uuid.New(), ClientID: client, Scope: "openid", Audience: []string{client}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _, err := jwt.NewParser().ParseUnverified(out.IDToken, jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.Header["alg"] != want {
+			t.Fatalf("%s: ID token alg %v, want %s", client, id.Header["alg"], want)
+		}
+		access, _, _ := jwt.NewParser().ParseUnverified(out.AccessToken, jwt.MapClaims{})
+		if access.Header["alg"] != iservice.DefaultSigningAlg {
+			t.Fatalf("%s: access token alg %v, want the server default", client, access.Header["alg"])
+		}
+	}
+}
+
+// newProfileUser returns a user with every standard profile claim set.
+func newProfileUser() *entity.User {
+	u, err := entity.NewUser("alice@example.com", "hash")
+	if err != nil {
+		panic(err)
+	}
+	u.EmailVerified = true
+	u.Profile = entity.UserProfile{
+		Name: "Alice Liddell", GivenName: "Alice", FamilyName: "Liddell", Locale: "en-GB",
+		PhoneNumber: "+441234567890", PhoneNumberVerified: true,
+		Address: entity.Address{Locality: "Oxford", Country: "GB"},
+	}
+	return u
+}
+
+func TestIssueTokenIDTokenClaimsPerScope(t *testing.T) {
+	ctx := context.Background()
+	user := newProfileUser()
+	rp := newTestClient("rp", true, "openid", "profile", "email", "phone", "address")
+	uc := NewIssueToken(testfake.NewClients(rp), newMemTokens(nil), newTestTokenService(nil), testfake.NewUsers(user), iservice.NewScopeRegistry())
+
+	cases := []struct {
+		scope      string
+		want, deny []string
+	}{
+		{scope: "openid", deny: []string{"name", "email", "phone_number", "address"}},
+		{scope: "openid profile", want: []string{"name", "given_name", "family_name", "locale", "updated_at"}, deny: []string{"email", "phone_number", "address"}},
+		{scope: "openid email", want: []string{"email", "email_verified"}, deny: []string{"name", "phone_number"}},
+		{scope: "openid phone address", want: []string{"phone_number", "phone_number_verified", "address"}, deny: []string{"name", "email"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.scope, func(t *testing.T) {
+			out, err := uc.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: "rp", Scope: tc.scope, Audience: []string{"rp"}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := jwt.MapClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(out.IDToken, claims); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range tc.want {
+				if _, ok := claims[c]; !ok {
+					t.Errorf("missing %s in %v", c, claims)
+				}
+			}
+			for _, c := range tc.deny {
+				if _, ok := claims[c]; ok {
+					t.Errorf("%s released without its scope", c)
+				}
+			}
+			if claims["sub"] != user.ID.String() {
+				t.Errorf("sub %v", claims["sub"])
+			}
+		})
+	}
+}
+
+func TestIssueTokenIDTokenClaimsRequest(t *testing.T) {
+	ctx := context.Background()
+	user := newProfileUser()
+	rp := newTestClient("rp", true, "openid", "profile", "email")
+	uc := NewIssueToken(testfake.NewClients(rp), newMemTokens(nil), newTestTokenService(nil), testfake.NewUsers(user), iservice.NewScopeRegistry())
+	// As narrowed at /authorize: the user approved email for this request.
+	req, err := vo.ParseClaimsRequest(`{"id_token":{"email":null,"acr":null,"given_name":{"value":"Bob"}},"userinfo":{"name":null}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := uc.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: "rp", Scope: "openid", Claims: req, Audience: []string{"rp"}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(out.IDToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["email"] != user.Email || claims["acr"] != du.PasswordACR {
+		t.Fatalf("requested claims missing: %v", claims)
+	}
+	if _, ok := claims["given_name"]; ok {
+		t.Error("given_name released despite failing its value constraint")
+	}
+	if _, ok := claims["name"]; ok {
+		t.Error("a userinfo claim leaked into the ID token")
+	}
+}
+
+func TestIssueTokenIDTokenSessionID(t *testing.T) {
+	ctx := context.Background()
+	uc := NewIssueToken(testfake.NewClients(newTestClient("rp", true, "openid")), newMemTokens(nil), newTestTokenService(nil), nil, nil)
+	sid := uuid.New()
+	for want, in := range map[string]uuid.UUID{sid.String(): sid, "": uuid.Nil} {
+		out, err := uc.Execute(ctx, du.IssueTokenInput{UserID: uuid.New(), ClientID: "rp", Scope: "openid", SessionID: in, Audience: []string{"rp"}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(out.IDToken, claims); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := claims["sid"].(string); got != want {
+			t.Fatalf("sid %q, want %q", got, want)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package usecase
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/RanguraGIT/sso/domain/entity"
@@ -16,36 +16,42 @@ type RefreshToken struct {
 	tokens       repository.TokenRepository
 	clients      repository.ClientRepository
 	tokenService dservice.TokenService
+	uow          repository.UnitOfWork
 }
 
-func NewRefreshToken(tokens repository.TokenRepository, clients repository.ClientRepository, tokenService dservice.TokenService) *RefreshToken {
-	return &RefreshToken{tokens: tokens, clients: clients, tokenService: tokenService}
+func NewRefreshToken(tokens repository.TokenRepository, clients repository.ClientRepository, tokenService dservice.TokenService, uow repository.UnitOfWork) *RefreshToken {
+	return &RefreshToken{tokens: tokens, clients: clients, tokenService: tokenService, uow: uow}
 }
 
 func (uc *RefreshToken) Execute(ctx context.Context, in du.RefreshTokenInput) (*du.RefreshTokenOutput, error) {
 	if in.RefreshTokenID == "" {
-		return nil, errors.New("missing refresh token id")
+		return nil, fmt.Errorf("%w: missing refresh token id", du.ErrRefreshTokenInvalid)
 	}
 	meta, err := uc.tokens.GetByRefreshID(ctx, in.RefreshTokenID)
 	if err != nil {
 		return nil, err
 	}
 	if meta == nil || meta.Revoked {
-		return nil, errors.New("invalid_refresh_token")
+		return nil, du.ErrRefreshTokenInvalid
 	}
-	if meta.Rotated { // reuse / replay detection
-		_ = uc.tokens.RevokeChain(ctx, in.RefreshTokenID) // best-effort
-		return nil, errors.New("refresh_token_reuse_detected")
+	if meta.ClientPublicID != in.ClientID {
+		return nil, du.ErrRefreshClientMismatch
+	}
+	if meta.Rotated { // reuse / replay detection: the family may be in an attacker's hands
+		if err := uc.tokens.RevokeChain(ctx, in.RefreshTokenID); err != nil {
+			return nil, fmt.Errorf("revoke token family: %w", err)
+		}
+		return nil, du.ErrRefreshTokenReused
 	}
 	if meta.IsRefreshExpired(time.Now().UTC()) {
-		return nil, errors.New("refresh_expired")
+		return nil, du.ErrRefreshTokenExpired
 	}
 	client, err := uc.clients.GetByClientID(ctx, meta.ClientPublicID)
 	if err != nil {
 		return nil, err
 	}
 	if client == nil {
-		return nil, errors.New("client_not_found")
+		return nil, fmt.Errorf("%w: client not found", du.ErrRefreshTokenInvalid)
 	}
 
 	// Build new JWT claims (same user, scopes, audience, issuer) with new expiry
@@ -64,13 +70,29 @@ func (uc *RefreshToken) Execute(ctx context.Context, in du.RefreshTokenInput) (*
 	if err != nil {
 		return nil, err
 	}
-	// Persist new token metadata with parent pointer
 	newMeta, err := entity.NewToken(meta.UserID, meta.ClientID, meta.Scopes, res.AccessToken, res.RefreshTokenID, time.Unix(claims.ExpiresAt, 0), res.RefreshExpiresAt)
-	if err == nil {
-		newMeta.ClientPublicID = meta.ClientPublicID
-		newMeta.ParentRefreshID = meta.RefreshTokenID
-		_ = uc.tokens.Store(ctx, newMeta)
-		_ = uc.tokens.MarkRotated(ctx, meta.RefreshTokenID) // mark old as rotated
+	if err != nil {
+		return nil, err
+	}
+	newMeta.ClientPublicID = meta.ClientPublicID
+	newMeta.AccessJTI = res.Claims.ID
+	newMeta.Claims = meta.Claims
+	newMeta.SessionID = meta.SessionID
+	newMeta.ContinueFamily(meta)
+	// Claim the parent and store the child atomically: a concurrent refresh with the same token
+	// loses the compare-and-set, and a failed insert leaves the parent usable.
+	err = uc.uow.Do(ctx, func(ctx context.Context) error {
+		won, err := uc.tokens.MarkRotated(ctx, meta.RefreshTokenID)
+		if err != nil {
+			return err
+		}
+		if !won {
+			return du.ErrRefreshTokenRotated
+		}
+		return uc.tokens.Store(ctx, newMeta)
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &du.RefreshTokenOutput{
 		AccessToken:  res.AccessToken,
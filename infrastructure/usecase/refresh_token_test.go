@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+// refreshID is the stored identifier of a raw refresh token.
+func refreshID(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	deny := testfake.NewDenylist()
+	tokenService := newTestTokenService(deny)
+	tokens := newMemTokens(deny)
+	clients := testfake.NewClients(newTestClient("rp", true, "openid"), newTestClient("other", true, "openid"))
+	issue := NewIssueToken(clients, tokens, tokenService, nil, nil)
+	userID := uuid.New()
+
+	// grant issues a fresh refresh token to rp and returns its stored identifier.
+	grant := func(t *testing.T) string {
+		t.Helper()
+		out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: userID, ClientID: "rp", Scope: "openid", Audience: []string{"rp"}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return refreshID(out.RefreshToken)
+	}
+	refresh := func(uc *RefreshToken, id, clientID string) (*du.RefreshTokenOutput, error) {
+		return uc.Execute(ctx, du.RefreshTokenInput{RefreshTokenID: id, ClientID: clientID, Issuer: testIssuer, Audience: []string{clientID}, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	}
+	uc := NewRefreshToken(tokens, clients, tokenService, inlineUOW{})
+
+	t.Run("rotation", func(t *testing.T) {
+		parent := grant(t)
+		out, err := refresh(uc, parent, "rp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := tokens.GetByRefreshID(ctx, parent)
+		child, _ := tokens.GetByRefreshID(ctx, refreshID(out.RefreshToken))
+		if !p.Rotated || child == nil || child.FamilyID != p.FamilyID || child.Rotated || out.Scope != "openid" {
+			t.Fatalf("parent %+v child %+v", p, child)
+		}
+	})
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		parent := grant(t)
+		out, err := refresh(uc, parent, "rp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := refresh(uc, parent, "rp"); !errors.Is(err, du.ErrRefreshTokenReused) {
+			t.Fatalf("err = %v, want reuse", err)
+		}
+		child, _ := tokens.GetByRefreshID(ctx, refreshID(out.RefreshToken))
+		if !child.Revoked {
+			t.Fatal("the rotated child survived reuse of its parent")
+		}
+		if _, err := tokenService.ValidateAccessToken(ctx, out.AccessToken); err == nil {
+			t.Fatal("the child's access token is still accepted")
+		}
+		if _, err := refresh(uc, refreshID(out.RefreshToken), "rp"); !errors.Is(err, du.ErrRefreshTokenInvalid) {
+			t.Fatalf("refresh with the revoked child: %v", err)
+		}
+	})
+	t.Run("another client", func(t *testing.T) {
+		id := grant(t)
+		if _, err := refresh(uc, id, "other"); !errors.Is(err, du.ErrRefreshClientMismatch) {
+			t.Fatalf("err = %v", err)
+		}
+		if tok, _ := tokens.GetByRefreshID(ctx, id); tok.Rotated || tok.Revoked {
+			t.Fatalf("token changed by a foreign client: %+v", tok)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := refresh(uc, refreshID("never-issued"), "rp"); !errors.Is(err, du.ErrRefreshTokenInvalid) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		id := grant(t)
+		tokens.mu.Lock()
+		for _, tok := range tokens.tokens {
+			if tok.RefreshTokenID == id {
+				tok.RefreshExpires = time.Now().Add(-time.Second)
+			}
+		}
+		tokens.mu.Unlock()
+		if _, err := refresh(uc, id, "rp"); !errors.Is(err, du.ErrRefreshTokenExpired) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+	t.Run("concurrent rotation loses", func(t *testing.T) {
+		id := grant(t)
+		racing := NewRefreshToken(tokens, clients, tokenService, inlineUOW{before: func(ctx context.Context) {
+			_, _ = tokens.MarkRotated(ctx, id) // another request rotates the token first
+		}})
+		if _, err := refresh(racing, id, "rp"); !errors.Is(err, du.ErrRefreshTokenRotated) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	deny := testfake.NewDenylist()
+	tokenService := newTestTokenService(deny)
+	tokens := newMemTokens(deny)
+	clients := testfake.NewClients(newTestClient("rp", true, "openid"))
+	issue := NewIssueToken(clients, tokens, tokenService, nil, nil)
+	uc := NewRefreshToken(tokens, clients, tokenService, inlineUOW{})
+	userID := uuid.New()
+
+	grant := func() *du.IssueTokenOutput {
+		out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: userID, ClientID: "rp", Scope: "openid", Audience: []string{"rp"}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	rotate := func(raw string) *du.RefreshTokenOutput {
+		out, err := uc.Execute(ctx, du.RefreshTokenInput{RefreshTokenID: refreshID(raw), ClientID: "rp", Issuer: testIssuer, Audience: []string{"rp"}, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	root := grant()
+	unrelated := grant()
+	child := rotate(root.RefreshToken)
+	grandchild := rotate(child.RefreshToken)
+
+	// The attacker replays the root after the legitimate client rotated twice.
+	_, err := uc.Execute(ctx, du.RefreshTokenInput{RefreshTokenID: refreshID(root.RefreshToken), ClientID: "rp", Issuer: testIssuer, Audience: []string{"rp"}, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if !errors.Is(err, du.ErrRefreshTokenReused) {
+		t.Fatalf("err = %v, want reuse", err)
+	}
+	rootMeta, _ := tokens.GetByRefreshID(ctx, refreshID(root.RefreshToken))
+	family, _ := tokens.ListFamily(ctx, rootMeta.FamilyID)
+	if len(family) != 3 {
+		t.Fatalf("family has %d tokens, want 3", len(family))
+	}
+	for _, tok := range family {
+		if !tok.Revoked {
+			t.Fatalf("family member %s survived", tok.RefreshTokenID)
+		}
+	}
+	if _, err := tokenService.ValidateAccessToken(ctx, grandchild.AccessToken); err == nil {
+		t.Fatal("the newest access token of the family is still accepted")
+	}
+	if other, _ := tokens.GetByRefreshID(ctx, refreshID(unrelated.RefreshToken)); other.Revoked {
+		t.Fatal("a grant outside the family was revoked")
+	}
+	if _, err := tokenService.ValidateAccessToken(ctx, unrelated.AccessToken); err != nil {
+		t.Fatalf("access token outside the family: %v", err)
+	}
+}
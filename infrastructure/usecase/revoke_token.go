@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+)
+
+type RevokeToken struct {
+	tokens       repository.TokenRepository
+	revoked      repository.RevokedAccessTokenRepository
+	tokenService dservice.TokenService
+}
+
+func NewRevokeToken(tokens repository.TokenRepository, revoked repository.RevokedAccessTokenRepository, tokenService dservice.TokenService) *RevokeToken {
+	return &RevokeToken{tokens: tokens, revoked: revoked, tokenService: tokenService}
+}
+
+// Execute tries the token as an access token and as a refresh token, in hint order, and stops at
+// the first match. Revoking a refresh token revokes its whole rotation family and denylists the
+// access tokens issued within it.
+func (uc *RevokeToken) Execute(ctx context.Context, in du.RevokeTokenInput) error {
+	attempts := []func(context.Context, du.RevokeTokenInput) (bool, error){uc.revokeAccess, uc.revokeRefresh}
+	if in.TokenTypeHint == "refresh_token" {
+		attempts[0], attempts[1] = attempts[1], attempts[0]
+	}
+	for _, attempt := range attempts {
+		found, err := attempt(ctx, in)
+		if err != nil || found {
+			return err
+		}
+	}
+	return nil
+}
+
+func (uc *RevokeToken) revokeAccess(ctx context.Context, in du.RevokeTokenInput) (bool, error) {
+	claims, err := uc.tokenService.ValidateAccessToken(ctx, in.Token)
+	if errors.Is(err, dservice.ErrTokenRevoked) {
+		return true, nil
+	}
+	if err != nil || (in.Issuer != "" && claims.Issuer != in.Issuer) {
+		return false, nil // not one of our live access tokens
+	}
+	if claims.ClientID != in.ClientID {
+		return true, du.ErrUnauthorizedClient
+	}
+	if claims.ID == "" {
+		return true, nil // issued before jti tracking; expires on its own
+	}
+	return true, uc.revoked.Add(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0))
+}
+
+func (uc *RevokeToken) revokeRefresh(ctx context.Context, in du.RevokeTokenInput) (bool, error) {
+	hash := sha256.Sum256([]byte(in.Token))
+	refreshID := hex.EncodeToString(hash[:])
+	meta, err := uc.tokens.GetByRefreshID(ctx, refreshID)
+	if err != nil || meta == nil {
+		return false, err
+	}
+	if meta.ClientPublicID != in.ClientID {
+		return true, du.ErrUnauthorizedClient
+	}
+	// Revokes the whole family and denylists its access tokens in one transaction.
+	return true, uc.tokens.RevokeChain(ctx, refreshID)
+}
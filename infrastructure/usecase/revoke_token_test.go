@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	deny := testfake.NewDenylist()
+	tokenService := newTestTokenService(deny)
+	tokens := newMemTokens(deny)
+	rp := newTestClient("rp", true, "openid")
+	uc := NewRevokeToken(tokens, deny, tokenService)
+
+	// grant issues an access/refresh pair to rp and records it, continuing parent's family if set.
+	type issued struct {
+		access, refresh string
+		meta            *entity.Token
+	}
+	grant := func(parent *entity.Token) issued {
+		now := time.Now()
+		res, err := tokenService.IssueAccessAndRefresh(ctx, vo.JWTClaims{Subject: "user-1", Issuer: testIssuer, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), ClientID: "rp"}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, _ := entity.NewToken(uuid.New(), rp.ID, []string{"openid"}, res.AccessToken, res.RefreshTokenID, res.AccessExpiresAt, res.RefreshExpiresAt)
+		meta.ClientPublicID = "rp"
+		meta.AccessJTI = res.Claims.ID
+		if parent != nil {
+			meta.ContinueFamily(parent)
+		}
+		_ = tokens.Store(ctx, meta)
+		return issued{res.AccessToken, res.RefreshToken, meta}
+	}
+	revoked := func(g issued) bool {
+		m, _ := tokens.GetByAccessJTI(ctx, g.meta.AccessJTI)
+		return m.Revoked
+	}
+	denylisted := func(g issued) bool {
+		_, err := tokenService.ValidateAccessToken(ctx, g.access)
+		return errors.Is(err, dservice.ErrTokenRevoked)
+	}
+
+	t.Run("access token is denylisted by jti", func(t *testing.T) {
+		g := grant(nil)
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: g.access, ClientID: "rp"}); err != nil {
+			t.Fatal(err)
+		}
+		if !denylisted(g) || revoked(g) {
+			t.Fatal("access token must be denylisted without revoking its refresh token")
+		}
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: g.access, ClientID: "rp"}); err != nil {
+			t.Fatalf("revoking again: %v", err)
+		}
+	})
+	t.Run("access token found despite refresh_token hint", func(t *testing.T) {
+		g := grant(nil)
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: g.access, TokenTypeHint: "refresh_token", ClientID: "rp"}); err != nil {
+			t.Fatal(err)
+		}
+		if !denylisted(g) {
+			t.Fatal("access token not denylisted")
+		}
+	})
+	t.Run("refresh token revokes its family", func(t *testing.T) {
+		root := grant(nil)
+		child := grant(root.meta)
+		other := grant(nil)
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: root.refresh, TokenTypeHint: "refresh_token", ClientID: "rp"}); err != nil {
+			t.Fatal(err)
+		}
+		if !revoked(root) || !revoked(child) || !denylisted(child) {
+			t.Fatal("family not revoked with its access tokens")
+		}
+		if revoked(other) || denylisted(other) {
+			t.Fatal("another grant was revoked")
+		}
+	})
+	t.Run("refresh token found despite access_token hint", func(t *testing.T) {
+		g := grant(nil)
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: g.refresh, TokenTypeHint: "access_token", ClientID: "rp"}); err != nil {
+			t.Fatal(err)
+		}
+		if !revoked(g) {
+			t.Fatal("refresh token not revoked")
+		}
+	})
+	t.Run("token of another client is left alone", func(t *testing.T) {
+		g := grant(nil)
+		for _, tok := range []string{g.access, g.refresh} {
+			if err := uc.Execute(ctx, du.RevokeTokenInput{Token: tok, ClientID: "intruder"}); !errors.Is(err, du.ErrUnauthorizedClient) {
+				t.Fatalf("err = %v, want unauthorized_client", err)
+			}
+		}
+		if revoked(g) || denylisted(g) {
+			t.Fatal("another client's token was revoked")
+		}
+	})
+	t.Run("access token from another issuer is unknown", func(t *testing.T) {
+		// With an issuer derived per request the token service cannot check iss itself.
+		derived := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(time.Hour), iservice.TokenValidation{Denylist: deny})
+		uc := NewRevokeToken(tokens, deny, derived)
+		now := time.Now()
+		res, err := derived.IssueAccessAndRefresh(ctx, vo.JWTClaims{Subject: "user-1", Issuer: testIssuer, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), ClientID: "rp"}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: res.AccessToken, ClientID: "rp", Issuer: "https://other.example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, _ := deny.IsRevoked(ctx, res.Claims.ID); revoked {
+			t.Fatal("token of another issuer was denylisted")
+		}
+		if err := uc.Execute(ctx, du.RevokeTokenInput{Token: res.AccessToken, ClientID: "rp", Issuer: testIssuer}); err != nil {
+			t.Fatal(err)
+		}
+		if revoked, _ := deny.IsRevoked(ctx, res.Claims.ID); !revoked {
+			t.Fatal("token of this issuer was not denylisted")
+		}
+	})
+	t.Run("unknown token is not an error", func(t *testing.T) {
+		for _, hint := range []string{"", "access_token", "refresh_token"} {
+			if err := uc.Execute(ctx, du.RevokeTokenInput{Token: "unknown", TokenTypeHint: hint, ClientID: "rp"}); err != nil {
+				t.Fatalf("hint %q: %v", hint, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"sort"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/enum"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+)
+
+// protocolClaims may be requested through the claims parameter without a scope: the server
+// produces them itself rather than reading them from the user's profile.
+var protocolClaims = map[string]bool{"sub": true, "acr": true, "auth_time": true}
+
+// narrowScopes applies the scope policy shared by /authorize and the client_credentials grant.
+// Every requested scope must be known to the registry, otherwise the request is invalid_scope.
+// Known scopes the client is not registered for are dropped, so the grant may be narrower than the
+// request. An empty request grants the client's registered scopes; a request that narrows to
+// nothing is invalid_scope.
+func narrowScopes(registry dservice.ScopeRegistry, c *entity.Client, requested string) (enum.ScopeSet, error) {
+	allowed := enum.NewScopeSet(c.Scopes...)
+	req := enum.ParseScopeString(requested)
+	if req.IsEmpty() {
+		return allowed, nil
+	}
+	for _, s := range req.Slice() {
+		if _, ok := registry.Lookup(s); !ok {
+			return enum.ScopeSet{}, du.ErrInvalidScope
+		}
+	}
+	granted := req.Intersect(allowed)
+	if granted.IsEmpty() {
+		return enum.ScopeSet{}, du.ErrInvalidScope
+	}
+	return granted, nil
+}
+
+// userScopes returns the scopes of set that only make sense with an end-user: openid,
+// offline_access and every scope that releases user claims.
+func userScopes(registry dservice.ScopeRegistry, set enum.ScopeSet) enum.ScopeSet {
+	out := enum.NewScopeSet()
+	for _, s := range set.Slice() {
+		def, known := registry.Lookup(s)
+		if s == enum.ScopeOpenID || s == enum.ScopeOfflineAccess || (known && len(def.Claims) > 0) {
+			out = out.Merge(enum.NewScopeSet(s))
+		}
+	}
+	return out
+}
+
+// narrowClaims applies the scope policy to a claims request. A requested claim is kept when it is a
+// protocol claim, released by a granted scope, or released by another scope the client is
+// registered for; scopes of the last kind are returned as extra, so the user can approve them.
+// Everything else is dropped.
+func narrowClaims(registry dservice.ScopeRegistry, c *entity.Client, granted enum.ScopeSet, req vo.ClaimsRequest) (vo.ClaimsRequest, []string) {
+	if req.IsZero() {
+		return req, nil
+	}
+	releasedBy := map[string][]string{} // claim -> registered scopes releasing it
+	for _, s := range c.Scopes {
+		def, ok := registry.Lookup(s)
+		if !ok {
+			continue
+		}
+		for _, claim := range def.Claims {
+			releasedBy[claim] = append(releasedBy[claim], s)
+		}
+	}
+	extra := enum.NewScopeSet()
+	keep := map[string]bool{}
+	for _, claim := range req.Names() {
+		if protocolClaims[claim] {
+			keep[claim] = true
+			continue
+		}
+		scopes := releasedBy[claim]
+		if len(scopes) == 0 {
+			continue
+		}
+		keep[claim] = true
+		if !enum.NewScopeSet(scopes...).Intersect(granted).IsEmpty() {
+			continue
+		}
+		sorted := append([]string(nil), scopes...)
+		sort.Strings(sorted)
+		extra = extra.Merge(enum.NewScopeSet(sorted[0]))
+	}
+	return req.Restrict(func(name string) bool { return keep[name] }), extra.Slice()
+}
+
+// acrSatisfied reports whether an essential acr in the ID token member of req accepts the only
+// authentication the server performs.
+func acrSatisfied(req vo.ClaimsRequest) bool {
+	spec := req.IDToken["acr"]
+	if spec == nil || !spec.Essential {
+		return true
+	}
+	return spec.Accepts(du.PasswordACR)
+}
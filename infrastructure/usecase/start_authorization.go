@@ -5,61 +5,149 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
-	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/RanguraGIT/sso/domain/entity"
 	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
 	du "github.com/RanguraGIT/sso/domain/usecase"
 )
 
 type StartAuthorization struct {
-	clients repository.ClientRepository
-	codes   repository.AuthorizationCodeRepository
+	clients  repository.ClientRepository
+	codes    repository.AuthorizationCodeRepository
+	consents repository.ConsentRepository
+	scopes   dservice.ScopeRegistry
+	sessions repository.SessionRepository // records the clients taking part in a session; optional
 }
 
-func NewStartAuthorization(clients repository.ClientRepository, codes repository.AuthorizationCodeRepository) *StartAuthorization {
-	return &StartAuthorization{clients: clients, codes: codes}
+func NewStartAuthorization(clients repository.ClientRepository, codes repository.AuthorizationCodeRepository, consents repository.ConsentRepository, scopes dservice.ScopeRegistry, sessions repository.SessionRepository) *StartAuthorization {
+	return &StartAuthorization{clients: clients, codes: codes, consents: consents, scopes: scopes, sessions: sessions}
 }
 
-func (uc *StartAuthorization) Execute(ctx context.Context, in du.StartAuthInput) (*du.StartAuthResult, error) {
-	if in.ResponseType != "code" {
-		return nil, errors.New("unsupported response_type")
+func (uc *StartAuthorization) Validate(ctx context.Context, in du.StartAuthInput) error {
+	_, err := uc.client(ctx, in)
+	return err
+}
+
+func (uc *StartAuthorization) GrantedScopes(ctx context.Context, in du.StartAuthInput) ([]string, error) {
+	cli, err := uc.client(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := narrowScopes(uc.scopes, cli, in.Scope)
+	if err != nil {
+		return nil, err
+	}
+	return granted.Slice(), nil
+}
+
+func (uc *StartAuthorization) ClaimScopes(ctx context.Context, in du.StartAuthInput) ([]string, error) {
+	cli, err := uc.client(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	granted, err := narrowScopes(uc.scopes, cli, in.Scope)
+	if err != nil {
+		return nil, err
+	}
+	_, extra := narrowClaims(uc.scopes, cli, granted, in.Claims)
+	return extra, nil
+}
+
+// client loads the client and checks redirect_uri against its registrations.
+func (uc *StartAuthorization) client(ctx context.Context, in du.StartAuthInput) (*entity.Client, error) {
+	if in.ClientID == "" {
+		return nil, du.ErrInvalidClient
 	}
 	cli, err := uc.clients.GetByClientID(ctx, in.ClientID)
-	if err != nil || cli == nil {
-		return nil, errors.New("invalid client_id")
+	if err != nil {
+		return nil, err
+	}
+	if cli == nil {
+		return nil, du.ErrInvalidClient
 	}
-	// Basic redirect URI check
-	allowed := false
+	// Exact match against the registered URIs (no prefix or wildcard matching).
 	for _, u := range cli.RedirectURIs {
 		if u == in.RedirectURI {
-			allowed = true
-			break
+			return cli, nil
 		}
 	}
-	if !allowed {
-		return nil, errors.New("invalid redirect_uri")
+	return nil, du.ErrInvalidRedirectURI
+}
+
+func (uc *StartAuthorization) Execute(ctx context.Context, in du.StartAuthInput) (*du.StartAuthResult, error) {
+	cli, err := uc.client(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	if in.ResponseType != "code" {
+		return nil, du.ErrUnsupportedResponseType
+	}
+	granted, err := narrowScopes(uc.scopes, cli, in.Scope)
+	if err != nil {
+		return nil, err
+	}
+	scopeSlice := granted.Slice()
+	if !acrSatisfied(in.Claims) {
+		return nil, du.ErrUnmetAuthenticationRequirements
+	}
+	claims, extra := narrowClaims(uc.scopes, cli, granted, in.Claims)
+	if err := uc.requireConsent(ctx, cli, in.UserID, append(append([]string(nil), scopeSlice...), extra...)); err != nil {
+		return nil, err
 	}
-	// TODO: Validate scopes subset
 	code, err := generateCode()
 	if err != nil {
 		return nil, err
 	}
-	scopeSlice := []string{}
-	if strings.TrimSpace(in.Scope) != "" {
-		scopeSlice = strings.Fields(in.Scope)
+	ttl := in.CodeTTL
+	if ttl <= 0 {
+		ttl = defaultAuthCodeTTL
 	}
-	c, err := entity.NewAuthorizationCode(code, in.ClientID, in.UserID, in.RedirectURI, scopeSlice, in.CodeChallenge, in.CodeChallengeMethod, 5*time.Minute)
+	c, err := entity.NewAuthorizationCode(code, in.ClientID, in.UserID, in.RedirectURI, scopeSlice, in.CodeChallenge, in.CodeChallengeMethod, ttl)
 	if err != nil {
 		return nil, err
 	}
+	c.Nonce = in.Nonce
+	c.AuthTime = in.AuthTime
+	c.SessionID = in.SessionID
+	c.Claims = claims
 	if err := uc.codes.Create(ctx, c); err != nil {
 		return nil, err
 	}
-	return &du.StartAuthResult{Code: code, State: in.State}, nil
+	// Back-channel logout notifies every client that took part in the session.
+	if sid, err := uuid.Parse(in.SessionID); err == nil && uc.sessions != nil {
+		if err := uc.sessions.AddClient(ctx, sid, cli.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &du.StartAuthResult{Code: code, State: in.State, Scope: granted.String()}, nil
+}
+
+// requireConsent fails with ErrConsentRequired unless the client is first party or the user has
+// approved every scope.
+func (uc *StartAuthorization) requireConsent(ctx context.Context, cli *entity.Client, userID string, scopes []string) error {
+	if cli.FirstParty {
+		return nil
+	}
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+	missing, err := missingConsent(ctx, uc.consents, uid, cli.ClientID, scopes)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return du.ErrConsentRequired
+	}
+	return nil
 }
 
+const defaultAuthCodeTTL = 5 * time.Minute
+
 func generateCode() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
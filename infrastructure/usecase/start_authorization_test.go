@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestStartAuthorizationScopes(t *testing.T) {
+	ctx := context.Background()
+	rp := newTestClient("rp", true, "openid", "profile", "offline_access")
+	rp.FirstParty = true
+	codes := testfake.NewCodes()
+	uc := NewStartAuthorization(testfake.NewClients(rp), codes, newMemConsents(), iservice.NewScopeRegistry(), nil)
+	in := func(scope string) du.StartAuthInput {
+		return du.StartAuthInput{ResponseType: "code", ClientID: "rp", RedirectURI: "https://rp.example.com/cb", Scope: scope, UserID: uuid.NewString()}
+	}
+
+	cases := []struct {
+		name, scope string
+		want        string
+		err         error
+	}{
+		{name: "request within the registration", scope: "openid profile", want: "openid profile"},
+		{name: "narrowed to the registration", scope: "openid profile email", want: "openid profile"},
+		{name: "no scope grants the registration", scope: "", want: "offline_access openid profile"},
+		{name: "unknown scope", scope: "openid admin", err: du.ErrInvalidScope},
+		{name: "nothing registered is requested", scope: "email phone", err: du.ErrInvalidScope},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			granted, err := uc.GrantedScopes(ctx, in(tc.scope))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("GrantedScopes err = %v, want %v", err, tc.err)
+			}
+			out, err := uc.Execute(ctx, in(tc.scope))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Execute err = %v, want %v", err, tc.err)
+			}
+			if tc.err != nil {
+				return
+			}
+			if out.Scope != tc.want {
+				t.Fatalf("scope %q, want %q", out.Scope, tc.want)
+			}
+			code, _ := codes.Get(ctx, out.Code)
+			if code == nil || !slices.Equal(code.Scope, granted) || !slices.Equal(granted, strings.Fields(tc.want)) {
+				t.Fatalf("code %+v, granted %v", code, granted)
+			}
+		})
+	}
+}
+
+func TestIssueTokenRequiresOpenIDForIDToken(t *testing.T) {
+	ctx := context.Background()
+	rp := newTestClient("rp", true, "openid", "profile")
+	uc := NewIssueToken(testfake.NewClients(rp), newMemTokens(nil), newTestTokenService(nil), nil, nil)
+	for scope, want := range map[string]bool{"openid profile": true, "profile": false} {
+		out, err := uc.Execute(ctx, du.IssueTokenInput{UserID: uuid.New(), ClientID: "rp", Scope: scope, Audience: []string{"rp"}, Issuer: testIssuer, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.IDToken != ""; got != want {
+			t.Fatalf("scope %q: ID token issued = %v, want %v", scope, got, want)
+		}
+	}
+}
+
+func TestStartAuthorizationClaimsRequest(t *testing.T) {
+	ctx := context.Background()
+	rp := newTestClient("rp", true, "openid", "email")
+	consents := newMemConsents()
+	codes := testfake.NewCodes()
+	uc := NewStartAuthorization(testfake.NewClients(rp), codes, consents, iservice.NewScopeRegistry(), nil)
+	consent := NewConsents(consents, testfake.NewClients(rp), newMemTokens(nil), inlineUOW{})
+	user := uuid.New()
+	in := func(raw string) du.StartAuthInput {
+		claims, err := vo.ParseClaimsRequest(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return du.StartAuthInput{ResponseType: "code", ClientID: "rp", RedirectURI: "https://rp.example.com/cb", Scope: "openid", UserID: user.String(), Claims: claims}
+	}
+	if err := consent.Grant(ctx, user, "rp", []string{"openid"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := in(`{"userinfo":{"email":null,"name":null},"id_token":{"email_verified":{"essential":true},"auth_time":null}}`)
+	t.Run("claims of registered scopes need consent", func(t *testing.T) {
+		extra, err := uc.ClaimScopes(ctx, req)
+		if err != nil || !slices.Equal(extra, []string{"email"}) {
+			t.Fatalf("extra %v err %v", extra, err)
+		}
+		if _, err := uc.Execute(ctx, req); !errors.Is(err, du.ErrConsentRequired) {
+			t.Fatalf("err = %v, want consent_required", err)
+		}
+	})
+	t.Run("code keeps only releasable claims", func(t *testing.T) {
+		if err := consent.Grant(ctx, user, "rp", []string{"email"}); err != nil {
+			t.Fatal(err)
+		}
+		out, err := uc.Execute(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := codes.Get(ctx, out.Code)
+		if got := code.Claims.Names(); !slices.Equal(got, []string{"auth_time", "email", "email_verified"}) {
+			t.Fatalf("claims %v, want name dropped (profile is not registered)", got)
+		}
+		if out.Scope != "openid" {
+			t.Fatalf("scope %q: a claims request must not widen the granted scope", out.Scope)
+		}
+	})
+	for name, tc := range map[string]struct {
+		raw string
+		err error
+	}{
+		"essential acr the server performs": {raw: `{"id_token":{"acr":{"essential":true,"values":["` + du.PasswordACR + `"]}}}`},
+		"essential acr it cannot satisfy":   {raw: `{"id_token":{"acr":{"essential":true,"value":"urn:example:mfa"}}}`, err: du.ErrUnmetAuthenticationRequirements},
+		"voluntary acr it cannot satisfy":   {raw: `{"id_token":{"acr":{"value":"urn:example:mfa"}}}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := uc.Execute(ctx, in(tc.raw)); !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/repository"
+	dservice "github.com/RanguraGIT/sso/domain/service"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+)
+
+type UserSessions struct {
+	sessions repository.SessionRepository
+	clients  repository.ClientRepository
+	tokens   repository.TokenRepository
+	logout   dservice.BackchannelLogout // optional
+}
+
+func NewUserSessions(sessions repository.SessionRepository, clients repository.ClientRepository, tokens repository.TokenRepository, logout dservice.BackchannelLogout) *UserSessions {
+	return &UserSessions{sessions: sessions, clients: clients, tokens: tokens, logout: logout}
+}
+
+func (uc *UserSessions) List(ctx context.Context, userID uuid.UUID) ([]du.SessionInfo, error) {
+	list, err := uc.sessions.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	names := map[uuid.UUID]*du.SessionClient{} // clients shared by several sessions are looked up once
+	out := make([]du.SessionInfo, 0, len(list))
+	for _, s := range list {
+		info := du.SessionInfo{ID: s.ID, IP: s.IP, UserAgent: s.UserAgent, CreatedAt: s.CreatedAt, LastSeenAt: s.LastSeenAt, ExpiresAt: s.ExpiresAt, Clients: []du.SessionClient{}}
+		for _, id := range s.ClientIDs {
+			sc, seen := names[id]
+			if !seen {
+				cli, err := uc.clients.GetByID(ctx, id)
+				if err != nil {
+					return nil, err
+				}
+				if cli != nil {
+					sc = &du.SessionClient{ClientID: cli.ClientID, ClientName: cli.Name}
+				}
+				names[id] = sc
+			}
+			if sc != nil {
+				info.Clients = append(info.Clients, *sc)
+			}
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+func (uc *UserSessions) Terminate(ctx context.Context, userID, sessionID uuid.UUID, issuer string) error {
+	sess, err := uc.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if sess == nil || sess.UserID != userID || sess.Revoked || sess.IsExpired(time.Now().UTC()) {
+		return du.ErrSessionNotFound
+	}
+	if err := uc.sessions.Revoke(ctx, sess.ID); err != nil {
+		return err
+	}
+	return uc.ended(ctx, sess, issuer)
+}
+
+func (uc *UserSessions) TerminateAll(ctx context.Context, userID, keep uuid.UUID, issuer string) (int, error) {
+	ended, err := uc.sessions.RevokeAllExcept(ctx, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+	for _, sess := range ended {
+		if err := uc.ended(ctx, sess, issuer); err != nil {
+			return 0, err
+		}
+	}
+	return len(ended), nil
+}
+
+// ended cascades a revoked session to the refresh and access tokens bound to it and tells its
+// clients over the back channel.
+func (uc *UserSessions) ended(ctx context.Context, sess *entity.Session, issuer string) error {
+	if err := uc.tokens.RevokeBySession(ctx, sess.ID); err != nil {
+		return err
+	}
+	if uc.logout != nil {
+		if err := uc.logout.SessionEnded(ctx, sess, issuer); err != nil {
+			return fmt.Errorf("queue back-channel logout: %w", err)
+		}
+	}
+	return nil
+}
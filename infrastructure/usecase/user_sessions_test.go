@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/internal/testfake"
+)
+
+func TestUserSessions(t *testing.T) {
+	ctx := context.Background()
+	rp := newTestClient("rp", true, "openid")
+	rp.Name = "Relying Party"
+	alice, bob := uuid.New(), uuid.New()
+	newSession := func(user uuid.UUID, clients ...uuid.UUID) *entity.Session {
+		s, err := entity.NewSession(user, time.Hour, "203.0.113.7", "test-agent")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.ClientIDs = clients
+		return s
+	}
+	// setup gives alice three sessions and bob one, each with a token granted within it.
+	setup := func(t *testing.T) (*UserSessions, []*entity.Session, *memTokens, *fakeBackchannel) {
+		t.Helper()
+		sessions := []*entity.Session{newSession(alice, rp.ID, uuid.New()), newSession(alice), newSession(alice), newSession(bob, rp.ID)}
+		tokens := newMemTokens(testfake.NewDenylist())
+		for _, s := range sessions {
+			tok, _ := entity.NewToken(s.UserID, rp.ID, []string{"openid"}, "access", uuid.NewString(), time.Now().Add(time.Minute), time.Now().Add(time.Hour))
+			tok.SessionID = s.ID
+			_ = tokens.Store(ctx, tok)
+		}
+		logout := &fakeBackchannel{}
+		return NewUserSessions(testfake.NewSessions(sessions...), testfake.NewClients(rp), tokens, logout), sessions, tokens, logout
+	}
+	// revoked reports, per session, whether its token was revoked.
+	revoked := func(tokens *memTokens) []bool {
+		var out []bool
+		for _, tok := range tokens.tokens {
+			out = append(out, tok.Revoked)
+		}
+		return out
+	}
+
+	t.Run("list", func(t *testing.T) {
+		uc, sessions, _, _ := setup(t)
+		sessions[2].Revoked = true
+		list, err := uc.List(ctx, alice)
+		if err != nil || len(list) != 2 {
+			t.Fatalf("list %+v err %v", list, err)
+		}
+		byID := map[uuid.UUID]du.SessionInfo{}
+		for _, info := range list {
+			byID[info.ID] = info
+		}
+		first := byID[sessions[0].ID]
+		if first.IP != "203.0.113.7" || first.UserAgent != "test-agent" || first.CreatedAt.IsZero() || first.ExpiresAt.IsZero() {
+			t.Fatalf("info %+v", first)
+		}
+		if !slices.Equal(first.Clients, []du.SessionClient{{ClientID: "rp", ClientName: "Relying Party"}}) {
+			t.Fatalf("clients %+v: unknown clients are skipped", first.Clients)
+		}
+		if second := byID[sessions[1].ID]; second.Clients == nil {
+			t.Fatal("a session without clients lists [] rather than null")
+		}
+	})
+	t.Run("terminate cascades to the session's tokens", func(t *testing.T) {
+		uc, sessions, tokens, logout := setup(t)
+		if err := uc.Terminate(ctx, alice, sessions[0].ID, testIssuer); err != nil {
+			t.Fatal(err)
+		}
+		if !sessions[0].Revoked || sessions[1].Revoked {
+			t.Fatal("wrong session revoked")
+		}
+		if got := revoked(tokens); !slices.Equal(got, []bool{true, false, false, false}) {
+			t.Fatalf("revoked tokens %v", got)
+		}
+		if !slices.Equal(logout.ended, []uuid.UUID{sessions[0].ID}) {
+			t.Fatalf("back-channel logout for %v", logout.ended)
+		}
+	})
+	t.Run("terminate only live sessions of the user", func(t *testing.T) {
+		uc, sessions, tokens, logout := setup(t)
+		sessions[1].Revoked = true
+		sessions[2].ExpiresAt = time.Now().Add(-time.Minute)
+		for name, id := range map[string]uuid.UUID{"other user's": sessions[3].ID, "revoked": sessions[1].ID, "expired": sessions[2].ID, "unknown": uuid.New()} {
+			if err := uc.Terminate(ctx, alice, id, testIssuer); !errors.Is(err, du.ErrSessionNotFound) {
+				t.Errorf("%s session: err = %v", name, err)
+			}
+		}
+		if sessions[3].Revoked || slices.Contains(revoked(tokens), true) || len(logout.ended) != 0 {
+			t.Fatal("a refused termination had effects")
+		}
+	})
+	t.Run("terminate all but the current session", func(t *testing.T) {
+		uc, sessions, tokens, logout := setup(t)
+		n, err := uc.TerminateAll(ctx, alice, sessions[1].ID, testIssuer)
+		if err != nil || n != 2 {
+			t.Fatalf("n %d err %v", n, err)
+		}
+		if got := revoked(tokens); !slices.Equal(got, []bool{true, false, true, false}) {
+			t.Fatalf("revoked tokens %v", got)
+		}
+		if len(logout.ended) != 2 || sessions[1].Revoked || sessions[3].Revoked {
+			t.Fatalf("ended %v", logout.ended)
+		}
+	})
+	t.Run("terminate all", func(t *testing.T) {
+		uc, _, tokens, _ := setup(t)
+		if n, err := uc.TerminateAll(ctx, alice, uuid.Nil, testIssuer); err != nil || n != 3 {
+			t.Fatalf("n %d err %v", n, err)
+		}
+		if got := revoked(tokens); !slices.Equal(got, []bool{true, true, true, false}) {
+			t.Fatalf("revoked tokens %v", got)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package testfake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// Codes is an in-memory repository.AuthorizationCodeRepository; Get returns a snapshot. When Gate
+// is set every Get waits on it first, so concurrent redemptions all read the code before any marks
+// it used. MarkErr, when set, fails MarkUsed.
+type Codes struct {
+	mu      sync.Mutex
+	codes   map[string]entity.AuthorizationCode
+	Gate    *sync.WaitGroup
+	MarkErr error
+}
+
+func NewCodes(codes ...*entity.AuthorizationCode) *Codes {
+	m := &Codes{codes: map[string]entity.AuthorizationCode{}}
+	for _, c := range codes {
+		m.codes[c.Code] = *c
+	}
+	return m
+}
+
+func (m *Codes) Create(_ context.Context, c *entity.AuthorizationCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[c.Code] = *c
+	return nil
+}
+
+func (m *Codes) Get(_ context.Context, code string) (*entity.AuthorizationCode, error) {
+	if m.Gate != nil {
+		m.Gate.Done()
+		m.Gate.Wait()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.codes[code]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (m *Codes) MarkUsed(_ context.Context, code string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.MarkErr != nil {
+		return false, m.MarkErr
+	}
+	c, ok := m.codes[code]
+	if !ok || c.Used {
+		return false, nil
+	}
+	c.Used = true
+	m.codes[code] = c
+	return true, nil
+}
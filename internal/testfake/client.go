@@ -0,0 +1,65 @@
+// Package testfake holds in-memory repositories shared by the unit tests of several packages, so
+// those tests run without MySQL. Each behaves like its MySQL counterpart where tests can tell:
+// lookups of unknown keys return nil without an error.
+package testfake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// Clients is an in-memory repository.ClientRepository. It stores and returns copies, as a
+// database would, so a test changing a client must Update it. Writes counts Create and Update
+// calls.
+type Clients struct {
+	mu         sync.Mutex
+	ByClientID map[string]*entity.Client
+	Writes     int
+}
+
+func NewClients(clients ...*entity.Client) *Clients {
+	m := &Clients{ByClientID: map[string]*entity.Client{}}
+	for _, c := range clients {
+		cp := *c
+		m.ByClientID[c.ClientID] = &cp
+	}
+	return m
+}
+
+func (m *Clients) GetByClientID(_ context.Context, clientID string) (*entity.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.ByClientID[clientID]
+	if !ok {
+		return nil, nil
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (m *Clients) GetByID(_ context.Context, id uuid.UUID) (*entity.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.ByClientID {
+		if c.ID == id {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Clients) Create(_ context.Context, c *entity.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *c
+	m.ByClientID[c.ClientID] = &cp
+	m.Writes++
+	return nil
+}
+
+func (m *Clients) Update(ctx context.Context, c *entity.Client) error { return m.Create(ctx, c) }
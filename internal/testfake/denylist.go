@@ -0,0 +1,42 @@
+package testfake
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Denylist is an in-memory repository.RevokedAccessTokenRepository.
+type Denylist struct {
+	mu   sync.Mutex
+	jtis map[string]time.Time
+}
+
+func NewDenylist() *Denylist { return &Denylist{jtis: map[string]time.Time{}} }
+
+func (m *Denylist) Add(_ context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jtis[jti] = expiresAt
+	return nil
+}
+
+func (m *Denylist) IsRevoked(_ context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	exp, ok := m.jtis[jti]
+	return ok && time.Now().Before(exp), nil
+}
+
+func (m *Denylist) PurgeExpired(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for jti, exp := range m.jtis {
+		if exp.Before(now) {
+			delete(m.jtis, jti)
+			n++
+		}
+	}
+	return n, nil
+}
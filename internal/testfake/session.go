@@ -0,0 +1,90 @@
+package testfake
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// Sessions is an in-memory repository.SessionRepository. Unlike the other fakes it keeps the
+// sessions it is given rather than copies, so a test can expire or revoke a session in place and
+// observe what the code under test did to it.
+type Sessions struct {
+	mu   sync.Mutex
+	byID map[uuid.UUID]*entity.Session
+}
+
+func NewSessions(sessions ...*entity.Session) *Sessions {
+	m := &Sessions{byID: map[uuid.UUID]*entity.Session{}}
+	for _, s := range sessions {
+		m.byID[s.ID] = s
+	}
+	return m
+}
+
+func (m *Sessions) Create(_ context.Context, s *entity.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byID[s.ID] = s
+	return nil
+}
+
+func (m *Sessions) Get(_ context.Context, id uuid.UUID) (*entity.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.byID[id], nil
+}
+
+func (m *Sessions) AddClient(_ context.Context, id uuid.UUID, clientID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.byID[id]; s != nil && !slices.Contains(s.ClientIDs, clientID) {
+		s.ClientIDs = append(s.ClientIDs, clientID)
+	}
+	return nil
+}
+
+func (m *Sessions) Revoke(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.byID[id]; s != nil {
+		s.Revoked = true
+	}
+	return nil
+}
+
+func (m *Sessions) ListByUser(_ context.Context, userID uuid.UUID) ([]*entity.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*entity.Session
+	for _, s := range m.byID {
+		if s.UserID == userID && !s.Revoked {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (m *Sessions) RevokeAllForUser(ctx context.Context, userID uuid.UUID) ([]*entity.Session, error) {
+	return m.RevokeAllExcept(ctx, userID, uuid.Nil)
+}
+
+func (m *Sessions) SaveActivity(context.Context, []*entity.Session) error { return nil }
+
+func (m *Sessions) RevokeAllExcept(ctx context.Context, userID uuid.UUID, keep uuid.UUID) ([]*entity.Session, error) {
+	live, _ := m.ListByUser(ctx, userID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ended []*entity.Session
+	for _, s := range live {
+		if s.ID != keep {
+			s.Revoked = true
+			ended = append(ended, s)
+		}
+	}
+	return ended, nil
+}
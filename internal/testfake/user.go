@@ -0,0 +1,61 @@
+package testfake
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+)
+
+// Users is an in-memory repository.UserRepository keyed by email. Like Clients it stores and
+// returns copies and counts writes.
+type Users struct {
+	mu      sync.Mutex
+	ByEmail map[string]*entity.User
+	Writes  int
+}
+
+func NewUsers(users ...*entity.User) *Users {
+	m := &Users{ByEmail: map[string]*entity.User{}}
+	for _, u := range users {
+		cp := *u
+		m.ByEmail[u.Email] = &cp
+	}
+	return m
+}
+
+func (m *Users) GetByID(_ context.Context, id uuid.UUID) (*entity.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.ByEmail {
+		if u.ID == id {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Users) GetByEmail(_ context.Context, email string) (*entity.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.ByEmail[email]
+	if !ok {
+		return nil, nil
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (m *Users) Create(_ context.Context, u *entity.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *u
+	m.ByEmail[u.Email] = &cp
+	m.Writes++
+	return nil
+}
+
+func (m *Users) Update(ctx context.Context, u *entity.User) error { return m.Create(ctx, u) }
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestBackchannelLogout ends a session a client took part in and expects the client's
+// backchannel_logout_uri to receive a logout_token carrying the sid its ID token had.
+func TestBackchannelLogout(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+	sessions := mysqlrepo.NewSessionRepo(db)
+
+	received := make(chan string, 1)
+	rp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		received <- r.PostForm.Get("logout_token")
+	}))
+	defer rp.Close()
+
+	user, _ := entity.NewUser("bcl-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	client, _ := entity.NewClient("bcl-"+uuid.NewString(), "BCL", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	client.BackchannelLogoutURI = rp.URL
+	_ = clients.Create(ctx, client)
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test")
+	_ = sessions.Create(ctx, sess)
+	_ = sessions.AddClient(ctx, sess.ID, client.ID)
+	_ = sessions.AddClient(ctx, sess.ID, client.ID)
+	if stored, _ := sessions.Get(ctx, sess.ID); stored == nil || len(stored.ClientIDs) != 1 {
+		t.Fatalf("expected the client recorded once: %+v", stored)
+	}
+
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	tokenSvc := iservice.NewJWTTokenService(keys, iservice.TokenValidation{})
+	out, err := usecase.NewIssueToken(clients, tokens, tokenSvc, nil, nil).Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid", Audience: []string{client.ClientID}, Issuer: "http://example.com", AccessTTL: time.Minute, RefreshTTL: time.Hour, SessionID: sess.ID})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if hint, err := tokenSvc.ValidateIDTokenHint(ctx, out.IDToken); err != nil || hint.SessionID != sess.ID.String() {
+		t.Fatalf("ID token sid missing: %v %+v", err, hint)
+	}
+
+	notifier := iservice.NewBackchannelLogoutService(clients, mysqlrepo.NewLogoutDeliveryRepo(db), tokenSvc)
+	go notifier.Run(ctx, time.Second)
+	end := usecase.NewEndSession(clients, sessions, tokens, notifier)
+	if _, err := end.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, Issuer: "http://example.com"}); err != nil {
+		t.Fatalf("end session: %v", err)
+	}
+
+	var logoutToken string
+	select {
+	case logoutToken = <-received:
+	case <-ctx.Done():
+		t.Fatal("no back-channel logout delivered")
+	}
+	parts := strings.Split(logoutToken, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]any
+	_ = json.Unmarshal(payload, &claims)
+	events, _ := claims["events"].(map[string]any)
+	if claims["sid"] != sess.ID.String() || claims["sub"] != user.ID.String() || claims["aud"] != client.ClientID || claims["iss"] != "http://example.com" || events == nil {
+		t.Fatalf("unexpected logout_token claims: %v", claims)
+	}
+	if _, has := claims["nonce"]; has {
+		t.Fatal("logout_token must not carry a nonce")
+	}
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	h "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
+	"github.com/RanguraGIT/sso/infrastructure/delivery/http/ui"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestConsentGrantAndWithdraw walks a third-party client through the consent screen, then withdraws
+// the consent and expects the client's refresh tokens to be revoked and the screen to return.
+func TestConsentGrantAndWithdraw(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	clients := mysqlrepo.NewClientRepo(db)
+	users := mysqlrepo.NewUserRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+	sessions := mysqlrepo.NewSessionRepo(db)
+	consentRepo := mysqlrepo.NewConsentRepo(db)
+	uow := mysqlrepo.NewUnitOfWork(db)
+
+	user, _ := entity.NewUser("consent-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	client, _ := entity.NewClient("consent-"+uuid.NewString(), "Third Party", "", []string{"http://localhost/cb"}, []string{"openid", "profile"}, false, true)
+	_ = clients.Create(ctx, client)
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test-agent")
+	_ = sessions.Create(ctx, sess)
+
+	templates, err := ui.Load("")
+	if err != nil {
+		t.Fatalf("templates: %v", err)
+	}
+	consents := usecase.NewConsents(consentRepo, clients, tokens, uow)
+	authHandler := &h.AuthorizeHandler{
+		Start:     usecase.NewStartAuthorization(clients, mysqlrepo.NewAuthCodeRepo(db), consentRepo, iservice.NewScopeRegistry(), sessions),
+		Sessions:  sessions,
+		Consents:  consents,
+		Templates: templates,
+	}
+	query := url.Values{"response_type": {"code"}, "client_id": {client.ClientID}, "redirect_uri": {"http://localhost/cb"}, "scope": {"openid profile"}, "state": {"s1"}}
+	sid := &http.Cookie{Name: "sid", Value: sess.ID.String()}
+
+	req := httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+	req.AddCookie(sid)
+	w := httptest.NewRecorder()
+	authHandler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "profile") {
+		t.Fatalf("expected consent screen, got %d %s", w.Code, w.Body.String())
+	}
+	var csrf *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "sso_csrf" {
+			csrf = c
+		}
+	}
+	if csrf == nil {
+		t.Fatalf("consent screen did not set a csrf cookie")
+	}
+
+	form := url.Values{}
+	for k, v := range query {
+		form[k] = v
+	}
+	form.Set("csrf_token", csrf.Value)
+	form.Set("consent", "allow")
+	req = httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(sid)
+	req.AddCookie(csrf)
+	w = httptest.NewRecorder()
+	authHandler.ServeHTTP(w, req)
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc == nil || loc.Query().Get("code") == "" {
+		t.Fatalf("expected code after consent, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	grants, err := consents.List(ctx, user.ID)
+	if err != nil || len(grants) != 1 || strings.Join(grants[0].Scopes, " ") != "openid profile" {
+		t.Fatalf("unexpected consents %+v err=%v", grants, err)
+	}
+
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	issue := usecase.NewIssueToken(clients, tokens, iservice.NewJWTTokenService(keys, iservice.TokenValidation{}), nil, nil)
+	out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid profile", Audience: []string{client.ClientID}, Issuer: "http://issuer", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	if err := consents.Withdraw(ctx, user.ID, client.ClientID); err != nil {
+		t.Fatalf("withdraw: %v", err)
+	}
+	meta, err := tokens.GetByRefreshID(ctx, sha256Sum(out.RefreshToken))
+	if err != nil || meta == nil || !meta.Revoked {
+		t.Fatalf("refresh token should be revoked after withdrawal: %+v err=%v", meta, err)
+	}
+	if err := consents.Withdraw(ctx, user.ID, client.ClientID); err != du.ErrConsentNotFound {
+		t.Fatalf("second withdraw: want ErrConsentNotFound, got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+	req.AddCookie(sid)
+	w = httptest.NewRecorder()
+	authHandler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected consent screen again after withdrawal, got %d", w.Code)
+	}
+}
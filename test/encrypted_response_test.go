@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	h "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestEncryptedIDTokenAndSignedUserInfo registers a client with an RSA encryption key, expects a
+// nested JWE ID token it can decrypt, and a signed JWT from /userinfo.
+func TestEncryptedIDTokenAndSignedUserInfo(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+
+	encKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	b64 := base64.RawURLEncoding
+	user, _ := entity.NewUser("jwe-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	client, _ := entity.NewClient("jwe-"+uuid.NewString(), "JWE", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	client.JWKS = fmt.Sprintf(`{"keys":[{"kty":"RSA","use":"enc","kid":"enc-1","n":"%s","e":"AQAB"}]}`, b64.EncodeToString(encKey.N.Bytes()))
+	client.IDTokenEncryptedResponseAlg = "RSA-OAEP-256"
+	client.IDTokenEncryptedResponseEnc = "A256GCM"
+	client.UserinfoSignedResponseAlg = "RS256"
+	if err := clients.Create(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	stored, _ := clients.GetByClientID(ctx, client.ClientID)
+	if stored == nil || stored.IDTokenEncryptedResponseEnc != "A256GCM" || stored.UserinfoSignedResponseAlg != "RS256" {
+		t.Fatalf("encryption metadata not persisted: %+v", stored)
+	}
+
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	tokenSvc := iservice.NewJWTTokenService(keys, iservice.TokenValidation{})
+	issue := usecase.NewIssueToken(clients, tokens, tokenSvc, nil, nil)
+	out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid", Audience: []string{client.ClientID}, Issuer: "http://example.com", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	parts := strings.Split(out.IDToken, ".")
+	if len(parts) != 5 {
+		t.Fatalf("expected a compact JWE, got %d parts", len(parts))
+	}
+	rawHeader, _ := b64.DecodeString(parts[0])
+	var header map[string]any
+	_ = json.Unmarshal(rawHeader, &header)
+	if header["alg"] != "RSA-OAEP-256" || header["enc"] != "A256GCM" || header["kid"] != "enc-1" || header["cty"] != "JWT" {
+		t.Fatalf("unexpected JWE header: %v", header)
+	}
+	wrapped, _ := b64.DecodeString(parts[1])
+	cek, err := rsa.DecryptOAEP(sha256.New(), nil, encKey, wrapped, nil)
+	if err != nil {
+		t.Fatalf("unwrap cek: %v", err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	iv, _ := b64.DecodeString(parts[2])
+	ciphertext, _ := b64.DecodeString(parts[3])
+	tag, _ := b64.DecodeString(parts[4])
+	inner, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if hint, err := tokenSvc.ValidateIDTokenHint(ctx, string(inner)); err != nil || hint.Subject != user.ID.String() {
+		t.Fatalf("inner ID token invalid: %v %+v", err, hint)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+out.AccessToken)
+	w := httptest.NewRecorder()
+	(&h.UserInfoHandler{Users: users, TokenService: tokenSvc, Clients: clients}).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/jwt" {
+		t.Fatalf("expected signed userinfo, got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	jwtParts := strings.Split(w.Body.String(), ".")
+	payload, _ := b64.DecodeString(jwtParts[1])
+	var info map[string]any
+	_ = json.Unmarshal(payload, &info)
+	if info["sub"] != user.ID.String() || info["aud"] != client.ClientID || info["iss"] != "http://example.com" {
+		t.Fatalf("unexpected signed userinfo claims: %v", info)
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestFrontchannelLogout ends a session two clients took part in and expects the
+// frontchannel_logout_uri of each, with iss and sid only for the client that requires them.
+func TestFrontchannelLogout(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	sessions := mysqlrepo.NewSessionRepo(db)
+
+	user, _ := entity.NewUser("fcl-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	withSid, _ := entity.NewClient("fcl-"+uuid.NewString(), "FCL", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	withSid.FrontchannelLogoutURI = "http://a.localhost/logout?x=1"
+	withSid.FrontchannelLogoutSessionRequired = true
+	_ = clients.Create(ctx, withSid)
+	plain, _ := entity.NewClient("fcl-"+uuid.NewString(), "FCL", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	plain.FrontchannelLogoutURI = "http://b.localhost/logout"
+	_ = clients.Create(ctx, plain)
+	if stored, _ := clients.GetByID(ctx, withSid.ID); stored == nil || stored.FrontchannelLogoutURI != withSid.FrontchannelLogoutURI || !stored.FrontchannelLogoutSessionRequired {
+		t.Fatalf("front-channel metadata not stored: %+v", stored)
+	}
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test")
+	_ = sessions.Create(ctx, sess)
+	_ = sessions.AddClient(ctx, sess.ID, withSid.ID)
+	_ = sessions.AddClient(ctx, sess.ID, plain.ID)
+
+	end := usecase.NewEndSession(clients, sessions, mysqlrepo.NewTokenRepo(db), nil)
+	out, err := end.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, Issuer: "http://example.com"})
+	if err != nil {
+		t.Fatalf("end session: %v", err)
+	}
+	want := []string{
+		"http://a.localhost/logout?iss=http%3A%2F%2Fexample.com&sid=" + sess.ID.String() + "&x=1",
+		"http://b.localhost/logout",
+	}
+	if len(out.FrontchannelLogoutURIs) != len(want) || out.FrontchannelLogoutURIs[0] != want[0] || out.FrontchannelLogoutURIs[1] != want[1] {
+		t.Fatalf("front-channel URIs = %v, want %v", out.FrontchannelLogoutURIs, want)
+	}
+	if out, err := end.Execute(ctx, du.EndSessionInput{SessionID: sess.ID, Issuer: "http://example.com"}); err != nil || len(out.FrontchannelLogoutURIs) != 0 {
+		t.Fatalf("an ended session notified again: %v %v", out, err)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
 	iservice "github.com/RanguraGIT/sso/infrastructure/service"
 	"github.com/RanguraGIT/sso/infrastructure/usecase"
+	"github.com/google/uuid"
 )
 
 // TestEndToEndAuthorizationCodeFlow covers: register (seed), login (session), authorize -> code, token exchange -> id_token, refresh -> new pair, reuse detection.
@@ -35,16 +36,21 @@ func TestEndToEndAuthorizationCodeFlow(t *testing.T) {
 	user, _ := entity.NewUser("alice@example.com", "pwd-hash")
 	_ = userRepo.Create(context.Background(), user)
 	client, _ := entity.NewClient("cli123", "Test SPA", "", []string{"http://localhost/cb"}, []string{"openid", "profile"}, false, true)
-	_ = clientRepo.Create(context.Background(), client)
+	client.FirstParty = true // no consent screen in this flow
+	if err := clientRepo.Create(context.Background(), client); err != nil {
+		_ = clientRepo.Update(context.Background(), client) // left over from an earlier run
+	}
 
 	keys := iservice.NewInMemoryKeyRotation(1 * time.Hour)
-	tokenSvc := iservice.NewJWTTokenService(keys)
-	issueUC := usecase.NewIssueToken(clientRepo, tokenRepo, tokenSvc)
-	startAuthUC := usecase.NewStartAuthorization(clientRepo, codeRepo)
-	refreshUC := usecase.NewRefreshToken(tokenRepo, clientRepo, tokenSvc)
+	tokenSvc := iservice.NewJWTTokenService(keys, iservice.TokenValidation{})
+	issueUC := usecase.NewIssueToken(clientRepo, tokenRepo, tokenSvc, userRepo, iservice.NewScopeRegistry())
+	startAuthUC := usecase.NewStartAuthorization(clientRepo, codeRepo, mysqlrepo.NewConsentRepo(db), iservice.NewScopeRegistry(), sessionRepo)
+	refreshUC := usecase.NewRefreshToken(tokenRepo, clientRepo, tokenSvc, mysqlrepo.NewUnitOfWork(db))
 
 	authHandler := &h.AuthorizeHandler{Start: startAuthUC, Sessions: sessionRepo}
-	tokenHandler := &h.TokenHandler{Issue: issueUC, Refresh: refreshUC, Codes: codeRepo}
+	bcryptAuth := iservice.NewBcryptAuthService(userRepo, clientRepo, 10)
+	clientAuth := iservice.NewClientAuthenticator(clientRepo, bcryptAuth)
+	tokenHandler := &h.TokenHandler{Issue: issueUC, Refresh: refreshUC, Codes: codeRepo, ClientAuth: clientAuth}
 
 	// Create session for user to simulate login
 	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test-agent")
@@ -55,11 +61,14 @@ func TestEndToEndAuthorizationCodeFlow(t *testing.T) {
 	req.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
 	w := httptest.NewRecorder()
 	authHandler.ServeHTTP(w, req)
-	if w.Code != 200 {
-		t.Fatalf("authorize expected 200 got %d body=%s", w.Code, w.Body.String())
+	if w.Code != http.StatusFound {
+		t.Fatalf("authorize expected 302 got %d body=%s", w.Code, w.Body.String())
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), client.RedirectURIs[0]+"?") {
+		t.Fatalf("bad authorize redirect: %q", w.Header().Get("Location"))
 	}
-	var authResp struct{ Code, State string }
-	_ = json.Unmarshal(w.Body.Bytes(), &authResp)
+	authResp := struct{ Code, State string }{loc.Query().Get("code"), loc.Query().Get("state")}
 	if authResp.Code == "" || authResp.State != "xyz" {
 		t.Fatalf("bad authorize response: %+v", authResp)
 	}
@@ -126,9 +135,154 @@ func TestEndToEndAuthorizationCodeFlow(t *testing.T) {
 	}
 }
 
+// TestAuthorizeResponseModes checks that errors for an untrusted redirect_uri are not redirected and
+// that fragment and form_post deliver the response through the requested channel.
+func TestAuthorizeResponseModes(t *testing.T) {
+	db := openIntegrationDB(t)
+	clientRepo := mysqlrepo.NewClientRepo(db)
+	userRepo := mysqlrepo.NewUserRepo(db)
+	codeRepo := mysqlrepo.NewAuthCodeRepo(db)
+	sessionRepo := mysqlrepo.NewSessionRepo(db)
+	ctx := context.Background()
+
+	user, _ := entity.NewUser("modes-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = userRepo.Create(ctx, user)
+	client, _ := entity.NewClient("modes-"+uuid.NewString(), "Modes", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	client.FirstParty = true
+	_ = clientRepo.Create(ctx, client)
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test-agent")
+	_ = sessionRepo.Create(ctx, sess)
+	authHandler := &h.AuthorizeHandler{Start: usecase.NewStartAuthorization(clientRepo, codeRepo, mysqlrepo.NewConsentRepo(db), iservice.NewScopeRegistry(), sessionRepo), Sessions: sessionRepo}
+
+	authorize := func(query url.Values, withSession bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+		if withSession {
+			req.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		}
+		w := httptest.NewRecorder()
+		authHandler.ServeHTTP(w, req)
+		return w
+	}
+	base := func(mode string) url.Values {
+		return url.Values{"response_type": {"code"}, "client_id": {client.ClientID}, "redirect_uri": {"http://localhost/cb"}, "scope": {"openid"}, "state": {"s1"}, "response_mode": {mode}}
+	}
+
+	bad := base("query")
+	bad.Set("redirect_uri", "http://evil.example/cb")
+	if w := authorize(bad, true); w.Code != http.StatusBadRequest || w.Header().Get("Location") != "" {
+		t.Fatalf("unregistered redirect_uri must not redirect: code=%d location=%q", w.Code, w.Header().Get("Location"))
+	}
+
+	w := authorize(base("fragment"), true)
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc == nil || loc.RawQuery != "" {
+		t.Fatalf("fragment mode expected 302 without query, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if frag, _ := url.ParseQuery(loc.Fragment); frag.Get("code") == "" || frag.Get("state") != "s1" {
+		t.Fatalf("fragment missing code/state: %q", loc.Fragment)
+	}
+
+	w = authorize(base("form_post"), true)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `action="http://localhost/cb"`) || !strings.Contains(body, `name="code"`) {
+		t.Fatalf("form_post expected auto-submitting form, got %d %s", w.Code, body)
+	}
+
+	w = authorize(base("query"), false)
+	loc, _ = url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc == nil || loc.Query().Get("error") != "login_required" || loc.Query().Get("state") != "s1" {
+		t.Fatalf("missing session should redirect login_required, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	unknown := base("query")
+	unknown.Set("scope", "openid no_such_scope")
+	w = authorize(unknown, true)
+	loc, _ = url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc == nil || loc.Query().Get("error") != "invalid_scope" {
+		t.Fatalf("unknown scope should redirect invalid_scope, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// email is a known scope the client is not registered for: it is dropped from the grant.
+	narrowed := base("query")
+	narrowed.Set("scope", "openid email")
+	w = authorize(narrowed, true)
+	loc, _ = url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc == nil || loc.Query().Get("code") == "" {
+		t.Fatalf("narrowed request should succeed, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if ac, err := codeRepo.Get(ctx, loc.Query().Get("code")); err != nil || ac == nil || strings.Join(ac.Scope, " ") != "openid" {
+		t.Fatalf("expected code scope narrowed to openid, got %+v err=%v", ac, err)
+	}
+}
+
 // (Custom reader helpers removed; using standard library io.NopCloser + strings.NewReader.)
 
 // openIntegrationDB prepares a MySQL database for integration testing.
+func TestAuthorizePromptAndMaxAge(t *testing.T) {
+	db := openIntegrationDB(t)
+	clientRepo := mysqlrepo.NewClientRepo(db)
+	userRepo := mysqlrepo.NewUserRepo(db)
+	codeRepo := mysqlrepo.NewAuthCodeRepo(db)
+	sessionRepo := mysqlrepo.NewSessionRepo(db)
+	ctx := context.Background()
+
+	user, _ := entity.NewUser("prompt-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = userRepo.Create(ctx, user)
+	client, _ := entity.NewClient("prompt-"+uuid.NewString(), "Prompt", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	client.FirstParty = true
+	_ = clientRepo.Create(ctx, client)
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test-agent")
+	sess.CreatedAt = time.Now().UTC().Add(-10 * time.Minute)
+	_ = sessionRepo.Create(ctx, sess)
+	authHandler := &h.AuthorizeHandler{Start: usecase.NewStartAuthorization(clientRepo, codeRepo, mysqlrepo.NewConsentRepo(db), iservice.NewScopeRegistry(), sessionRepo), Sessions: sessionRepo, LoginPath: h.LoginPagePath}
+
+	authorize := func(extra url.Values, withSession bool) (*httptest.ResponseRecorder, *url.URL) {
+		q := url.Values{"response_type": {"code"}, "client_id": {client.ClientID}, "redirect_uri": {"http://localhost/cb"}, "scope": {"openid"}, "state": {"s1"}, "nonce": {"n-0S6"}}
+		for k, vs := range extra {
+			q[k] = vs
+		}
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil)
+		if withSession {
+			req.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		}
+		w := httptest.NewRecorder()
+		authHandler.ServeHTTP(w, req)
+		loc, _ := url.Parse(w.Header().Get("Location"))
+		return w, loc
+	}
+
+	if w, loc := authorize(url.Values{"prompt": {"none"}}, false); w.Code != http.StatusFound || loc.Query().Get("error") != "login_required" {
+		t.Fatalf("prompt=none without session should redirect login_required, got %d %v", w.Code, loc)
+	}
+	if w, loc := authorize(url.Values{"prompt": {"none login"}}, true); w.Code != http.StatusFound || loc.Query().Get("error") != "invalid_request" {
+		t.Fatalf("prompt=none combined with login should be invalid_request, got %d %v", w.Code, loc)
+	}
+
+	// prompt=login and an exceeded max_age send the user to the login page without the
+	// re-authentication parameters, so the resumed request does not loop.
+	for _, extra := range []url.Values{{"prompt": {"login"}}, {"max_age": {"60"}}} {
+		w, loc := authorize(extra, true)
+		if w.Code != http.StatusFound || loc.Path != h.LoginPagePath {
+			t.Fatalf("%v should redirect to login, got %d %v", extra, w.Code, loc)
+		}
+		if rt := loc.Query().Get("return_to"); strings.Contains(rt, "prompt=") || strings.Contains(rt, "max_age=") || !strings.Contains(rt, "nonce=") {
+			t.Fatalf("return_to should drop prompt/max_age and keep the request: %q", rt)
+		}
+	}
+
+	w, loc := authorize(url.Values{"prompt": {"none"}, "max_age": {"3600"}}, true)
+	if w.Code != http.StatusFound || loc.Query().Get("code") == "" {
+		t.Fatalf("silent request within max_age should succeed, got %d %v", w.Code, loc)
+	}
+	ac, err := codeRepo.Get(ctx, loc.Query().Get("code"))
+	if err != nil || ac == nil {
+		t.Fatalf("code lookup: %v", err)
+	}
+	if ac.Nonce != "n-0S6" || ac.AuthTime.Sub(sess.CreatedAt).Abs() > time.Second {
+		t.Fatalf("code should carry nonce and session auth time, got nonce=%q auth_time=%v", ac.Nonce, ac.AuthTime)
+	}
+}
+
 func openIntegrationDB(t *testing.T) *sql.DB {
 	t.Helper()
 	if os.Getenv("DB_NAME") == "" {
@@ -31,14 +31,14 @@ func TestLoginFlow(t *testing.T) {
 	userRepo := mysqlrepo.NewUserRepo(db)
 	sessionRepo := mysqlrepo.NewSessionRepo(db)
 	// Seed user via register use case to ensure hash + validation (unique email per run)
-	bcryptAuth := iservice.NewBcryptAuthService(userRepo, 10).(interface{ HashPassword(string) (string, error) })
+	bcryptAuth := iservice.NewBcryptAuthService(userRepo, nil, 10).(interface{ HashPassword(string) (string, error) })
 	registerUC := usecase.NewRegisterUser(userRepo, bcryptAuth)
 	email := "login-user-" + time.Now().UTC().Format("20060102150405.000") + "@example.com"
 	if _, err := registerUC.Execute(ctx, du.RegisterUserInput{Email: email, Password: "secretpass"}); err != nil {
 		t.Fatalf("register user: %v", err)
 	}
 
-	loginUC := usecase.NewUserLogin(userRepo, iservice.NewBcryptAuthService(userRepo, 10))
+	loginUC := usecase.NewUserLogin(userRepo, iservice.NewBcryptAuthService(userRepo, nil, 10))
 	createSessionUC := usecase.NewCreateSession(sessionRepo)
 	handler := &h.LoginHandler{LoginUC: loginUC, SessionUC: createSessionUC}
 
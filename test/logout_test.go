@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	h "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestRPInitiatedLogout ends a session through /logout with an id_token_hint and expects the
+// session, its tokens and the sid cookie to be gone, and only registered redirect targets used.
+func TestRPInitiatedLogout(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+	sessions := mysqlrepo.NewSessionRepo(db)
+
+	user, _ := entity.NewUser("logout-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	client, _ := entity.NewClient("logout-"+uuid.NewString(), "Logout", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	client.PostLogoutRedirectURIs = []string{"http://localhost/bye"}
+	_ = clients.Create(ctx, client)
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test")
+	_ = sessions.Create(ctx, sess)
+
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	tokenSvc := iservice.NewJWTTokenService(keys, iservice.TokenValidation{})
+	issue := usecase.NewIssueToken(clients, tokens, tokenSvc, nil, nil)
+	out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid", Audience: []string{client.ClientID}, Issuer: "http://example.com", AccessTTL: time.Minute, RefreshTTL: time.Hour, SessionID: sess.ID})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	access, err := tokenSvc.ValidateAccessToken(ctx, out.AccessToken)
+	if err != nil {
+		t.Fatalf("validate access token: %v", err)
+	}
+
+	handler := &h.LogoutHandler{End: usecase.NewEndSession(clients, sessions, tokens, nil), Sessions: sessions, Tokens: tokenSvc, RevokeTokens: true}
+	logout := func(params url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/logout?"+params.Encode(), nil)
+		req.AddCookie(&http.Cookie{Name: "sid", Value: sess.ID.String()})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := logout(url.Values{"id_token_hint": {out.IDToken}, "post_logout_redirect_uri": {"http://evil.example/"}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unregistered post_logout_redirect_uri accepted: %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if s, _ := sessions.Get(ctx, sess.ID); s == nil || s.Revoked {
+		t.Fatal("session ended by a rejected logout request")
+	}
+
+	w = logout(url.Values{"id_token_hint": {out.IDToken}, "post_logout_redirect_uri": {"http://localhost/bye"}, "state": {"xyz"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "http://localhost/bye?state=xyz" {
+		t.Fatalf("expected redirect to post_logout_redirect_uri, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	cleared := false
+	for _, c := range w.Result().Cookies() {
+		cleared = cleared || (c.Name == "sid" && c.MaxAge < 0)
+	}
+	if !cleared {
+		t.Fatal("sid cookie not cleared")
+	}
+	if s, _ := sessions.Get(ctx, sess.ID); s == nil || !s.Revoked {
+		t.Fatal("session not revoked")
+	}
+	if meta, _ := tokens.GetByAccessJTI(ctx, access.ID); meta == nil || !meta.Revoked || meta.SessionID != sess.ID {
+		t.Fatalf("session tokens not revoked: %+v", meta)
+	}
+}
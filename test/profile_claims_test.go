@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	"github.com/RanguraGIT/sso/domain/vo"
+	h "github.com/RanguraGIT/sso/infrastructure/delivery/http/handler"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestProfileClaimsPerScope stores a profile and expects /userinfo and the ID token to release only
+// the claims of the granted scopes, plus those named in a claims request.
+func TestProfileClaimsPerScope(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+
+	user, _ := entity.NewUser("profile-"+uuid.NewString()+"@example.com", "pwd-hash")
+	user.Profile = entity.UserProfile{GivenName: "Alice", FamilyName: "Liddell", Locale: "en-GB", PhoneNumber: "+441234567890", Address: entity.Address{Locality: "Oxford", Country: "GB"}}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	stored, err := users.GetByID(ctx, user.ID)
+	if err != nil || stored == nil || stored.Profile.GivenName != "Alice" || stored.Profile.Address.Locality != "Oxford" {
+		t.Fatalf("profile not persisted: %+v err=%v", stored, err)
+	}
+	client, _ := entity.NewClient("profile-"+uuid.NewString(), "Profile", "", []string{"http://localhost/cb"}, []string{"openid", "profile", "email", "phone", "address"}, false, true)
+	_ = clients.Create(ctx, client)
+
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	tokenSvc := iservice.NewJWTTokenService(keys, iservice.TokenValidation{})
+	scopes := iservice.NewScopeRegistry()
+	issue := usecase.NewIssueToken(clients, tokens, tokenSvc, users, scopes)
+	out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid profile", Audience: []string{client.ClientID}, Issuer: "http://example.com", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	parts := strings.Split(out.IDToken, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var idClaims map[string]any
+	_ = json.Unmarshal(payload, &idClaims)
+	if idClaims["given_name"] != "Alice" || idClaims["locale"] != "en-GB" {
+		t.Fatalf("ID token missing profile claims: %v", idClaims)
+	}
+	if _, has := idClaims["email"]; has {
+		t.Fatalf("ID token released email without the email scope: %v", idClaims)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+out.AccessToken)
+	w := httptest.NewRecorder()
+	(&h.UserInfoHandler{Users: users, TokenService: tokenSvc, Scopes: scopes}).ServeHTTP(w, req)
+	var info map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &info)
+	if w.Code != http.StatusOK || info["sub"] != user.ID.String() || info["family_name"] != "Liddell" {
+		t.Fatalf("userinfo: %d %v", w.Code, info)
+	}
+	for _, c := range []string{"email", "phone_number", "address"} {
+		if _, has := info[c]; has {
+			t.Fatalf("userinfo released %s without its scope: %v", c, info)
+		}
+	}
+
+	// A claims request adds individual claims on top of the scopes.
+	cr, _ := vo.ParseClaimsRequest(`{"userinfo":{"email":null},"id_token":{"acr":null}}`)
+	out, err = issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid profile", Audience: []string{client.ClientID}, Issuer: "http://example.com", AccessTTL: time.Minute, RefreshTTL: time.Hour, Claims: cr})
+	if err != nil {
+		t.Fatalf("issue with claims request: %v", err)
+	}
+	payload, _ = base64.RawURLEncoding.DecodeString(strings.Split(out.IDToken, ".")[1])
+	idClaims = nil
+	_ = json.Unmarshal(payload, &idClaims)
+	if idClaims["acr"] != du.PasswordACR {
+		t.Fatalf("ID token should carry the requested acr: %v", idClaims)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+out.AccessToken)
+	w = httptest.NewRecorder()
+	(&h.UserInfoHandler{Users: users, TokenService: tokenSvc, Scopes: scopes, Tokens: tokens}).ServeHTTP(w, req)
+	info = nil
+	_ = json.Unmarshal(w.Body.Bytes(), &info)
+	if info["email"] != user.Email || info["given_name"] != "Alice" {
+		t.Fatalf("userinfo should add the requested email claim: %d %v", w.Code, info)
+	}
+}
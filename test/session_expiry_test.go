@@ -0,0 +1,67 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	"github.com/RanguraGIT/sso/domain/vo"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+)
+
+// TestSlidingSessionExpiry uses a session through the batched activity writer and expects the
+// stored expiry to slide within the policy, never backwards, and never for a revoked session.
+func TestSlidingSessionExpiry(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	sessions := mysqlrepo.NewSessionRepo(db)
+
+	client, _ := entity.NewClient("idle-"+uuid.NewString(), "Idle", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	client.SessionIdleTimeout = 15 * time.Minute
+	client.SessionMaxLifetime = time.Hour
+	_ = clients.Create(ctx, client)
+	if stored, _ := clients.GetByClientID(ctx, client.ClientID); stored == nil || stored.SessionPolicy() != client.SessionPolicy() {
+		t.Fatalf("client session policy not stored: %+v", stored)
+	}
+
+	user, _ := entity.NewUser("idle-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	policy := vo.SessionPolicy{IdleTimeout: time.Hour, MaxLifetime: 3 * time.Hour}
+	sess, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test")
+	_ = sessions.Create(ctx, sess)
+	revoked, _ := entity.NewSession(user.ID, time.Hour, "127.0.0.1", "test")
+	_ = sessions.Create(ctx, revoked)
+	_ = sessions.Revoke(ctx, revoked.ID)
+
+	activity := iservice.NewSessionActivityService(sessions, policy)
+	used := sess.CreatedAt.Add(90 * time.Minute)
+	for _, id := range []uuid.UUID{sess.ID, revoked.ID} {
+		s, _ := sessions.Get(ctx, id)
+		activity.Seen(s, used)
+	}
+	activity.Flush(ctx)
+
+	got, _ := sessions.Get(ctx, sess.ID)
+	if !got.LastSeenAt.Equal(used) || !got.ExpiresAt.Equal(used.Add(time.Hour)) {
+		t.Fatalf("expiry did not slide: last_seen=%v expires=%v", got.LastSeenAt, got.ExpiresAt)
+	}
+	if client.SessionPolicy().Allows(got.CreatedAt, got.LastSeenAt, used.Add(time.Minute)) {
+		t.Fatal("a session older than the client's max lifetime must need a new login")
+	}
+	if r, _ := sessions.Get(ctx, revoked.ID); !r.LastSeenAt.Equal(revoked.CreatedAt) {
+		t.Fatalf("revoked session touched: %v", r.LastSeenAt)
+	}
+
+	stale := *got
+	stale.LastSeenAt, stale.ExpiresAt = sess.CreatedAt.Add(time.Minute), sess.CreatedAt.Add(61*time.Minute)
+	_ = sessions.SaveActivity(ctx, []*entity.Session{&stale})
+	if again, _ := sessions.Get(ctx, sess.ID); !again.ExpiresAt.Equal(got.ExpiresAt) {
+		t.Fatalf("older activity moved expiry back to %v", again.ExpiresAt)
+	}
+}
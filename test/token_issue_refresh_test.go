@@ -31,9 +31,9 @@ func TestIssueAndRefreshRotation(t *testing.T) {
 	_ = users.Create(context.Background(), user)
 
 	keys := iservice.NewInMemoryKeyRotation(15 * time.Minute)
-	jwtSvc := iservice.NewJWTTokenService(keys)
-	issue := usecase.NewIssueToken(clients, tokens, jwtSvc)
-	refreshUC := usecase.NewRefreshToken(tokens, clients, jwtSvc)
+	jwtSvc := iservice.NewJWTTokenService(keys, iservice.TokenValidation{})
+	issue := usecase.NewIssueToken(clients, tokens, jwtSvc, nil, nil)
+	refreshUC := usecase.NewRefreshToken(tokens, clients, jwtSvc, mysqlrepo.NewUnitOfWork(db))
 
 	out, err := issue.Execute(context.Background(), du.IssueTokenInput{
 		UserID: user.ID, ClientID: client.ClientID, Scope: "openid profile", Audience: []string{client.ClientID}, Issuer: "http://issuer", AccessTTL: time.Minute, RefreshTTL: time.Hour,
@@ -58,7 +58,7 @@ func TestIssueAndRefreshRotation(t *testing.T) {
 
 	// Perform refresh
 	time.Sleep(1100 * time.Millisecond) // ensure new iat second so JWT differs
-	refOut, err := refreshUC.Execute(context.Background(), du.RefreshTokenInput{RefreshTokenID: rot, Issuer: "http://issuer", Audience: []string{client.ClientID}, AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	refOut, err := refreshUC.Execute(context.Background(), du.RefreshTokenInput{RefreshTokenID: rot, ClientID: client.ClientID, Issuer: "http://issuer", Audience: []string{client.ClientID}, AccessTTL: time.Minute, RefreshTTL: time.Hour})
 	if err != nil {
 		t.Fatalf("refresh execute: %v", err)
 	}
@@ -77,6 +77,93 @@ func TestIssueAndRefreshRotation(t *testing.T) {
 	}
 }
 
+// TestRefreshReuseRevokesFamily replays a rotated refresh token and expects the whole family,
+// including the descendant issued by the legitimate rotation, to be revoked.
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+	client, _ := entity.NewClient("family-client", "Family Client", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	_ = clients.Create(ctx, client)
+	user, _ := entity.NewUser("family@example.com", "hashpw")
+
+	jwtSvc := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(15*time.Minute), iservice.TokenValidation{})
+	issue := usecase.NewIssueToken(clients, tokens, jwtSvc, nil, nil)
+	refreshUC := usecase.NewRefreshToken(tokens, clients, jwtSvc, mysqlrepo.NewUnitOfWork(db))
+	in := du.RefreshTokenInput{ClientID: client.ClientID, Issuer: "http://issuer", Audience: []string{client.ClientID}, AccessTTL: time.Minute, RefreshTTL: time.Hour}
+
+	out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid", Audience: []string{client.ClientID}, Issuer: "http://issuer", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("issue execute: %v", err)
+	}
+	rootID := sha256Sum(out.RefreshToken)
+	in.RefreshTokenID = rootID
+	child, err := refreshUC.Execute(ctx, in)
+	if err != nil {
+		t.Fatalf("refresh execute: %v", err)
+	}
+	if _, err := refreshUC.Execute(ctx, in); err == nil {
+		t.Fatalf("expected reuse of rotated token to fail")
+	}
+
+	childMeta, err := tokens.GetByRefreshID(ctx, sha256Sum(child.RefreshToken))
+	if err != nil || childMeta == nil {
+		t.Fatalf("expected child token meta err=%v", err)
+	}
+	if !childMeta.Revoked {
+		t.Fatalf("expected descendant to be revoked after reuse")
+	}
+	rootMeta, _ := tokens.GetByRefreshID(ctx, rootID)
+	if childMeta.FamilyID != rootMeta.ID {
+		t.Fatalf("expected child family %s to be root id %s", childMeta.FamilyID, rootMeta.ID)
+	}
+	lineage, err := tokens.ListFamily(ctx, rootMeta.FamilyID)
+	if err != nil {
+		t.Fatalf("list family: %v", err)
+	}
+	if len(lineage) != 2 || lineage[0].ID != rootMeta.ID || lineage[1].ID != childMeta.ID {
+		t.Fatalf("unexpected lineage: %+v", lineage)
+	}
+}
+
+// TestConcurrentRefreshSingleWinner races two refreshes of the same token; exactly one may rotate it.
+func TestConcurrentRefreshSingleWinner(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+	client, _ := entity.NewClient("race-client", "Race Client", "", []string{"http://localhost/cb"}, []string{"openid"}, false, true)
+	_ = clients.Create(ctx, client)
+	user, _ := entity.NewUser("race@example.com", "hashpw")
+
+	jwtSvc := iservice.NewJWTTokenService(iservice.NewInMemoryKeyRotation(15*time.Minute), iservice.TokenValidation{})
+	issue := usecase.NewIssueToken(clients, tokens, jwtSvc, nil, nil)
+	refreshUC := usecase.NewRefreshToken(tokens, clients, jwtSvc, mysqlrepo.NewUnitOfWork(db))
+	out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid", Audience: []string{client.ClientID}, Issuer: "http://issuer", AccessTTL: time.Minute, RefreshTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("issue execute: %v", err)
+	}
+	in := du.RefreshTokenInput{RefreshTokenID: sha256Sum(out.RefreshToken), ClientID: client.ClientID, Issuer: "http://issuer", Audience: []string{client.ClientID}, AccessTTL: time.Minute, RefreshTTL: time.Hour}
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := refreshUC.Execute(ctx, in)
+			results <- err
+		}()
+	}
+	successes := 0
+	for i := 0; i < 2; i++ {
+		if err := <-results; err == nil {
+			successes++
+		}
+	}
+	if successes != 1 {
+		t.Fatalf("expected exactly one successful rotation, got %d", successes)
+	}
+}
+
 func sha256Sum(s string) string {
 	sum := sha256.Sum256([]byte(s))
 	return hex.EncodeToString(sum[:])
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/RanguraGIT/sso/domain/entity"
+	du "github.com/RanguraGIT/sso/domain/usecase"
+	mysqlrepo "github.com/RanguraGIT/sso/infrastructure/repository/mysql"
+	iservice "github.com/RanguraGIT/sso/infrastructure/service"
+	"github.com/RanguraGIT/sso/infrastructure/usecase"
+)
+
+// TestUserSessions lists a user's live sessions with their clients, then ends one and the rest,
+// expecting the refresh tokens bound to each ended session to be revoked with it.
+func TestUserSessions(t *testing.T) {
+	db := openIntegrationDB(t)
+	ctx := context.Background()
+	users := mysqlrepo.NewUserRepo(db)
+	clients := mysqlrepo.NewClientRepo(db)
+	tokens := mysqlrepo.NewTokenRepo(db)
+	sessions := mysqlrepo.NewSessionRepo(db)
+
+	user, _ := entity.NewUser("us-"+uuid.NewString()+"@example.com", "pwd-hash")
+	_ = users.Create(ctx, user)
+	client, _ := entity.NewClient("us-"+uuid.NewString(), "Sessions App", "", []string{"http://localhost/cb"}, []string{"openid", "offline_access"}, false, true)
+	_ = clients.Create(ctx, client)
+	keys := iservice.NewInMemoryKeyRotation(time.Hour)
+	issue := usecase.NewIssueToken(clients, tokens, iservice.NewJWTTokenService(keys, iservice.TokenValidation{}), nil, nil)
+
+	var ids []uuid.UUID
+	refresh := map[uuid.UUID]string{}
+	for i := 0; i < 3; i++ {
+		sess, _ := entity.NewSession(user.ID, time.Hour, "10.0.0.1", "browser")
+		_ = sessions.Create(ctx, sess)
+		_ = sessions.AddClient(ctx, sess.ID, client.ID)
+		out, err := issue.Execute(ctx, du.IssueTokenInput{UserID: user.ID, ClientID: client.ClientID, Scope: "openid offline_access", Audience: []string{client.ClientID}, Issuer: "http://example.com", AccessTTL: time.Minute, RefreshTTL: time.Hour, SessionID: sess.ID})
+		if err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+		ids = append(ids, sess.ID)
+		refresh[sess.ID] = out.RefreshToken
+	}
+	expired, _ := entity.NewSession(user.ID, time.Hour, "10.0.0.2", "old")
+	expired.ExpiresAt = time.Now().UTC().Add(-time.Minute)
+	_ = sessions.Create(ctx, expired)
+
+	uc := usecase.NewUserSessions(sessions, clients, tokens, nil)
+	list, err := uc.List(ctx, user.ID)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("expected 3 live sessions, got %d (%v)", len(list), err)
+	}
+	if c := list[0].Clients; len(c) != 1 || c[0].ClientID != client.ClientID || c[0].ClientName != "Sessions App" || list[0].IP != "10.0.0.1" || list[0].LastSeenAt.IsZero() {
+		t.Fatalf("unexpected session info: %+v", list[0])
+	}
+
+	if err := uc.Terminate(ctx, uuid.New(), ids[0], "http://example.com"); err != du.ErrSessionNotFound {
+		t.Fatalf("another user's session terminated: %v", err)
+	}
+	if err := uc.Terminate(ctx, user.ID, ids[0], "http://example.com"); err != nil {
+		t.Fatalf("terminate: %v", err)
+	}
+	if n, err := uc.TerminateAll(ctx, user.ID, ids[1], "http://example.com"); err != nil || n != 1 {
+		t.Fatalf("terminate all but one: n=%d err=%v", n, err)
+	}
+	left, _ := sessions.ListByUser(ctx, user.ID)
+	if len(left) != 1 || left[0].ID != ids[1] {
+		t.Fatalf("expected only the kept session, got %+v", left)
+	}
+	for _, id := range ids {
+		meta, _ := tokens.GetByRefreshID(ctx, sha256Sum(refresh[id]))
+		if meta == nil || meta.Revoked != (id != ids[1]) {
+			t.Fatalf("session %s: refresh token revoked=%v", id, meta != nil && meta.Revoked)
+		}
+	}
+	if ended, err := sessions.RevokeAllForUser(ctx, user.ID); err != nil || len(ended) != 1 {
+		t.Fatalf("revoke all: %d %v", len(ended), err)
+	}
+}